@@ -0,0 +1,152 @@
+package missinggo
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Splits a host:port string, separating any IPv6 zone from the host, as in
+// "[fe80::1%eth0]:80".
+func SplitHostPortZone(hostport string) (host, zone string, port int, err error) {
+	host, portStr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return
+	}
+	port64, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return
+	}
+	port = int(port64)
+	if i := strings.LastIndexByte(host, '%'); i >= 0 {
+		host, zone = host[:i], host[i+1:]
+	}
+	return
+}
+
+// The inverse of SplitHostPortZone.
+func JoinHostPortZone(host, zone string, port int) string {
+	if zone != "" {
+		host += "%" + zone
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// Returns the IPv6 zone of addr, if any.
+func AddrZone(addr net.Addr) string {
+	switch raw := addr.(type) {
+	case *net.UDPAddr:
+		return raw.Zone
+	case *net.TCPAddr:
+		return raw.Zone
+	case *net.IPAddr:
+		return raw.Zone
+	default:
+		return ""
+	}
+}
+
+// Converts addr to a netip.AddrPort, keeping any zone. IPv4-mapped IPv6
+// addresses are unmapped so that equal endpoints compare equal. ok is false
+// if addr isn't an IP address and port.
+func NetAddrToAddrPort(addr net.Addr) (ap netip.AddrPort, ok bool) {
+	switch raw := addr.(type) {
+	case *net.UDPAddr:
+		ap = raw.AddrPort()
+	case *net.TCPAddr:
+		ap = raw.AddrPort()
+	default:
+		if addr == nil {
+			return
+		}
+		var err error
+		ap, err = netip.ParseAddrPort(addr.String())
+		if err != nil {
+			return
+		}
+	}
+	if !ap.Addr().IsValid() {
+		return
+	}
+	return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port()), true
+}
+
+// Converts ap to a net.Addr for network, which must be "tcp" or "udp",
+// optionally suffixed with "4" or "6".
+func AddrPortToNetAddr(network string, ap netip.AddrPort) net.Addr {
+	switch strings.TrimRight(network, "46") {
+	case "tcp":
+		return net.TCPAddrFromAddrPort(ap)
+	case "udp":
+		return net.UDPAddrFromAddrPort(ap)
+	default:
+		panic(network)
+	}
+}
+
+// Appends the string form of ap to b, as a key without allocating a string.
+func AppendAddrPort(b []byte, ap netip.AddrPort) []byte {
+	return ap.AppendTo(b)
+}
+
+// A broad classification of an IP address by where it can be reached from.
+type AddrClass int
+
+const (
+	AddrClassInvalid AddrClass = iota
+	AddrClassUnspecified
+	AddrClassLoopback
+	AddrClassLinkLocal
+	AddrClassMulticast
+	AddrClassPrivate
+	AddrClassGlobal
+)
+
+func (me AddrClass) String() string {
+	switch me {
+	case AddrClassUnspecified:
+		return "unspecified"
+	case AddrClassLoopback:
+		return "loopback"
+	case AddrClassLinkLocal:
+		return "link-local"
+	case AddrClassMulticast:
+		return "multicast"
+	case AddrClassPrivate:
+		return "private"
+	case AddrClassGlobal:
+		return "global"
+	default:
+		return "invalid"
+	}
+}
+
+func ClassifyAddr(a netip.Addr) AddrClass {
+	a = a.Unmap()
+	switch {
+	case !a.IsValid():
+		return AddrClassInvalid
+	case a.IsUnspecified():
+		return AddrClassUnspecified
+	case a.IsLoopback():
+		return AddrClassLoopback
+	case a.IsLinkLocalUnicast():
+		return AddrClassLinkLocal
+	case a.IsMulticast():
+		return AddrClassMulticast
+	case a.IsPrivate():
+		return AddrClassPrivate
+	default:
+		return AddrClassGlobal
+	}
+}
+
+// Classifies the IP of addr.
+func ClassifyNetAddr(addr net.Addr) AddrClass {
+	ap, ok := NetAddrToAddrPort(addr)
+	if !ok {
+		return AddrClassInvalid
+	}
+	return ClassifyAddr(ap.Addr())
+}
@@ -0,0 +1,67 @@
+package missinggo
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitHostPortZone(t *testing.T) {
+	for _, _case := range []struct {
+		hostport, host, zone string
+		port                 int
+	}{
+		{"[fe80::1%eth0]:80", "fe80::1", "eth0", 80},
+		{"[::1]:1", "::1", "", 1},
+		{"1.2.3.4:65535", "1.2.3.4", "", 65535},
+		{"example.com:443", "example.com", "", 443},
+	} {
+		host, zone, port, err := SplitHostPortZone(_case.hostport)
+		assert.NoError(t, err)
+		assert.Equal(t, _case.host, host)
+		assert.Equal(t, _case.zone, zone)
+		assert.Equal(t, _case.port, port)
+		assert.Equal(t, _case.hostport, JoinHostPortZone(host, zone, port))
+	}
+	_, _, _, err := SplitHostPortZone("1.2.3.4:65536")
+	assert.Error(t, err)
+}
+
+func TestNetAddrToAddrPort(t *testing.T) {
+	ua := &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 6881, Zone: "eth0"}
+	assert.Equal(t, "eth0", AddrZone(ua))
+	ap, ok := NetAddrToAddrPort(ua)
+	assert.True(t, ok)
+	assert.Equal(t, "[fe80::1%eth0]:6881", ap.String())
+	assert.Equal(t, ua.String(), AddrPortToNetAddr("udp", ap).String())
+	// IPv4 from net.ParseIP is 16 bytes, and is unmapped.
+	ap, ok = NetAddrToAddrPort(&net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 1})
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParseAddrPort("1.2.3.4:1"), ap)
+	assert.Equal(t, "x1.2.3.4:1", string(AppendAddrPort([]byte("x"), ap)))
+	_, ok = NetAddrToAddrPort(&net.UnixAddr{Name: "/tmp/sock", Net: "unix"})
+	assert.False(t, ok)
+	_, ok = NetAddrToAddrPort(nil)
+	assert.False(t, ok)
+}
+
+func TestClassifyAddr(t *testing.T) {
+	for s, c := range map[string]AddrClass{
+		"0.0.0.0":          AddrClassUnspecified,
+		"::":               AddrClassUnspecified,
+		"127.0.0.1":        AddrClassLoopback,
+		"::ffff:127.0.0.1": AddrClassLoopback,
+		"fe80::1":          AddrClassLinkLocal,
+		"224.0.0.1":        AddrClassMulticast,
+		"10.1.2.3":         AddrClassPrivate,
+		"fd00::1":          AddrClassPrivate,
+		"8.8.8.8":          AddrClassGlobal,
+	} {
+		assert.Equal(t, c, ClassifyAddr(netip.MustParseAddr(s)), s)
+	}
+	assert.Equal(t, AddrClassInvalid, ClassifyAddr(netip.Addr{}))
+	assert.Equal(t, "private", AddrClassPrivate.String())
+	assert.Equal(t, AddrClassLoopback, ClassifyNetAddr(&net.TCPAddr{IP: net.IPv6loopback}))
+}
@@ -0,0 +1,175 @@
+// Package atomicfile writes files so that readers, and the file after a
+// crash, see either the old content or the new, never a mix.
+package atomicfile
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+type SyncPolicy int
+
+const (
+	// Syncs the file before it's renamed into place, and the directory
+	// after, so the new content survives a crash once Commit returns.
+	SyncAll SyncPolicy = iota
+	// Syncs only the file, so a crash can leave the old content, but never
+	// a mix.
+	SyncFile
+	// Doesn't sync. A crash can leave the file empty or partially written,
+	// depending on the filesystem.
+	SyncNone
+)
+
+type Opts struct {
+	// The permissions of a new file, before the umask. Defaults to 0666.
+	Perm os.FileMode
+	// Keep the permissions of the file being replaced, if there is one.
+	PreserveMode bool
+	Sync         SyncPolicy
+}
+
+var ErrDone = errors.New("file already committed or aborted")
+
+// A file being written, that replaces the file at its path on Commit.
+type File struct {
+	*os.File
+	path string
+	opts Opts
+	done bool
+}
+
+// Starts writing a file to replace path. The content is written to a
+// temporary file alongside, so it can be renamed into place.
+func Create(path string, opts Opts) (*File, error) {
+	perm := opts.Perm
+	if perm == 0 {
+		perm = 0o666
+	}
+	f, err := createTemp(path, perm)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PreserveMode {
+		if fi, err := os.Stat(path); err == nil {
+			// Unlike at creation, the umask doesn't apply.
+			if err := f.Chmod(fi.Mode().Perm()); err != nil {
+				f.Close()
+				os.Remove(f.Name())
+				return nil, err
+			}
+		}
+	}
+	return &File{
+		File: f,
+		path: path,
+		opts: opts,
+	}, nil
+}
+
+// Like os.CreateTemp, but with the permissions of a regular file creation.
+func createTemp(path string, perm os.FileMode) (f *os.File, err error) {
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("%s.%d.tmp", path, rand.Uint32())
+		f, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if !os.IsExist(err) {
+			return
+		}
+	}
+	return
+}
+
+// Returns whether name looks like one of the temporary files written
+// alongside a path, such as one left behind by a crash.
+func IsTemp(name string) bool {
+	rest := strings.TrimSuffix(name, ".tmp")
+	if len(rest) == len(name) {
+		return false
+	}
+	i := strings.LastIndexByte(rest, '.')
+	if i < 0 || i == len(rest)-1 {
+		return false
+	}
+	_, err := strconv.ParseUint(rest[i+1:], 10, 32)
+	return err == nil
+}
+
+// Replaces the file at the path with what's been written.
+func (me *File) Commit() (err error) {
+	if me.done {
+		return ErrDone
+	}
+	me.done = true
+	if me.opts.Sync != SyncNone {
+		err = me.File.Sync()
+	}
+	if cerr := me.File.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(me.File.Name(), me.path)
+	}
+	if err != nil {
+		os.Remove(me.File.Name())
+		return
+	}
+	if me.opts.Sync == SyncAll {
+		err = syncDir(filepath.Dir(me.path))
+	}
+	return
+}
+
+// Discards what's been written, leaving the file at the path untouched.
+func (me *File) Abort() error {
+	if me.done {
+		return ErrDone
+	}
+	me.done = true
+	me.File.Close()
+	return os.Remove(me.File.Name())
+}
+
+// Aborts, unless already committed, so it can be deferred to clean up after
+// errors.
+func (me *File) Close() error {
+	if me.done {
+		return nil
+	}
+	return me.Abort()
+}
+
+// Writes data to path atomically.
+func WriteFile(path string, data []byte, opts Opts) error {
+	f, err := Create(path, opts)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// Makes a rename in dir durable. Windows can't sync directories, but
+// renames there are durable by the time they return.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dirNames(t *testing.T, dir string) (ret []string) {
+	des, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, de := range des {
+		ret = append(ret, de.Name())
+	}
+	return
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state")
+	for _, sync := range []SyncPolicy{SyncAll, SyncFile, SyncNone} {
+		require.NoError(t, WriteFile(path, []byte{byte(sync)}, Opts{Sync: sync}))
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{byte(sync)}, b)
+	}
+	assert.Equal(t, []string{"state"}, dirNames(t, dir))
+}
+
+func TestAbort(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state")
+	require.NoError(t, WriteFile(path, []byte("old"), Opts{}))
+	f, err := Create(path, Opts{})
+	require.NoError(t, err)
+	f.WriteString("new")
+	require.NoError(t, f.Close())
+	assert.Equal(t, ErrDone, f.Commit())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(b))
+	assert.Equal(t, []string{"state"}, dirNames(t, dir))
+}
+
+func TestPerm(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	require.NoError(t, WriteFile(path, nil, Opts{Perm: 0o600}))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	require.NoError(t, os.Chmod(path, 0o640))
+	require.NoError(t, WriteFile(path, nil, Opts{PreserveMode: true}))
+	fi, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), fi.Mode().Perm())
+}
+
+func TestIsTemp(t *testing.T) {
+	for _, _case := range []struct {
+		name string
+		temp bool
+	}{
+		{"a.123.tmp", true},
+		{"dir/a.b.0.tmp", true},
+		{"a.tmp", false},
+		{"a..tmp", false},
+		{"a.x1.tmp", false},
+		{"a.123", false},
+	} {
+		assert.Equal(t, _case.temp, IsTemp(_case.name), _case.name)
+	}
+	f, err := Create(filepath.Join(t.TempDir(), "a"), Opts{})
+	require.NoError(t, err)
+	defer f.Close()
+	assert.True(t, IsTemp(f.Name()))
+}
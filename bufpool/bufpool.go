@@ -0,0 +1,122 @@
+// Package bufpool pools byte slices in power of two size classes.
+package bufpool
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+type Pool struct {
+	minShift int
+	classes  []sync.Pool
+
+	gets, misses, puts, outstanding int64
+}
+
+type Stats struct {
+	Gets int64
+	// Gets that had to allocate, including those too large to pool.
+	Misses int64
+	Puts   int64
+	// Buffers got but not yet put back.
+	Outstanding int64
+}
+
+// The fraction of gets that reused a buffer.
+func (me Stats) HitRate() float64 {
+	if me.Gets == 0 {
+		return 0
+	}
+	return float64(me.Gets-me.Misses) / float64(me.Gets)
+}
+
+// Returns a Pool with size classes from min to max, rounded up to powers of
+// two.
+func New(min, max int) *Pool {
+	minShift := shiftFor(min)
+	maxShift := shiftFor(max)
+	me := &Pool{
+		minShift: minShift,
+		classes:  make([]sync.Pool, maxShift-minShift+1),
+	}
+	for i := range me.classes {
+		size := 1 << (minShift + i)
+		me.classes[i].New = func() interface{} {
+			atomic.AddInt64(&me.misses, 1)
+			b := make([]byte, size)
+			return &b
+		}
+	}
+	return me
+}
+
+// The smallest shift where 1<<shift >= n.
+func shiftFor(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	return bits.Len(uint(n - 1))
+}
+
+// Returns the index of the class for buffers of capacity n, or -1 if there
+// isn't one.
+func (me *Pool) class(n int) int {
+	i := shiftFor(n) - me.minShift
+	if i < 0 {
+		return 0
+	}
+	if i >= len(me.classes) {
+		return -1
+	}
+	return i
+}
+
+// Returns a buffer of length n. Its capacity is that of its size class.
+func (me *Pool) Get(n int) []byte {
+	atomic.AddInt64(&me.gets, 1)
+	atomic.AddInt64(&me.outstanding, 1)
+	i := me.class(n)
+	if i < 0 {
+		atomic.AddInt64(&me.misses, 1)
+		return make([]byte, n)
+	}
+	b := *me.classes[i].Get().(*[]byte)
+	return b[:n]
+}
+
+// Returns b to the pool. Buffers that didn't come from Get are accepted if
+// their capacity is exactly a size class. b must not be used after.
+func (me *Pool) Put(b []byte) {
+	atomic.AddInt64(&me.puts, 1)
+	atomic.AddInt64(&me.outstanding, -1)
+	c := cap(b)
+	i := me.class(c)
+	if i < 0 || c != 1<<(me.minShift+i) {
+		return
+	}
+	b = b[:c]
+	me.classes[i].Put(&b)
+}
+
+func (me *Pool) Stats() Stats {
+	return Stats{
+		Gets:        atomic.LoadInt64(&me.gets),
+		Misses:      atomic.LoadInt64(&me.misses),
+		Puts:        atomic.LoadInt64(&me.puts),
+		Outstanding: atomic.LoadInt64(&me.outstanding),
+	}
+}
+
+// Pools buffers from 512B to 1MiB.
+var Default = New(512, 1<<20)
+
+// Gets from Default.
+func Get(n int) []byte {
+	return Default.Get(n)
+}
+
+// Puts to Default.
+func Put(b []byte) {
+	Default.Put(b)
+}
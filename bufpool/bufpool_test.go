@@ -0,0 +1,36 @@
+package bufpool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	p := New(100, 1000)
+	b := p.Get(1)
+	assert.Len(t, b, 1)
+	assert.Equal(t, 128, cap(b))
+	b = p.Get(129)
+	assert.Equal(t, 256, cap(b))
+	p.Put(b)
+	// Too large to pool.
+	b = p.Get(1025)
+	assert.Equal(t, 1025, cap(b))
+	p.Put(b)
+	// Not a class size.
+	p.Put(make([]byte, 100))
+	s := p.Stats()
+	assert.EqualValues(t, 3, s.Gets)
+	assert.EqualValues(t, 3, s.Misses)
+	assert.EqualValues(t, 3, s.Puts)
+	assert.EqualValues(t, 0, s.Outstanding)
+	assert.EqualValues(t, 0, s.HitRate())
+	assert.Equal(t, 1024, cap(p.Get(1000)))
+}
+
+func TestShiftFor(t *testing.T) {
+	for n, shift := range map[int]int{0: 0, 1: 0, 2: 1, 3: 2, 4: 2, 5: 3, 1 << 20: 20} {
+		assert.Equal(t, shift, shiftFor(n), n)
+	}
+}
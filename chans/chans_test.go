@@ -0,0 +1,81 @@
+package chans
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sendAll[T any](vs ...T) <-chan T {
+	ch := make(chan T)
+	go func() {
+		for _, v := range vs {
+			ch <- v
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+func collect[T any](ch <-chan T) (ret []T) {
+	for v := range ch {
+		ret = append(ret, v)
+	}
+	return
+}
+
+func TestSendRecvCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ch := make(chan int, 1)
+	assert.NoError(t, SendCtx(context.Background(), ch, 1))
+	assert.Equal(t, context.Canceled, SendCtx(ctx, ch, 2))
+	v, ok, err := RecvCtx(context.Background(), ch)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	_, _, err = RecvCtx(ctx, ch)
+	assert.Equal(t, context.Canceled, err)
+	close(ch)
+	_, ok, err = RecvCtx(context.Background(), ch)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMerge(t *testing.T) {
+	vs := collect(Merge(sendAll(1, 2), sendAll(3), sendAll[int]()))
+	sort.Ints(vs)
+	assert.Equal(t, []int{1, 2, 3}, vs)
+	assert.Empty(t, collect(Merge[int]()))
+}
+
+func TestFanOut(t *testing.T) {
+	outs := FanOut(sendAll(1, 2, 3), 2, 3)
+	assert.Equal(t, []int{1, 2, 3}, collect(outs[0]))
+	assert.Equal(t, []int{1, 2, 3}, collect(outs[1]))
+}
+
+func TestDebounce(t *testing.T) {
+	in := make(chan int)
+	out := Debounce(in, 10*time.Millisecond)
+	in <- 1
+	in <- 2
+	assert.Equal(t, 2, <-out)
+	in <- 3
+	close(in)
+	assert.Equal(t, []int{3}, collect(out))
+}
+
+func TestCoalesceLatest(t *testing.T) {
+	in := make(chan int)
+	out := CoalesceLatest(in)
+	for i := 1; i <= 3; i++ {
+		in <- i
+	}
+	close(in)
+	// The consumer wasn't receiving, so only the last value remains.
+	assert.Equal(t, []int{3}, collect(out))
+}
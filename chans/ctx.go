@@ -0,0 +1,23 @@
+package chans
+
+import "context"
+
+// Sends v on ch, unless ctx is done first.
+func SendCtx[T any](ctx context.Context, ch chan<- T, v T) error {
+	select {
+	case ch <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Receives from ch, unless ctx is done first. ok is false if ch is closed.
+func RecvCtx[T any](ctx context.Context, ch <-chan T) (v T, ok bool, err error) {
+	select {
+	case v, ok = <-ch:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
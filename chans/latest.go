@@ -0,0 +1,69 @@
+package chans
+
+import "time"
+
+// Returns a channel that receives the last value from in once no other value
+// has arrived for d. A pending value is sent when in is closed, and then the
+// returned channel is closed.
+func Debounce[T any](in <-chan T, d time.Duration) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		var (
+			latest  T
+			pending bool
+			timer   *time.Timer
+			fire    <-chan time.Time
+		)
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					if pending {
+						out <- latest
+					}
+					return
+				}
+				latest, pending = v, true
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.NewTimer(d)
+				fire = timer.C
+			case <-fire:
+				timer, fire = nil, nil
+				out <- latest
+				pending = false
+			}
+		}
+	}()
+	return out
+}
+
+// Returns a channel that receives the most recent value from in. Values that
+// arrive while the consumer isn't receiving replace any that's pending, so a
+// slow consumer only sees the latest. A pending value is still delivered after
+// in is closed, and then the returned channel is closed.
+func CoalesceLatest[T any](in <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		var (
+			latest T
+			send   chan<- T
+		)
+		for in != nil || send != nil {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				latest, send = v, out
+			case send <- latest:
+				send = nil
+			}
+		}
+	}()
+	return out
+}
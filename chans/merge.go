@@ -0,0 +1,47 @@
+package chans
+
+import "sync"
+
+// Returns a channel receiving the values from all of chs, closed once they're
+// all closed.
+func Merge[T any](chs ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	wg.Add(len(chs))
+	for _, ch := range chs {
+		go func(ch <-chan T) {
+			defer wg.Done()
+			for v := range ch {
+				out <- v
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Returns n channels that each receive every value from in, and are closed
+// after in is. Each has the given buffer, and a consumer that falls that far
+// behind holds up the others.
+func FanOut[T any](in <-chan T, n, buffer int) []<-chan T {
+	outs := make([]chan T, n)
+	ret := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T, buffer)
+		ret[i] = outs[i]
+	}
+	go func() {
+		for v := range in {
+			for _, out := range outs {
+				out <- v
+			}
+		}
+		for _, out := range outs {
+			close(out)
+		}
+	}()
+	return ret
+}
@@ -0,0 +1,79 @@
+// Package clock abstracts the time functions, so time-dependent code can be
+// driven by a Fake clock in tests.
+package clock
+
+import (
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	Since(time.Time) time.Duration
+	Sleep(time.Duration)
+	After(time.Duration) <-chan time.Time
+	NewTimer(time.Duration) Timer
+	NewTicker(time.Duration) Ticker
+	// Calls f after d. Unlike the time package, f may run in the goroutine
+	// that advances a Fake clock.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Mirrors time.Timer. C returns nil for timers created with AfterFunc.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(time.Duration) bool
+}
+
+// Mirrors time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(time.Duration)
+}
+
+// The system clock, using the time package.
+var Real Clock = realClock{}
+
+// Returns c, or Real if it's nil. Handy for optional Clock fields.
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (me realTimer) C() <-chan time.Time {
+	return me.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (me realTicker) C() <-chan time.Time {
+	return me.Ticker.C
+}
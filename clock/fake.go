@@ -0,0 +1,218 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Clock that only moves when told to. Timers fire, in order, as Advance or
+// Set pass their deadlines.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeTimer
+	// Signalled when waiters are added.
+	cond sync.Cond
+}
+
+var _ Clock = (*Fake)(nil)
+
+func NewFake(now time.Time) *Fake {
+	me := &Fake{now: now}
+	me.cond.L = &me.mu
+	return me
+}
+
+func (me *Fake) Now() time.Time {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.now
+}
+
+func (me *Fake) Since(t time.Time) time.Duration {
+	return me.Now().Sub(t)
+}
+
+// Blocks until the clock is advanced by at least d.
+func (me *Fake) Sleep(d time.Duration) {
+	<-me.After(d)
+}
+
+func (me *Fake) After(d time.Duration) <-chan time.Time {
+	return me.NewTimer(d).C()
+}
+
+func (me *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: me, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+func (me *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	t := &fakeTimer{clock: me, f: f}
+	t.Reset(d)
+	return t
+}
+
+func (me *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{clock: me, c: make(chan time.Time, 1), period: d}
+	t.Reset(d)
+	return fakeTicker{t}
+}
+
+// Moves the clock forward by d, firing timers along the way. Functions from
+// AfterFunc are called in this goroutine.
+func (me *Fake) Advance(d time.Duration) {
+	me.Set(me.Now().Add(d))
+}
+
+// Moves the clock to t, firing timers due by then. The clock never moves
+// backwards.
+func (me *Fake) Set(t time.Time) {
+	for {
+		me.mu.Lock()
+		if len(me.waiters) == 0 || me.waiters[0].when.After(t) {
+			if t.After(me.now) {
+				me.now = t
+			}
+			me.mu.Unlock()
+			return
+		}
+		w := me.waiters[0]
+		me.waiters = me.waiters[1:]
+		if w.when.After(me.now) {
+			me.now = w.when
+		}
+		now := me.now
+		if w.period != 0 {
+			w.when = w.when.Add(w.period)
+			me.insert(w)
+		} else {
+			w.pending = false
+		}
+		me.mu.Unlock()
+		w.fire(now)
+	}
+}
+
+// Blocks until there are at least n pending timers and tickers. Use it to
+// wait for the code under test to start waiting before advancing the clock.
+func (me *Fake) BlockUntil(n int) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	for len(me.waiters) < n {
+		me.cond.Wait()
+	}
+}
+
+// Returns the number of pending timers and tickers.
+func (me *Fake) Waiters() int {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return len(me.waiters)
+}
+
+func (me *Fake) insert(t *fakeTimer) {
+	i := sort.Search(len(me.waiters), func(i int) bool {
+		return me.waiters[i].when.After(t.when)
+	})
+	me.waiters = append(me.waiters, nil)
+	copy(me.waiters[i+1:], me.waiters[i:])
+	me.waiters[i] = t
+	t.pending = true
+	me.cond.Broadcast()
+}
+
+func (me *Fake) remove(t *fakeTimer) bool {
+	if !t.pending {
+		return false
+	}
+	for i, w := range me.waiters {
+		if w == t {
+			me.waiters = append(me.waiters[:i], me.waiters[i+1:]...)
+			break
+		}
+	}
+	t.pending = false
+	return true
+}
+
+type fakeTimer struct {
+	clock *Fake
+	c     chan time.Time
+	f     func()
+	// Non-zero for tickers.
+	period time.Duration
+	// Guarded by the clock.
+	when    time.Time
+	pending bool
+}
+
+func (me *fakeTimer) fire(now time.Time) {
+	if me.f != nil {
+		me.f()
+		return
+	}
+	// Like the time package, drop ticks the receiver isn't keeping up with.
+	select {
+	case me.c <- now:
+	default:
+	}
+}
+
+func (me *fakeTimer) C() <-chan time.Time {
+	return me.c
+}
+
+func (me *fakeTimer) Stop() bool {
+	me.clock.mu.Lock()
+	defer me.clock.mu.Unlock()
+	return me.clock.remove(me)
+}
+
+func (me *fakeTimer) Reset(d time.Duration) bool {
+	c := me.clock
+	c.mu.Lock()
+	wasPending := c.remove(me)
+	me.when = c.now.Add(d)
+	if me.period == 0 && d <= 0 {
+		c.mu.Unlock()
+		if me.f != nil {
+			// The caller may hold locks f needs, as it would with the time
+			// package.
+			go me.f()
+		} else {
+			me.fire(me.when)
+		}
+		return wasPending
+	}
+	c.insert(me)
+	c.mu.Unlock()
+	return wasPending
+}
+
+type fakeTicker struct {
+	t *fakeTimer
+}
+
+func (me fakeTicker) C() <-chan time.Time {
+	return me.t.c
+}
+
+func (me fakeTicker) Stop() {
+	me.t.Stop()
+}
+
+func (me fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	me.t.clock.mu.Lock()
+	me.t.period = d
+	me.t.clock.mu.Unlock()
+	me.t.Reset(d)
+}
@@ -0,0 +1,78 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeTimers(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewFake(start)
+	var fired []int
+	c.AfterFunc(3*time.Second, func() { fired = append(fired, 3) })
+	c.AfterFunc(time.Second, func() { fired = append(fired, 1) })
+	stopped := c.AfterFunc(2*time.Second, func() { fired = append(fired, 2) })
+	timer := c.NewTimer(2 * time.Second)
+	assert.Equal(t, 4, c.Waiters())
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	c.Advance(2 * time.Second)
+	assert.Equal(t, []int{1}, fired)
+	select {
+	case now := <-timer.C():
+		assert.Equal(t, start.Add(2*time.Second), now)
+	default:
+		t.Fatal("timer didn't fire")
+	}
+	assert.False(t, timer.Reset(time.Second))
+	c.Advance(time.Second)
+	assert.Equal(t, []int{1, 3}, fired)
+	assert.Len(t, timer.C(), 1)
+	assert.Equal(t, start.Add(3*time.Second), c.Now())
+	assert.Equal(t, 3*time.Second, c.Since(start))
+	assert.Equal(t, 0, c.Waiters())
+}
+
+func TestFakeTicker(t *testing.T) {
+	c := NewFake(time.Unix(0, 0))
+	tk := c.NewTicker(time.Second)
+	var ticks int
+	for i := 0; i < 3; i++ {
+		c.Advance(time.Second)
+		<-tk.C()
+		ticks++
+	}
+	// Ticks are dropped when the receiver falls behind.
+	c.Advance(5 * time.Second)
+	assert.Len(t, tk.C(), 1)
+	<-tk.C()
+	tk.Reset(10 * time.Second)
+	c.Advance(9 * time.Second)
+	assert.Len(t, tk.C(), 0)
+	tk.Stop()
+	c.Advance(time.Hour)
+	assert.Len(t, tk.C(), 0)
+	assert.Equal(t, 3, ticks)
+}
+
+func TestFakeSleep(t *testing.T) {
+	c := NewFake(time.Unix(0, 0))
+	done := make(chan struct{})
+	go func() {
+		c.Sleep(time.Minute)
+		close(done)
+	}()
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	<-done
+}
+
+func TestReal(t *testing.T) {
+	assert.Equal(t, Real, OrReal(nil))
+	timer := Real.NewTimer(time.Millisecond)
+	<-timer.C()
+	assert.False(t, timer.Stop())
+}
@@ -1,7 +1,9 @@
 package conntrack
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"math"
 	"math/rand"
 	"strconv"
@@ -12,9 +14,12 @@ import (
 	_ "github.com/anacrolix/envpprof"
 	"github.com/bradfitz/iter"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/anacrolix/stm"
 	"github.com/anacrolix/stm/stmutil"
+
+	"github.com/anacrolix/missinggo/v2/clock"
 )
 
 func entry(id int) Entry {
@@ -52,6 +57,21 @@ func TestWaitingForSameEntry(t *testing.T) {
 	<-gotE2s
 }
 
+func TestEntryTimeout(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	i := NewInstance()
+	i.Clock = c
+	i.SetMaxEntries(1)
+	i.WaitDefault(context.Background(), entry(1)).Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Nil(t, i.WaitDefault(ctx, entry(2)))
+	c.Advance(30*time.Second - 1)
+	assert.Nil(t, i.WaitDefault(ctx, entry(2)))
+	c.Advance(1)
+	assert.NotNil(t, i.WaitDefault(ctx, entry(2)))
+}
+
 func TestInstanceSetNoMaxEntries(t *testing.T) {
 	i := NewInstance()
 	i.SetMaxEntries(0)
@@ -214,3 +234,68 @@ func BenchmarkPriority10(b *testing.B) {
 func BenchmarkPriority100(b *testing.B) {
 	benchmarkPriority(b, 100)
 }
+
+func TestReasonMaxEntries(t *testing.T) {
+	i := NewInstance()
+	i.SetMaxEntries(10)
+	i.SetReasonMaxEntries("dht", 1)
+	eh1 := i.Wait(context.Background(), entry(1), "dht", 1)
+	assert.NotNil(t, eh1)
+	got2 := make(chan *EntryHandle)
+	go func() {
+		got2 <- i.Wait(context.Background(), entry(2), "dht", 1)
+	}()
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		tx.Assert(tx.Get(i.waiters).(stmutil.Lenner).Len() == 1)
+	}))
+	// The waiter held back by its reason doesn't hold back lower priorities.
+	eh3 := i.Wait(context.Background(), entry(3), "other", 0)
+	assert.NotNil(t, eh3)
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		assert.Nil(t, i.Allow(tx, entry(4), "dht", 1))
+	}))
+	eh1.Forget()
+	eh2 := <-got2
+	assert.EqualValues(t, entry(2), eh2.e)
+	i.SetReasonNoMaxEntries("dht")
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		assert.NotNil(t, i.Allow(tx, entry(4), "dht", 1))
+	}))
+}
+
+func TestStatus(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	i := NewInstance()
+	i.Clock = c
+	i.SetMaxEntries(2)
+	e2 := Entry{"udp", "", "1.2.3.4:2"}
+	i.Wait(context.Background(), e2, "b", 0)
+	c.Advance(time.Second)
+	e1 := Entry{"udp", "", "1.2.3.4:1"}
+	i.Wait(context.Background(), e1, "a", 1).Done()
+	go i.Wait(context.Background(), entry(3), "c", 0)
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		tx.Assert(tx.Get(i.waiters).(stmutil.Lenner).Len() == 1)
+	}))
+	want := Status{
+		NumEntries:      2,
+		NumWaiters:      1,
+		WaitersByReason: map[string]int{"c": 1},
+		Handles: []HandleStatus{
+			{Entry: e1, Reason: "a", Priority: 1, Done: true, ExpiresIn: 30 * time.Second},
+			{Entry: e2, Reason: "b", Age: time.Second},
+		},
+	}
+	assert.Equal(t, want, i.Status())
+
+	var buf bytes.Buffer
+	require.NoError(t, i.WriteJSON(&buf))
+	var got Status
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, want, got)
+
+	buf.Reset()
+	i.PrintStatus(&buf)
+	assert.Contains(t, buf.String(), "num entries: 2\n")
+	assert.Contains(t, buf.String(), `"udp"     ""     "1.2.3.4:1"  "a"     30s       0s ago`)
+}
@@ -16,11 +16,11 @@ type EntryHandle struct {
 func (eh *EntryHandle) Done() {
 	expvars.Add("entry handles done", 1)
 	timeout := eh.timeout()
-	eh.expires = time.Now().Add(timeout)
+	eh.expires = eh.i.Clock.Now().Add(timeout)
 	if timeout <= 0 {
 		eh.remove()
 	} else {
-		time.AfterFunc(eh.timeout(), eh.remove)
+		eh.i.Clock.AfterFunc(timeout, eh.remove)
 	}
 }
 
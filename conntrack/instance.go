@@ -1,21 +1,19 @@
 package conntrack
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net"
-	"sort"
 	"strconv"
+	"sync"
 	"text/tabwriter"
 	"time"
 
 	"github.com/anacrolix/stm"
 	"github.com/anacrolix/stm/stmutil"
 
-	"github.com/anacrolix/missinggo/v2"
-	"github.com/anacrolix/missinggo/v2/iter"
+	"github.com/anacrolix/missinggo/v2/clock"
 )
 
 type reason = string
@@ -24,15 +22,48 @@ type Instance struct {
 	maxEntries   *stm.Var
 	noMaxEntries *stm.Var
 	Timeout      func(Entry) time.Duration
+	// Source of time for entry timeouts. Replace it before use, such as with
+	// a clock.Fake in tests.
+	Clock clock.Clock
 
 	// Occupied slots
 	entries *stm.Var
+	// reason to the number of handles holding entries
+	entriesByReason *stm.Var //Mappish
+	// reason to the most entries its handles may hold, for reasons that are
+	// limited
+	maxEntriesByReason *stm.Var //Mappish
+	// Handles that have been given entries
+	acquisitions *stm.Var
 
 	// priority to entryHandleSet, ordered by priority ascending
 	waitersByPriority *stm.Var //Mappish
 	waitersByReason   *stm.Var //Mappish
 	waitersByEntry    *stm.Var //Mappish
 	waiters           *stm.Var // Settish
+
+	stats stats
+}
+
+// Totals for metrics of waits.
+type stats struct {
+	mu sync.Mutex
+	// Waits that gave up when their context was done.
+	timeouts int64
+	// Waits that acquired an entry, and the time they took.
+	waits    uint64
+	waitTime time.Duration
+}
+
+func (s *stats) waited(acquired bool, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !acquired {
+		s.timeouts++
+		return
+	}
+	s.waits++
+	s.waitTime += d
 }
 
 type (
@@ -48,7 +79,11 @@ func NewInstance() *Instance {
 			// udp is the main offender, and the default is allegedly 30s.
 			return 30 * time.Second
 		},
-		entries: stm.NewVar(stmutil.NewMap()),
+		Clock:              clock.Real,
+		entries:            stm.NewVar(stmutil.NewMap()),
+		entriesByReason:    stm.NewVar(stmutil.NewMap()),
+		maxEntriesByReason: stm.NewVar(stmutil.NewMap()),
+		acquisitions:       stm.NewVar(int64(0)),
 		waitersByPriority: stm.NewVar(stmutil.NewSortedMap(func(l, r interface{}) bool {
 			return l.(priority) > r.(priority)
 		})),
@@ -71,10 +106,75 @@ func (i *Instance) SetMaxEntries(max int) {
 	}))
 }
 
+// Limits the entries held by handles for the reason, in addition to the
+// overall limit, so one user can't starve the others. Each handle counts,
+// including those sharing an entry. Waiters held back by their reason's
+// limit don't hold back waiters of lower priority.
+func (i *Instance) SetReasonMaxEntries(r string, max int) {
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		tx.Set(i.maxEntriesByReason, tx.Get(i.maxEntriesByReason).(stmutil.Mappish).Set(r, max))
+	}))
+}
+
+// Removes the limit set by SetReasonMaxEntries.
+func (i *Instance) SetReasonNoMaxEntries(r string) {
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		tx.Set(i.maxEntriesByReason, tx.Get(i.maxEntriesByReason).(stmutil.Mappish).Delete(r))
+	}))
+}
+
+func (i *Instance) reasonEntries(tx *stm.Tx, r reason) int {
+	n, _ := tx.Get(i.entriesByReason).(stmutil.Mappish).Get(r)
+	held, _ := n.(int)
+	return held
+}
+
+func (i *Instance) reasonHasRoom(tx *stm.Tx, r reason) bool {
+	max, ok := tx.Get(i.maxEntriesByReason).(stmutil.Mappish).Get(r)
+	return !ok || i.reasonEntries(tx, r) < max.(int)
+}
+
+// Returns the highest priority among waiters whose reasons have room.
+func (i *Instance) topPriority(tx *stm.Tx) (ret priority, ok bool) {
+	tx.Get(i.waitersByPriority).(stmutil.Mappish).Range(func(p, ws interface{}) bool {
+		ws.(stmutil.Settish).Range(func(w interface{}) bool {
+			ok = i.reasonHasRoom(tx, w.(*EntryHandle).reason)
+			return !ok
+		})
+		if ok {
+			ret = p.(priority)
+		}
+		return !ok
+	})
+	return
+}
+
+// Adds the handle to its entry's, counting it toward its reason.
+func (i *Instance) hold(tx *stm.Tx, eh *EntryHandle) {
+	tx.Set(i.entries, addToMapToSet(tx.Get(i.entries).(stmutil.Mappish), eh.e, eh))
+	i.addReasonEntries(tx, eh.reason, 1)
+	tx.Set(i.acquisitions, tx.Get(i.acquisitions).(int64)+1)
+}
+
+func (i *Instance) addReasonEntries(tx *stm.Tx, r reason, delta int) {
+	m := tx.Get(i.entriesByReason).(stmutil.Mappish)
+	if n := i.reasonEntries(tx, r) + delta; n == 0 {
+		m = m.Delete(r)
+	} else {
+		m = m.Set(r, n)
+	}
+	tx.Set(i.entriesByReason, m)
+}
+
 func (i *Instance) remove(eh *EntryHandle) {
 	stm.Atomically(func(tx *stm.Tx) interface{} {
-		es, _ := deleteFromMapToSet(tx.Get(i.entries).(stmutil.Mappish), eh.e, eh)
+		es := tx.Get(i.entries).(stmutil.Mappish)
+		if s, ok := es.Get(eh.e); !ok || !s.(stmutil.Settish).Contains(eh) {
+			return nil
+		}
+		es, _ = deleteFromMapToSet(es, eh.e, eh)
 		tx.Set(i.entries, es)
+		i.addReasonEntries(tx, eh.reason, -1)
 		return nil
 	})
 }
@@ -129,25 +229,27 @@ func (i *Instance) Wait(ctx context.Context, e Entry, reason string, p priority)
 		e:        e,
 		i:        i,
 		priority: p,
-		created:  time.Now(),
+		created:  i.Clock.Now(),
 	}
 	i.addWaiter(eh)
 	ctxDone, cancel := stmutil.ContextDoneVar(ctx)
 	defer cancel()
 	success := stm.Atomically(func(tx *stm.Tx) interface{} {
-		es := tx.Get(i.entries).(stmutil.Mappish)
-		if s, ok := es.Get(e); ok {
-			tx.Set(i.entries, es.Set(e, s.(stmutil.Settish).Add(eh)))
-			return true
-		}
-		haveRoom := tx.Get(i.noMaxEntries).(bool) || es.Len() < tx.Get(i.maxEntries).(int)
-		topPrio, ok := iter.First(tx.Get(i.waitersByPriority).(iter.Iterable).Iter)
-		if !ok {
-			panic("y u no waiting")
-		}
-		if haveRoom && p == topPrio {
-			tx.Set(i.entries, addToMapToSet(es, e, eh))
-			return true
+		if i.reasonHasRoom(tx, reason) {
+			es := tx.Get(i.entries).(stmutil.Mappish)
+			if _, ok := es.Get(e); ok {
+				i.hold(tx, eh)
+				return true
+			}
+			haveRoom := tx.Get(i.noMaxEntries).(bool) || es.Len() < tx.Get(i.maxEntries).(int)
+			topPrio, ok := i.topPriority(tx)
+			if !ok {
+				panic("y u no waiting")
+			}
+			if haveRoom && p == topPrio {
+				i.hold(tx, eh)
+				return true
+			}
 		}
 		if tx.Get(ctxDone).(bool) {
 			return false
@@ -158,6 +260,7 @@ func (i *Instance) Wait(ctx context.Context, e Entry, reason string, p priority)
 	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
 		i.deleteWaiter(eh, tx)
 	}))
+	i.stats.waited(success, i.Clock.Since(eh.created))
 	if !success {
 		eh = nil
 	}
@@ -170,17 +273,20 @@ func (i *Instance) Allow(tx *stm.Tx, e Entry, reason string, p priority) *EntryH
 		e:        e,
 		i:        i,
 		priority: p,
-		created:  time.Now(),
+		created:  i.Clock.Now(),
+	}
+	if !i.reasonHasRoom(tx, reason) {
+		return nil
 	}
 	es := tx.Get(i.entries).(stmutil.Mappish)
-	if s, ok := es.Get(e); ok {
-		tx.Set(i.entries, es.Set(e, s.(stmutil.Settish).Add(eh)))
+	if _, ok := es.Get(e); ok {
+		i.hold(tx, eh)
 		return eh
 	}
 	haveRoom := tx.Get(i.noMaxEntries).(bool) || es.Len() < tx.Get(i.maxEntries).(int)
-	topPrio, ok := iter.First(tx.Get(i.waitersByPriority).(iter.Iterable).Iter)
+	topPrio, ok := i.topPriority(tx)
 	if haveRoom && (!ok || p == topPrio) {
-		tx.Set(i.entries, addToMapToSet(es, e, eh))
+		i.hold(tx, eh)
 		return eh
 	}
 	return nil
@@ -201,66 +307,32 @@ func parseHostPort(hostport string) (ret struct {
 }
 
 func (i *Instance) PrintStatus(w io.Writer) {
+	s := i.Status()
 	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
-	fmt.Fprintf(w, "num entries: %d\n", stm.AtomicGet(i.entries).(stmutil.Lenner).Len())
+	fmt.Fprintf(w, "num entries: %d\n", s.NumEntries)
 	fmt.Fprintln(w)
-	fmt.Fprintf(w, "%d waiters:\n", stm.AtomicGet(i.waiters).(stmutil.Lenner).Len())
+	fmt.Fprintf(w, "%d waiters:\n", s.NumWaiters)
 	fmt.Fprintf(tw, "num\treason\n")
-	stm.AtomicGet(i.waitersByReason).(stmutil.Mappish).Range(func(r, ws interface{}) bool {
-		fmt.Fprintf(tw, "%d\t%q\n", ws.(stmutil.Settish).Len(), r.(reason))
-		return true
-	})
+	for r, n := range s.WaitersByReason {
+		fmt.Fprintf(tw, "%d\t%q\n", n, r)
+	}
 	tw.Flush()
 	fmt.Fprintln(w)
 	fmt.Fprintln(w, "handles:")
 	fmt.Fprintf(tw, "protocol\tlocal\tremote\treason\texpires\tcreated\n")
-	entries := stm.AtomicGet(i.entries).(stmutil.Mappish)
-	type entriesItem struct {
-		Entry
-		stmutil.Settish
-	}
-	entriesItems := make([]entriesItem, 0, entries.Len())
-	entries.Range(func(e, hs interface{}) bool {
-		entriesItems = append(entriesItems, entriesItem{e.(Entry), hs.(stmutil.Settish)})
-		return true
-	})
-	sort.Slice(entriesItems, func(i, j int) bool {
-		l := entriesItems[i].Entry
-		r := entriesItems[j].Entry
-		var ml missinggo.MultiLess
-		f := func(l, r string) {
-			pl := parseHostPort(l)
-			pr := parseHostPort(r)
-			ml.NextBool(pl.hostportErr != nil, pr.hostportErr != nil)
-			ml.NextBool(pl.hostIp.To4() == nil, pr.hostIp.To4() == nil)
-			ml.Compare(bytes.Compare(pl.hostIp, pr.hostIp))
-			ml.NextBool(pl.portIntErr != nil, pr.portIntErr != nil)
-			ml.StrictNext(pl.portInt64 == pr.portInt64, pl.portInt64 < pr.portInt64)
-			ml.StrictNext(pl.port == pr.port, pl.port < pr.port)
-		}
-		f(l.RemoteAddr, r.RemoteAddr)
-		ml.StrictNext(l.Protocol == r.Protocol, l.Protocol < r.Protocol)
-		f(l.LocalAddr, r.LocalAddr)
-		return ml.Less()
-	})
-	for _, ei := range entriesItems {
-		e := ei.Entry
-		ei.Settish.Range(func(_h interface{}) bool {
-			h := _h.(*EntryHandle)
-			fmt.Fprintf(tw,
-				"%q\t%q\t%q\t%q\t%s\t%v ago\n",
-				e.Protocol, e.LocalAddr, e.RemoteAddr, h.reason,
-				func() interface{} {
-					if h.expires.IsZero() {
-						return "not done"
-					} else {
-						return time.Until(h.expires)
-					}
-				}(),
-				time.Since(h.created),
-			)
-			return true
-		})
+	for _, h := range s.Handles {
+		fmt.Fprintf(tw,
+			"%q\t%q\t%q\t%q\t%s\t%v ago\n",
+			h.Protocol, h.LocalAddr, h.RemoteAddr, h.Reason,
+			func() interface{} {
+				if !h.Done {
+					return "not done"
+				} else {
+					return h.ExpiresIn
+				}
+			}(),
+			h.Age,
+		)
 	}
 	tw.Flush()
 }
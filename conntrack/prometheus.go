@@ -0,0 +1,92 @@
+package conntrack
+
+import (
+	"strconv"
+
+	"github.com/anacrolix/stm"
+	"github.com/anacrolix/stm/stmutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type collector struct {
+	i *Instance
+
+	entries         *prometheus.Desc
+	entriesByReason *prometheus.Desc
+	waiters         *prometheus.Desc
+	acquisitions    *prometheus.Desc
+	timeouts        *prometheus.Desc
+	waits           *prometheus.Desc
+}
+
+// Returns a Prometheus collector of the instance's entries and waiters, and
+// of how long waits take. The labels distinguish instances registered
+// together.
+func (i *Instance) PrometheusCollector(constLabels prometheus.Labels) prometheus.Collector {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc("conntrack_"+name, help, labels, constLabels)
+	}
+	return &collector{
+		i:               i,
+		entries:         desc("entries", "Entries held."),
+		entriesByReason: desc("reason_handles", "Handles holding entries, by reason.", "reason"),
+		waiters:         desc("waiters", "Handles waiting for entries.", "reason", "priority"),
+		acquisitions:    desc("acquisitions_total", "Handles given entries."),
+		timeouts:        desc("wait_timeouts_total", "Waits that gave up when their context was done."),
+		waits:           desc("wait_duration_seconds", "Time waits took to get entries."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (me *collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		me.entries, me.entriesByReason, me.waiters,
+		me.acquisitions, me.timeouts, me.waits,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (me *collector) Collect(ch chan<- prometheus.Metric) {
+	type waiterKey struct {
+		reason
+		priority
+	}
+	var (
+		entries      int
+		byReason     map[reason]int
+		waiters      map[waiterKey]int
+		acquisitions int64
+	)
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		entries = tx.Get(me.i.entries).(stmutil.Lenner).Len()
+		byReason = make(map[reason]int)
+		tx.Get(me.i.entriesByReason).(stmutil.Mappish).Range(func(r, n interface{}) bool {
+			byReason[r.(reason)] = n.(int)
+			return true
+		})
+		waiters = make(map[waiterKey]int)
+		tx.Get(me.i.waiters).(stmutil.Settish).Range(func(w interface{}) bool {
+			eh := w.(*EntryHandle)
+			waiters[waiterKey{eh.reason, eh.priority}]++
+			return true
+		})
+		acquisitions = tx.Get(me.i.acquisitions).(int64)
+	}))
+	s := &me.i.stats
+	s.mu.Lock()
+	timeouts, waits, waitTime := s.timeouts, s.waits, s.waitTime
+	s.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(me.entries, prometheus.GaugeValue, float64(entries))
+	for r, n := range byReason {
+		ch <- prometheus.MustNewConstMetric(me.entriesByReason, prometheus.GaugeValue, float64(n), r)
+	}
+	for k, n := range waiters {
+		ch <- prometheus.MustNewConstMetric(me.waiters, prometheus.GaugeValue, float64(n),
+			k.reason, strconv.Itoa(int(k.priority)))
+	}
+	ch <- prometheus.MustNewConstMetric(me.acquisitions, prometheus.CounterValue, float64(acquisitions))
+	ch <- prometheus.MustNewConstMetric(me.timeouts, prometheus.CounterValue, float64(timeouts))
+	ch <- prometheus.MustNewConstSummary(me.waits, waits, waitTime.Seconds(), nil)
+}
@@ -0,0 +1,56 @@
+package conntrack
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/stm"
+	"github.com/anacrolix/stm/stmutil"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+	i := NewInstance()
+	i.SetMaxEntries(1)
+	eh := i.Wait(context.Background(), entry(1), "a", 0)
+	require.NotNil(t, eh)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Nil(t, i.Wait(ctx, entry(2), "a", 0))
+	go i.Wait(context.Background(), entry(3), "b", 2)
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		tx.Assert(tx.Get(i.waiters).(stmutil.Lenner).Len() == 1)
+	}))
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(i.PrometheusCollector(prometheus.Labels{"instance": "test"})))
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP conntrack_acquisitions_total Handles given entries.
+# TYPE conntrack_acquisitions_total counter
+conntrack_acquisitions_total{instance="test"} 1
+# HELP conntrack_entries Entries held.
+# TYPE conntrack_entries gauge
+conntrack_entries{instance="test"} 1
+# HELP conntrack_reason_handles Handles holding entries, by reason.
+# TYPE conntrack_reason_handles gauge
+conntrack_reason_handles{instance="test",reason="a"} 1
+# HELP conntrack_wait_timeouts_total Waits that gave up when their context was done.
+# TYPE conntrack_wait_timeouts_total counter
+conntrack_wait_timeouts_total{instance="test"} 1
+# HELP conntrack_waiters Handles waiting for entries.
+# TYPE conntrack_waiters gauge
+conntrack_waiters{instance="test",priority="2",reason="b"} 1
+`), "conntrack_acquisitions_total", "conntrack_entries", "conntrack_reason_handles",
+		"conntrack_wait_timeouts_total", "conntrack_waiters"))
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		if mf.GetName() == "conntrack_wait_duration_seconds" {
+			assert.EqualValues(t, 1, mf.GetMetric()[0].GetSummary().GetSampleCount())
+		}
+	}
+	eh.Forget()
+}
@@ -0,0 +1,96 @@
+package conntrack
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/anacrolix/stm"
+	"github.com/anacrolix/stm/stmutil"
+
+	"github.com/anacrolix/missinggo/v2"
+)
+
+// A snapshot of an Instance, as shown by PrintStatus.
+type Status struct {
+	NumEntries      int
+	NumWaiters      int
+	WaitersByReason map[string]int
+	// Ordered by remote address, protocol, then local address.
+	Handles []HandleStatus
+}
+
+// An EntryHandle holding an entry.
+type HandleStatus struct {
+	Entry
+	Reason   string
+	Priority int
+	// Whether Done was called, after which the entry is held until it
+	// expires.
+	Done      bool
+	ExpiresIn time.Duration
+	Age       time.Duration
+}
+
+func (i *Instance) Status() (ret Status) {
+	var entries, waitersByReason stmutil.Mappish
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		entries = tx.Get(i.entries).(stmutil.Mappish)
+		waitersByReason = tx.Get(i.waitersByReason).(stmutil.Mappish)
+		ret.NumWaiters = tx.Get(i.waiters).(stmutil.Lenner).Len()
+	}))
+	now := i.Clock.Now()
+	ret.NumEntries = entries.Len()
+	ret.WaitersByReason = make(map[string]int, waitersByReason.Len())
+	waitersByReason.Range(func(r, ws interface{}) bool {
+		ret.WaitersByReason[r.(reason)] = ws.(stmutil.Settish).Len()
+		return true
+	})
+	entries.Range(func(e, hs interface{}) bool {
+		hs.(stmutil.Settish).Range(func(_h interface{}) bool {
+			h := _h.(*EntryHandle)
+			hst := HandleStatus{
+				Entry:    e.(Entry),
+				Reason:   h.reason,
+				Priority: int(h.priority),
+				Done:     !h.expires.IsZero(),
+				Age:      now.Sub(h.created),
+			}
+			if hst.Done {
+				hst.ExpiresIn = h.expires.Sub(now)
+			}
+			ret.Handles = append(ret.Handles, hst)
+			return true
+		})
+		return true
+	})
+	sort.SliceStable(ret.Handles, func(i, j int) bool {
+		return entryLess(ret.Handles[i].Entry, ret.Handles[j].Entry)
+	})
+	return
+}
+
+// Writes Status as JSON. Durations are in nanoseconds.
+func (i *Instance) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(i.Status())
+}
+
+func entryLess(l, r Entry) bool {
+	var ml missinggo.MultiLess
+	f := func(l, r string) {
+		pl := parseHostPort(l)
+		pr := parseHostPort(r)
+		ml.NextBool(pl.hostportErr != nil, pr.hostportErr != nil)
+		ml.NextBool(pl.hostIp.To4() == nil, pr.hostIp.To4() == nil)
+		ml.Compare(bytes.Compare(pl.hostIp, pr.hostIp))
+		ml.NextBool(pl.portIntErr != nil, pr.portIntErr != nil)
+		ml.StrictNext(pl.portInt64 == pr.portInt64, pl.portInt64 < pr.portInt64)
+		ml.StrictNext(pl.port == pr.port, pl.port < pr.port)
+	}
+	f(l.RemoteAddr, r.RemoteAddr)
+	ml.StrictNext(l.Protocol == r.Protocol, l.Protocol < r.Protocol)
+	f(l.LocalAddr, r.LocalAddr)
+	return ml.Less()
+}
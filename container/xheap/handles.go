@@ -0,0 +1,140 @@
+package xheap
+
+// A binary min-heap of values of type T, where each value pushed has a Handle
+// for changing its priority, or removing it, in O(log n).
+type Heap[T any] struct {
+	less  func(l, r T) bool
+	items []*Handle[T]
+}
+
+// Refers to a value in a Heap.
+type Handle[T any] struct {
+	value T
+	// Position in the heap's items, or -1 if it's not in the heap.
+	index int
+}
+
+func (me *Handle[T]) Value() T {
+	return me.value
+}
+
+// Whether the value is still in the Heap, not having been popped or removed.
+func (me *Handle[T]) InHeap() bool {
+	return me.index >= 0
+}
+
+// Returns a Heap ordered by less, with the least value on top.
+func New[T any](less func(l, r T) bool) *Heap[T] {
+	return &Heap[T]{less: less}
+}
+
+func (me *Heap[T]) Len() int {
+	return len(me.items)
+}
+
+func (me *Heap[T]) Push(v T) *Handle[T] {
+	h := &Handle[T]{v, len(me.items)}
+	me.items = append(me.items, h)
+	me.up(h.index)
+	return h
+}
+
+// Returns the least value without removing it.
+func (me *Heap[T]) Peek() (v T, ok bool) {
+	if len(me.items) == 0 {
+		return
+	}
+	return me.items[0].value, true
+}
+
+// Removes and returns the least value.
+func (me *Heap[T]) Pop() (v T, ok bool) {
+	if len(me.items) == 0 {
+		return
+	}
+	h := me.items[0]
+	me.remove(h)
+	return h.value, true
+}
+
+// Removes the value from the heap. Returns false if it was already gone.
+func (me *Heap[T]) Remove(h *Handle[T]) bool {
+	if !h.InHeap() {
+		return false
+	}
+	me.remove(h)
+	return true
+}
+
+func (me *Heap[T]) remove(h *Handle[T]) {
+	i := h.index
+	last := len(me.items) - 1
+	me.swap(i, last)
+	me.items[last] = nil
+	me.items = me.items[:last]
+	h.index = -1
+	if i != last {
+		me.fix(i)
+	}
+}
+
+// Changes the handle's value, and moves it to its new position.
+func (me *Heap[T]) Update(h *Handle[T], v T) {
+	h.value = v
+	if h.InHeap() {
+		me.fix(h.index)
+	}
+}
+
+// Like Update, for a value that doesn't order after the old one, which only
+// needs to move up.
+func (me *Heap[T]) DecreaseKey(h *Handle[T], v T) {
+	h.value = v
+	if h.InHeap() {
+		me.up(h.index)
+	}
+}
+
+func (me *Heap[T]) fix(i int) {
+	if !me.down(i) {
+		me.up(i)
+	}
+}
+
+func (me *Heap[T]) swap(i, j int) {
+	me.items[i], me.items[j] = me.items[j], me.items[i]
+	me.items[i].index = i
+	me.items[j].index = j
+}
+
+func (me *Heap[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !me.less(me.items[i].value, me.items[parent].value) {
+			return
+		}
+		me.swap(i, parent)
+		i = parent
+	}
+}
+
+// Returns whether the item moved.
+func (me *Heap[T]) down(i int) bool {
+	start := i
+	n := len(me.items)
+	for {
+		least := 2*i + 1
+		if least >= n {
+			break
+		}
+		if r := least + 1; r < n && me.less(me.items[r].value, me.items[least].value) {
+			least = r
+		}
+		if !me.less(me.items[least].value, me.items[i].value) {
+			break
+		}
+		me.swap(i, least)
+		i = least
+	}
+	return i != start
+}
@@ -0,0 +1,52 @@
+package xheap
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeapHandles(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	h := New(func(l, r int) bool { return l < r })
+	_, ok := h.Pop()
+	assert.False(t, ok)
+	handles := make(map[*Handle[int]]struct{})
+	for i := 0; i < 1000; i++ {
+		handles[h.Push(r.Intn(1000))] = struct{}{}
+	}
+	i := 0
+	for hd := range handles {
+		switch i % 4 {
+		case 0:
+			assert.True(t, h.Remove(hd))
+			assert.False(t, h.Remove(hd))
+			delete(handles, hd)
+		case 1:
+			h.DecreaseKey(hd, hd.Value()-r.Intn(500))
+		case 2:
+			h.Update(hd, r.Intn(2000))
+		}
+		i++
+	}
+	var want []int
+	for hd := range handles {
+		assert.True(t, hd.InHeap())
+		want = append(want, hd.Value())
+	}
+	sort.Ints(want)
+	assert.Equal(t, len(want), h.Len())
+	top, _ := h.Peek()
+	assert.Equal(t, want[0], top)
+	var got []int
+	for h.Len() != 0 {
+		v, _ := h.Pop()
+		got = append(got, v)
+	}
+	assert.Equal(t, want, got)
+	for hd := range handles {
+		assert.False(t, hd.InHeap())
+	}
+}
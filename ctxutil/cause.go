@@ -0,0 +1,56 @@
+package ctxutil
+
+import (
+	"context"
+	"time"
+)
+
+type causeKey struct{}
+
+type deadlineCauseCtx struct {
+	context.Context
+	parent   context.Context
+	deadline time.Time
+	cause    error
+}
+
+func (me *deadlineCauseCtx) Value(key interface{}) interface{} {
+	if key == (causeKey{}) {
+		return me
+	}
+	return me.Context.Value(key)
+}
+
+// Like context.WithDeadline, but Cause returns cause if the Context expires
+// due to this deadline.
+func WithDeadlineCause(parent context.Context, d time.Time, cause error) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithDeadline(parent, d)
+	return &deadlineCauseCtx{
+		Context:  ctx,
+		parent:   parent,
+		deadline: d,
+		cause:    cause,
+	}, cancel
+}
+
+// Returns why ctx is done: the cause given to WithDeadlineCause if it was
+// that deadline that expired, or otherwise ctx.Err().
+func Cause(ctx context.Context) error {
+	err := ctx.Err()
+	if err != context.DeadlineExceeded {
+		return err
+	}
+	c, ok := ctx.Value(causeKey{}).(*deadlineCauseCtx)
+	if !ok {
+		return err
+	}
+	pd, pok := c.parent.Deadline()
+	// A descendant with an earlier deadline of its own expired.
+	if d, _ := ctx.Deadline(); d.Before(c.deadline) && (!pok || d.Before(pd)) {
+		return err
+	}
+	if pok && !pd.After(c.deadline) {
+		return Cause(c.parent)
+	}
+	return c.cause
+}
@@ -0,0 +1,68 @@
+package ctxutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type key int
+
+func TestMerge(t *testing.T) {
+	a, cancelA := context.WithCancel(context.WithValue(context.Background(), key(1), "a"))
+	defer cancelA()
+	deadline := time.Now().Add(time.Hour)
+	b, cancelB := context.WithDeadline(context.WithValue(context.Background(), key(2), "b"), deadline)
+	defer cancelB()
+	ctx, cancel := Merge(a, b)
+	defer cancel()
+	assert.Equal(t, "a", ctx.Value(key(1)))
+	assert.Equal(t, "b", ctx.Value(key(2)))
+	d, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, deadline, d)
+	assert.NoError(t, ctx.Err())
+	cancelB()
+	<-ctx.Done()
+	assert.Equal(t, context.Canceled, ctx.Err())
+
+	ctx, cancel = Merge(context.Background(), context.Background())
+	cancel()
+	<-ctx.Done()
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
+
+func TestDetach(t *testing.T) {
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key(1), "a"))
+	cancel()
+	ctx := Detach(parent)
+	assert.NoError(t, ctx.Err())
+	assert.Nil(t, ctx.Done())
+	assert.Equal(t, "a", ctx.Value(key(1)))
+}
+
+func TestWithDeadlineCause(t *testing.T) {
+	slow := errors.New("too slow")
+	ctx, cancel := WithDeadlineCause(context.Background(), time.Now().Add(time.Millisecond), slow)
+	defer cancel()
+	assert.NoError(t, Cause(ctx))
+	<-ctx.Done()
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+	assert.Equal(t, slow, Cause(ctx))
+
+	// An earlier deadline in a child isn't attributed to the cause.
+	parent, cancel := WithDeadlineCause(context.Background(), time.Now().Add(time.Hour), slow)
+	defer cancel()
+	child, cancel := context.WithTimeout(parent, time.Millisecond)
+	defer cancel()
+	<-child.Done()
+	assert.Equal(t, context.DeadlineExceeded, Cause(child))
+
+	// Cancellation isn't either.
+	ctx, cancel = WithDeadlineCause(context.Background(), time.Now().Add(time.Hour), slow)
+	cancel()
+	assert.Equal(t, context.Canceled, Cause(ctx))
+}
@@ -0,0 +1,33 @@
+package ctxutil
+
+import (
+	"context"
+	"time"
+)
+
+type detachedCtx struct {
+	parent context.Context
+}
+
+// Returns a Context with the values of ctx, but none of its cancellation or
+// deadline. This is for starting work that must outlive the request that
+// caused it.
+func Detach(ctx context.Context) context.Context {
+	return detachedCtx{ctx}
+}
+
+func (detachedCtx) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedCtx) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedCtx) Err() error {
+	return nil
+}
+
+func (me detachedCtx) Value(key interface{}) interface{} {
+	return me.parent.Value(key)
+}
@@ -0,0 +1,73 @@
+// Package ctxutil has helpers for combining Contexts with different
+// lifetimes.
+package ctxutil
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type mergedCtx struct {
+	a, b context.Context
+	done chan struct{}
+	mu   sync.Mutex
+	err  error
+}
+
+// Returns a Context that's done when either a or b is, with the earlier of
+// their deadlines, and the values of both, preferring a's. The CancelFunc
+// must be called to release resources when the Context is no longer needed.
+func Merge(a, b context.Context) (context.Context, context.CancelFunc) {
+	me := &mergedCtx{
+		a:    a,
+		b:    b,
+		done: make(chan struct{}),
+	}
+	stop := make(chan struct{})
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() { close(stop) })
+	}
+	go func() {
+		var err error
+		select {
+		case <-a.Done():
+			err = a.Err()
+		case <-b.Done():
+			err = b.Err()
+		case <-stop:
+			err = context.Canceled
+		}
+		me.mu.Lock()
+		me.err = err
+		me.mu.Unlock()
+		close(me.done)
+	}()
+	return me, cancel
+}
+
+func (me *mergedCtx) Deadline() (deadline time.Time, ok bool) {
+	deadline, ok = me.a.Deadline()
+	if d, bok := me.b.Deadline(); bok && (!ok || d.Before(deadline)) {
+		deadline, ok = d, true
+	}
+	return
+}
+
+func (me *mergedCtx) Done() <-chan struct{} {
+	return me.done
+}
+
+func (me *mergedCtx) Err() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.err
+}
+
+func (me *mergedCtx) Value(key interface{}) interface{} {
+	if v := me.a.Value(key); v != nil {
+		return v
+	}
+	return me.b.Value(key)
+}
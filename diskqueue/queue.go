@@ -0,0 +1,347 @@
+// Package diskqueue provides a persistent FIFO queue of byte slices, stored
+// in append-only segment files, that survives restarts.
+package diskqueue
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2/atomicfile"
+)
+
+var (
+	ErrEmpty  = errors.New("queue is empty")
+	ErrClosed = errors.New("queue is closed")
+)
+
+type SyncPolicy int
+
+const (
+	// Syncs only on Close, or calls to Sync.
+	SyncOnClose SyncPolicy = iota
+	// Syncs after every Push and Pop.
+	SyncAlways
+	// Syncs every Opts.SyncInterval.
+	SyncPeriodic
+)
+
+type Opts struct {
+	// Segments are started when the last reaches this size. Defaults to
+	// 64MiB.
+	SegmentSize int64
+	// After a crash, pushes since the last sync can be lost, and pops since
+	// the last sync are delivered again.
+	Sync SyncPolicy
+	// Defaults to 1s.
+	SyncInterval time.Duration
+}
+
+const headFileName = "head"
+
+// A FIFO queue persisted in a directory. It's safe for concurrent use.
+type Queue struct {
+	dir  string
+	opts Opts
+
+	mu       sync.Mutex
+	closed   bool
+	segments []segment
+	// Appended to, and the last of segments.
+	tail *os.File
+	// Opened on the first segment when reading.
+	headFile   *os.File
+	headOffset int64
+	// The sequence numbers of the next item to pop, and push.
+	head, next uint64
+	// Whether head has changed since it was written.
+	headDirty bool
+	stop      chan struct{}
+	stopped   sync.WaitGroup
+}
+
+// Opens the queue in dir, creating it if necessary, and recovering from any
+// incomplete writes.
+func Open(dir string, opts Opts) (me *Queue, err error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = 64 << 20
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	me = &Queue{
+		dir:  dir,
+		opts: opts,
+		stop: make(chan struct{}),
+	}
+	if err = me.recover(); err != nil {
+		me.closeFiles()
+		return nil, err
+	}
+	if opts.Sync == SyncPeriodic {
+		me.stopped.Add(1)
+		go me.syncPeriodically()
+	}
+	return
+}
+
+func (me *Queue) recover() (err error) {
+	me.segments, err = listSegments(me.dir)
+	if err != nil {
+		return
+	}
+	for i := range me.segments {
+		if err = me.segments[i].scan(me.dir, i == len(me.segments)-1); err != nil {
+			return
+		}
+	}
+	if len(me.segments) == 0 {
+		me.segments = []segment{{}}
+		f, err := os.OpenFile(segmentPath(me.dir, 0), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		me.tail = f
+	} else {
+		last := me.segments[len(me.segments)-1]
+		me.tail, err = os.OpenFile(segmentPath(me.dir, last.first), os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return
+		}
+	}
+	last := me.segments[len(me.segments)-1]
+	me.next = last.first + last.count
+	me.head, err = readHead(me.dir)
+	if err != nil {
+		return
+	}
+	// Segments may have been removed after the head was last written.
+	if me.head < me.segments[0].first {
+		me.head = me.segments[0].first
+	}
+	if me.head > me.next {
+		return fmt.Errorf("head %d is beyond the last item %d", me.head, me.next)
+	}
+	// Removal of consumed segments may have been interrupted.
+	for len(me.segments) > 1 && me.head >= me.segments[1].first {
+		if err = os.Remove(segmentPath(me.dir, me.segments[0].first)); err != nil {
+			return
+		}
+		me.segments = me.segments[1:]
+	}
+	// Find the offset of the head item.
+	if err = me.openHead(); err != nil {
+		return
+	}
+	for seq := me.segments[0].first; seq < me.head; seq++ {
+		_, me.headOffset, err = readRecord(me.headFile, me.headOffset, me.segments[0].size)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (me *Queue) openHead() (err error) {
+	me.headFile, err = os.Open(segmentPath(me.dir, me.segments[0].first))
+	me.headOffset = 0
+	return
+}
+
+func readHead(dir string) (uint64, error) {
+	b, err := os.ReadFile(filepath.Join(dir, headFileName))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 12 || crc32.Checksum(b[:8], castagnoli) != binary.BigEndian.Uint32(b[8:]) {
+		return 0, errors.New("corrupt head file")
+	}
+	return binary.BigEndian.Uint64(b[:8]), nil
+}
+
+func (me *Queue) writeHead() error {
+	var b [12]byte
+	binary.BigEndian.PutUint64(b[:8], me.head)
+	binary.BigEndian.PutUint32(b[8:], crc32.Checksum(b[:8], castagnoli))
+	err := atomicfile.WriteFile(filepath.Join(me.dir, headFileName), b[:], atomicfile.Opts{})
+	if err == nil {
+		me.headDirty = false
+	}
+	return err
+}
+
+// Appends an item to the queue.
+func (me *Queue) Push(item []byte) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.closed {
+		return ErrClosed
+	}
+	last := &me.segments[len(me.segments)-1]
+	if last.count != 0 && last.size >= me.opts.SegmentSize {
+		if err := me.rotate(); err != nil {
+			return err
+		}
+		last = &me.segments[len(me.segments)-1]
+	}
+	rec := appendRecord(nil, item)
+	if _, err := me.tail.Write(rec); err != nil {
+		return err
+	}
+	last.size += int64(len(rec))
+	last.count++
+	me.next++
+	if me.opts.Sync == SyncAlways {
+		return me.tail.Sync()
+	}
+	return nil
+}
+
+// Starts a new segment for pushes.
+func (me *Queue) rotate() error {
+	if err := me.tail.Sync(); err != nil {
+		return err
+	}
+	if err := me.tail.Close(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(segmentPath(me.dir, me.next), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	me.tail = f
+	me.segments = append(me.segments, segment{first: me.next})
+	return me.dropConsumed()
+}
+
+// Removes the first segment if all its items have been popped, and it's not
+// being appended to.
+func (me *Queue) dropConsumed() error {
+	if len(me.segments) == 1 || me.head < me.segments[1].first {
+		return nil
+	}
+	me.headFile.Close()
+	if err := os.Remove(segmentPath(me.dir, me.segments[0].first)); err != nil {
+		return err
+	}
+	me.segments = me.segments[1:]
+	return me.openHead()
+}
+
+func (me *Queue) read() (item []byte, next int64, err error) {
+	if me.closed {
+		err = ErrClosed
+		return
+	}
+	if me.head == me.next {
+		err = ErrEmpty
+		return
+	}
+	return readRecord(me.headFile, me.headOffset, me.segments[0].size)
+}
+
+// Returns the item at the front of the queue without removing it, or
+// ErrEmpty.
+func (me *Queue) Peek() ([]byte, error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	item, _, err := me.read()
+	return item, err
+}
+
+// Removes and returns the item at the front of the queue, or ErrEmpty.
+func (me *Queue) Pop() (item []byte, err error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	item, next, err := me.read()
+	if err != nil {
+		return
+	}
+	me.head++
+	me.headOffset = next
+	me.headDirty = true
+	if me.opts.Sync == SyncAlways {
+		if err = me.writeHead(); err != nil {
+			return
+		}
+	}
+	err = me.dropConsumed()
+	return
+}
+
+// Returns the number of items in the queue.
+func (me *Queue) Len() int {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return int(me.next - me.head)
+}
+
+// Flushes pushes and pops to disk.
+func (me *Queue) Sync() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.closed {
+		return ErrClosed
+	}
+	return me.sync()
+}
+
+func (me *Queue) sync() error {
+	if err := me.tail.Sync(); err != nil {
+		return err
+	}
+	if !me.headDirty {
+		return nil
+	}
+	return me.writeHead()
+}
+
+func (me *Queue) syncPeriodically() {
+	defer me.stopped.Done()
+	t := time.NewTicker(me.opts.SyncInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			me.Sync()
+		case <-me.stop:
+			return
+		}
+	}
+}
+
+// Syncs and closes the queue.
+func (me *Queue) Close() error {
+	me.mu.Lock()
+	if me.closed {
+		me.mu.Unlock()
+		return nil
+	}
+	err := me.sync()
+	me.closed = true
+	me.closeFiles()
+	me.mu.Unlock()
+	close(me.stop)
+	me.stopped.Wait()
+	return err
+}
+
+func (me *Queue) closeFiles() {
+	if me.tail != nil {
+		me.tail.Close()
+	}
+	if me.headFile != nil {
+		me.headFile.Close()
+	}
+}
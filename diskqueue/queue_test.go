@@ -0,0 +1,121 @@
+package diskqueue
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func item(i int) []byte {
+	return []byte(fmt.Sprintf("item %d", i))
+}
+
+func segmentFiles(t *testing.T, dir string) int {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	return len(matches)
+}
+
+func TestPushPop(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, Opts{SegmentSize: 64})
+	require.NoError(t, err)
+	defer q.Close()
+	_, err = q.Pop()
+	assert.Equal(t, ErrEmpty, err)
+	for i := 0; i < 20; i++ {
+		require.NoError(t, q.Push(item(i)))
+	}
+	assert.Equal(t, 20, q.Len())
+	assert.True(t, segmentFiles(t, dir) > 1)
+	b, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, item(0), b)
+	for i := 0; i < 20; i++ {
+		b, err := q.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, item(i), b)
+	}
+	_, err = q.Peek()
+	assert.Equal(t, ErrEmpty, err)
+	assert.Equal(t, 1, segmentFiles(t, dir))
+}
+
+func TestReopen(t *testing.T) {
+	for _, sync := range []SyncPolicy{SyncOnClose, SyncAlways, SyncPeriodic} {
+		dir := t.TempDir()
+		opts := Opts{SegmentSize: 64, Sync: sync}
+		q, err := Open(dir, opts)
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, q.Push(item(i)))
+		}
+		for i := 0; i < 4; i++ {
+			_, err := q.Pop()
+			require.NoError(t, err)
+		}
+		require.NoError(t, q.Close())
+		_, err = q.Pop()
+		assert.Equal(t, ErrClosed, err)
+
+		q, err = Open(dir, opts)
+		require.NoError(t, err)
+		assert.Equal(t, 6, q.Len(), sync)
+		require.NoError(t, q.Push(item(10)))
+		for i := 4; i <= 10; i++ {
+			b, err := q.Pop()
+			require.NoError(t, err)
+			assert.Equal(t, item(i), b)
+		}
+		q.Close()
+	}
+}
+
+// A partially written record at the end of the last segment, as from a
+// crash, is discarded.
+func TestTornTail(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, Opts{})
+	require.NoError(t, err)
+	require.NoError(t, q.Push(item(0)))
+	require.NoError(t, q.Push(item(1)))
+	require.NoError(t, q.Close())
+	path := segmentPath(dir, 0)
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, fi.Size()-1))
+
+	q, err = Open(dir, Opts{})
+	require.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 1, q.Len())
+	require.NoError(t, q.Push(item(2)))
+	for _, i := range []int{0, 2} {
+		b, err := q.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, item(i), b)
+	}
+}
+
+// Pops that weren't synced before a crash are delivered again.
+func TestUnsyncedPops(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, Opts{})
+	require.NoError(t, err)
+	require.NoError(t, q.Push(item(0)))
+	require.NoError(t, q.Push(item(1)))
+	require.NoError(t, q.Sync())
+	_, err = q.Pop()
+	require.NoError(t, err)
+	// Simulate a crash by abandoning q.
+	q.closeFiles()
+
+	q, err = Open(dir, Opts{})
+	require.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 2, q.Len())
+}
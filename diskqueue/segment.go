@@ -0,0 +1,137 @@
+package diskqueue
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	segmentExt = ".seg"
+	// Payload length, and its CRC.
+	recordHeaderLen = 8
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var errTorn = errors.New("torn record")
+
+// A segment file holds consecutive items, starting with the sequence number
+// in its name.
+type segment struct {
+	first uint64
+	count uint64
+	size  int64
+}
+
+func segmentPath(dir string, first uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", first, segmentExt))
+}
+
+// Returns the segments in dir, oldest first.
+func listSegments(dir string) (ret []segment, err error) {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, de := range des {
+		name := de.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, segment{first: first})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].first < ret[j].first
+	})
+	return
+}
+
+func appendRecord(b, payload []byte) []byte {
+	var h [recordHeaderLen]byte
+	binary.BigEndian.PutUint32(h[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(h[4:], crc32.Checksum(payload, castagnoli))
+	return append(append(b, h[:]...), payload...)
+}
+
+// Reads the record at off, returning its payload, and the offset of the next
+// record. io.EOF is returned at the end of the file, and errTorn if the
+// record is incomplete or corrupt.
+func readRecord(f io.ReaderAt, off, size int64) (payload []byte, next int64, err error) {
+	if off == size {
+		err = io.EOF
+		return
+	}
+	var h [recordHeaderLen]byte
+	if off+recordHeaderLen > size {
+		err = errTorn
+		return
+	}
+	if _, err = f.ReadAt(h[:], off); err != nil {
+		return
+	}
+	n := int64(binary.BigEndian.Uint32(h[:4]))
+	next = off + recordHeaderLen + n
+	if next > size {
+		err = errTorn
+		return
+	}
+	payload = make([]byte, n)
+	if _, err = f.ReadAt(payload, off+recordHeaderLen); err != nil {
+		return
+	}
+	if crc32.Checksum(payload, castagnoli) != binary.BigEndian.Uint32(h[4:]) {
+		err = errTorn
+	}
+	return
+}
+
+// Counts the records in the segment, truncating a torn record at the end if
+// truncate is set.
+func (me *segment) scan(dir string, truncate bool) error {
+	f, err := os.OpenFile(segmentPath(dir, me.first), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := fi.Size()
+	var off int64
+	me.count = 0
+	for {
+		_, next, err := readRecord(f, off, size)
+		if err == io.EOF {
+			break
+		}
+		if err == errTorn {
+			if !truncate {
+				return fmt.Errorf("segment %d: corrupt record %d", me.first, me.count)
+			}
+			if err := f.Truncate(off); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		off = next
+		me.count++
+	}
+	me.size = off
+	return nil
+}
@@ -0,0 +1,43 @@
+// Package diskspace reports the space and inodes of the filesystem holding a
+// path, and watches for free space crossing thresholds.
+package diskspace
+
+import (
+	"errors"
+)
+
+// Returned on platforms where the query isn't implemented.
+var ErrUnsupported = errors.New("disk space query unsupported")
+
+var errNotChecked = errors.New("not checked yet")
+
+type Usage struct {
+	// Bytes in the filesystem.
+	Total int64
+	// Bytes not in use, including any reserved for privileged users.
+	Free int64
+	// Bytes that unprivileged users can use.
+	Available int64
+	// Inode counts, or zero where the filesystem has no such limit, such as
+	// on Windows.
+	Inodes     int64
+	FreeInodes int64
+}
+
+// Returns the bytes in use.
+func (me Usage) Used() int64 {
+	return me.Total - me.Free
+}
+
+// Returns the fraction of the filesystem available, from 0 to 1.
+func (me Usage) AvailableFraction() float64 {
+	if me.Total == 0 {
+		return 0
+	}
+	return float64(me.Available) / float64(me.Total)
+}
+
+// Returns the usage of the filesystem holding path.
+func Get(path string) (Usage, error) {
+	return get(path)
+}
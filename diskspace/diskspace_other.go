@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package diskspace
+
+func get(path string) (Usage, error) {
+	return Usage{}, ErrUnsupported
+}
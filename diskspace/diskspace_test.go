@@ -0,0 +1,81 @@
+package diskspace
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/v2/clock"
+)
+
+func TestGet(t *testing.T) {
+	u, err := Get(t.TempDir())
+	if err == ErrUnsupported {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	assert.True(t, u.Total > 0)
+	assert.True(t, u.Free <= u.Total)
+	assert.True(t, u.Available <= u.Free)
+	assert.True(t, u.AvailableFraction() <= 1)
+}
+
+func TestWatcherThresholds(t *testing.T) {
+	var mu sync.Mutex
+	available := int64(100)
+	w := newWatcher("", WatchOpts{}, func(string) (Usage, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return Usage{Total: 1000, Free: available, Available: available}, nil
+	})
+	setAvailable := func(n int64) {
+		mu.Lock()
+		available = n
+		mu.Unlock()
+		w.Check()
+	}
+	var got []Crossing
+	record := func(c Crossing) {
+		c.Usage = Usage{}
+		got = append(got, c)
+	}
+	w.OnThreshold(50, record)
+	remove := w.OnThreshold(200, record)
+	_, err := w.Usage()
+	assert.Error(t, err)
+	w.Check()
+	assert.Equal(t, []Crossing{{Threshold: 200, Below: true}}, got)
+	setAvailable(40)
+	setAvailable(45)
+	assert.Equal(t, []Crossing{
+		{Threshold: 200, Below: true},
+		{Threshold: 50, Below: true},
+	}, got)
+	remove()
+	got = nil
+	setAvailable(500)
+	assert.Equal(t, []Crossing{{Threshold: 50, Below: false}}, got)
+	u, err := w.Usage()
+	require.NoError(t, err)
+	assert.EqualValues(t, 500, u.Available)
+}
+
+func TestWatcherInterval(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	checks := make(chan struct{}, 1)
+	w := newWatcher("", WatchOpts{Clock: c, Interval: time.Minute}, func(string) (Usage, error) {
+		checks <- struct{}{}
+		return Usage{}, nil
+	})
+	go w.run()
+	defer w.Close()
+	<-checks
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	<-checks
+	c.Advance(time.Minute)
+	<-checks
+}
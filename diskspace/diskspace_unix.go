@@ -0,0 +1,26 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package diskspace
+
+import (
+	"syscall"
+)
+
+func get(path string) (ret Usage, err error) {
+	var st syscall.Statfs_t
+	if err = syscall.Statfs(path, &st); err != nil {
+		return
+	}
+	bsize := int64(st.Bsize)
+	ret.Total = int64(st.Blocks) * bsize
+	ret.Free = int64(st.Bfree) * bsize
+	ret.Available = int64(st.Bavail) * bsize
+	if ret.Available < 0 {
+		// Some BSDs report negative availability when the reserve is in use.
+		ret.Available = 0
+	}
+	ret.Inodes = int64(st.Files)
+	ret.FreeInodes = int64(st.Ffree)
+	return
+}
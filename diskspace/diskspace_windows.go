@@ -0,0 +1,31 @@
+package diskspace
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+func get(path string) (ret Usage, err error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return
+	}
+	// Available honours per-user quotas.
+	var available, total, free uint64
+	r, _, e := procGetDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&available)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&free)),
+	)
+	if r == 0 {
+		err = e
+		return
+	}
+	ret.Total = int64(total)
+	ret.Free = int64(free)
+	ret.Available = int64(available)
+	return
+}
@@ -0,0 +1,151 @@
+package diskspace
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2/clock"
+)
+
+type WatchOpts struct {
+	// How often to check. Defaults to 10s.
+	Interval time.Duration
+	// Defaults to clock.Real.
+	Clock clock.Clock
+	// Called with errors from checks. Thresholds aren't evaluated for failed
+	// checks.
+	OnError func(error)
+}
+
+// Passed to threshold callbacks when available space crosses the threshold.
+type Crossing struct {
+	Threshold int64
+	// True if available space has fallen below the threshold, false if it has
+	// recovered.
+	Below bool
+	Usage Usage
+}
+
+type threshold struct {
+	bytes int64
+	f     func(Crossing)
+	below bool
+}
+
+// Periodically checks the usage of a filesystem.
+type Watcher struct {
+	path  string
+	opts  WatchOpts
+	query func(string) (Usage, error)
+	stop  chan struct{}
+	done  chan struct{}
+
+	// Serializes checks, so callbacks are in order.
+	checkMu    sync.Mutex
+	mu         sync.Mutex
+	usage      Usage
+	err        error
+	thresholds map[*threshold]struct{}
+}
+
+// Starts watching the filesystem holding path. The first check happens
+// immediately.
+func Watch(path string, opts WatchOpts) *Watcher {
+	me := newWatcher(path, opts, get)
+	go me.run()
+	return me
+}
+
+func newWatcher(path string, opts WatchOpts, query func(string) (Usage, error)) *Watcher {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	opts.Clock = clock.OrReal(opts.Clock)
+	return &Watcher{
+		path:       path,
+		opts:       opts,
+		query:      query,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		err:        errNotChecked,
+		thresholds: make(map[*threshold]struct{}),
+	}
+}
+
+func (me *Watcher) run() {
+	defer close(me.done)
+	t := me.opts.Clock.NewTicker(me.opts.Interval)
+	defer t.Stop()
+	for {
+		me.Check()
+		select {
+		case <-t.C():
+		case <-me.stop:
+			return
+		}
+	}
+}
+
+// Calls f from the watcher each time available space crosses below bytes, or
+// recovers to at least bytes. If space is already below, f is called after
+// the next check.
+func (me *Watcher) OnThreshold(bytes int64, f func(Crossing)) (remove func()) {
+	t := &threshold{bytes: bytes, f: f}
+	me.mu.Lock()
+	me.thresholds[t] = struct{}{}
+	me.mu.Unlock()
+	return func() {
+		me.mu.Lock()
+		delete(me.thresholds, t)
+		me.mu.Unlock()
+	}
+}
+
+// Checks usage now, running any threshold callbacks.
+func (me *Watcher) Check() {
+	me.checkMu.Lock()
+	defer me.checkMu.Unlock()
+	u, err := me.query(me.path)
+	me.mu.Lock()
+	me.err = err
+	if err != nil {
+		me.mu.Unlock()
+		if me.opts.OnError != nil {
+			me.opts.OnError(err)
+		}
+		return
+	}
+	me.usage = u
+	var crossed []Crossing
+	var fs []func(Crossing)
+	for t := range me.thresholds {
+		below := u.Available < t.bytes
+		if below == t.below {
+			continue
+		}
+		t.below = below
+		crossed = append(crossed, Crossing{t.bytes, below, u})
+		fs = append(fs, t.f)
+	}
+	me.mu.Unlock()
+	for i, f := range fs {
+		f(crossed[i])
+	}
+}
+
+// Returns the result of the latest check.
+func (me *Watcher) Usage() (Usage, error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.usage, me.err
+}
+
+// Stops checking. Callbacks aren't called after it returns.
+func (me *Watcher) Close() {
+	select {
+	case <-me.stop:
+	default:
+		close(me.stop)
+	}
+	<-me.done
+}
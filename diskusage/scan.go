@@ -0,0 +1,249 @@
+// Package diskusage totals the space used by directory trees, scanning
+// directories in parallel.
+package diskusage
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2/sparse"
+)
+
+type Usage struct {
+	Bytes int64
+	Files int64
+	Dirs  int64
+}
+
+func (me *Usage) add(other *Usage) {
+	atomic.AddInt64(&me.Bytes, other.Bytes)
+	atomic.AddInt64(&me.Files, other.Files)
+	atomic.AddInt64(&me.Dirs, other.Dirs)
+}
+
+func (me *Usage) load() Usage {
+	return Usage{
+		Bytes: atomic.LoadInt64(&me.Bytes),
+		Files: atomic.LoadInt64(&me.Files),
+		Dirs:  atomic.LoadInt64(&me.Dirs),
+	}
+}
+
+type Opts struct {
+	// Directories read at once. Defaults to GOMAXPROCS.
+	Workers int
+	// Count the space allocated to files, rather than their apparent size.
+	// Allocated size reflects sparse and compressed files, but costs a
+	// further stat per file on some platforms.
+	Allocated bool
+	// Report usage for each subtree down to this many levels below the root.
+	Depth int
+	// Called with the running total every ProgressInterval, which defaults
+	// to 1s, and once with the final total.
+	Progress         func(Usage)
+	ProgressInterval time.Duration
+	// Called concurrently for every regular file found, such as to index
+	// them. path is relative to the root.
+	OnFile func(path string, fi fs.FileInfo)
+	// Called concurrently with errors reading directories or files. Return
+	// nil to skip the path and continue. By default errors are counted, and
+	// skipped.
+	OnError func(path string, err error) error
+}
+
+type Result struct {
+	Total Usage
+	// Usage of directories down to Opts.Depth, keyed by path relative to the
+	// root, with "/" separators. Each includes the directory itself.
+	Subtrees map[string]Usage
+	// Paths skipped due to errors.
+	Errors int64
+}
+
+type scanner struct {
+	ctx  context.Context
+	opts Opts
+	root string
+	sem  chan struct{}
+	wg   sync.WaitGroup
+
+	total  Usage
+	errors int64
+
+	mu       sync.Mutex
+	subtrees map[string]*Usage
+	err      error
+	cancel   context.CancelFunc
+}
+
+// Scans the tree at root. Symlinks aren't followed.
+func Scan(ctx context.Context, root string, opts Opts) (ret Result, err error) {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = time.Second
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	me := &scanner{
+		ctx:      ctx,
+		opts:     opts,
+		root:     root,
+		sem:      make(chan struct{}, opts.Workers-1),
+		subtrees: make(map[string]*Usage),
+		cancel:   cancel,
+	}
+	if opts.Progress != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go me.reportProgress(stop)
+	}
+	fi, err := os.Lstat(root)
+	if err != nil {
+		return
+	}
+	if fi.IsDir() {
+		me.dir("", nil)
+	} else {
+		me.file("", fi, nil)
+	}
+	me.wg.Wait()
+	if me.err != nil {
+		return ret, me.err
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	ret.Total = me.total.load()
+	ret.Errors = atomic.LoadInt64(&me.errors)
+	ret.Subtrees = make(map[string]Usage, len(me.subtrees))
+	for k, v := range me.subtrees {
+		ret.Subtrees[k] = v.load()
+	}
+	if opts.Progress != nil {
+		opts.Progress(ret.Total)
+	}
+	return
+}
+
+func (me *scanner) reportProgress(stop chan struct{}) {
+	t := time.NewTicker(me.opts.ProgressInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			me.opts.Progress(me.total.load())
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (me *scanner) handleError(path string, err error) {
+	if me.opts.OnError != nil {
+		if err := me.opts.OnError(path, err); err != nil {
+			me.mu.Lock()
+			if me.err == nil {
+				me.err = err
+			}
+			me.mu.Unlock()
+			me.cancel()
+			return
+		}
+	}
+	atomic.AddInt64(&me.errors, 1)
+}
+
+// Returns the rollups for a directory, given those of its parent.
+func (me *scanner) rollups(rel string, parent []*Usage) []*Usage {
+	depth := 0
+	if rel != "" {
+		depth = strings.Count(rel, "/") + 1
+	}
+	if depth == 0 || depth > me.opts.Depth {
+		return parent
+	}
+	u := new(Usage)
+	me.mu.Lock()
+	me.subtrees[rel] = u
+	me.mu.Unlock()
+	return append(parent[:len(parent):len(parent)], u)
+}
+
+func (me *scanner) add(u Usage, rollups []*Usage) {
+	me.total.add(&u)
+	for _, r := range rollups {
+		r.add(&u)
+	}
+}
+
+// Scans the directory at rel, relative to the root. Subdirectories are
+// scanned in new goroutines while there are workers to spare, and in this
+// one otherwise.
+func (me *scanner) dir(rel string, rollups []*Usage) {
+	if me.ctx.Err() != nil {
+		return
+	}
+	rollups = me.rollups(rel, rollups)
+	if rel != "" {
+		me.add(Usage{Dirs: 1}, rollups)
+	}
+	path := filepath.Join(me.root, filepath.FromSlash(rel))
+	des, err := os.ReadDir(path)
+	if err != nil {
+		me.handleError(rel, err)
+	}
+	for _, de := range des {
+		childRel := de.Name()
+		if rel != "" {
+			childRel = rel + "/" + childRel
+		}
+		if de.IsDir() {
+			select {
+			case me.sem <- struct{}{}:
+				me.wg.Add(1)
+				go func() {
+					defer me.wg.Done()
+					me.dir(childRel, rollups)
+					<-me.sem
+				}()
+			default:
+				me.dir(childRel, rollups)
+			}
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			me.handleError(childRel, err)
+			continue
+		}
+		me.file(childRel, fi, rollups)
+	}
+}
+
+func (me *scanner) file(rel string, fi fs.FileInfo, rollups []*Usage) {
+	if !fi.Mode().IsRegular() {
+		return
+	}
+	size := fi.Size()
+	if me.opts.Allocated {
+		var err error
+		size, err = sparse.AllocatedSize(filepath.Join(me.root, filepath.FromSlash(rel)))
+		if err != nil {
+			me.handleError(rel, err)
+			return
+		}
+	}
+	if me.opts.OnFile != nil {
+		me.opts.OnFile(rel, fi)
+	}
+	me.add(Usage{Bytes: size, Files: 1}, rollups)
+}
@@ -0,0 +1,79 @@
+package diskusage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTree(t *testing.T, root string, files map[string]int) {
+	for name, size := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, make([]byte, size), 0o644))
+	}
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]int{
+		"a":       1,
+		"b/c":     10,
+		"b/d/e":   100,
+		"b/d/f/g": 1000,
+		"h/i":     10000,
+	})
+	for _, workers := range []int{1, 2, 8} {
+		var mu sync.Mutex
+		var seen []string
+		var progress []Usage
+		res, err := Scan(context.Background(), root, Opts{
+			Workers: workers,
+			Depth:   2,
+			OnFile: func(path string, fi fs.FileInfo) {
+				mu.Lock()
+				seen = append(seen, path)
+				mu.Unlock()
+			},
+			Progress: func(u Usage) { progress = append(progress, u) },
+		})
+		require.NoError(t, err)
+		assert.Equal(t, Usage{Bytes: 11111, Files: 5, Dirs: 4}, res.Total)
+		assert.Equal(t, map[string]Usage{
+			"b":   {Bytes: 1110, Files: 3, Dirs: 3},
+			"b/d": {Bytes: 1100, Files: 2, Dirs: 2},
+			"h":   {Bytes: 10000, Files: 1, Dirs: 1},
+		}, res.Subtrees)
+		assert.ElementsMatch(t, []string{"a", "b/c", "b/d/e", "b/d/f/g", "h/i"}, seen)
+		require.NotEmpty(t, progress)
+		assert.Equal(t, res.Total, progress[len(progress)-1])
+	}
+}
+
+func TestScanAllocated(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]int{"a": 1 << 16})
+	res, err := Scan(context.Background(), root, Opts{Allocated: true})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, res.Total.Files)
+	assert.NotZero(t, res.Total.Bytes)
+}
+
+func TestScanErrors(t *testing.T) {
+	_, err := Scan(context.Background(), filepath.Join(t.TempDir(), "missing"), Opts{})
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]int{"a/b": 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Scan(ctx, root, Opts{})
+	assert.Equal(t, context.Canceled, err)
+}
@@ -1,16 +1,21 @@
 package missinggo
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
+// An Event that's safe for concurrent use.
 type SynchronizedEvent struct {
 	mu sync.Mutex
 	e  Event
 }
 
-func (me *SynchronizedEvent) Set() {
+func (me *SynchronizedEvent) Set() (first bool) {
 	me.mu.Lock()
-	me.e.Set()
+	first = me.e.Set()
 	me.mu.Unlock()
+	return
 }
 
 func (me *SynchronizedEvent) Clear() {
@@ -19,8 +24,30 @@ func (me *SynchronizedEvent) Clear() {
 	me.mu.Unlock()
 }
 
+func (me *SynchronizedEvent) IsSet() bool {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.e.IsSet()
+}
+
 func (me *SynchronizedEvent) C() <-chan struct{} {
 	me.mu.Lock()
 	defer me.mu.Unlock()
 	return me.e.C()
 }
+
+// Returns a chan that's closed when the event is set. A later Clear doesn't
+// affect chans already returned.
+func (me *SynchronizedEvent) Done() <-chan struct{} {
+	return me.C()
+}
+
+// Waits for the event to be set, returning ctx's error if it's done first.
+func (me *SynchronizedEvent) Wait(ctx context.Context) error {
+	select {
+	case <-me.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
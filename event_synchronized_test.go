@@ -0,0 +1,26 @@
+package missinggo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynchronizedEvent(t *testing.T) {
+	var e SynchronizedEvent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, e.Wait(ctx))
+	done := e.Done()
+	go func() {
+		assert.True(t, e.Set())
+	}()
+	assert.NoError(t, e.Wait(context.Background()))
+	assert.True(t, e.IsSet())
+	assert.False(t, e.Set())
+	<-done
+	e.Clear()
+	assert.False(t, e.IsSet())
+	assert.Equal(t, context.Canceled, e.Wait(ctx))
+}
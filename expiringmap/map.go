@@ -0,0 +1,216 @@
+// Package expiringmap provides a map whose entries expire.
+package expiringmap
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// A map where each entry can have its own lifetime. Expired entries are
+// never returned, and are removed by a timer in the background. It's safe
+// for concurrent use.
+type Map[K comparable, V any] struct {
+	onEvict func(K, V)
+
+	mu      sync.Mutex
+	entries map[K]*entry[K, V]
+	// Entries with an expiry, soonest first.
+	expiries expiryHeap[K, V]
+	timer    *time.Timer
+	closed   bool
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+	// Position in the expiry heap, or -1.
+	index int
+}
+
+func (me *entry[K, V]) expired(now time.Time) bool {
+	return me.index >= 0 && !now.Before(me.expires)
+}
+
+// onEvict, if not nil, is called with entries removed due to expiry, without
+// the Map locked.
+func New[K comparable, V any](onEvict func(K, V)) *Map[K, V] {
+	return &Map[K, V]{
+		onEvict: onEvict,
+		entries: make(map[K]*entry[K, V]),
+	}
+}
+
+// Sets the value for key, replacing any existing entry. If ttl isn't
+// positive, the entry doesn't expire.
+func (me *Map[K, V]) Set(key K, value V, ttl time.Duration) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if e, ok := me.entries[key]; ok {
+		me.remove(e)
+	}
+	e := &entry[K, V]{
+		key:   key,
+		value: value,
+		index: -1,
+	}
+	me.entries[key] = e
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+		heap.Push(&me.expiries, e)
+		me.schedule()
+	}
+}
+
+func (me *Map[K, V]) Get(key K) (value V, ok bool) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	e, ok := me.entries[key]
+	if !ok || e.expired(time.Now()) {
+		ok = false
+		return
+	}
+	return e.value, true
+}
+
+// Returns when key's entry expires. ok is false if there's no entry, and the
+// time is zero if it doesn't expire.
+func (me *Map[K, V]) Expiry(key K) (expires time.Time, ok bool) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	e, ok := me.entries[key]
+	if !ok || e.expired(time.Now()) {
+		return time.Time{}, false
+	}
+	return e.expires, true
+}
+
+// Removes key's entry, without calling the eviction callback.
+func (me *Map[K, V]) Delete(key K) (ok bool) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	e, ok := me.entries[key]
+	if ok {
+		me.remove(e)
+	}
+	return
+}
+
+func (me *Map[K, V]) remove(e *entry[K, V]) {
+	delete(me.entries, e.key)
+	if e.index >= 0 {
+		heap.Remove(&me.expiries, e.index)
+	}
+}
+
+// Returns the number of entries that haven't expired.
+func (me *Map[K, V]) Len() int {
+	evicted := me.expire()
+	defer me.evicted(evicted)
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return len(me.entries)
+}
+
+// Calls fn with each entry that hasn't expired, in no particular order,
+// until it returns false. The Map is not locked during calls to fn.
+func (me *Map[K, V]) Range(fn func(K, V) bool) {
+	now := time.Now()
+	me.mu.Lock()
+	es := make([]*entry[K, V], 0, len(me.entries))
+	for _, e := range me.entries {
+		if !e.expired(now) {
+			es = append(es, e)
+		}
+	}
+	me.mu.Unlock()
+	for _, e := range es {
+		if !fn(e.key, e.value) {
+			return
+		}
+	}
+}
+
+// Removes expired entries, returning them.
+func (me *Map[K, V]) expire() (evicted []*entry[K, V]) {
+	now := time.Now()
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	for len(me.expiries) != 0 && me.expiries[0].expired(now) {
+		e := me.expiries[0]
+		me.remove(e)
+		evicted = append(evicted, e)
+	}
+	return
+}
+
+func (me *Map[K, V]) evicted(es []*entry[K, V]) {
+	if me.onEvict == nil {
+		return
+	}
+	for _, e := range es {
+		me.onEvict(e.key, e.value)
+	}
+}
+
+// Arranges for the timer to fire at the soonest expiry. Must be called with
+// the Map locked.
+func (me *Map[K, V]) schedule() {
+	if me.closed || len(me.expiries) == 0 {
+		return
+	}
+	d := time.Until(me.expiries[0].expires)
+	if me.timer == nil {
+		me.timer = time.AfterFunc(d, me.timerFired)
+	} else {
+		me.timer.Reset(d)
+	}
+}
+
+func (me *Map[K, V]) timerFired() {
+	evicted := me.expire()
+	me.mu.Lock()
+	me.schedule()
+	me.mu.Unlock()
+	me.evicted(evicted)
+}
+
+// Stops background expiry. Expired entries are still hidden.
+func (me *Map[K, V]) Close() {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.closed = true
+	if me.timer != nil {
+		me.timer.Stop()
+	}
+}
+
+type expiryHeap[K comparable, V any] []*entry[K, V]
+
+func (me expiryHeap[K, V]) Len() int { return len(me) }
+
+func (me expiryHeap[K, V]) Less(i, j int) bool {
+	return me[i].expires.Before(me[j].expires)
+}
+
+func (me expiryHeap[K, V]) Swap(i, j int) {
+	me[i], me[j] = me[j], me[i]
+	me[i].index = i
+	me[j].index = j
+}
+
+func (me *expiryHeap[K, V]) Push(x interface{}) {
+	e := x.(*entry[K, V])
+	e.index = len(*me)
+	*me = append(*me, e)
+}
+
+func (me *expiryHeap[K, V]) Pop() interface{} {
+	old := *me
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*me = old[:len(old)-1]
+	return e
+}
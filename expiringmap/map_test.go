@@ -0,0 +1,68 @@
+package expiringmap
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapExpiry(t *testing.T) {
+	evicted := make(chan string, 2)
+	m := New(func(k string, v int) {
+		evicted <- k
+	})
+	defer m.Close()
+	m.Set("forever", 1, 0)
+	m.Set("short", 2, time.Millisecond)
+	m.Set("long", 3, time.Hour)
+	assert.Equal(t, "short", <-evicted)
+	_, ok := m.Get("short")
+	assert.False(t, ok)
+	v, ok := m.Get("forever")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, m.Len())
+	var keys []string
+	m.Range(func(k string, v int) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Strings(keys)
+	assert.Equal(t, []string{"forever", "long"}, keys)
+	expires, ok := m.Expiry("forever")
+	assert.True(t, ok)
+	assert.True(t, expires.IsZero())
+}
+
+func TestMapReplaceAndDelete(t *testing.T) {
+	m := New[int, int](func(int, int) {
+		t.Error("unexpected eviction")
+	})
+	m.Set(1, 1, time.Millisecond)
+	// Replacing the entry removes its expiry.
+	m.Set(1, 2, 0)
+	m.Set(2, 2, time.Millisecond)
+	assert.True(t, m.Delete(2))
+	assert.False(t, m.Delete(2))
+	time.Sleep(5 * time.Millisecond)
+	v, ok := m.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	m.Close()
+}
+
+func TestMapLazyExpiry(t *testing.T) {
+	var evicted []int
+	m := New(func(k int, v int) {
+		evicted = append(evicted, k)
+	})
+	m.Close()
+	m.Set(1, 1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	_, ok := m.Get(1)
+	assert.False(t, ok)
+	assert.Equal(t, 0, m.Len())
+	assert.Equal(t, []int{1}, evicted)
+}
@@ -0,0 +1,71 @@
+package filecache
+
+import (
+	"time"
+)
+
+// Records an access to the item. With batching, it's only applied to the
+// item on the next flush, so the cache isn't locked.
+func (me *Cache) touch(k key) {
+	if me.accessBatchInterval <= 0 {
+		me.mu.Lock()
+		defer me.mu.Unlock()
+		me.applyAccess(k, me.clock.Now())
+		return
+	}
+	me.accessFlusherOnce.Do(me.startAccessFlusher)
+	now := me.clock.Now()
+	me.accessMu.Lock()
+	if at, ok := me.accesses[k]; !ok || now.After(at) {
+		me.accesses[k] = now
+	}
+	me.accessMu.Unlock()
+}
+
+// Updates the item's access time in place. Nothing else about the item
+// changes, so there's no need to trim, or tell subscribers.
+func (me *Cache) applyAccess(k key, at time.Time) {
+	i, ok := me.items[k]
+	if !ok || !at.After(i.Accessed) {
+		return
+	}
+	pinned := me.pins[k] != 0
+	ns := me.namespaceOf(k)
+	ns.forget(k, i, pinned)
+	i.Accessed = at
+	me.items[k] = i
+	ns.add(k, i, pinned)
+	if !pinned {
+		me.policy.Used(k, at, i.Priority)
+	}
+}
+
+// Applies the batched accesses. The cache must be locked.
+func (me *Cache) flushAccesses() {
+	if me.accessBatchInterval <= 0 {
+		return
+	}
+	me.accessMu.Lock()
+	accesses := me.accesses
+	if len(accesses) != 0 {
+		me.accesses = make(map[key]time.Time)
+	}
+	me.accessMu.Unlock()
+	for k, at := range accesses {
+		me.applyAccess(k, at)
+	}
+}
+
+// Started with the first batched access, so it uses the clock in effect
+// by then.
+func (me *Cache) startAccessFlusher() {
+	p := new(periodic)
+	me.startPeriodic(p, me.accessBatchInterval, func() {
+		me.mu.Lock()
+		defer me.mu.Unlock()
+		me.flushAccesses()
+	})
+	me.mu.Lock()
+	me.accessFlusher = p
+	me.mu.Unlock()
+}
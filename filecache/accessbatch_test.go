@@ -0,0 +1,42 @@
+package filecache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/v2/clock"
+)
+
+func TestAccessBatching(t *testing.T) {
+	c, err := NewCacheOpts(t.TempDir(), CacheOpts{AccessBatchInterval: time.Minute})
+	require.NoError(t, err)
+	defer c.Close()
+	fc := clock.NewFake(time.Unix(1000, 0))
+	c.SetClock(fc)
+	accessed := func(path string) time.Time {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.items[key(path)].Accessed
+	}
+	createItem(t, c, "a", OpenOpts{})
+	fc.Advance(time.Second)
+	createItem(t, c, "b", OpenOpts{})
+	fc.Advance(time.Second)
+	readItem(t, c, "a")
+	assert.Equal(t, time.Unix(1000, 0), accessed("a"))
+	// Accesses are applied periodically.
+	fc.Advance(time.Minute * 11 / 10)
+	assert.Equal(t, time.Unix(1002, 0), accessed("a"))
+
+	readItem(t, c, "b")
+	assert.Equal(t, time.Unix(1001, 0), accessed("b"))
+	fc.Advance(time.Second)
+	readItem(t, c, "a")
+	// And before trimming, so "a" is kept.
+	c.SetCapacity(1)
+	c.TrimToCapacity()
+	assert.Equal(t, []string{"a"}, itemPaths(c))
+}
@@ -0,0 +1,62 @@
+package filecache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anacrolix/missinggo"
+	"github.com/anacrolix/missinggo/v2/clock"
+)
+
+// Trims a cache periodically. See Cache.StartAutoTrim.
+type AutoTrim struct {
+	periodic
+}
+
+// Calls TrimToCapacity about every interval, using the cache's clock, until
+// Stop. Each wait varies by up to a tenth of the interval, so caches started
+// together don't trim in lockstep.
+func (me *Cache) StartAutoTrim(interval time.Duration) *AutoTrim {
+	if interval <= 0 {
+		panic("non-positive interval for StartAutoTrim")
+	}
+	at := new(AutoTrim)
+	me.startPeriodic(&at.periodic, interval, me.TrimToCapacity)
+	return at
+}
+
+// Runs a task on the cache's clock, with jitter, until stopped.
+type periodic struct {
+	mu      sync.Mutex
+	timer   clock.Timer
+	stopped bool
+}
+
+func (me *Cache) startPeriodic(p *periodic, interval time.Duration, f func()) {
+	me.mu.Lock()
+	cl := me.clock
+	me.mu.Unlock()
+	wait := func() time.Duration {
+		return missinggo.JitterDuration(interval, interval/10)
+	}
+	var run func()
+	run = func() {
+		f()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if !p.stopped {
+			p.timer = cl.AfterFunc(wait(), run)
+		}
+	}
+	p.mu.Lock()
+	p.timer = cl.AfterFunc(wait(), run)
+	p.mu.Unlock()
+}
+
+// Stops further runs. One in progress may still complete.
+func (me *periodic) Stop() {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.stopped = true
+	me.timer.Stop()
+}
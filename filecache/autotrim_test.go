@@ -0,0 +1,30 @@
+package filecache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoTrim(t *testing.T) {
+	c, fc := newTestCache(t)
+	for _, p := range []string{"a", "b", "c"} {
+		createItem(t, c, p, OpenOpts{})
+		fc.Advance(time.Second)
+	}
+	c.SetMaxItems(2)
+	at := c.StartAutoTrim(time.Minute)
+	fc.Advance(time.Minute * 9 / 10)
+	assert.Len(t, itemPaths(c), 3)
+	fc.Advance(time.Minute * 2 / 10)
+	assert.ElementsMatch(t, []string{"b", "c"}, itemPaths(c))
+	c.SetMaxItems(1)
+	fc.Advance(time.Minute * 11 / 10)
+	assert.Equal(t, []string{"c"}, itemPaths(c))
+	at.Stop()
+	c.SetMaxItems(0)
+	fc.Advance(time.Hour)
+	assert.Equal(t, []string{"c"}, itemPaths(c))
+	assert.Zero(t, fc.Waiters())
+}
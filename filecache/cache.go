@@ -1,16 +1,29 @@
 package filecache
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/cipher"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
-	"path"
 	"path/filepath"
 	"sync"
 	"time"
 
-	"github.com/anacrolix/missinggo/pproffd"
+	"github.com/anacrolix/missinggo"
 	"github.com/anacrolix/missinggo/resource"
+	"github.com/anacrolix/missinggo/v2/atomicfile"
+	"github.com/anacrolix/missinggo/v2/clock"
+	"github.com/anacrolix/missinggo/v2/diskspace"
+	"github.com/anacrolix/missinggo/v2/diskusage"
+	"github.com/anacrolix/missinggo/v2/orderedset"
+	"github.com/anacrolix/missinggo/v2/pathsan"
+	"github.com/anacrolix/missinggo/v2/pproffd"
+	"github.com/anacrolix/missinggo/v2/pubsub"
+	"github.com/anacrolix/missinggo/v2/sparse"
 )
 
 const (
@@ -19,79 +32,370 @@ const (
 )
 
 type Cache struct {
-	root     string
+	root string
+	// All the directories holding items, starting with root.
+	roots []string
+	// The roots of items, when there's more than one. Items not here are
+	// looked for on each root.
+	placeMu    sync.Mutex
+	placed     map[key]int
+	rootFilled []int64
+	rootItems  []int
+
+	keyLocks [numKeyLocks]keyLock
 	mu       sync.Mutex
 	capacity int64
+	maxItems int
 	filled   int64
-	policy   Policy
+	policy   *classPolicy
 	items    map[key]itemState
+	clock    clock.Clock
+
+	defaultTTL time.Duration
+	// Items younger than this aren't evicted.
+	evictionGrace time.Duration
+	// Accesses not yet applied to items, if they're batched.
+	accessBatchInterval time.Duration
+	accessMu            sync.Mutex
+	accesses            map[key]time.Time
+	accessFlusherOnce   sync.Once
+	accessFlusher       *periodic
+	// Items with a TTL, by when they expire.
+	expiring *orderedset.Set[expiry]
+
+	indexPath string
+	ready     chan struct{}
+	// Appended to with items about to change, once the index is saved.
+	journalMu sync.Mutex
+	journal   *os.File
+	// Counts of writable Files open, by item.
+	writers map[key]int
+	// Deletions of roots moved aside by Clear.
+	clearing sync.WaitGroup
+
+	// Pin counts, which can exist before the items do.
+	pins   map[key]int
+	pinned int64
+
+	namespaces map[string]*namespace
+
+	stats Stats
+
+	verifyOnOpen  bool
+	compress      bool
+	allocatedSize bool
+	dedupe        bool
+	escapeKeys    bool
+	rawKeys       bool
+	clockEviction bool
+	readOnly      bool
+	syncOnClose   SyncPolicy
+
+	// Holds a value for each open File, if they're limited.
+	fileSlots          chan struct{}
+	failAtMaxOpenFiles bool
+	openFiles          int
+	// Small items that were read recently, if enabled.
+	memory *memoryLayer
+	// Trims that found the cache over capacity, and the time they took.
+	trims    int64
+	trimTime time.Duration
+	// Encrypts items, if set.
+	aead       cipher.AEAD
+	cryptLocks missinggo.SingleFlight
+
+	// Serializes GetOrCreate fills by key.
+	fills missinggo.SingleFlight
+
+	evictHooks map[*evictHook]struct{}
+
+	errorHandler func(error)
+
+	minFreeSpace             int64
+	diskUsage                func(string) (diskspace.Usage, error)
+	freeSpaceWatcher         *diskspace.Watcher
+	removeFreeSpaceThreshold func()
+	// Resizes the capacity after SetCapacityPercent.
+	capacityPercent *periodic
+
+	// Created for the first subscriber.
+	events *pubsub.PubSub
+	// The event type for items being removed.
+	removal EventType
 }
 
 type CacheInfo struct {
 	Capacity int64
 	Filled   int64
 	NumItems int
+	// Bytes in pinned items, which are included in Filled.
+	Pinned int64
+	Stats  Stats
+	// Files opened and not yet closed. If there are more than expected,
+	// pproffd can show where they were opened.
+	OpenFiles int
+	// Bytes of items also held in memory.
+	Memory int64
+	// The fill of each root, if there's more than one.
+	Roots []RootInfo
 }
 
 type ItemInfo struct {
 	Path     key
 	Accessed time.Time
 	Size     int64
+	// Zero if the item doesn't expire.
+	Expires  time.Time
+	Pinned   bool
+	Priority int
 }
 
-// Calls the function for every item known to be in the cache.
+// Calls the function for every item known to be in the cache, including
+// those in namespaces. See WalkItemsOpts.
 func (me *Cache) WalkItems(cb func(ItemInfo)) {
-	me.mu.Lock()
-	defer me.mu.Unlock()
-	for k, ii := range me.items {
-		cb(ItemInfo{
-			Path:     k,
-			Accessed: ii.Accessed,
-			Size:     ii.Size,
-		})
+	me.WalkItemsOpts(WalkOpts{}, func(i ItemInfo) bool {
+		cb(i)
+		return true
+	})
+}
+
+func (me *Cache) itemInfo(k key, ii itemState) ItemInfo {
+	return ItemInfo{
+		Path:     k,
+		Accessed: ii.Accessed,
+		Size:     ii.Size,
+		Expires:  me.expires(ii),
+		Pinned:   me.pins[k] != 0,
+		Priority: ii.Priority,
 	}
 }
 
+// Returns the totals for the cache, including namespaces.
 func (me *Cache) Info() (ret CacheInfo) {
 	me.mu.Lock()
 	defer me.mu.Unlock()
 	ret.Capacity = me.capacity
 	ret.Filled = me.filled
 	ret.NumItems = len(me.items)
+	ret.Pinned = me.pinned
+	ret.Stats = me.stats
+	ret.OpenFiles = me.openFiles
+	if me.memory != nil {
+		ret.Memory = me.memory.filled
+	}
+	if len(me.roots) > 1 {
+		ret.Roots = me.rootInfos()
+	}
 	return
 }
 
-// Setting a negative capacity means unlimited.
+// Sets the source of access times, such as a clock.Fake for tests.
+func (me *Cache) SetClock(c clock.Clock) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.clock = c
+}
+
+// Limits the total size of the cache, including namespaces. Setting a
+// negative capacity means unlimited.
 func (me *Cache) SetCapacity(capacity int64) {
 	me.mu.Lock()
 	defer me.mu.Unlock()
+	me.stopCapacityPercent()
 	me.capacity = capacity
 }
 
+// Limits the number of items in the cache, including namespaces, as lots of
+// small items can run out of inodes before filling the capacity. Setting a
+// negative limit means unlimited.
+func (me *Cache) SetMaxItems(n int) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.maxItems = n
+}
+
+type CacheOpts struct {
+	// If set, item metadata is saved here by SaveIndex and Close, and loaded
+	// instead of scanning the root at startup. It shouldn't be inside the
+	// root.
+	IndexPath string
+	// Called periodically while scanning the root, with the totals so far.
+	OnScanProgress func(diskusage.Usage)
+	// Check items that have a checksum each time they're opened. See
+	// Verify.
+	VerifyOnOpen bool
+	// Compress items written by Put and GetOrCreate with gzip. Filled
+	// counts the compressed size, as does File.Stat. Compressed items can
+	// only be read sequentially, and replaced, not modified. Items are
+	// recognized as compressed only while this is set.
+	Compress bool
+	// If set, items are encrypted with AES-GCM using its key, which is
+	// fetched once. Filled counts the encrypted size, while Stat reports
+	// the plaintext size. All items are expected to be encrypted with the
+	// same key.
+	Keyer Keyer
+	// Account items by the disk space allocated to them, rather than their
+	// size, so sparse files and block overhead are counted as they use the
+	// disk. This costs a stat after each write.
+	AllocatedSize bool
+	// Called with errors that can't be returned to a caller, such as from
+	// scanning the root, or removing evicted items. It may be called with the
+	// cache locked, so it mustn't use the cache. By default errors are logged.
+	ErrorHandler func(error)
+	// Store the content of items written by Put and GetOrCreate once, with
+	// items of identical content hard linked to it. Filled still counts
+	// each item in full. Items are copied before they're opened for
+	// writing, if they share their content. Encrypted items are never
+	// identical, so this is no use with a Keyer. Not supported on Plan 9.
+	Dedupe bool
+	// Escape keys in the names of items' files, so that any key is usable on
+	// any OS, including names Windows reserves. An existing root needs
+	// MigrateEscapeKeys first.
+	EscapeKeys bool
+	// Approximate LRU eviction with CLOCK, which costs far less memory and
+	// time per access for caches with millions of items, but may evict
+	// items that were recently used.
+	ClockEviction bool
+	// Limits the Files open at once, including those opened internally.
+	// Opening more waits for one to be closed, or for the context of
+	// OpenFileContext. Zero means no limit.
+	MaxOpenFiles int
+	// Return ErrTooManyOpenFiles instead of waiting at MaxOpenFiles.
+	FailAtMaxOpenFiles bool
+	// Bytes of memory in which to keep small items when they're read, so
+	// they can be opened for reading again without the filesystem. Writes
+	// still go to the files. Zero disables this.
+	MemoryCapacity int64
+	// The largest item to keep in memory. Defaults to MemoryCapacity.
+	MaxMemoryItemSize int64
+	// Don't modify the root, such as to serve a prebuilt cache from an
+	// immutable volume. Writes, removals and renames return ErrReadOnly, and
+	// items aren't evicted or expired. The index isn't saved.
+	ReadOnly bool
+	// More directories to hold items, such as on other disks. New items go
+	// on the root with the most space available, and stay there. Items are
+	// keyed the same whichever root they're on. The minimum free space
+	// applies to the first root only. Dedupe isn't supported, as items
+	// can't be linked across filesystems.
+	ExtraRoots []string
+	// Records accesses without locking the cache, and applies them this
+	// often, and before trims, walks and saving the index. This cuts
+	// contention for read-heavy use, at the cost of eviction order lagging
+	// behind reads. Zero applies each access as it happens.
+	AccessBatchInterval time.Duration
+	// What closing a File opened for writing syncs, for durability across
+	// crashes. Put and GetOrCreate always sync contents, and under SyncAll
+	// also sync directories.
+	SyncOnClose SyncPolicy
+	// Use paths as keys exactly as given, rather than cleaning them, so
+	// distinct byte strings are always distinct items, and none are
+	// rejected. Items' files are named by their keys in hex, so they aren't
+	// readable in the root, and RenameDir isn't supported. EscapeKeys has no
+	// effect. An existing root can't be switched to or from this.
+	RawKeys bool
+}
+
 func NewCache(root string) (ret *Cache, err error) {
+	return NewCacheOpts(root, CacheOpts{})
+}
+
+func NewCacheOpts(root string, opts CacheOpts) (ret *Cache, err error) {
 	root, err = filepath.Abs(root)
 	ret = &Cache{
-		root:     root,
-		capacity: -1, // unlimited
+		root:         root,
+		capacity:     -1, // unlimited
+		maxItems:     -1,
+		minFreeSpace: -1,
+		removal:      ItemRemoved,
+		diskUsage:    diskspace.Get,
+		clock:        clock.Real,
+		policy:       newClassPolicy(opts.ClockEviction),
+		items:        make(map[key]itemState),
+		expiring:     newExpirySet(),
+		indexPath:    opts.IndexPath,
+		ready:        make(chan struct{}),
+		pins:         make(map[key]int),
+		writers:      make(map[key]int),
+		namespaces:   make(map[string]*namespace),
+
+		verifyOnOpen:  opts.VerifyOnOpen,
+		compress:      opts.Compress,
+		allocatedSize: opts.AllocatedSize,
+		errorHandler:  opts.ErrorHandler,
+		dedupe:        opts.Dedupe,
+		escapeKeys:    opts.EscapeKeys,
+		rawKeys:       opts.RawKeys,
+		clockEviction: opts.ClockEviction,
+		readOnly:      opts.ReadOnly,
+		syncOnClose:   opts.SyncOnClose,
+
+		accessBatchInterval: opts.AccessBatchInterval,
+		accesses:            make(map[key]time.Time),
+
+		failAtMaxOpenFiles: opts.FailAtMaxOpenFiles,
+	}
+	if opts.MaxOpenFiles > 0 {
+		ret.fileSlots = make(chan struct{}, opts.MaxOpenFiles)
+	}
+	if opts.MemoryCapacity > 0 {
+		ret.memory = newMemoryLayer(opts.MemoryCapacity, opts.MaxMemoryItemSize)
+	}
+	if opts.Dedupe && (!hardLinksSupported || len(opts.ExtraRoots) != 0) {
+		return nil, ErrDedupeUnsupported
+	}
+	ret.roots = []string{root}
+	for _, r := range opts.ExtraRoots {
+		r, err = filepath.Abs(r)
+		if err != nil {
+			return nil, err
+		}
+		ret.roots = append(ret.roots, r)
+	}
+	if len(ret.roots) > 1 {
+		ret.placed = make(map[key]int)
+		ret.rootFilled = make([]int64, len(ret.roots))
+		ret.rootItems = make([]int, len(ret.roots))
+	}
+	ret.initKeyLocks()
+	if !ret.readOnly {
+		ret.removeCleared()
+	}
+	if opts.Keyer != nil {
+		ret.aead, err = newAEAD(opts.Keyer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if ret.loadIndex() {
+		if ret.dedupe {
+			ret.sweepContent()
+		}
+		close(ret.ready)
+	} else {
+		go ret.rescan(opts.OnScanProgress)
 	}
-	ret.mu.Lock()
-	go func() {
-		defer ret.mu.Unlock()
-		ret.rescan()
-	}()
 	return
 }
 
-// An empty return path is an error.
-func sanitizePath(p string) (ret key) {
-	if p == "" {
+// Closed once the items in the root are known, after loading the index or
+// scanning. Until then, the cache works with the items found so far, and
+// those it's been asked for.
+func (me *Cache) Ready() <-chan struct{} {
+	return me.ready
+}
+
+func (me *Cache) handleError(err error) {
+	if me.errorHandler != nil {
+		me.errorHandler(err)
 		return
 	}
-	ret = key(path.Clean("/" + p))
-	if ret[0] == '/' {
-		ret = ret[1:]
-	}
-	return
+	log.Printf("filecache: %v", err)
+}
+
+// Keys are paths as cleaned by pathsan.Clean, relative to the cache root. An
+// empty return path is an error.
+func sanitizePath(p string) key {
+	return key(pathsan.Clean(p))
 }
 
 // Leaf is a descendent of root.
@@ -124,152 +428,512 @@ func pruneEmptyDirs(root string, leaf string) (err error) {
 }
 
 func (me *Cache) Remove(path string) error {
+	return me.RemoveContext(context.Background(), path)
+}
+
+// Like Remove, but gives up waiting on other operations on the item when ctx
+// is done.
+func (me *Cache) RemoveContext(ctx context.Context, path string) error {
+	key := me.keyOf(path)
+	unlock, err := me.lockKeysContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	me.mu.Lock()
+	content := me.items[key].Content
+	me.mu.Unlock()
+	if err := me.removeFile(key, content); err != nil {
+		return err
+	}
 	me.mu.Lock()
 	defer me.mu.Unlock()
-	return me.remove(sanitizePath(path))
+	me.updateItem(key, func(*itemState, bool) bool { return false })
+	return nil
 }
 
 var (
 	ErrBadPath = errors.New("bad path")
 	ErrIsDir   = errors.New("is directory")
+	ErrNotDir  = errors.New("not a directory")
+	// Returned for changes to a cache opened with CacheOpts.ReadOnly.
+	ErrReadOnly = errors.New("cache is read-only")
 )
 
 func (me *Cache) StatFile(path string) (os.FileInfo, error) {
-	return os.Stat(me.realpath(sanitizePath(path)))
+	return me.stat(me.realpath(me.keyOf(path)))
+}
+
+type OpenOpts struct {
+	// Sets the item's TTL, counting from now. Negative means the item never
+	// expires. If zero, the default TTL applies to new items, and existing
+	// ones are unchanged.
+	TTL time.Duration
+	// Sets the item's priority class, if non-zero. See SetPriority.
+	Priority int
+	// Syncs writes when the File is closed, if stronger than
+	// CacheOpts.SyncOnClose.
+	SyncOnClose SyncPolicy
 }
 
 func (me *Cache) OpenFile(path string, flag int) (ret *File, err error) {
-	key := sanitizePath(path)
-	if key == "" {
+	return me.OpenFileOpts(path, flag, OpenOpts{})
+}
+
+// Like OpenFile, with options for the item. Items that have expired, but
+// not been swept yet, are treated as missing.
+func (me *Cache) OpenFileOpts(path string, flag int, opts OpenOpts) (ret *File, err error) {
+	return me.OpenFileContext(context.Background(), path, flag, opts)
+}
+
+// Like OpenFileOpts, but gives up when ctx is done while waiting on other
+// operations on the item, such as the startup scan, or a write being
+// committed. The file system calls themselves can't be interrupted.
+func (me *Cache) OpenFileContext(ctx context.Context, path string, flag int, opts OpenOpts) (ret *File, err error) {
+	key := me.keyOf(path)
+	if key == "" && !me.rawKeys {
 		err = ErrIsDir
 		return
 	}
-	f, err := os.OpenFile(me.realpath(key), flag, filePerm)
-	if flag&os.O_CREATE != 0 && os.IsNotExist(err) {
-		// Ensure intermediate directories and try again.
-		dirErr := os.MkdirAll(filepath.Dir(me.realpath(key)), dirPerm)
-		f, err = os.OpenFile(me.realpath(key), flag, filePerm)
-		if dirErr != nil && os.IsNotExist(err) {
-			return nil, dirErr
+	if me.readOnly && flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		err = ErrReadOnly
+		return
+	}
+	ret, known, err := me.openFile(ctx, key, flag, opts)
+	if err == nil || os.IsNotExist(err) {
+		me.countOpen(key, err == nil && known)
+	}
+	return
+}
+
+func (me *Cache) countOpen(k key, hit bool) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.count(k, func(s *Stats) {
+		if hit {
+			s.Hits++
+		} else {
+			s.Misses++
 		}
+	})
+}
+
+// Opens the item, returning whether it was known beforehand. Doesn't count
+// toward the stats.
+func (me *Cache) openFile(ctx context.Context, key key, flag int, opts OpenOpts) (ret *File, known bool, err error) {
+	err = me.acquireFile(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
 		if err != nil {
-			go me.pruneEmptyDirs(key)
+			me.releaseFile()
 		}
-	}
+	}()
+	unlock, err := me.lockKeysContext(ctx, key)
 	if err != nil {
 		return
 	}
+	defer unlock()
+	me.mu.Lock()
+	if !me.readOnly && me.pins[key] == 0 && me.expired(me.items[key]) {
+		me.expire(key)
+	}
+	item, known := me.items[key]
+	me.mu.Unlock()
+	if me.verifyOnOpen && item.HasChecksum && flag&os.O_TRUNC == 0 {
+		err = me.verify(key)
+		if err != nil {
+			return
+		}
+	}
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	if writable || flag&(os.O_CREATE|os.O_TRUNC) != 0 {
+		me.journalChange(key)
+	}
+	if me.dedupe && writable {
+		err = me.unshare(key, flag&os.O_TRUNC != 0)
+		if err != nil {
+			return
+		}
+		me.releaseContent(item.Content)
+	}
+	osFlag := flag
+	if me.aead != nil && writable {
+		// Blocks are read to be rewritten, and appends are done by the File,
+		// as the OS would ignore the offsets.
+		osFlag = flag&^(os.O_WRONLY|os.O_APPEND) | os.O_RDWR
+	}
+	readOnly := !writable && flag&os.O_TRUNC == 0
+	if !readOnly {
+		me.mu.Lock()
+		me.memory.drop(key)
+		me.mu.Unlock()
+	}
+	var osf pproffd.OSFile
+	if readOnly {
+		if mf := me.openMemory(key); mf != nil {
+			osf = pproffd.WrapFile(mf)
+		}
+	}
+	if osf == nil {
+		osf, err = me.openOSFile(key, flag, osFlag, readOnly)
+		if os.IsNotExist(err) {
+			// The index may be stale.
+			me.mu.Lock()
+			me.updateItem(key, func(*itemState, bool) bool { return false })
+			me.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+	var crypt *cryptFile
+	var content io.ReaderAt = osf
+	if me.aead != nil {
+		crypt = me.newCryptFile(osf, key)
+		content = crypt
+	}
+	var gz *gzip.Reader
+	if me.compress && flag&os.O_TRUNC == 0 {
+		if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+			gz, err = openCompressed(content)
+		} else if compressed, cerr := me.fileIsCompressed(key); cerr != nil {
+			err = cerr
+		} else if compressed {
+			err = ErrCompressed
+		}
+		if err != nil {
+			osf.Close()
+			return
+		}
+	}
+	onClose := me.releaseFile
+	syncPolicy := SyncNone
+	if !readOnly {
+		syncPolicy = me.syncOnClose
+		if opts.SyncOnClose > syncPolicy {
+			syncPolicy = opts.SyncOnClose
+		}
+		me.addWriter(key)
+		onClose = func() {
+			me.removeWriter(key)
+			me.releaseFile()
+		}
+	}
 	ret = &File{
-		path: key,
-		f:    pproffd.WrapOSFile(f),
+		path:    key,
+		f:       osf,
+		gz:      gz,
+		crypt:   crypt,
+		append:  flag&os.O_APPEND != 0,
+		onClose: onClose,
+		syncDirs: func() error {
+			return me.syncDirs(key)
+		},
+		syncPolicy: syncPolicy,
 		onRead: func(n int) {
-			me.mu.Lock()
-			defer me.mu.Unlock()
-			me.updateItem(key, func(i *itemState, ok bool) bool {
-				i.Accessed = time.Now()
-				return ok
-			})
+			me.touch(key)
 		},
 		afterWrite: func(endOff int64) {
-			me.mu.Lock()
-			defer me.mu.Unlock()
-			me.updateItem(key, func(i *itemState, ok bool) bool {
-				i.Accessed = time.Now()
-				if endOff > i.Size {
-					i.Size = endOff
-				}
-				return ok
-			})
+			me.resized(key, endOff, false)
+		},
+		afterTruncate: func(size int64) {
+			me.resized(key, size, true)
 		},
 	}
+	if known && !writable && flag&os.O_TRUNC == 0 && opts == (OpenOpts{}) {
+		// Nothing changes but the access time.
+		me.touch(key)
+		return
+	}
+	var st itemState
+	if !known {
+		fi, serr := osf.Stat()
+		if serr != nil {
+			osf.Close()
+			return nil, known, serr
+		}
+		st.FromOSFileInfo(fi)
+		st.Size = me.itemSize(key, fi)
+	}
 	me.mu.Lock()
 	defer me.mu.Unlock()
 	me.updateItem(key, func(i *itemState, ok bool) bool {
+		now := me.clock.Now()
 		if !ok {
-			*i, ok = me.statKey(key)
+			if known {
+				// It was evicted since it was looked up.
+				return false
+			}
+			*i, ok = st, true
+			i.Created = now
+		} else if flag&os.O_TRUNC != 0 {
+			i.Created = now
+			i.HasChecksum = false
+			i.Size = 0
 		}
-		i.Accessed = time.Now()
+		if writable {
+			i.Content = ""
+		}
+		if opts.TTL != 0 {
+			i.Created = now
+			i.TTL = opts.TTL
+		}
+		if opts.Priority != 0 {
+			i.Priority = opts.Priority
+		}
+		i.Accessed = now
 		return ok
 	})
 	return
 }
 
-func (me *Cache) rescan() {
-	me.filled = 0
-	me.policy = new(lru)
-	me.items = make(map[key]itemState)
-	err := filepath.Walk(me.root, func(path string, info os.FileInfo, err error) error {
-		if os.IsNotExist(err) {
-			return nil
+// Accounts for a File changing the item's size. Writes only grow it to the
+// end of what was written, while truncation sets it.
+func (me *Cache) resized(k key, size int64, truncated bool) {
+	allocated, allocErr := int64(0), error(nil)
+	if me.allocatedSize {
+		allocated, allocErr = sparse.AllocatedSize(me.realpath(k))
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.memory.drop(k)
+	me.updateItem(k, func(i *itemState, ok bool) bool {
+		i.Accessed = me.clock.Now()
+		i.HasChecksum = false
+		if me.allocatedSize {
+			if allocErr == nil {
+				i.Size = allocated
+			}
+		} else if truncated || size > i.Size {
+			i.Size = size
 		}
-		if err != nil {
-			return err
+		return ok
+	})
+}
+
+// Adds the items found in the root. The lock is only held for each item, so
+// the cache can be used meanwhile.
+func (me *Cache) rescan(progress func(diskusage.Usage)) {
+	defer close(me.ready)
+	me.scanItems(progress, func(k key) { me.addFound(k) })
+	if me.dedupe {
+		me.sweepContent()
+	}
+}
+
+// Calls found concurrently with the key of each item file in the roots.
+// Progress is reported for all the roots together.
+func (me *Cache) scanItems(progress func(diskusage.Usage), found func(key)) {
+	var done diskusage.Usage
+	for i, root := range me.roots {
+		opts := diskusage.Opts{
+			OnFile: func(path string, _ os.FileInfo) {
+				if atomicfile.IsTemp(path) {
+					// Put is writing it, or crashed while doing so.
+					return
+				}
+				if isContentKey(key(path)) {
+					return
+				}
+				key, err := me.storedKey(path)
+				if err != nil {
+					me.handleError(fmt.Errorf("scanning %q: %w", path, err))
+					return
+				}
+				if !me.placeFound(key, i) {
+					me.handleError(fmt.Errorf("ignoring %q in %q, found on another root", key, root))
+					return
+				}
+				found(key)
+			},
+			OnError: func(path string, err error) error {
+				// Skip what can't be read, rather than abandoning the scan.
+				me.handleError(err)
+				return nil
+			},
 		}
-		if info.IsDir() {
-			return nil
+		if progress != nil {
+			prev := done
+			opts.Progress = func(u diskusage.Usage) {
+				u.Bytes += prev.Bytes
+				u.Files += prev.Files
+				u.Dirs += prev.Dirs
+				progress(u)
+			}
 		}
-		path, err = filepath.Rel(me.root, path)
-		if err != nil {
-			log.Print(err)
-			return nil
+		res, err := diskusage.Scan(context.Background(), root, opts)
+		if err != nil && !os.IsNotExist(err) {
+			me.handleError(fmt.Errorf("scanning %q: %w", root, err))
 		}
-		key := sanitizePath(path)
-		me.updateItem(key, func(i *itemState, ok bool) bool {
-			if ok {
-				panic("scanned duplicate items")
-			}
-			*i, ok = me.statKey(key)
-			return ok
-		})
-		return nil
+		done.Bytes += res.Total.Bytes
+		done.Files += res.Total.Files
+		done.Dirs += res.Total.Dirs
+	}
+}
+
+// Adds the item from its file if it isn't already known. Returns whether it
+// was added.
+func (me *Cache) addFound(key key) bool {
+	defer me.lockKeys(key)()
+	me.mu.Lock()
+	_, known := me.items[key]
+	me.mu.Unlock()
+	if known {
+		// Already added since the scan began.
+		return false
+	}
+	st, ok, err := me.statKey(key)
+	if err != nil {
+		me.handleError(err)
+	}
+	if !ok {
+		return false
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.updateItem(key, func(i *itemState, known bool) bool {
+		if !known {
+			*i = st
+		}
+		return true
 	})
+	return true
+}
+
+// Opens the item's file, creating intermediate directories if necessary.
+// Small items opened read-only may come from memory instead.
+func (me *Cache) openOSFile(k key, flag, osFlag int, readOnly bool) (pproffd.OSFile, error) {
+	if flag&os.O_CREATE != 0 {
+		me.place(k)
+	}
+	f, err := os.OpenFile(me.realpath(k), osFlag, filePerm)
+	if flag&os.O_CREATE != 0 && os.IsNotExist(err) {
+		// Ensure intermediate directories and try again.
+		dirErr := os.MkdirAll(filepath.Dir(me.realpath(k)), dirPerm)
+		f, err = os.OpenFile(me.realpath(k), osFlag, filePerm)
+		if dirErr != nil && os.IsNotExist(err) {
+			return nil, dirErr
+		}
+		if err != nil {
+			me.pruneEmptyDirs(k)
+		}
+	}
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	if readOnly {
+		if mf := me.loadMemory(k, f); mf != nil {
+			return pproffd.WrapFile(mf), nil
+		}
 	}
+	return pproffd.WrapOSFile(f), nil
 }
 
-func (me *Cache) statKey(k key) (i itemState, ok bool) {
+// Returns the item's state from its file, and false if it doesn't exist.
+func (me *Cache) statKey(k key) (i itemState, ok bool, err error) {
 	fi, err := os.Stat(me.realpath(k))
 	if os.IsNotExist(err) {
+		err = nil
 		return
 	}
 	if err != nil {
-		panic(err)
+		return
 	}
 	i.FromOSFileInfo(fi)
+	i.Size = me.itemSize(k, fi)
 	ok = true
 	return
 }
 
+// Returns the size to account for the item's file.
+func (me *Cache) itemSize(k key, fi os.FileInfo) int64 {
+	if me.allocatedSize {
+		if n, err := sparse.AllocatedSize(me.realpath(k)); err == nil {
+			return n
+		}
+	}
+	return fi.Size()
+}
+
 func (me *Cache) updateItem(k key, u func(*itemState, bool) bool) {
 	ii, ok := me.items[k]
+	prev := ii
+	pinned := me.pins[k] != 0
+	ns := me.namespaceOf(k)
 	me.filled -= ii.Size
-	if u(&ii, ok) {
+	if pinned {
+		me.pinned -= ii.Size
+	}
+	if ok {
+		me.fillRoot(k, -ii.Size, -1)
+		me.unexpire(k, ii)
+		ns.forget(k, ii, pinned)
+	}
+	kept := u(&ii, ok)
+	cur := ii
+	cur.Accessed = prev.Accessed
+	changed := cur != prev
+	// Only accesses leave what's in memory current.
+	if !kept || changed {
+		me.memory.drop(k)
+	}
+	me.publishItemEvent(k, prev, ok, ii, kept, changed)
+	if kept {
+		me.fillRoot(k, ii.Size, 1)
 		me.filled += ii.Size
-		me.policy.Used(k, ii.Accessed)
+		if pinned {
+			me.pinned += ii.Size
+		} else {
+			me.policy.Used(k, ii.Accessed, ii.Priority)
+		}
 		me.items[k] = ii
+		me.scheduleExpiry(k, ii)
+		ns.add(k, ii, pinned)
 	} else {
 		me.policy.Forget(k)
 		delete(me.items, k)
+		me.unplace(k)
 	}
+	me.trimNamespace(ns)
 	me.trimToCapacity()
 }
 
 func (me *Cache) realpath(path key) string {
-	return filepath.Join(me.root, filepath.FromSlash(string(path)))
+	i, _ := me.rootOf(path)
+	return me.pathIn(me.roots[i], path)
 }
 
+func (me *Cache) overCapacity() bool {
+	return me.capacity >= 0 && me.filled > me.capacity ||
+		me.maxItems >= 0 && len(me.items) > me.maxItems
+}
+
+// Evicts items until the cache, and each namespace, is within capacity, and
+// any minimum free space is available.
 func (me *Cache) TrimToCapacity() {
 	me.mu.Lock()
-	defer me.mu.Unlock()
+	for _, ns := range me.namespaces {
+		me.trimNamespace(ns)
+	}
 	me.trimToCapacity()
+	me.mu.Unlock()
+	me.trimFreeSpace()
 }
 
 func (me *Cache) pruneEmptyDirs(path key) {
-	pruneEmptyDirs(me.root, me.realpath(path))
+	i, _ := me.rootOf(path)
+	pruneEmptyDirs(me.roots[i], me.realpath(path))
 }
 
-func (me *Cache) remove(path key) error {
+// Removes the item's file, and any directories left empty, and its stored
+// content if nothing else links to it.
+func (me *Cache) removeFile(path key, content string) error {
+	if me.readOnly {
+		return ErrReadOnly
+	}
+	me.journalChange(path)
 	err := os.Remove(me.realpath(path))
 	if os.IsNotExist(err) {
 		err = nil
@@ -278,6 +942,16 @@ func (me *Cache) remove(path key) error {
 		return err
 	}
 	me.pruneEmptyDirs(path)
+	me.releaseContent(content)
+	return nil
+}
+
+// Removes the item with the cache locked, for when it's the cache that's
+// removing it, such as to evict it.
+func (me *Cache) remove(path key) error {
+	if err := me.removeFile(path, me.items[path].Content); err != nil {
+		return err
+	}
 	me.updateItem(path, func(*itemState, bool) bool {
 		return false
 	})
@@ -285,46 +959,98 @@ func (me *Cache) remove(path key) error {
 }
 
 func (me *Cache) trimToCapacity() {
-	if me.capacity < 0 {
+	if me.readOnly || !me.overCapacity() {
 		return
 	}
-	for me.filled > me.capacity {
-		me.remove(me.policy.Choose().(key))
-	}
+	defer me.countTrim(time.Now())
+	// Pinned items aren't in the policy, and may be all that's left.
+	me.trimPolicy(me.policy, me.overCapacity)
+}
+
+func (me *Cache) countTrim(started time.Time) {
+	me.trims++
+	me.trimTime += time.Since(started)
 }
 
 // TODO: Do I need this?
 func (me *Cache) pathInfo(p string) itemState {
-	return me.items[sanitizePath(p)]
+	return me.items[me.keyOf(p)]
 }
 
 func (me *Cache) Rename(from, to string) (err error) {
-	_from := sanitizePath(from)
-	_to := sanitizePath(to)
-	me.mu.Lock()
-	defer me.mu.Unlock()
-	err = os.MkdirAll(filepath.Dir(me.realpath(_to)), dirPerm)
+	return me.RenameContext(context.Background(), from, to)
+}
+
+// Like Rename, but gives up waiting on other operations on either item when
+// ctx is done.
+func (me *Cache) RenameContext(ctx context.Context, from, to string) (err error) {
+	if me.readOnly {
+		return ErrReadOnly
+	}
+	_from := me.keyOf(from)
+	_to := me.keyOf(to)
+	unlock, err := me.lockKeysContext(ctx, _from, _to)
 	if err != nil {
 		return
 	}
-	err = os.Rename(me.realpath(_from), me.realpath(_to))
+	defer unlock()
+	me.journalChange(_from, _to)
+	// Files can't be moved between roots, so the item stays on its root.
+	fromRoot, _ := me.rootOf(_from)
+	toRoot, toPlaced := me.rootOf(_to)
+	dst := me.pathIn(me.roots[fromRoot], _to)
+	err = os.MkdirAll(filepath.Dir(dst), dirPerm)
 	if err != nil {
 		return
 	}
-	// We can do a dance here to copy the state from the old item, but lets
-	// just stat the new item for now.
-	me.updateItem(_from, func(i *itemState, ok bool) bool {
-		return false
-	})
-	me.updateItem(_to, func(i *itemState, ok bool) bool {
-		*i, ok = me.statKey(_to)
+	err = os.Rename(me.realpath(_from), dst)
+	if err != nil {
+		return
+	}
+	if toPlaced && toRoot != fromRoot {
+		// The replaced item was on another root.
+		old := me.realpath(_to)
+		if err := os.Remove(old); err != nil {
+			me.handleError(fmt.Errorf("removing replaced %q: %w", _to, err))
+		}
+		pruneEmptyDirs(me.roots[toRoot], old)
+		me.mu.Lock()
+		me.updateItem(_to, func(*itemState, bool) bool { return false })
+		me.mu.Unlock()
+	}
+	me.placeOn(_to, fromRoot)
+	st, ok, err := me.statKey(_to)
+	me.mu.Lock()
+	// The item keeps its state, such as its TTL, priority and checksum.
+	// Only the size is taken from the file, in case it changed outside the
+	// cache.
+	moved, known := me.items[_from]
+	if !known {
+		moved = st
+	}
+	moved.Size = st.Size
+	moved.Accessed = me.clock.Now()
+	replaced := me.items[_to].Content
+	me.updateItem(_to, func(*itemState, bool) bool { return false })
+	me.updateItem(_from, func(*itemState, bool) bool { return false })
+	if n := me.pins[_from]; n != 0 {
+		delete(me.pins, _from)
+		me.pins[_to] += n
+	}
+	me.memory.drop(_to)
+	me.updateItem(_to, func(i *itemState, _ bool) bool {
+		*i = moved
 		return ok
 	})
+	me.mu.Unlock()
+	if replaced != moved.Content {
+		me.releaseContent(replaced)
+	}
 	return
 }
 
 func (me *Cache) Stat(path string) (os.FileInfo, error) {
-	return os.Stat(me.realpath(sanitizePath(path)))
+	return me.stat(me.realpath(me.keyOf(path)))
 }
 
 func (me *Cache) AsResourceProvider() resource.Provider {
@@ -5,13 +5,21 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bradfitz/iter"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/anacrolix/missinggo"
+	"github.com/anacrolix/missinggo/v2/clock"
+	"github.com/anacrolix/missinggo/v2/diskspace"
+	"github.com/anacrolix/missinggo/v2/diskusage"
+	"github.com/anacrolix/missinggo/v2/sparse"
 )
 
 func TestCache(t *testing.T) {
@@ -56,9 +64,11 @@ func TestCache(t *testing.T) {
 	require.NoError(t, err)
 	defer f.Close()
 	require.Equal(t, CacheInfo{
-		Filled:   0,
-		Capacity: -1,
-		NumItems: 1,
+		Filled:    0,
+		Capacity:  -1,
+		NumItems:  1,
+		Stats:     Stats{Misses: 4},
+		OpenFiles: 1,
 	}, c.Info())
 
 	c.WalkItems(func(i ItemInfo) {})
@@ -86,9 +96,11 @@ func TestCache(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, 5, n)
 	assert.EqualValues(t, CacheInfo{
-		Filled:   5,
-		Capacity: -1,
-		NumItems: 2,
+		Filled:    5,
+		Capacity:  -1,
+		NumItems:  2,
+		Stats:     Stats{Misses: 6},
+		OpenFiles: 3,
 	}, c.Info())
 	assert.False(t, c.pathInfo("b").Accessed.After(c.pathInfo("a").Accessed))
 
@@ -97,16 +109,20 @@ func TestCache(t *testing.T) {
 	a, err = c.OpenFile("a", 0)
 	require.NoError(t, err)
 	require.EqualValues(t, CacheInfo{
-		Filled:   5,
-		Capacity: -1,
-		NumItems: 2,
+		Filled:    5,
+		Capacity:  -1,
+		NumItems:  2,
+		Stats:     Stats{Hits: 1, Misses: 6},
+		OpenFiles: 3,
 	}, c.Info())
 
 	c.SetCapacity(5)
 	require.EqualValues(t, CacheInfo{
-		Filled:   5,
-		Capacity: 5,
-		NumItems: 2,
+		Filled:    5,
+		Capacity:  5,
+		NumItems:  2,
+		Stats:     Stats{Hits: 1, Misses: 6},
+		OpenFiles: 3,
 	}, c.Info())
 
 	n, err = a.WriteAt([]byte(" world"), 5)
@@ -116,9 +132,11 @@ func TestCache(t *testing.T) {
 	require.NoError(t, err)
 	require.EqualValues(t, 5, n)
 	require.EqualValues(t, CacheInfo{
-		Filled:   5,
-		Capacity: 5,
-		NumItems: 1,
+		Filled:    5,
+		Capacity:  5,
+		NumItems:  1,
+		Stats:     Stats{Hits: 1, Misses: 6, Evictions: 1, EvictedBytes: 5},
+		OpenFiles: 3,
 	}, c.Info())
 }
 
@@ -170,3 +188,236 @@ func TestFileReadWrite(t *testing.T) {
 	}
 
 }
+
+func TestCacheClock(t *testing.T) {
+	c, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+	fc := clock.NewFake(time.Unix(1000, 0))
+	c.SetClock(fc)
+	accessed := func() (ret time.Time) {
+		c.WalkItems(func(i ItemInfo) { ret = i.Accessed })
+		return
+	}
+	f, err := c.OpenFile("a", os.O_CREATE|os.O_RDWR)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, time.Unix(1000, 0), accessed())
+	fc.Advance(time.Minute)
+	_, err = f.Write([]byte("x"))
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1060, 0), accessed())
+}
+
+func TestAsyncRescan(t *testing.T) {
+	root := t.TempDir()
+	for i := range iter.N(100) {
+		p := filepath.Join(root, strconv.Itoa(i%10), strconv.Itoa(i))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte("x"), 0o644))
+	}
+	var mu sync.Mutex
+	var last diskusage.Usage
+	c, err := NewCacheOpts(root, CacheOpts{
+		OnScanProgress: func(u diskusage.Usage) {
+			mu.Lock()
+			last = u
+			mu.Unlock()
+		},
+	})
+	require.NoError(t, err)
+	// Items can be used while the scan is ongoing.
+	f, err := c.OpenFile("0/0", os.O_RDONLY)
+	require.NoError(t, err)
+	f.Close()
+	<-c.Ready()
+	info := c.Info()
+	// Whether the open was a hit depends on whether the scan got there first.
+	assert.EqualValues(t, 1, info.Stats.Hits+info.Stats.Misses)
+	info.Stats = Stats{}
+	assert.Equal(t, CacheInfo{Capacity: -1, Filled: 100, NumItems: 100}, info)
+	mu.Lock()
+	assert.EqualValues(t, 100, last.Files)
+	mu.Unlock()
+}
+
+func TestMaxItems(t *testing.T) {
+	c, fc := newTestCache(t)
+	c.SetMaxItems(2)
+	for _, p := range []string{"a", "b", "c"} {
+		createItem(t, c, p, OpenOpts{})
+		fc.Advance(time.Second)
+	}
+	assert.ElementsMatch(t, []string{"b", "c"}, itemPaths(c))
+	c.Pin("b")
+	c.SetMaxItems(1)
+	c.TrimToCapacity()
+	assert.Equal(t, []string{"b"}, itemPaths(c))
+	assert.EqualValues(t, 2, c.Info().Stats.Evictions)
+	c.Unpin("b")
+	c.SetMaxItems(-1)
+	createItem(t, c, "d", OpenOpts{})
+	assert.Equal(t, 2, c.Info().NumItems)
+}
+
+func TestMinFreeSpace(t *testing.T) {
+	c, fc := newTestCache(t)
+	defer c.Close()
+	var available int64 = 7
+	c.diskUsage = func(string) (diskspace.Usage, error) {
+		return diskspace.Usage{Available: available}, nil
+	}
+	for _, p := range []string{"a", "bb", "ccc", "dddd"} {
+		createItem(t, c, p, OpenOpts{})
+		fc.Advance(time.Second)
+	}
+	c.TrimToCapacity()
+	assert.Len(t, itemPaths(c), 4)
+	c.SetMinFreeSpace(10)
+	c.Pin("a")
+	c.TrimToCapacity()
+	assert.ElementsMatch(t, []string{"a", "dddd"}, itemPaths(c))
+	c.Unpin("a")
+	available = 10
+	c.TrimToCapacity()
+	assert.Len(t, itemPaths(c), 2)
+	available = 0
+	c.SetMinFreeSpace(-1)
+	c.TrimToCapacity()
+	assert.Len(t, itemPaths(c), 2)
+}
+
+func TestEvictError(t *testing.T) {
+	var errs []error
+	c, err := NewCacheOpts(t.TempDir(), CacheOpts{
+		ErrorHandler: func(err error) { errs = append(errs, err) },
+	})
+	require.NoError(t, err)
+	<-c.Ready()
+	createItem(t, c, "a", OpenOpts{})
+	createItem(t, c, "b", OpenOpts{})
+	// Replace the item with something that can't be removed.
+	p := filepath.Join(c.root, "a")
+	require.NoError(t, os.Remove(p))
+	require.NoError(t, os.MkdirAll(filepath.Join(p, "x"), dirPerm))
+	c.SetCapacity(0)
+	c.TrimToCapacity()
+	assert.Empty(t, itemPaths(c))
+	assert.EqualValues(t, 0, c.Info().Filled)
+	assert.EqualValues(t, 1, c.Info().Stats.Evictions)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), `evicting "a"`)
+}
+
+func TestEmptyWriteAt(t *testing.T) {
+	c, _ := newTestCache(t)
+	f, err := c.OpenFile("a", os.O_CREATE|os.O_WRONLY)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteAt(nil, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, c.Info().Filled)
+}
+
+func TestFileSizeChanges(t *testing.T) {
+	c, _ := newTestCache(t)
+	createItem(t, c, "aaaa", OpenOpts{})
+	assert.EqualValues(t, 4, c.Info().Filled)
+	// Truncating on open shrinks the item before anything is written.
+	f, err := c.OpenFile("aaaa", os.O_WRONLY|os.O_TRUNC)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.EqualValues(t, 0, c.Info().Filled)
+	_, err = f.Write([]byte("ab"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, c.Info().Filled)
+	require.NoError(t, f.Truncate(10))
+	assert.EqualValues(t, 10, c.Info().Filled)
+	require.NoError(t, f.Truncate(1))
+	assert.EqualValues(t, 1, c.Info().Filled)
+	assert.Equal(t, "a", readItem(t, c, "aaaa"))
+}
+
+func TestFileAppendSize(t *testing.T) {
+	c, _ := newTestCache(t)
+	createItem(t, c, "aaaa", OpenOpts{})
+	f, err := c.OpenFile("aaaa", os.O_WRONLY|os.O_APPEND)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write([]byte("bbbb"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 8, c.Info().Filled)
+	assert.Equal(t, "aaaabbbb", readItem(t, c, "aaaa"))
+}
+
+func TestFileReadThenWriteSize(t *testing.T) {
+	c, _ := newTestCache(t)
+	createItem(t, c, "aaaa", OpenOpts{})
+	f, err := c.OpenFile("aaaa", os.O_RDWR)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = io.ReadFull(f, make([]byte, 4))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("bbbbbb"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, c.Info().Filled)
+	assert.Equal(t, "aaaabbbbbb", readItem(t, c, "aaaa"))
+}
+
+func TestRenameKeepsState(t *testing.T) {
+	c, fc := newTestCache(t)
+	require.NoError(t, c.PutOpts("a", strings.NewReader("hello"), OpenOpts{
+		TTL:      time.Hour,
+		Priority: 5,
+	}))
+	expires := fc.Now().Add(time.Hour)
+	c.Pin("a")
+	fc.Advance(time.Minute)
+	require.NoError(t, c.Rename("a", "b"))
+	infos := sortedItemInfos(c)
+	require.Len(t, infos, 1)
+	assert.Equal(t, ItemInfo{
+		Path:     "b",
+		Accessed: fc.Now(),
+		Size:     5,
+		Expires:  expires,
+		Pinned:   true,
+		Priority: 5,
+	}, infos[0])
+	assert.EqualValues(t, 5, c.Info().Pinned)
+	// The checksum moved with the item.
+	corruptItem(t, c, "b")
+	assert.Equal(t, ErrCorrupt, c.Verify("b"))
+
+	require.NoError(t, c.Put("c", strings.NewReader("c")))
+	require.NoError(t, c.PutOpts("d", strings.NewReader("d"), OpenOpts{TTL: time.Hour}))
+	require.NoError(t, c.Rename("d", "e"))
+	fc.Advance(time.Hour)
+	assert.Equal(t, 1, c.RemoveExpired())
+	assert.Equal(t, []string{"c"}, itemPaths(c))
+}
+
+func TestAllocatedSize(t *testing.T) {
+	for _, allocated := range []bool{false, true} {
+		c, err := NewCacheOpts(t.TempDir(), CacheOpts{AllocatedSize: allocated})
+		require.NoError(t, err)
+		<-c.Ready()
+		f, err := c.OpenFile("sparse", os.O_CREATE|os.O_WRONLY)
+		require.NoError(t, err)
+		// Leaves a hole before the byte, where the filesystem supports it.
+		_, err = f.WriteAt([]byte{1}, 10<<20)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		want := int64(10<<20 + 1)
+		if allocated {
+			want, err = sparse.AllocatedSize(c.realpath("sparse"))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, want, c.Info().Filled, allocated)
+
+		// The same applies to items found on disk.
+		c, err = NewCacheOpts(c.root, CacheOpts{AllocatedSize: allocated})
+		require.NoError(t, err)
+		<-c.Ready()
+		assert.Equal(t, want, c.Info().Filled, allocated)
+	}
+}
@@ -0,0 +1,67 @@
+package filecache
+
+import (
+	"fmt"
+	"time"
+)
+
+// How often the filesystem size is checked for SetCapacityPercent.
+const capacityPercentInterval = time.Minute
+
+// Sets the capacity to the given percentage of the size of the filesystem
+// holding the root, or the sum of them if there are several roots. The size
+// is checked again about every minute in case the filesystem is resized,
+// until SetCapacity or Close. If a later check fails, the error is handled
+// and the capacity is left as it was.
+func (me *Cache) SetCapacityPercent(percent float64) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("capacity percent %v out of range", percent)
+	}
+	p := new(periodic)
+	me.mu.Lock()
+	me.stopCapacityPercent()
+	me.capacityPercent = p
+	me.mu.Unlock()
+	if err := me.applyCapacityPercent(p, percent); err != nil {
+		me.mu.Lock()
+		if me.capacityPercent == p {
+			me.capacityPercent = nil
+		}
+		me.mu.Unlock()
+		return err
+	}
+	me.startPeriodic(p, capacityPercentInterval, func() {
+		if err := me.applyCapacityPercent(p, percent); err != nil {
+			me.handleError(err)
+		}
+	})
+	return nil
+}
+
+func (me *Cache) applyCapacityPercent(p *periodic, percent float64) error {
+	var total int64
+	for _, root := range me.roots {
+		u, err := me.diskUsage(root)
+		if err != nil {
+			return fmt.Errorf("checking filesystem size: %w", err)
+		}
+		total += u.Total
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	// Another capacity may have been set meanwhile.
+	if me.capacityPercent == p {
+		me.capacity = int64(float64(total) * percent / 100)
+	}
+	return nil
+}
+
+// Stops a SetCapacityPercent from resizing the capacity. The cache must be
+// locked.
+func (me *Cache) stopCapacityPercent() {
+	if me.capacityPercent == nil {
+		return
+	}
+	me.capacityPercent.Stop()
+	me.capacityPercent = nil
+}
@@ -0,0 +1,41 @@
+package filecache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/v2/diskspace"
+)
+
+func TestCapacityPercent(t *testing.T) {
+	c, fc := newTestCache(t)
+	defer c.Close()
+	var total int64 = 1000
+	var err, handled error
+	c.diskUsage = func(string) (diskspace.Usage, error) {
+		return diskspace.Usage{Total: total}, err
+	}
+	c.errorHandler = func(err error) { handled = err }
+	assert.Error(t, c.SetCapacityPercent(101))
+	require.NoError(t, c.SetCapacityPercent(10))
+	assert.EqualValues(t, 100, c.Info().Capacity)
+	// The filesystem is resized.
+	total = 2000
+	fc.Advance(time.Minute * 11 / 10)
+	assert.EqualValues(t, 200, c.Info().Capacity)
+	// Failed checks keep the capacity.
+	err = errors.New("boom")
+	fc.Advance(time.Minute * 11 / 10)
+	assert.EqualValues(t, 200, c.Info().Capacity)
+	assert.True(t, errors.Is(handled, err), handled)
+	err = nil
+	c.SetCapacity(50)
+	total = 4000
+	fc.Advance(time.Hour)
+	assert.EqualValues(t, 50, c.Info().Capacity)
+	assert.Zero(t, fc.Waiters())
+}
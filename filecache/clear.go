@@ -0,0 +1,88 @@
+package filecache
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// Removes all the items at once, along with anything else in the roots.
+// Each root is renamed aside and recreated empty, and the old one deleted in
+// the background, so this doesn't wait on the filesystem for each item.
+// Where a root can't be renamed, such as a mount point, its contents are
+// deleted in place. Pins are kept. Close waits for the deletions.
+func (me *Cache) Clear() error {
+	if me.readOnly {
+		return ErrReadOnly
+	}
+	<-me.ready
+	defer me.lockAllKeys()()
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	keys := make([]key, 0, len(me.items))
+	roots := make([]int, 0, len(me.items))
+	for k := range me.items {
+		i, _ := me.rootOf(k)
+		keys = append(keys, k)
+		roots = append(roots, i)
+	}
+	me.journalChange(keys...)
+	cleared := make([]bool, len(me.roots))
+	var err error
+	for i, root := range me.roots {
+		if err = me.clearRoot(root); err != nil {
+			err = fmt.Errorf("clearing %q: %w", root, err)
+			break
+		}
+		cleared[i] = true
+	}
+	for j, k := range keys {
+		if !cleared[roots[j]] {
+			continue
+		}
+		me.updateItem(k, func(*itemState, bool) bool { return false })
+	}
+	return err
+}
+
+func (me *Cache) clearRoot(root string) error {
+	aside := fmt.Sprintf("%s.%d.cleared", root, rand.Uint32())
+	if os.Rename(root, aside) == nil {
+		me.removeInBackground(aside)
+		return os.MkdirAll(root, dirPerm)
+	}
+	des, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, de := range des {
+		if err := os.RemoveAll(filepath.Join(root, de.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (me *Cache) removeInBackground(path string) {
+	me.clearing.Add(1)
+	go func() {
+		defer me.clearing.Done()
+		if err := os.RemoveAll(path); err != nil {
+			me.handleError(fmt.Errorf("removing cleared root: %w", err))
+		}
+	}()
+}
+
+// Resumes deleting roots that were cleared before the process stopped.
+func (me *Cache) removeCleared() {
+	for _, root := range me.roots {
+		matches, _ := filepath.Glob(root + ".*.cleared")
+		for _, m := range matches {
+			me.removeInBackground(m)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClear(t *testing.T) {
+	td := t.TempDir()
+	root := filepath.Join(td, "root")
+	c, err := NewCache(root)
+	require.NoError(t, err)
+	<-c.Ready()
+	createItem(t, c, "a", OpenOpts{})
+	createItem(t, c, "dir/b", OpenOpts{})
+	c.Pin("a")
+	require.NoError(t, c.Clear())
+	info := c.Info()
+	assert.EqualValues(t, 0, info.Filled)
+	assert.EqualValues(t, 0, info.Pinned)
+	assert.Equal(t, 0, info.NumItems)
+	des, err := os.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, des)
+
+	// The pin still applies to new items.
+	createItem(t, c, "a", OpenOpts{})
+	assert.EqualValues(t, 1, c.Info().Pinned)
+	assert.Equal(t, "a", readItem(t, c, "a"))
+	require.NoError(t, c.Close())
+	matches, err := filepath.Glob(root + ".*.cleared")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestClearLeftovers(t *testing.T) {
+	td := t.TempDir()
+	root := filepath.Join(td, "root")
+	leftover := root + ".1.cleared"
+	require.NoError(t, os.MkdirAll(filepath.Join(leftover, "dir"), 0o755))
+	c, err := NewCache(root)
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+	_, err = os.Stat(leftover)
+	assert.True(t, os.IsNotExist(err))
+}
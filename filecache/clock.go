@@ -0,0 +1,68 @@
+package filecache
+
+import "time"
+
+// Approximates LRU with the CLOCK algorithm. Uses only set a flag, rather
+// than reordering, so they're cheap, and there's no ordered structure to
+// keep. Access times are ignored, so items loaded at startup start out in
+// no particular order.
+type clockPolicy struct {
+	entries []clockEntry
+	index   map[policyItemKey]int
+	hand    int
+}
+
+type clockEntry struct {
+	item       policyItemKey
+	referenced bool
+}
+
+var _ Policy = (*clockPolicy)(nil)
+
+func (me *clockPolicy) Choose() policyItemKey {
+	if len(me.entries) == 0 {
+		panic("cache empty")
+	}
+	for {
+		e := &me.entries[me.hand]
+		if !e.referenced {
+			return e.item
+		}
+		e.referenced = false
+		me.hand = (me.hand + 1) % len(me.entries)
+	}
+}
+
+func (me *clockPolicy) Used(k policyItemKey, _ time.Time) {
+	if i, ok := me.index[k]; ok {
+		me.entries[i].referenced = true
+		return
+	}
+	if me.index == nil {
+		me.index = make(map[policyItemKey]int)
+	}
+	me.index[k] = len(me.entries)
+	me.entries = append(me.entries, clockEntry{k, true})
+}
+
+func (me *clockPolicy) Forget(k policyItemKey) {
+	i, ok := me.index[k]
+	if !ok {
+		return
+	}
+	delete(me.index, k)
+	last := len(me.entries) - 1
+	if i != last {
+		me.entries[i] = me.entries[last]
+		me.index[me.entries[i].item] = i
+	}
+	me.entries[last] = clockEntry{}
+	me.entries = me.entries[:last]
+	if me.hand >= len(me.entries) {
+		me.hand = 0
+	}
+}
+
+func (me *clockPolicy) NumItems() int {
+	return len(me.entries)
+}
@@ -0,0 +1,41 @@
+package filecache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock(t *testing.T) {
+	testPolicy(t, &clockPolicy{})
+	var p clockPolicy
+	for _, k := range []key{"a", "b", "c"} {
+		p.Used(k, time.Time{})
+	}
+	// Everything has been used since the hand last passed, so it goes
+	// around once.
+	assert.Equal(t, key("a"), p.Choose())
+	p.Used(key("a"), time.Time{})
+	assert.Equal(t, key("b"), p.Choose())
+	p.Forget(key("b"))
+	assert.Equal(t, key("c"), p.Choose())
+	p.Forget(key("c"))
+	assert.Equal(t, key("a"), p.Choose())
+	assert.Equal(t, 1, p.NumItems())
+}
+
+func TestClockEviction(t *testing.T) {
+	c, err := NewCacheOpts(t.TempDir(), CacheOpts{ClockEviction: true})
+	require.NoError(t, err)
+	<-c.Ready()
+	c.SetMaxItems(2)
+	for _, p := range []string{"a", "b", "c", "d"} {
+		createItem(t, c, p, OpenOpts{})
+	}
+	assert.Len(t, itemPaths(c), 2)
+	assert.EqualValues(t, 2, c.Info().Stats.Evictions)
+	createNamespaceItem(t, c.Namespace("ns"), "e", 1)
+	assert.Len(t, itemPaths(c), 2)
+}
@@ -0,0 +1,69 @@
+package filecache
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// Marks items compressed by the cache, so other gzip files are left alone.
+const gzipComment = "filecache"
+
+var (
+	ErrCompressed  = errors.New("item is compressed and can't be modified")
+	ErrNotSeekable = errors.New("compressed item can only be read sequentially")
+)
+
+// Compresses what fill writes to w.
+func compressFill(fill func(io.Writer) error) func(io.Writer) error {
+	return func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		gz.Comment = gzipComment
+		if err := fill(gz); err != nil {
+			return err
+		}
+		return gz.Close()
+	}
+}
+
+// Returns whether r holds an item compressed by the cache.
+func isCompressed(r io.ReaderAt) (bool, error) {
+	// The magic number, and the flag for a comment.
+	var b [4]byte
+	_, err := r.ReadAt(b[:], 0)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if b[0] != 0x1f || b[1] != 0x8b || b[3]&0x10 == 0 {
+		return false, nil
+	}
+	gz, err := gzip.NewReader(io.NewSectionReader(r, 0, math.MaxInt64))
+	return err == nil && gz.Comment == gzipComment, nil
+}
+
+// For items opened without read access.
+func (me *Cache) fileIsCompressed(k key) (bool, error) {
+	f, err := os.Open(me.realpath(k))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if me.aead != nil {
+		return isCompressed(me.newCryptFile(f, k))
+	}
+	return isCompressed(f)
+}
+
+// Returns a decompressing reader if r holds an item compressed by the cache.
+func openCompressed(r io.ReaderAt) (*gzip.Reader, error) {
+	ok, err := isCompressed(r)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return gzip.NewReader(io.NewSectionReader(r, 0, math.MaxInt64))
+}
@@ -0,0 +1,67 @@
+package filecache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompress(t *testing.T) {
+	root := t.TempDir()
+	c, err := NewCacheOpts(root, CacheOpts{Compress: true})
+	require.NoError(t, err)
+	content := bytes.Repeat([]byte("compressible "), 1000)
+	require.NoError(t, c.Put("a", bytes.NewReader(content)))
+	filled := c.Info().Filled
+	assert.True(t, filled < int64(len(content)), filled)
+	fi, err := os.Stat(filepath.Join(root, "a"))
+	require.NoError(t, err)
+	assert.Equal(t, fi.Size(), filled)
+	assert.Equal(t, string(content), readItem(t, c, "a"))
+	assert.NoError(t, c.Verify("a"))
+
+	f, err := c.OpenFile("a", os.O_RDONLY)
+	require.NoError(t, err)
+	_, err = f.ReadAt(make([]byte, 1), 0)
+	assert.Equal(t, ErrNotSeekable, err)
+	_, err = f.Seek(0, io.SeekEnd)
+	assert.Equal(t, ErrNotSeekable, err)
+	f.Close()
+	_, err = c.OpenFile("a", os.O_RDWR)
+	assert.Equal(t, ErrCompressed, err)
+	_, err = c.OpenFile("a", os.O_WRONLY)
+	assert.Equal(t, ErrCompressed, err)
+
+	// Items written through OpenFile aren't compressed, even if they're gzip.
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(content)
+	w.Close()
+	f, err = c.OpenFile("b", os.O_CREATE|os.O_WRONLY)
+	require.NoError(t, err)
+	_, err = f.Write(gz.Bytes())
+	require.NoError(t, err)
+	f.Close()
+	assert.Equal(t, gz.String(), readItem(t, c, "b"))
+
+	// Truncating replaces a compressed item with an uncompressed one.
+	f, err = c.OpenFile("a", os.O_WRONLY|os.O_TRUNC)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("plain"))
+	require.NoError(t, err)
+	f.Close()
+	assert.Equal(t, "plain", readItem(t, c, "a"))
+
+	// Without the option, compressed items are read as they're stored.
+	require.NoError(t, c.Put("c", bytes.NewReader(content)))
+	c, err = NewCache(root)
+	require.NoError(t, err)
+	<-c.Ready()
+	assert.NotEqual(t, string(content), readItem(t, c, "c"))
+}
@@ -0,0 +1,401 @@
+package filecache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/anacrolix/missinggo"
+)
+
+// Plaintext bytes per encrypted block. Each block is stored with its own
+// nonce and tag, so items can still be read and written at offsets.
+const cryptBlockSize = 64 << 10
+
+// Encrypted files start with a random ID, which their blocks are bound to,
+// so blocks can't be moved between files. New items start with no bytes at
+// all, and get an ID when first written.
+const cryptIDSize = 16
+
+// Supplies the key for encrypting items at rest.
+type Keyer interface {
+	// Returns an AES key, of 16, 24 or 32 bytes.
+	Key() ([]byte, error)
+}
+
+// A Keyer for a fixed key.
+type StaticKey []byte
+
+func (me StaticKey) Key() ([]byte, error) {
+	return me, nil
+}
+
+func newAEAD(k Keyer) (cipher.AEAD, error) {
+	key, err := k.Key()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func cryptOverhead(aead cipher.AEAD) int64 {
+	return int64(aead.NonceSize() + aead.Overhead())
+}
+
+// Returns the plaintext size of an encrypted file of size n.
+func plainSize(aead cipher.AEAD, n int64) int64 {
+	if n <= cryptIDSize {
+		return 0
+	}
+	n -= cryptIDSize
+	o := cryptOverhead(aead)
+	full, rem := n/(cryptBlockSize+o), n%(cryptBlockSize+o)
+	n = full * cryptBlockSize
+	if rem > o {
+		n += rem - o
+	}
+	return n
+}
+
+// Returns the size of the encrypted file for n bytes of plaintext.
+func encryptedSize(aead cipher.AEAD, n int64) int64 {
+	if n == 0 {
+		return 0
+	}
+	o := cryptOverhead(aead)
+	full, rem := n/cryptBlockSize, n%cryptBlockSize
+	n = cryptIDSize + full*(cryptBlockSize+o)
+	if rem != 0 {
+		n += rem + o
+	}
+	return n
+}
+
+// Returns the offset of block i in an encrypted file.
+func blockOffset(aead cipher.AEAD, i int64) int64 {
+	return cryptIDSize + i*(cryptBlockSize+cryptOverhead(aead))
+}
+
+// Returns how many blocks an encrypted file of size n has.
+func numBlocks(aead cipher.AEAD, n int64) int64 {
+	if n <= cryptIDSize {
+		return 0
+	}
+	bs := cryptBlockSize + cryptOverhead(aead)
+	return (n - cryptIDSize + bs - 1) / bs
+}
+
+func newCryptID() []byte {
+	id := make([]byte, cryptIDSize)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// Blocks are bound to their file and position, so they can't be moved or
+// reordered. The last block is marked, so cutting off whole blocks is
+// detected too.
+func blockAD(id []byte, i int64, final bool) []byte {
+	b := make([]byte, len(id)+9)
+	copy(b, id)
+	binary.BigEndian.PutUint64(b[len(id):], uint64(i))
+	if final {
+		b[len(b)-1] = 1
+	}
+	return b
+}
+
+func sealBlock(aead cipher.AEAD, id []byte, i int64, final bool, plain []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic(err)
+	}
+	return aead.Seal(nonce, nonce, plain, blockAD(id, i, final))
+}
+
+func openBlock(aead cipher.AEAD, id []byte, i int64, final bool, b []byte) ([]byte, error) {
+	ns := aead.NonceSize()
+	if len(b) < ns+aead.Overhead() {
+		return nil, ErrCorrupt
+	}
+	plain, err := aead.Open(nil, b[:ns], b[ns:], blockAD(id, i, final))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return plain, nil
+}
+
+// Encrypts what fill writes.
+func encryptFill(aead cipher.AEAD, fill func(io.Writer) error) func(io.Writer) error {
+	return func(w io.Writer) error {
+		cw := &cryptWriter{w: w, aead: aead}
+		if err := fill(cw); err != nil {
+			return err
+		}
+		return cw.flush()
+	}
+}
+
+// Encrypts a whole item as it's written. A full block is held until more
+// follows, as it's only known to be the last when flushed.
+type cryptWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+	id   []byte
+	buf  []byte
+	i    int64
+}
+
+func (me *cryptWriter) Write(b []byte) (n int, err error) {
+	for len(b) != 0 {
+		if len(me.buf) == cryptBlockSize {
+			if err = me.writeBlock(false); err != nil {
+				return
+			}
+		}
+		m := cryptBlockSize - len(me.buf)
+		if m > len(b) {
+			m = len(b)
+		}
+		me.buf = append(me.buf, b[:m]...)
+		b = b[m:]
+		n += m
+	}
+	return
+}
+
+func (me *cryptWriter) writeBlock(final bool) error {
+	if me.id == nil {
+		me.id = newCryptID()
+		if _, err := me.w.Write(me.id); err != nil {
+			return err
+		}
+	}
+	_, err := me.w.Write(sealBlock(me.aead, me.id, me.i, final, me.buf))
+	me.buf = me.buf[:0]
+	me.i++
+	return err
+}
+
+// Writes the last block. Nothing is written for an empty item.
+func (me *cryptWriter) flush() error {
+	if len(me.buf) == 0 {
+		return nil
+	}
+	return me.writeBlock(true)
+}
+
+type cryptBacking interface {
+	io.ReaderAt
+	io.WriterAt
+	Stat() (os.FileInfo, error)
+}
+
+// Reads and writes the plaintext of an encrypted file at offsets.
+type cryptFile struct {
+	f    cryptBacking
+	aead cipher.AEAD
+	// Blocks are rewritten in place, so access to the item is serialized.
+	locks *missinggo.SingleFlight
+	key   key
+}
+
+func (me *Cache) newCryptFile(f cryptBacking, k key) *cryptFile {
+	return &cryptFile{
+		f:     f,
+		aead:  me.aead,
+		locks: &me.cryptLocks,
+		key:   k,
+	}
+}
+
+func (me *cryptFile) Size() (int64, error) {
+	fi, err := me.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return plainSize(me.aead, fi.Size()), nil
+}
+
+// Returns the file's ID, which is nil if it's empty, and its size on disk.
+func (me *cryptFile) header() (id []byte, size int64, err error) {
+	fi, err := me.f.Stat()
+	if err != nil {
+		return
+	}
+	size = fi.Size()
+	if size == 0 {
+		return
+	}
+	if size < cryptIDSize+cryptOverhead(me.aead) {
+		// There must be at least one block.
+		return nil, size, ErrCorrupt
+	}
+	id = make([]byte, cryptIDSize)
+	_, err = me.f.ReadAt(id, 0)
+	return
+}
+
+// Returns io.EOF if the block doesn't exist. size is the file's size on
+// disk, from which the last block is known.
+func (me *cryptFile) readBlock(id []byte, size, i int64) ([]byte, error) {
+	n := numBlocks(me.aead, size)
+	if i >= n {
+		return nil, io.EOF
+	}
+	b := make([]byte, cryptBlockSize+cryptOverhead(me.aead))
+	m, err := me.f.ReadAt(b, blockOffset(me.aead, i))
+	if err == io.EOF && m != 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openBlock(me.aead, id, i, i == n-1, b[:m])
+}
+
+func (me *cryptFile) ReadAt(b []byte, off int64) (n int, err error) {
+	op := me.locks.Lock(string(me.key))
+	defer op.Unlock()
+	id, size, err := me.header()
+	if err != nil {
+		return
+	}
+	for len(b) != 0 {
+		var plain []byte
+		plain, err = me.readBlock(id, size, off/cryptBlockSize)
+		if err != nil {
+			return
+		}
+		o := int(off % cryptBlockSize)
+		if o >= len(plain) {
+			return n, io.EOF
+		}
+		m := copy(b, plain[o:])
+		n += m
+		b = b[m:]
+		off += int64(m)
+	}
+	return
+}
+
+// Only truncating to zero is supported, as a shortened last block would have
+// to be encrypted again.
+func (me *cryptFile) Truncate(size int64) error {
+	if size != 0 {
+		return ErrEncryptedTruncate
+	}
+	t, ok := me.f.(truncater)
+	if !ok {
+		// Served from memory.
+		return &os.PathError{Op: "truncate", Path: string(me.key), Err: os.ErrPermission}
+	}
+	op := me.locks.Lock(string(me.key))
+	defer op.Unlock()
+	return t.Truncate(0)
+}
+
+func (me *cryptFile) WriteAt(b []byte, off int64) (n int, err error) {
+	if len(b) == 0 {
+		return
+	}
+	op := me.locks.Lock(string(me.key))
+	defer op.Unlock()
+	id, diskSize, err := me.header()
+	if err != nil {
+		return
+	}
+	if id == nil {
+		id = newCryptID()
+		if _, err = me.f.WriteAt(id, 0); err != nil {
+			return
+		}
+	}
+	size := plainSize(me.aead, diskSize)
+	end := off + int64(len(b))
+	newSize := size
+	if end > newSize {
+		newSize = end
+	}
+	last := (newSize - 1) / cryptBlockSize
+	// Any gap after the current end is filled with zeroes.
+	first := off / cryptBlockSize
+	if size < off {
+		first = size / cryptBlockSize
+	}
+	// The old last block is no longer the last if the file grows past it.
+	if end > size && size != 0 && (size-1)/cryptBlockSize < first {
+		first = (size - 1) / cryptBlockSize
+	}
+	for i := first; i*cryptBlockSize < end; i++ {
+		start := i * cryptBlockSize
+		var plain []byte
+		if start < size {
+			plain, err = me.readBlock(id, diskSize, i)
+			if err != nil {
+				return
+			}
+		}
+		want := end - start
+		if want > cryptBlockSize {
+			want = cryptBlockSize
+		}
+		if int64(len(plain)) < want {
+			plain = append(plain, make([]byte, want-int64(len(plain)))...)
+		}
+		if off < start+cryptBlockSize && start < end {
+			lo := off
+			if lo < start {
+				lo = start
+			}
+			copy(plain[lo-start:], b[lo-off:])
+		}
+		_, err = me.f.WriteAt(sealBlock(me.aead, id, i, i == last, plain), blockOffset(me.aead, i))
+		if err != nil {
+			return
+		}
+	}
+	return len(b), nil
+}
+
+// Reports the plaintext size of an encrypted file.
+type plainFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (me plainFileInfo) Size() int64 {
+	return me.size
+}
+
+// Stats the file, reporting the plaintext size if items are encrypted.
+func (me *Cache) stat(name string) (os.FileInfo, error) {
+	fi, err := os.Stat(name)
+	if err != nil || me.aead == nil || fi.IsDir() {
+		return fi, err
+	}
+	return plainFileInfo{fi, plainSize(me.aead, fi.Size())}, nil
+}
+
+// Reports plaintext sizes for encrypted items.
+type plainDirEntry struct {
+	fs.DirEntry
+	aead cipher.AEAD
+}
+
+func (me plainDirEntry) Info() (fs.FileInfo, error) {
+	fi, err := me.DirEntry.Info()
+	if err != nil || fi.IsDir() {
+		return fi, err
+	}
+	return plainFileInfo{fi, plainSize(me.aead, fi.Size())}, nil
+}
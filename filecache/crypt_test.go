@@ -0,0 +1,192 @@
+package filecache
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCryptCache(t *testing.T, root string, opts CacheOpts) *Cache {
+	opts.Keyer = StaticKey(bytes.Repeat([]byte{1}, 32))
+	c, err := NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	<-c.Ready()
+	return c
+}
+
+func TestEncryption(t *testing.T) {
+	root := t.TempDir()
+	c := newCryptCache(t, root, CacheOpts{})
+	content := make([]byte, 2*cryptBlockSize+100)
+	rand.New(rand.NewSource(1)).Read(content)
+	require.NoError(t, c.Put("a", bytes.NewReader(content)))
+	assert.Equal(t, string(content), readItem(t, c, "a"))
+	onDisk, err := os.ReadFile(filepath.Join(root, "a"))
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(onDisk, content[:64]))
+	assert.EqualValues(t, len(onDisk), c.Info().Filled)
+	fi, err := c.Stat("a")
+	require.NoError(t, err)
+	assert.EqualValues(t, len(content), fi.Size())
+	assert.NoError(t, c.Verify("a"))
+
+	// Another key can't read it.
+	c2, err := NewCacheOpts(root, CacheOpts{Keyer: StaticKey(bytes.Repeat([]byte{2}, 32))})
+	require.NoError(t, err)
+	f, err := c2.OpenFile("a", os.O_RDONLY)
+	require.NoError(t, err)
+	_, err = io.ReadAll(f)
+	assert.Equal(t, ErrCorrupt, err)
+	f.Close()
+
+	_, err = NewCacheOpts(root, CacheOpts{Keyer: StaticKey("short")})
+	assert.Error(t, err)
+}
+
+func TestEncryptionFS(t *testing.T) {
+	c := newCryptCache(t, t.TempDir(), CacheOpts{})
+	require.NoError(t, c.Put("dir/a", bytes.NewReader([]byte("hello"))))
+	require.NoError(t, fstest.TestFS(c.FS(), "dir/a"))
+}
+
+func TestEncryptionWriteAt(t *testing.T) {
+	c := newCryptCache(t, t.TempDir(), CacheOpts{})
+	f, err := c.OpenFile("a", os.O_CREATE|os.O_RDWR)
+	require.NoError(t, err)
+	defer f.Close()
+	var want []byte
+	r := rand.New(rand.NewSource(1))
+	for _, _case := range []struct {
+		off int64
+		n   int
+	}{
+		{0, 10},
+		{5, cryptBlockSize},
+		// Leaves a gap, spanning a block boundary.
+		{3*cryptBlockSize - 5, 10},
+		{cryptBlockSize - 1, 2},
+		{100, 0},
+	} {
+		b := make([]byte, _case.n)
+		r.Read(b)
+		n, err := f.WriteAt(b, _case.off)
+		require.NoError(t, err)
+		require.Equal(t, _case.n, n)
+		if end := _case.off + int64(_case.n); end > int64(len(want)) {
+			want = append(want, make([]byte, end-int64(len(want)))...)
+		}
+		copy(want[_case.off:], b)
+	}
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	assert.EqualValues(t, len(want), fi.Size())
+	got := make([]byte, len(want)+1)
+	n, err := f.ReadAt(got, 0)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, len(want), n)
+	assert.True(t, bytes.Equal(want, got[:n]))
+	onDisk, err := os.Stat(c.realpath("a"))
+	require.NoError(t, err)
+	assert.Equal(t, onDisk.Size(), c.Info().Filled)
+
+	// Sequential access goes through the File's offset.
+	_, err = f.Seek(-3, io.SeekEnd)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("xyz!"))
+	require.NoError(t, err)
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	b, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "xyz!", string(b[len(want)-3:]))
+
+	g, err := c.OpenFile("a", os.O_WRONLY|os.O_APPEND)
+	require.NoError(t, err)
+	_, err = g.Write([]byte("?"))
+	require.NoError(t, err)
+	g.Close()
+	b, err = io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "?", string(b))
+}
+
+func TestEncryptionTampering(t *testing.T) {
+	root := t.TempDir()
+	c := newCryptCache(t, root, CacheOpts{})
+	content := make([]byte, 2*cryptBlockSize)
+	rand.New(rand.NewSource(1)).Read(content)
+	for _, p := range []string{"a", "b", "c"} {
+		require.NoError(t, c.Put(p, bytes.NewReader(content)))
+	}
+	readAll := func(p string) error {
+		f, err := c.OpenFile(p, os.O_RDONLY)
+		require.NoError(t, err)
+		defer f.Close()
+		_, err = io.ReadAll(f)
+		return err
+	}
+	// Cutting off the last block leaves one that isn't marked as the last.
+	blockSize := cryptBlockSize + cryptOverhead(c.aead)
+	require.NoError(t, os.Truncate(filepath.Join(root, "a"), cryptIDSize+blockSize))
+	assert.Equal(t, ErrCorrupt, readAll("a"))
+	// A block from another file, at the same position.
+	b, err := os.ReadFile(filepath.Join(root, "b"))
+	require.NoError(t, err)
+	f, err := os.OpenFile(filepath.Join(root, "c"), os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt(b[cryptIDSize:cryptIDSize+blockSize], cryptIDSize)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, ErrCorrupt, readAll("c"))
+	assert.NoError(t, readAll("b"))
+
+	// Writing past a full last block marks the new one as the last instead.
+	g, err := c.OpenFile("b", os.O_WRONLY|os.O_APPEND)
+	require.NoError(t, err)
+	_, err = g.Write([]byte("more"))
+	require.NoError(t, err)
+	require.NoError(t, g.Close())
+	assert.Equal(t, string(content)+"more", readItem(t, c, "b"))
+}
+
+func TestEncryptionTruncate(t *testing.T) {
+	c := newCryptCache(t, t.TempDir(), CacheOpts{})
+	require.NoError(t, c.Put("a", bytes.NewReader([]byte("hello"))))
+	f, err := c.OpenFile("a", os.O_RDWR)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, ErrEncryptedTruncate, f.Truncate(1))
+	require.NoError(t, f.Truncate(0))
+	assert.EqualValues(t, 0, c.Info().Filled)
+	assert.Equal(t, "", readItem(t, c, "a"))
+}
+
+func TestEncryptionComposes(t *testing.T) {
+	c := newCryptCache(t, t.TempDir(), CacheOpts{Compress: true})
+	content := bytes.Repeat([]byte("compressible "), 1000)
+	require.NoError(t, c.Put("dir/a", bytes.NewReader(content)))
+	assert.Equal(t, string(content), readItem(t, c, "dir/a"))
+	assert.True(t, c.Info().Filled < int64(len(content)))
+	_, err := c.OpenFile("dir/a", os.O_WRONLY)
+	assert.Equal(t, ErrCompressed, err)
+
+	i, err := c.AsResourceProvider().NewInstance("b")
+	require.NoError(t, err)
+	require.NoError(t, i.Put(bytes.NewReader([]byte("hello"))))
+	_, err = i.WriteAt([]byte(" world"), 5)
+	require.NoError(t, err)
+	fi, err := i.Stat()
+	require.NoError(t, err)
+	assert.EqualValues(t, 11, fi.Size())
+	b := make([]byte, 5)
+	_, err = i.ReadAt(b, 6)
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(b))
+}
@@ -0,0 +1,133 @@
+package filecache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anacrolix/missinggo/v2/atomicfile"
+)
+
+// Bodies of deduplicated items are stored here in the root, named by the
+// SHA-256 of their content, and hard linked to by the items.
+const contentDir = ".content"
+
+var ErrDedupeUnsupported = errors.New("hard links unsupported")
+
+func isContentKey(k key) bool {
+	return strings.HasPrefix(string(k), contentDir+"/")
+}
+
+func (me *Cache) contentPath(sum string) string {
+	return filepath.Join(me.root, contentDir, sum[:2], sum)
+}
+
+// Replaces the item's file with a link to the stored content with the
+// given hash, or stores the new file as that content if there isn't any.
+// Returns the hash if the item is linked to stored content. If the content
+// can't be linked, the file is committed alone.
+func (me *Cache) commitDeduped(f *atomicfile.File, k key, sum string) (content string, err error) {
+	p := me.realpath(k)
+	cp := me.contentPath(sum)
+	link := fmt.Sprintf("%s.%d.tmp", p, rand.Uint32())
+	err = os.Link(cp, link)
+	if err == nil {
+		err = os.Rename(link, p)
+		if err != nil {
+			os.Remove(link)
+			return
+		}
+		f.Abort()
+		return sum, nil
+	}
+	if os.IsNotExist(err) {
+		err = os.MkdirAll(filepath.Dir(cp), dirPerm)
+		if err == nil {
+			err = os.Link(f.Name(), cp)
+		}
+		if err == nil {
+			content = sum
+		}
+	}
+	// Without stored content, the item is committed alone. That includes
+	// when another write stored the same content first.
+	return content, f.Commit()
+}
+
+// Removes the stored content if no items link to it anymore.
+func (me *Cache) releaseContent(sum string) {
+	if sum == "" {
+		return
+	}
+	cp := me.contentPath(sum)
+	n, err := linkCount(cp)
+	if err != nil || n > 1 {
+		return
+	}
+	if os.Remove(cp) == nil {
+		pruneEmptyDirs(me.root, cp)
+	}
+}
+
+// Gives the item its own copy of its file, if it shares it with other items,
+// so it can be modified. The copy is skipped if the item is to be truncated.
+func (me *Cache) unshare(k key, trunc bool) error {
+	p := me.realpath(k)
+	n, err := linkCount(p)
+	if err != nil || n < 2 {
+		return nil
+	}
+	f, err := atomicfile.Create(p, atomicfile.Opts{
+		Perm: filePerm,
+		Sync: atomicfile.SyncNone,
+	})
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if !trunc {
+		src, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return f.Commit()
+}
+
+// Removes stored content that no items link to, such as after a crash, or
+// after items were removed while the cache wasn't running.
+func (me *Cache) sweepContent() {
+	if me.readOnly {
+		return
+	}
+	dir := filepath.Join(me.root, contentDir)
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if atomicfile.IsTemp(d.Name()) {
+			os.Remove(path)
+			return nil
+		}
+		if n, err := linkCount(path); err == nil && n < 2 {
+			os.Remove(path)
+		}
+		return nil
+	})
+	// Remove directories left empty.
+	des, _ := os.ReadDir(dir)
+	for _, de := range des {
+		os.Remove(filepath.Join(dir, de.Name()))
+	}
+	os.Remove(dir)
+}
@@ -0,0 +1,77 @@
+package filecache
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func contentFiles(t *testing.T, c *Cache) (ret []string) {
+	filepath.WalkDir(filepath.Join(c.root, contentDir), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			ret = append(ret, d.Name())
+		}
+		return nil
+	})
+	return
+}
+
+func TestDedupe(t *testing.T) {
+	if !hardLinksSupported {
+		t.Skip(ErrDedupeUnsupported)
+	}
+	td := t.TempDir()
+	root := filepath.Join(td, "root")
+	opts := CacheOpts{Dedupe: true, IndexPath: filepath.Join(td, "index")}
+	c, err := NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	<-c.Ready()
+	require.NoError(t, c.Put("a", strings.NewReader("hello")))
+	require.NoError(t, c.Put("dir/b", strings.NewReader("hello")))
+	require.NoError(t, c.Put("c", strings.NewReader("other")))
+	assert.Len(t, contentFiles(t, c), 2)
+	n, err := linkCount(c.realpath("a"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.EqualValues(t, 15, c.Info().Filled)
+	des, err := fs.ReadDir(c.FS(), ".")
+	require.NoError(t, err)
+	assert.Len(t, des, 3)
+
+	// Writing to an item leaves the others with the content.
+	f, err := c.OpenFile("a", os.O_WRONLY|os.O_APPEND)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(" world"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "hello world", readItem(t, c, "a"))
+	assert.Equal(t, "hello", readItem(t, c, "dir/b"))
+	n, err = linkCount(c.realpath("dir/b"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// Content goes when the last item linked to it does, including across
+	// restarts.
+	require.NoError(t, c.Remove("dir/b"))
+	assert.Len(t, contentFiles(t, c), 1)
+	require.NoError(t, c.Close())
+	c, err = NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	require.NoError(t, c.Rename("c", "d"))
+	assert.Len(t, contentFiles(t, c), 1)
+	require.NoError(t, c.Remove("d"))
+	assert.Empty(t, contentFiles(t, c))
+
+	// Stored content isn't mistaken for items by a scan.
+	require.NoError(t, c.Put("e", strings.NewReader("hello")))
+	c, err = NewCacheOpts(root, CacheOpts{Dedupe: true})
+	require.NoError(t, err)
+	<-c.Ready()
+	assert.ElementsMatch(t, []string{"a", "e"}, itemPaths(c))
+	assert.Len(t, contentFiles(t, c), 1)
+}
@@ -0,0 +1,74 @@
+package filecache
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/anacrolix/missinggo/v2/atomicfile"
+	"github.com/anacrolix/missinggo/v2/pathsan"
+)
+
+// Keys are escaped for Windows on every OS, so a root can be moved between
+// them. Long components aren't shortened, as that can't be reversed to
+// recover keys when scanning.
+var keyEscaping = pathsan.Options{Windows: true}
+
+// Returns the path of the item's file relative to the root, with slashes.
+func (me *Cache) storedPath(k key) string {
+	if me.rawKeys {
+		return rawKeyPath(k)
+	}
+	if me.escapeKeys {
+		return keyEscaping.EscapePath(string(k))
+	}
+	return string(k)
+}
+
+// Returns the key for a file's path relative to the root, with slashes.
+func (me *Cache) storedKey(p string) (key, error) {
+	if me.rawKeys {
+		return parseRawKeyPath(p)
+	}
+	if me.escapeKeys {
+		var err error
+		p, err = pathsan.UnescapePath(p)
+		if err != nil {
+			return "", err
+		}
+	}
+	return sanitizePath(p), nil
+}
+
+// Renames the files under root to where a cache with EscapeKeys expects
+// them. The cache mustn't be open meanwhile, and this must only be done
+// once, as names already escaped would be escaped again. An index for root
+// remains valid.
+func MigrateEscapeKeys(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || atomicfile.IsTemp(d.Name()) {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		k := sanitizePath(filepath.ToSlash(rel))
+		if isContentKey(k) {
+			return nil
+		}
+		to := filepath.Join(root, filepath.FromSlash(keyEscaping.EscapePath(string(k))))
+		if to == path {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(to), dirPerm); err != nil {
+			return err
+		}
+		if err := os.Rename(path, to); err != nil {
+			return fmt.Errorf("migrating %q: %w", k, err)
+		}
+		pruneEmptyDirs(root, path)
+		return nil
+	})
+}
@@ -0,0 +1,45 @@
+package filecache
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeKeys(t *testing.T) {
+	if os.PathSeparator != '/' {
+		t.Skip("can't create the unescaped names")
+	}
+	root := t.TempDir()
+	for _, p := range []string{"con", "dir:x/a.", "b%c", "plain"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, p)), dirPerm))
+		require.NoError(t, os.WriteFile(filepath.Join(root, p), []byte(p), filePerm))
+	}
+	require.NoError(t, MigrateEscapeKeys(root))
+	for _, p := range []string{"%63on", "dir%3Ax/a%2E", "b%25c", "plain"} {
+		assert.FileExists(t, filepath.Join(root, p))
+	}
+	_, err := os.Stat(filepath.Join(root, "dir:x"))
+	assert.True(t, os.IsNotExist(err))
+
+	c, err := NewCacheOpts(root, CacheOpts{EscapeKeys: true})
+	require.NoError(t, err)
+	<-c.Ready()
+	assert.ElementsMatch(t, []string{"con", "dir:x/a.", "b%c", "plain"}, itemPaths(c))
+	assert.Equal(t, "dir:x/a.", readItem(t, c, "dir:x/a."))
+	require.NoError(t, c.Put("aux.txt", strings.NewReader("hello")))
+	assert.FileExists(t, filepath.Join(root, "%61ux.txt"))
+
+	// The file system has the names as stored.
+	b, err := fs.ReadFile(c.FS(), "%61ux.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+	require.NoError(t, c.Remove("b%c"))
+	_, err = os.Stat(filepath.Join(root, "b%25c"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,122 @@
+package filecache
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/anacrolix/missinggo/v2/pubsub"
+)
+
+type EventType int
+
+const (
+	// The item is new to the cache.
+	ItemAdded EventType = iota
+	// The item's content or state changed. Accesses alone aren't reported.
+	ItemUpdated
+	// The item was removed through the cache, or found to be missing.
+	ItemRemoved
+	// The item was removed to make room.
+	ItemEvicted
+	// The item was removed because its TTL passed.
+	ItemExpired
+)
+
+func (me EventType) String() string {
+	switch me {
+	case ItemAdded:
+		return "added"
+	case ItemUpdated:
+		return "updated"
+	case ItemRemoved:
+		return "removed"
+	case ItemEvicted:
+		return "evicted"
+	case ItemExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(me))
+	}
+}
+
+// A change to an item. For removals, Item is as it was before.
+type Event struct {
+	Type EventType
+	Item ItemInfo
+}
+
+// Receives the cache's events in the order they happen. See Cache.Subscribe.
+type Subscription struct {
+	// Closed after the subscription or the cache is closed.
+	Events    <-chan Event
+	sub       *pubsub.Subscription
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Stops receiving events. Events may still be delivered until Events is
+// closed.
+func (me *Subscription) Close() {
+	me.closeOnce.Do(func() {
+		close(me.closed)
+		me.sub.Close()
+	})
+}
+
+// Returns a subscription to changes to items from now on, including in
+// namespaces. Events are buffered without limit, so they must be received
+// until the subscription is closed.
+func (me *Cache) Subscribe() *Subscription {
+	me.mu.Lock()
+	if me.events == nil {
+		me.events = pubsub.NewPubSub()
+	}
+	sub := me.events.Subscribe()
+	me.mu.Unlock()
+	events := make(chan Event)
+	ret := &Subscription{
+		Events: events,
+		sub:    sub,
+		closed: make(chan struct{}),
+	}
+	go func() {
+		defer close(events)
+		for v := range sub.Values {
+			select {
+			case events <- v.(Event):
+			case <-ret.closed:
+				return
+			}
+		}
+	}()
+	return ret
+}
+
+// Publishes the event for an item changing from prev to cur, if there are
+// subscribers. changed is whether there was more to it than an access.
+func (me *Cache) publishItemEvent(k key, prev itemState, known bool, cur itemState, kept, changed bool) {
+	if me.events == nil {
+		return
+	}
+	var e Event
+	switch {
+	case kept && !known:
+		e = Event{ItemAdded, me.itemInfo(k, cur)}
+	case kept && changed:
+		e = Event{ItemUpdated, me.itemInfo(k, cur)}
+	case !kept && known:
+		e = Event{me.removal, me.itemInfo(k, prev)}
+	default:
+		return
+	}
+	me.events.Publish(e)
+}
+
+// Reports removals in f as the given type of event. The cache must be
+// locked.
+func (me *Cache) removingAs(t EventType, f func()) {
+	prev := me.removal
+	me.removal = t
+	defer func() { me.removal = prev }()
+	f()
+}
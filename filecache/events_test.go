@@ -0,0 +1,52 @@
+package filecache
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribe(t *testing.T) {
+	c, fc := newTestCache(t)
+	<-c.Ready()
+	sub := c.Subscribe()
+	next := func() [2]string {
+		select {
+		case e := <-sub.Events:
+			return [2]string{e.Type.String(), string(e.Item.Path)}
+		case <-time.After(time.Second):
+		}
+		t.Fatal("no event")
+		return [2]string{}
+	}
+	createItem(t, c, "a", OpenOpts{})
+	// Creating, then writing.
+	assert.Equal(t, [2]string{"added", "a"}, next())
+	assert.Equal(t, [2]string{"updated", "a"}, next())
+	// Accesses aren't reported.
+	readItem(t, c, "a")
+	fc.Advance(time.Second)
+	require.NoError(t, c.Put("b", strings.NewReader("b")))
+	assert.Equal(t, [2]string{"added", "b"}, next())
+	c.SetCapacity(1)
+	require.NoError(t, c.PutOpts("c", strings.NewReader(""), OpenOpts{TTL: time.Minute}))
+	assert.Equal(t, [2]string{"added", "c"}, next())
+	assert.Equal(t, [2]string{"evicted", "a"}, next())
+	fc.Advance(time.Minute)
+	_, err := c.OpenFile("c", os.O_RDONLY)
+	assert.True(t, os.IsNotExist(err), err)
+	assert.Equal(t, [2]string{"expired", "c"}, next())
+	require.NoError(t, c.Remove("b"))
+	e := <-sub.Events
+	assert.Equal(t, ItemRemoved, e.Type)
+	assert.EqualValues(t, 1, e.Item.Size)
+
+	sub.Close()
+	for range sub.Events {
+	}
+	require.NoError(t, c.Close())
+}
@@ -0,0 +1,112 @@
+package filecache
+
+import (
+	"fmt"
+	"time"
+)
+
+type evictHook struct {
+	f func(ItemInfo)
+}
+
+// Registers f to be called with each item removed by trimming to capacity
+// or for expiring, but not by Remove. It's called with the cache locked,
+// as the item is removed, so it mustn't use the cache. Call remove to
+// unregister it.
+func (me *Cache) OnEvict(f func(ItemInfo)) (remove func()) {
+	h := &evictHook{f}
+	me.mu.Lock()
+	if me.evictHooks == nil {
+		me.evictHooks = make(map[*evictHook]struct{})
+	}
+	me.evictHooks[h] = struct{}{}
+	me.mu.Unlock()
+	return func() {
+		me.mu.Lock()
+		delete(me.evictHooks, h)
+		me.mu.Unlock()
+	}
+}
+
+// Sets how long items are spared from eviction after they're added or
+// replaced, so items still being written aren't evicted for looking
+// unused. Trimming stops short if only such items are left. Zero, the
+// default, disables this.
+func (me *Cache) SetEvictionGrace(d time.Duration) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.evictionGrace = d
+}
+
+func (me *Cache) inGrace(i itemState) bool {
+	return me.evictionGrace > 0 && me.clock.Now().Sub(i.Created) < me.evictionGrace
+}
+
+// Evicts the items p chooses while over returns true, skipping those in
+// their grace period, and those whose key lock is held.
+func (me *Cache) trimPolicy(p *classPolicy, over func() bool) {
+	me.flushAccesses()
+	var spared []key
+	for over() && p.NumItems() != 0 {
+		k := p.Choose().(key)
+		if me.inGrace(me.items[k]) || !me.evict(k) {
+			p.Forget(k)
+			spared = append(spared, k)
+		}
+	}
+	for _, k := range spared {
+		if i, ok := me.items[k]; ok && me.pins[k] == 0 {
+			p.Used(k, i.Accessed, i.Priority)
+		}
+	}
+}
+
+// Removes an item to make room, counting it as an eviction. Items that
+// can't be removed are forgotten instead, so trimming can't get stuck on
+// them. A later scan finds them again. Returns false without removing it if
+// the item's key lock is held, as its file may be in use.
+func (me *Cache) evict(k key) bool {
+	unlock, ok := me.tryLockKey(k)
+	if !ok {
+		return false
+	}
+	defer unlock()
+	me.removingAs(ItemEvicted, func() { me.evictItem(k) })
+	return true
+}
+
+func (me *Cache) evictItem(k key) {
+	info := me.itemInfo(k, me.items[k])
+	if err := me.remove(k); err != nil {
+		me.handleError(fmt.Errorf("evicting %q: %w", k, err))
+		me.updateItem(k, func(*itemState, bool) bool { return false })
+		return
+	}
+	me.count(k, func(s *Stats) {
+		s.Evictions++
+		s.EvictedBytes += info.Size
+	})
+	me.runEvictHooks(info)
+}
+
+// Removes an item whose TTL has passed. The item's key lock must be held.
+func (me *Cache) expire(k key) (err error) {
+	me.removingAs(ItemExpired, func() { err = me.expireItem(k) })
+	return
+}
+
+func (me *Cache) expireItem(k key) error {
+	info := me.itemInfo(k, me.items[k])
+	if err := me.remove(k); err != nil {
+		return err
+	}
+	me.count(k, func(s *Stats) { s.Expirations++ })
+	me.runEvictHooks(info)
+	return nil
+}
+
+func (me *Cache) runEvictHooks(info ItemInfo) {
+	for h := range me.evictHooks {
+		h.f(info)
+	}
+}
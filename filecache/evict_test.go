@@ -0,0 +1,81 @@
+package filecache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnEvict(t *testing.T) {
+	c, fc := newTestCache(t)
+	var evicted []ItemInfo
+	remove := c.OnEvict(func(i ItemInfo) {
+		evicted = append(evicted, i)
+	})
+	createItem(t, c, "a", OpenOpts{TTL: time.Minute})
+	fc.Advance(time.Second)
+	createItem(t, c, "bb", OpenOpts{})
+	fc.Advance(time.Second)
+	createItem(t, c, "ccc", OpenOpts{})
+	c.Remove("ccc")
+	assert.Empty(t, evicted)
+
+	fc.Advance(time.Minute)
+	c.RemoveExpired()
+	c.SetCapacity(0)
+	c.TrimToCapacity()
+	if assert.Len(t, evicted, 2) {
+		assert.EqualValues(t, "a", evicted[0].Path)
+		assert.Equal(t, time.Unix(1060, 0), evicted[0].Expires)
+		assert.EqualValues(t, "bb", evicted[1].Path)
+		assert.EqualValues(t, 2, evicted[1].Size)
+	}
+
+	remove()
+	c.SetCapacity(-1)
+	createItem(t, c, "d", OpenOpts{})
+	c.SetCapacity(0)
+	c.TrimToCapacity()
+	assert.Empty(t, itemPaths(c))
+	assert.Len(t, evicted, 2)
+}
+
+func TestEvictionGrace(t *testing.T) {
+	c, fc := newTestCache(t)
+	c.SetEvictionGrace(time.Minute)
+	createItem(t, c, "old", OpenOpts{})
+	fc.Advance(time.Minute)
+	createItem(t, c, "new", OpenOpts{})
+	fc.Advance(time.Second)
+	readItem(t, c, "old")
+	// "new" is least recently used, but too young to evict.
+	c.SetCapacity(3)
+	c.TrimToCapacity()
+	assert.Equal(t, []string{"new"}, itemPaths(c))
+	// Only young items are left, so the cache stays over capacity.
+	c.SetCapacity(0)
+	c.TrimToCapacity()
+	assert.Equal(t, []string{"new"}, itemPaths(c))
+	fc.Advance(time.Minute)
+	c.TrimToCapacity()
+	assert.Empty(t, itemPaths(c))
+}
+
+func TestEvictionSkipsLockedKeys(t *testing.T) {
+	c, fc := newTestCache(t)
+	createItem(t, c, "a", OpenOpts{TTL: time.Minute})
+	fc.Advance(time.Second)
+	createItem(t, c, "bb", OpenOpts{})
+	// Something else is operating on the items' files.
+	unlock := c.lockKeys("a", "bb")
+	fc.Advance(time.Minute)
+	assert.Equal(t, 0, c.RemoveExpired())
+	c.SetCapacity(0)
+	c.TrimToCapacity()
+	assert.ElementsMatch(t, []string{"a", "bb"}, itemPaths(c))
+	unlock()
+	assert.Equal(t, 1, c.RemoveExpired())
+	c.TrimToCapacity()
+	assert.Empty(t, itemPaths(c))
+}
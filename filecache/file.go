@@ -1,23 +1,43 @@
 package filecache
 
 import (
+	"compress/gzip"
 	"errors"
+	"io"
 	"os"
 	"sync"
 
-	"github.com/anacrolix/missinggo/pproffd"
+	"github.com/anacrolix/missinggo/v2/pproffd"
 )
 
 type File struct {
 	path       key
 	f          pproffd.OSFile
 	afterWrite func(endOff int64)
-	onRead     func(n int)
-	mu         sync.Mutex
-	offset     int64
+	// Called with the new size after truncating.
+	afterTruncate func(size int64)
+	onRead        func(n int)
+	onClose       func()
+	syncDirs      func() error
+	syncPolicy    SyncPolicy
+	mu            sync.Mutex
+	closed        bool
+	offset        int64
+	// Decompresses the content of compressed items.
+	gz *gzip.Reader
+	// Encrypts and decrypts the content of encrypted items, in which case
+	// the offset is tracked here rather than by the OS.
+	crypt  *cryptFile
+	append bool
 }
 
 func (me *File) Seek(offset int64, whence int) (ret int64, err error) {
+	if me.gz != nil {
+		return 0, ErrNotSeekable
+	}
+	if me.crypt != nil {
+		return me.cryptSeek(offset, whence)
+	}
 	ret, err = me.f.Seek(offset, whence)
 	if err != nil {
 		return
@@ -26,40 +46,146 @@ func (me *File) Seek(offset int64, whence int) (ret int64, err error) {
 	return
 }
 
+func (me *File) cryptSeek(offset int64, whence int) (ret int64, err error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += me.offset
+	case io.SeekEnd:
+		size, err := me.crypt.Size()
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	me.offset = offset
+	return offset, nil
+}
+
 var (
 	ErrFileTooLarge    = errors.New("file too large for cache")
 	ErrFileDisappeared = errors.New("file disappeared")
+	// Returned when truncating an encrypted item other than to zero.
+	ErrEncryptedTruncate = errors.New("encrypted item can only be truncated to zero")
 )
 
 func (me *File) Write(b []byte) (n int, err error) {
+	if me.crypt != nil {
+		if me.append {
+			if me.offset, err = me.crypt.Size(); err != nil {
+				return
+			}
+		}
+		n, err = me.WriteAt(b, me.offset)
+		me.offset += int64(n)
+		return
+	}
 	n, err = me.f.Write(b)
 	me.offset += int64(n)
+	if me.append {
+		// The OS wrote at the end of the file, wherever that was.
+		if end, serr := me.f.Seek(0, io.SeekCurrent); serr == nil {
+			me.offset = end
+		}
+	}
 	me.afterWrite(me.offset)
 	return
 }
 
 func (me *File) WriteAt(b []byte, off int64) (n int, err error) {
+	if me.crypt != nil {
+		n, err = me.crypt.WriteAt(b, off)
+		me.afterWrite(encryptedSize(me.crypt.aead, me.writeEnd(off, n)))
+		return
+	}
 	n, err = me.f.WriteAt(b, off)
-	me.afterWrite(off + int64(n))
+	me.afterWrite(me.writeEnd(off, n))
 	return
 }
 
+// Changes the item's size, as os.File.Truncate does, and accounts for it
+// without a stat.
+func (me *File) Truncate(size int64) (err error) {
+	if me.crypt != nil {
+		err = me.crypt.Truncate(size)
+	} else if t, ok := me.f.(truncater); ok {
+		err = t.Truncate(size)
+	} else {
+		// Served from memory.
+		err = &os.PathError{Op: "truncate", Path: string(me.path), Err: os.ErrPermission}
+	}
+	if err != nil {
+		return
+	}
+	me.afterTruncate(size)
+	return
+}
+
+type truncater interface {
+	Truncate(size int64) error
+}
+
+// Returns the size the file must now have. Writing nothing doesn't extend
+// it.
+func (me *File) writeEnd(off int64, n int) int64 {
+	if n == 0 {
+		return 0
+	}
+	return off + int64(n)
+}
+
 func (me *File) Close() error {
-	return me.f.Close()
+	me.mu.Lock()
+	closed := me.closed
+	me.closed = true
+	me.mu.Unlock()
+	var err error
+	if !closed {
+		err = me.syncOnClose()
+		me.onClose()
+	}
+	if cerr := me.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 func (me *File) Stat() (os.FileInfo, error) {
-	return me.f.Stat()
+	fi, err := me.f.Stat()
+	if err != nil || me.crypt == nil {
+		return fi, err
+	}
+	return plainFileInfo{fi, plainSize(me.crypt.aead, fi.Size())}, nil
 }
 
 func (me *File) Read(b []byte) (n int, err error) {
-	n, err = me.f.Read(b)
+	if me.gz != nil {
+		n, err = me.gz.Read(b)
+	} else if me.crypt != nil {
+		n, err = me.crypt.ReadAt(b, me.offset)
+		me.offset += int64(n)
+	} else {
+		n, err = me.f.Read(b)
+		me.offset += int64(n)
+	}
 	me.onRead(n)
 	return
 }
 
 func (me *File) ReadAt(b []byte, off int64) (n int, err error) {
-	n, err = me.f.ReadAt(b, off)
+	if me.gz != nil {
+		return 0, ErrNotSeekable
+	}
+	if me.crypt != nil {
+		n, err = me.crypt.ReadAt(b, off)
+	} else {
+		n, err = me.f.ReadAt(b, off)
+	}
 	me.onRead(n)
 	return
 }
@@ -0,0 +1,71 @@
+package filecache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2/diskspace"
+)
+
+// Sets the bytes to keep available on the filesystem holding the root.
+// While there's less, items are evicted regardless of the capacity. Space
+// is checked periodically, and on TrimToCapacity. Negative disables this,
+// which is the default.
+func (me *Cache) SetMinFreeSpace(bytes int64) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.minFreeSpace = bytes
+	if me.removeFreeSpaceThreshold != nil {
+		me.removeFreeSpaceThreshold()
+		me.removeFreeSpaceThreshold = nil
+	}
+	if bytes < 0 {
+		return
+	}
+	if me.freeSpaceWatcher == nil {
+		me.freeSpaceWatcher = diskspace.Watch(me.root, diskspace.WatchOpts{Clock: me.clock})
+	}
+	me.removeFreeSpaceThreshold = me.freeSpaceWatcher.OnThreshold(bytes, func(c diskspace.Crossing) {
+		if c.Below {
+			me.trimFreeSpace()
+		}
+	})
+}
+
+// Evicts items until the minimum free space would be restored, going by
+// their sizes.
+func (me *Cache) trimFreeSpace() {
+	me.mu.Lock()
+	min := me.minFreeSpace
+	me.mu.Unlock()
+	if min < 0 || me.readOnly {
+		return
+	}
+	u, err := me.diskUsage(me.root)
+	if err != nil {
+		me.handleError(fmt.Errorf("checking free space: %w", err))
+		return
+	}
+	deficit := min - u.Available
+	if deficit <= 0 {
+		return
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	defer me.countTrim(time.Now())
+	filled := me.filled
+	me.trimPolicy(me.policy, func() bool {
+		return deficit > filled-me.filled
+	})
+}
+
+func (me *Cache) stopFreeSpaceWatcher() {
+	me.mu.Lock()
+	w := me.freeSpaceWatcher
+	me.freeSpaceWatcher = nil
+	me.removeFreeSpaceThreshold = nil
+	me.mu.Unlock()
+	if w != nil {
+		w.Close()
+	}
+}
@@ -0,0 +1,220 @@
+package filecache
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/anacrolix/missinggo/v2/atomicfile"
+)
+
+// Returns the cache as a read-only file system, also implementing
+// fs.StatFS and fs.ReadDirFS. Opening an item counts as an access, as for
+// OpenFile. Temporary files from Put in progress aren't listed. Names are
+// as stored, so they're escaped if the cache escapes keys.
+func (me *Cache) FS() fs.FS {
+	return cacheFS{me}
+}
+
+type cacheFS struct {
+	c *Cache
+}
+
+var (
+	_ fs.StatFS    = cacheFS{}
+	_ fs.ReadDirFS = cacheFS{}
+)
+
+// Returns the real path for a name valid for fs.FS. With several roots,
+// it's on the first that has it.
+func (me cacheFS) realpath(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	for _, root := range me.c.roots[1:] {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if _, err := os.Lstat(p); err == nil {
+			return p, nil
+		}
+	}
+	return filepath.Join(me.c.root, filepath.FromSlash(name)), nil
+}
+
+// Lists the directory across all the roots, for when there's more than one.
+func (me cacheFS) readDirRoots(name string) (ret []fs.DirEntry, err error) {
+	seen := make(map[string]bool)
+	found := false
+	for _, root := range me.c.roots {
+		des, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, de := range des {
+			if !seen[de.Name()] {
+				seen[de.Name()] = true
+				ret = append(ret, de)
+			}
+		}
+	}
+	if !found {
+		return nil, fs.ErrNotExist
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name() < ret[j].Name() })
+	return me.c.dirEntries(name, ret), nil
+}
+
+// Replaces the real path in errors from the os package with name.
+func fsError(op, name string, err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (me cacheFS) Open(name string) (fs.File, error) {
+	p, err := me.realpath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+	if fi.IsDir() {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, fsError("open", name, err)
+		}
+		df := &dirFile{f: f, name: name, c: me.c}
+		if len(me.c.roots) > 1 {
+			des, err := me.readDirRoots(name)
+			if err != nil {
+				f.Close()
+				return nil, fsError("open", name, err)
+			}
+			df.merged = append([]fs.DirEntry{}, des...)
+		}
+		return df, nil
+	}
+	k, err := me.c.storedKey(name)
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+	f, err := me.c.OpenFile(string(k), os.O_RDONLY)
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+	return f, nil
+}
+
+func (me cacheFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := me.realpath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := me.c.stat(p)
+	if err != nil {
+		return nil, fsError("stat", name, err)
+	}
+	return fi, nil
+}
+
+func (me cacheFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := me.realpath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(me.c.roots) > 1 {
+		des, err := me.readDirRoots(name)
+		if err != nil {
+			return nil, fsError("readdir", name, err)
+		}
+		return des, nil
+	}
+	des, err := os.ReadDir(p)
+	if err != nil {
+		return nil, fsError("readdir", name, err)
+	}
+	return me.c.dirEntries(name, des), nil
+}
+
+// Leaves out temporary files and stored content, and reports plaintext
+// sizes.
+func (me *Cache) dirEntries(dir string, des []fs.DirEntry) []fs.DirEntry {
+	ret := des[:0]
+	for _, de := range des {
+		if atomicfile.IsTemp(de.Name()) {
+			continue
+		}
+		if dir == "." && de.Name() == contentDir {
+			continue
+		}
+		if me.aead != nil {
+			de = plainDirEntry{de, me.aead}
+		}
+		ret = append(ret, de)
+	}
+	return ret
+}
+
+// A directory in the cache, listed as for ReadDir.
+type dirFile struct {
+	f    *os.File
+	name string
+	c    *Cache
+	// The entries from all the roots, if there's more than one, as they're
+	// yet to be read.
+	merged []fs.DirEntry
+}
+
+func (me *dirFile) Stat() (fs.FileInfo, error) {
+	return me.f.Stat()
+}
+
+func (me *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: me.name, Err: ErrIsDir}
+}
+
+func (me *dirFile) Close() error {
+	return me.f.Close()
+}
+
+func (me *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if me.merged != nil {
+		return me.readMerged(n)
+	}
+	for {
+		des, err := me.f.ReadDir(n)
+		des = me.c.dirEntries(me.name, des)
+		// With n > 0, an empty result must come with an error.
+		if n <= 0 || len(des) != 0 || err != nil {
+			if err == io.EOF && n <= 0 {
+				err = nil
+			}
+			return des, err
+		}
+	}
+}
+
+func (me *dirFile) readMerged(n int) (des []fs.DirEntry, err error) {
+	if n <= 0 {
+		des, me.merged = me.merged, me.merged[len(me.merged):]
+		return
+	}
+	if len(me.merged) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(me.merged) {
+		n = len(me.merged)
+	}
+	des, me.merged = me.merged[:n:n], me.merged[n:]
+	return
+}
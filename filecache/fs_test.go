@@ -0,0 +1,45 @@
+package filecache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFS(t *testing.T) {
+	c, _ := newTestCache(t)
+	require.NoError(t, c.Put("a", strings.NewReader("hello")))
+	require.NoError(t, c.Put("dir/b", strings.NewReader("world")))
+	createItem(t, c, "dir/sub/c", OpenOpts{})
+	// A Put that's in progress, or was interrupted.
+	require.NoError(t, os.WriteFile(filepath.Join(c.root, "dir", "d.123.tmp"), nil, 0o644))
+	fsys := c.FS()
+	require.NoError(t, fstest.TestFS(fsys, "a", "dir/b", "dir/sub/c"))
+
+	des, err := fs.ReadDir(fsys, "dir")
+	require.NoError(t, err)
+	var names []string
+	for _, de := range des {
+		names = append(names, de.Name())
+	}
+	assert.Equal(t, []string{"b", "sub"}, names)
+	b, err := fs.ReadFile(fsys, "dir/b")
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(b))
+
+	_, err = fsys.Open("missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist), err)
+	var pe *fs.PathError
+	if assert.True(t, errors.As(err, &pe)) {
+		assert.Equal(t, "missing", pe.Path)
+	}
+	_, err = fsys.Open("../a")
+	assert.True(t, errors.Is(err, fs.ErrInvalid), err)
+}
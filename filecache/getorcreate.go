@@ -0,0 +1,42 @@
+package filecache
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// Opens the item for reading, first calling fill to write it if it doesn't
+// exist. Concurrent callers for the same item wait for a single fill, which
+// is stored as by Put. If fill fails, its error is returned, and the next
+// waiting caller tries its own fill.
+func (me *Cache) GetOrCreate(path string, fill func(io.Writer) error) (ret *File, err error) {
+	key := me.keyOf(path)
+	if key == "" && !me.rawKeys {
+		return nil, ErrIsDir
+	}
+	// Opens the item if it exists, returning whether that's the result.
+	get := func() bool {
+		ret, _, err = me.openFile(context.Background(), key, os.O_RDONLY, OpenOpts{})
+		if err == nil {
+			me.countOpen(key, true)
+		}
+		return !os.IsNotExist(err)
+	}
+	if get() {
+		return
+	}
+	op := me.fills.Lock(string(key))
+	defer op.Unlock()
+	// It may have been filled while we waited.
+	if get() {
+		return
+	}
+	me.countOpen(key, false)
+	err = me.put(key, OpenOpts{}, fill)
+	if err != nil {
+		return
+	}
+	ret, _, err = me.openFile(context.Background(), key, os.O_RDONLY, OpenOpts{})
+	return
+}
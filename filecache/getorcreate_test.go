@@ -0,0 +1,68 @@
+package filecache
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOrCreate(t *testing.T) {
+	c, _ := newTestCache(t)
+	var fills int32
+	release := make(chan struct{})
+	fill := func(w io.Writer) error {
+		atomic.AddInt32(&fills, 1)
+		<-release
+		_, err := io.WriteString(w, "filled")
+		return err
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := c.GetOrCreate("a", fill)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer f.Close()
+			b, err := io.ReadAll(f)
+			assert.NoError(t, err)
+			assert.Equal(t, "filled", string(b))
+		}()
+	}
+	close(release)
+	wg.Wait()
+	assert.EqualValues(t, 1, fills)
+	assert.Equal(t, Stats{Hits: 9, Misses: 1}, c.Info().Stats)
+	assert.NoError(t, c.Verify("a"))
+}
+
+func TestGetOrCreateFillError(t *testing.T) {
+	c, _ := newTestCache(t)
+	fillErr := errors.New("fill failed")
+	_, err := c.GetOrCreate("dir/a", func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return fillErr
+	})
+	assert.Equal(t, fillErr, err)
+	assert.Empty(t, itemPaths(c))
+
+	// Failures aren't remembered.
+	fill := func(w io.Writer) error {
+		_, err := io.WriteString(w, "ok")
+		return err
+	}
+	f, err := c.GetOrCreate("dir/a", fill)
+	require.NoError(t, err)
+	f.Close()
+	f, err = c.Namespace("n").GetOrCreate("a", fill)
+	require.NoError(t, err)
+	f.Close()
+	assert.ElementsMatch(t, []string{"dir/a", namespacesDir + "/n/a"}, itemPaths(c))
+}
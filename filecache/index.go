@@ -0,0 +1,212 @@
+package filecache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2/atomicfile"
+)
+
+// Identifies the index format.
+const indexMagic = "filecache index 4\n"
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Writes item metadata to the index path, so the next NewCacheOpts can skip
+// scanning the root. Items changed after saving are journaled, so they're
+// restated if the cache isn't closed. Does nothing if there's no index path.
+func (me *Cache) SaveIndex() error {
+	defer me.lockAllKeys()()
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.saveIndex()
+}
+
+// Stops watching free space, waits for roots removed by Clear to be
+// deleted, and saves the index, if there's an index path.
+func (me *Cache) Close() error {
+	me.stopFreeSpaceWatcher()
+	me.mu.Lock()
+	me.stopCapacityPercent()
+	if me.accessFlusher != nil {
+		me.accessFlusher.Stop()
+	}
+	if me.events != nil {
+		me.events.Close()
+	}
+	me.mu.Unlock()
+	me.clearing.Wait()
+	if err := me.SaveIndex(); err != nil {
+		return err
+	}
+	me.closeJournal()
+	return nil
+}
+
+func (me *Cache) saveIndex() (err error) {
+	if me.indexPath == "" || me.readOnly {
+		return nil
+	}
+	me.flushAccesses()
+	f, err := atomicfile.Create(me.indexPath, atomicfile.Opts{Sync: atomicfile.SyncFile})
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	h := crc32.New(castagnoli)
+	w := bufio.NewWriter(io.MultiWriter(f, h))
+	w.WriteString(indexMagic)
+	var b []byte
+	for k, i := range me.items {
+		b = appendIndexEntry(b[:0], k, i)
+		w.Write(b)
+	}
+	if err = w.Flush(); err != nil {
+		return
+	}
+	if err = binary.Write(f, binary.BigEndian, h.Sum32()); err != nil {
+		return
+	}
+	if err = f.Commit(); err != nil {
+		return
+	}
+	if err = me.startJournal(); err != nil {
+		// Without a journal, the index can't be trusted after changes.
+		os.Remove(me.indexPath)
+		return fmt.Errorf("starting index journal: %w", err)
+	}
+	return nil
+}
+
+func appendIndexEntry(b []byte, k key, i itemState) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	putUvarint := func(v uint64) {
+		b = append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+	}
+	putVarint := func(v int64) {
+		b = append(b, buf[:binary.PutVarint(buf[:], v)]...)
+	}
+	putUvarint(uint64(len(k)))
+	b = append(b, k...)
+	putVarint(i.Size)
+	putVarint(i.Accessed.UnixNano())
+	putVarint(i.Created.UnixNano())
+	putVarint(int64(i.TTL))
+	checksum := int64(-1)
+	if i.HasChecksum {
+		checksum = int64(i.Checksum)
+	}
+	putVarint(checksum)
+	putUvarint(uint64(len(i.Content)))
+	b = append(b, i.Content...)
+	putVarint(int64(i.Priority))
+	return b
+}
+
+var errBadIndex = errors.New("bad index")
+
+// Loads the index into the new cache, returning false if there isn't a
+// usable one. The index file is removed, so that if the process stops without
+// saving it again, the next start rescans rather than trusting stale data.
+func (me *Cache) loadIndex() bool {
+	if me.indexPath == "" {
+		return false
+	}
+	b, err := os.ReadFile(me.indexPath)
+	if os.IsNotExist(err) {
+		me.removeStaleJournal()
+		return false
+	}
+	if err == nil {
+		err = me.parseIndex(b)
+	}
+	if err != nil {
+		me.handleError(fmt.Errorf("ignoring index %q: %w", me.indexPath, err))
+		me.filled = 0
+		me.policy = newClassPolicy(me.clockEviction)
+		me.items = make(map[key]itemState)
+		me.expiring = newExpirySet()
+		me.namespaces = make(map[string]*namespace)
+		me.removeStaleJournal()
+		return false
+	}
+	me.replayJournal()
+	if me.readOnly {
+		// The items can't change, so the index stays valid.
+		return true
+	}
+	if err := os.Remove(me.indexPath); err != nil {
+		me.handleError(fmt.Errorf("removing loaded index: %w", err))
+	}
+	return true
+}
+
+func (me *Cache) parseIndex(b []byte) error {
+	if len(b) < len(indexMagic)+4 || string(b[:len(indexMagic)]) != indexMagic {
+		return errBadIndex
+	}
+	sum := binary.BigEndian.Uint32(b[len(b)-4:])
+	b = b[:len(b)-4]
+	if crc32.Checksum(b, castagnoli) != sum {
+		return errBadIndex
+	}
+	b = b[len(indexMagic):]
+	for len(b) != 0 {
+		k, i, n := parseIndexEntry(b)
+		if n <= 0 {
+			return errBadIndex
+		}
+		b = b[n:]
+		me.updateItem(k, func(ii *itemState, ok bool) bool {
+			*ii = i
+			return true
+		})
+	}
+	return nil
+}
+
+// Returns the number of bytes consumed, or 0 if the entry is malformed.
+func parseIndexEntry(b []byte) (k key, i itemState, n int) {
+	l, m := binary.Uvarint(b)
+	if m <= 0 || uint64(len(b)-m) < l {
+		return
+	}
+	n = m + int(l)
+	k = key(b[m:n])
+	var fields [5]int64
+	for f := range fields {
+		fields[f], m = binary.Varint(b[n:])
+		if m <= 0 {
+			return k, i, 0
+		}
+		n += m
+	}
+	i.Size = fields[0]
+	i.Accessed = time.Unix(0, fields[1])
+	i.Created = time.Unix(0, fields[2])
+	i.TTL = time.Duration(fields[3])
+	if fields[4] >= 0 {
+		i.Checksum = uint32(fields[4])
+		i.HasChecksum = true
+	}
+	l, m = binary.Uvarint(b[n:])
+	if m <= 0 || uint64(len(b)-n-m) < l {
+		return k, i, 0
+	}
+	n += m
+	i.Content = string(b[n : n+int(l)])
+	n += int(l)
+	priority, m := binary.Varint(b[n:])
+	if m <= 0 {
+		return k, i, 0
+	}
+	n += m
+	i.Priority = int(priority)
+	return
+}
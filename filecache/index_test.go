@@ -0,0 +1,62 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	td := t.TempDir()
+	root := filepath.Join(td, "root")
+	opts := CacheOpts{IndexPath: filepath.Join(td, "index")}
+	c, err := NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	createItem(t, c, "a", OpenOpts{TTL: time.Hour})
+	createItem(t, c, "dir/b", OpenOpts{})
+	want := make(map[key]ItemInfo)
+	c.WalkItems(func(i ItemInfo) { want[i.Path] = i })
+	require.NoError(t, c.Close())
+
+	// Files added behind the cache's back aren't seen, as the index is
+	// trusted instead of rescanning, until they're opened.
+	require.NoError(t, os.WriteFile(filepath.Join(root, "c"), []byte("c"), 0o644))
+	c, err = NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	got := make(map[key]ItemInfo)
+	c.WalkItems(func(i ItemInfo) { got[i.Path] = i })
+	require.Len(t, got, len(want))
+	for k, w := range want {
+		assert.True(t, w.Accessed.Equal(got[k].Accessed))
+		assert.True(t, w.Expires.Equal(got[k].Expires))
+		assert.Equal(t, w.Size, got[k].Size)
+	}
+	assert.EqualValues(t, 6, c.Info().Filled)
+	_, err = os.Stat(opts.IndexPath)
+	assert.True(t, os.IsNotExist(err))
+	f, err := c.OpenFile("c", os.O_RDONLY)
+	require.NoError(t, err)
+	f.Close()
+	assert.Equal(t, 3, c.Info().NumItems)
+
+	// Items removed behind the cache's back are forgotten when opened.
+	require.NoError(t, os.Remove(filepath.Join(root, "a")))
+	_, err = c.OpenFile("a", os.O_RDONLY)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, 2, c.Info().NumItems)
+	require.NoError(t, c.Close())
+
+	// A corrupt index falls back to a rescan.
+	b, err := os.ReadFile(opts.IndexPath)
+	require.NoError(t, err)
+	b[len(b)/2] ^= 1
+	require.NoError(t, os.WriteFile(opts.IndexPath, b, 0o644))
+	c, err = NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	<-c.Ready()
+	assert.Equal(t, 2, c.Info().NumItems)
+}
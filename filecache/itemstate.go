@@ -10,10 +10,24 @@ import (
 type itemState struct {
 	Accessed time.Time
 	Size     int64
+	// When the item was added, from which its TTL counts.
+	Created time.Time
+	// Zero to use the cache's default TTL, negative for none.
+	TTL time.Duration
+	// CRC-32C of the content, if it was known when the item was last
+	// written.
+	Checksum    uint32
+	HasChecksum bool
+	// The hash of the stored content the item's file is linked to, if it's
+	// deduplicated.
+	Content string
+	// Lower classes are evicted first.
+	Priority int
 }
 
 func (i *itemState) FromOSFileInfo(fi os.FileInfo) {
 	i.Size = fi.Size()
+	i.Created = fi.ModTime()
 	i.Accessed = missinggo.FileInfoAccessTime(fi)
 	if fi.ModTime().After(i.Accessed) {
 		i.Accessed = fi.ModTime()
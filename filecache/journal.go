@@ -0,0 +1,159 @@
+package filecache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// After the index is saved, the keys of items about to change on disk are
+// appended to a journal beside it, before each change is made. If the
+// process stops without saving the index again, loading it restats the
+// journaled items, rather than trusting their saved state. Records aren't
+// synced, so they survive the process dying, but not necessarily the
+// machine.
+
+func (me *Cache) journalPath() string {
+	return me.indexPath + ".journal"
+}
+
+// Records that the items are about to change on disk. Does nothing if no
+// index has been saved since the cache was opened.
+func (me *Cache) journalChange(ks ...key) {
+	me.journalMu.Lock()
+	defer me.journalMu.Unlock()
+	if me.journal == nil {
+		return
+	}
+	var b []byte
+	for _, k := range ks {
+		b = appendJournalEntry(b, k)
+	}
+	if _, err := me.journal.Write(b); err != nil {
+		me.handleError(fmt.Errorf("writing index journal: %w", err))
+	}
+}
+
+func appendJournalEntry(b []byte, k key) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	b = append(b, buf[:binary.PutUvarint(buf[:], uint64(len(k)))]...)
+	return append(b, k...)
+}
+
+// Starts a new journal for the index just saved. The cache must be locked,
+// and so must all the keys, so no change can be partway done. Open writable
+// Files can still change their items, so they're journaled up front.
+func (me *Cache) startJournal() error {
+	me.journalMu.Lock()
+	defer me.journalMu.Unlock()
+	me.closeJournalLocked()
+	f, err := os.Create(me.journalPath())
+	if err != nil {
+		return err
+	}
+	var b []byte
+	for k := range me.writers {
+		b = appendJournalEntry(b, k)
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	me.journal = f
+	return nil
+}
+
+func (me *Cache) closeJournalLocked() {
+	if me.journal != nil {
+		me.journal.Close()
+		me.journal = nil
+	}
+}
+
+// Stops journaling and removes the journal, for when the index has been
+// saved for the last time.
+func (me *Cache) closeJournal() {
+	me.journalMu.Lock()
+	defer me.journalMu.Unlock()
+	if me.journal == nil {
+		return
+	}
+	me.closeJournalLocked()
+	if err := os.Remove(me.journalPath()); err != nil {
+		me.handleError(fmt.Errorf("removing index journal: %w", err))
+	}
+}
+
+// Restats the items journaled since the loaded index was saved. A record
+// cut short by the process stopping ends the journal.
+func (me *Cache) replayJournal() {
+	b, err := os.ReadFile(me.journalPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		me.handleError(fmt.Errorf("reading index journal: %w", err))
+		return
+	}
+	seen := make(map[key]struct{})
+	for len(b) != 0 {
+		l, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < l {
+			break
+		}
+		k := key(b[n : n+int(l)])
+		b = b[n+int(l):]
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		me.restatItem(k)
+	}
+	me.removeStaleJournal()
+}
+
+func (me *Cache) restatItem(k key) {
+	st, ok, err := me.statKey(k)
+	if err != nil {
+		me.handleError(fmt.Errorf("restating journaled %q: %w", k, err))
+		return
+	}
+	me.updateItem(k, func(i *itemState, known bool) bool {
+		if !ok {
+			return false
+		}
+		if !known {
+			*i = st
+			return true
+		}
+		i.Size = st.Size
+		// The contents may have been rewritten.
+		i.HasChecksum = false
+		return true
+	})
+}
+
+// Removes a journal that doesn't apply to any index that'll be loaded.
+func (me *Cache) removeStaleJournal() {
+	if me.readOnly {
+		return
+	}
+	err := os.Remove(me.journalPath())
+	if err != nil && !os.IsNotExist(err) {
+		me.handleError(fmt.Errorf("removing index journal: %w", err))
+	}
+}
+
+func (me *Cache) addWriter(k key) {
+	me.mu.Lock()
+	me.writers[k]++
+	me.mu.Unlock()
+}
+
+func (me *Cache) removeWriter(k key) {
+	me.mu.Lock()
+	if me.writers[k]--; me.writers[k] == 0 {
+		delete(me.writers, k)
+	}
+	me.mu.Unlock()
+}
@@ -0,0 +1,76 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	td := t.TempDir()
+	root := filepath.Join(td, "root")
+	opts := CacheOpts{IndexPath: filepath.Join(td, "index")}
+	c, err := NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	createItem(t, c, "a", OpenOpts{})
+	createItem(t, c, "b", OpenOpts{})
+	createItem(t, c, "dir/c", OpenOpts{})
+	w, err := c.OpenFile("w", os.O_CREATE|os.O_WRONLY)
+	require.NoError(t, err)
+	require.NoError(t, c.SaveIndex())
+
+	// Change things after saving, and stop without closing.
+	require.NoError(t, c.Remove("a"))
+	require.NoError(t, c.Put("d", strings.NewReader("dddd")))
+	require.NoError(t, c.Rename("b", "e"))
+	require.NoError(t, c.RenameDir("dir", "dir2"))
+	_, err = w.Write([]byte("written"))
+	require.NoError(t, err)
+	filled := c.Info().Filled
+	paths := itemPaths(c)
+	sort.Strings(paths)
+
+	c2, err := NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	got := itemPaths(c2)
+	sort.Strings(got)
+	assert.Equal(t, []string{"d", "dir2/c", "e", "w"}, got)
+	assert.Equal(t, paths, got)
+	assert.Equal(t, filled, c2.Info().Filled)
+	_, err = os.Stat(opts.IndexPath + ".journal")
+	assert.True(t, os.IsNotExist(err))
+	w.Close()
+
+	// Closing leaves no journal behind.
+	require.NoError(t, c2.Close())
+	_, err = os.Stat(opts.IndexPath + ".journal")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestJournalCutShort(t *testing.T) {
+	td := t.TempDir()
+	root := filepath.Join(td, "root")
+	opts := CacheOpts{IndexPath: filepath.Join(td, "index")}
+	c, err := NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	createItem(t, c, "a", OpenOpts{})
+	createItem(t, c, "b", OpenOpts{})
+	require.NoError(t, c.SaveIndex())
+	require.NoError(t, c.Remove("a"))
+	require.NoError(t, os.Remove(filepath.Join(root, "b")))
+	// A record for "b" that was partly written when the process stopped.
+	f, err := os.OpenFile(opts.IndexPath+".journal", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{1})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	c, err = NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, itemPaths(c))
+}
@@ -0,0 +1,91 @@
+package filecache
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+)
+
+// Operations on an item's file hold a lock chosen by its key, so that the
+// cache's lock is only held for bookkeeping, and not while waiting on the
+// file system. Items sharing a lock only contend with each other. Key locks
+// are taken before the cache's lock, and only tried while holding it.
+const numKeyLocks = 64
+
+// A mutex that waiters can give up on. Holding it means having sent to it.
+type keyLock chan struct{}
+
+func (me *Cache) initKeyLocks() {
+	for i := range me.keyLocks {
+		me.keyLocks[i] = make(keyLock, 1)
+	}
+}
+
+func keyLockIndex(k key) int {
+	h := fnv.New32a()
+	h.Write([]byte(k))
+	return int(h.Sum32() % numKeyLocks)
+}
+
+// Locks the keys, in a consistent order to avoid deadlocks.
+func (me *Cache) lockKeys(ks ...key) (unlock func()) {
+	unlock, _ = me.lockKeysContext(context.Background(), ks...)
+	return
+}
+
+// Like lockKeys, but gives up if ctx is done first, holding none of the
+// locks.
+func (me *Cache) lockKeysContext(ctx context.Context, ks ...key) (unlock func(), err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	is := make([]int, 0, len(ks))
+	for _, k := range ks {
+		is = append(is, keyLockIndex(k))
+	}
+	sort.Ints(is)
+	var locked []keyLock
+	unlock = func() {
+		for _, l := range locked {
+			<-l
+		}
+	}
+	for j, i := range is {
+		if j != 0 && i == is[j-1] {
+			continue
+		}
+		l := me.keyLocks[i]
+		select {
+		case l <- struct{}{}:
+			locked = append(locked, l)
+		case <-ctx.Done():
+			unlock()
+			return nil, ctx.Err()
+		}
+	}
+	return
+}
+
+// Locks the key if that doesn't mean waiting, so it can be used with the
+// cache locked, such as to evict an item.
+func (me *Cache) tryLockKey(k key) (unlock func(), ok bool) {
+	l := me.keyLocks[keyLockIndex(k)]
+	select {
+	case l <- struct{}{}:
+		return func() { <-l }, true
+	default:
+		return nil, false
+	}
+}
+
+// Locks every key, for operations on many items at once.
+func (me *Cache) lockAllKeys() (unlock func()) {
+	for _, l := range me.keyLocks {
+		l <- struct{}{}
+	}
+	return func() {
+		for _, l := range me.keyLocks {
+			<-l
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package filecache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/v2/diskusage"
+)
+
+func TestLockKeysSameLock(t *testing.T) {
+	c, _ := newTestCache(t)
+	// Keys sharing a lock must not deadlock.
+	a := key("a")
+	b := a
+	for i := 0; b == a || keyLockIndex(a) != keyLockIndex(b); i++ {
+		b = key(fmt.Sprint(i))
+	}
+	c.lockKeys(a, b, a)()
+}
+
+func TestContextOps(t *testing.T) {
+	c, _ := newTestCache(t)
+	createItem(t, c, "a", OpenOpts{})
+	createItem(t, c, "b", OpenOpts{})
+	unlock := c.lockKeys("a")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.OpenFileContext(ctx, "a", os.O_RDONLY, OpenOpts{})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, context.DeadlineExceeded, c.RemoveContext(ctx, "a"))
+	assert.Equal(t, context.DeadlineExceeded, c.RenameContext(ctx, "b", "a"))
+	// Locks taken before giving up are released.
+	c.lockKeys("b")()
+	unlock()
+	assert.ElementsMatch(t, []string{"a", "b"}, itemPaths(c))
+	// Only the creations count.
+	assert.EqualValues(t, 2, c.Info().Stats.Misses)
+	f, err := c.OpenFileContext(context.Background(), "a", os.O_RDONLY, OpenOpts{})
+	require.NoError(t, err)
+	f.Close()
+	cancel()
+	_, err = c.Namespace("ns").OpenFileContext(ctx, "a", os.O_RDONLY, OpenOpts{})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+// Mixes operations on overlapping keys, then checks the accounting matches
+// what's on disk.
+func TestConcurrentOps(t *testing.T) {
+	c, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+	<-c.Ready()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < 200; i++ {
+				k := fmt.Sprintf("d%d/%d", r.Intn(3), r.Intn(10))
+				switch r.Intn(4) {
+				case 0:
+					f, err := c.OpenFile(k, os.O_CREATE|os.O_WRONLY)
+					if err == nil {
+						f.WriteAt(make([]byte, r.Intn(100)), int64(r.Intn(100)))
+						f.Close()
+					}
+				case 1:
+					c.Put(k, bytes.NewReader(make([]byte, r.Intn(100))))
+				case 2:
+					c.Remove(k)
+				case 3:
+					c.Rename(k, fmt.Sprintf("d%d/%d", r.Intn(3), r.Intn(10)))
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+	u, err := diskusage.Scan(context.Background(), c.root, diskusage.Opts{})
+	require.NoError(t, err)
+	info := c.Info()
+	assert.EqualValues(t, u.Total.Files, info.NumItems)
+	assert.EqualValues(t, u.Total.Bytes, info.Filled)
+}
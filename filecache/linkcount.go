@@ -0,0 +1,20 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package filecache
+
+import (
+	"os"
+	"syscall"
+)
+
+const hardLinksSupported = true
+
+// Returns the number of hard links to the file at path.
+func linkCount(path string) (int, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return int(fi.Sys().(*syscall.Stat_t).Nlink), nil
+}
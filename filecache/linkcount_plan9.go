@@ -0,0 +1,14 @@
+package filecache
+
+import "os"
+
+// Plan 9 file systems don't have hard links.
+const hardLinksSupported = false
+
+func linkCount(path string) (int, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
@@ -0,0 +1,24 @@
+package filecache
+
+import (
+	"os"
+	"syscall"
+)
+
+const hardLinksSupported = true
+
+// Returns the number of hard links to the file at path. The information
+// returned by Stat doesn't include it.
+func linkCount(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var d syscall.ByHandleFileInformation
+	err = syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &d)
+	if err != nil {
+		return 0, &os.PathError{Op: "GetFileInformationByHandle", Path: path, Err: err}
+	}
+	return int(d.NumberOfLinks), nil
+}
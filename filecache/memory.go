@@ -0,0 +1,135 @@
+package filecache
+
+import (
+	"bytes"
+	"os"
+	"time"
+)
+
+// Holds the stored bytes of small items that were read recently, so they can
+// be opened for reading without the filesystem. Writes go to the files, and
+// drop the items from memory. It's guarded by Cache.mu.
+type memoryLayer struct {
+	capacity    int64
+	maxItemSize int64
+	filled      int64
+	items       map[key]memoryItem
+	policy      lru
+	// Increases whenever an item is dropped, so loads that raced with a
+	// change can be discarded.
+	drops uint64
+}
+
+type memoryItem struct {
+	b  []byte
+	fi os.FileInfo
+}
+
+func newMemoryLayer(capacity, maxItemSize int64) *memoryLayer {
+	if maxItemSize <= 0 || maxItemSize > capacity {
+		maxItemSize = capacity
+	}
+	return &memoryLayer{
+		capacity:    capacity,
+		maxItemSize: maxItemSize,
+		items:       make(map[key]memoryItem),
+	}
+}
+
+func (me *memoryLayer) get(k key, now time.Time) (memoryItem, bool) {
+	if me == nil {
+		return memoryItem{}, false
+	}
+	i, ok := me.items[k]
+	if ok {
+		me.policy.Used(k, now)
+	}
+	return i, ok
+}
+
+func (me *memoryLayer) add(k key, i memoryItem, now time.Time) {
+	me.drop(k)
+	me.items[k] = i
+	me.filled += int64(len(i.b))
+	me.policy.Used(k, now)
+	for me.filled > me.capacity {
+		me.drop(me.policy.Choose().(key))
+	}
+}
+
+func (me *memoryLayer) drop(k key) {
+	if me == nil {
+		return
+	}
+	me.drops++
+	i, ok := me.items[k]
+	if !ok {
+		return
+	}
+	me.filled -= int64(len(i.b))
+	delete(me.items, k)
+	me.policy.Forget(k)
+}
+
+// Returns the item from memory, if it's there.
+func (me *Cache) openMemory(k key) *memoryFile {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	i, ok := me.memory.get(k, me.clock.Now())
+	if !ok {
+		return nil
+	}
+	return &memoryFile{bytes.NewReader(i.b), i.fi}
+}
+
+// Puts the item in memory from f, if it's small enough, and returns it from
+// there, closing f. Otherwise returns nil, and f is left open.
+func (me *Cache) loadMemory(k key, f *os.File) *memoryFile {
+	if me.memory == nil {
+		return nil
+	}
+	me.mu.Lock()
+	drops := me.memory.drops
+	me.mu.Unlock()
+	fi, err := f.Stat()
+	if err != nil || fi.Size() > me.memory.maxItemSize {
+		return nil
+	}
+	b := make([]byte, fi.Size())
+	if n, _ := f.ReadAt(b, 0); n != len(b) {
+		return nil
+	}
+	f.Close()
+	me.mu.Lock()
+	if me.memory.drops == drops {
+		me.memory.add(k, memoryItem{b, fi}, me.clock.Now())
+	}
+	me.mu.Unlock()
+	return &memoryFile{bytes.NewReader(b), fi}
+}
+
+// A read-only item served from memory.
+type memoryFile struct {
+	*bytes.Reader
+	fi os.FileInfo
+}
+
+func (me *memoryFile) Write([]byte) (int, error) {
+	return 0, me.readOnly("write")
+}
+
+func (me *memoryFile) WriteAt([]byte, int64) (int, error) {
+	return 0, me.readOnly("write")
+}
+
+func (me *memoryFile) readOnly(op string) error {
+	return &os.PathError{Op: op, Path: me.fi.Name(), Err: os.ErrPermission}
+}
+
+func (me *memoryFile) Stat() (os.FileInfo, error) {
+	return me.fi, nil
+}
+
+func (me *memoryFile) Close() error {
+	return nil
+}
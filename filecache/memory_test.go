@@ -0,0 +1,89 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/v2/pproffd"
+)
+
+func TestMemoryLayer(t *testing.T) {
+	c, err := NewCacheOpts(t.TempDir(), CacheOpts{
+		MemoryCapacity:    7,
+		MaxMemoryItemSize: 5,
+	})
+	require.NoError(t, err)
+	// Changes made behind the cache's back show what's served from memory.
+	overwrite := func(path, s string) {
+		require.NoError(t, os.WriteFile(filepath.Join(c.root, path), []byte(s), 0o640))
+	}
+	for _, p := range []string{"a", "bb", "toolong"} {
+		require.NoError(t, c.Put(p, strings.NewReader(p)))
+	}
+	assert.Equal(t, "a", readItem(t, c, "a"))
+	assert.EqualValues(t, 1, c.Info().Memory)
+	overwrite("a", "A")
+	assert.Equal(t, "a", readItem(t, c, "a"))
+
+	// Too large to hold.
+	assert.Equal(t, "toolong", readItem(t, c, "toolong"))
+	assert.EqualValues(t, 1, c.Info().Memory)
+
+	// Writes go through, and drop the item from memory.
+	_, err = c.WriteAt("a", []byte("w"), 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, c.Info().Memory)
+	assert.Equal(t, "w", readItem(t, c, "a"))
+	assert.Equal(t, "bb", readItem(t, c, "bb"))
+	assert.EqualValues(t, 3, c.Info().Memory)
+
+	// Replacing and removing items drops them too.
+	require.NoError(t, c.Put("bb", strings.NewReader("cc")))
+	assert.Equal(t, "cc", readItem(t, c, "bb"))
+	require.NoError(t, c.Remove("a"))
+	assert.EqualValues(t, 2, c.Info().Memory)
+
+	// The least recently read items are dropped to make room.
+	for _, p := range []string{"d", "eeeee"} {
+		require.NoError(t, c.Put(p, strings.NewReader(p)))
+		readItem(t, c, p)
+	}
+	assert.EqualValues(t, 6, c.Info().Memory)
+	overwrite("bb", "xx")
+	assert.Equal(t, "xx", readItem(t, c, "bb"))
+}
+
+func TestMemoryLayerCompressed(t *testing.T) {
+	c, err := NewCacheOpts(t.TempDir(), CacheOpts{
+		Compress:       true,
+		MemoryCapacity: 1 << 10,
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.Put("a", strings.NewReader("a")))
+	assert.Equal(t, "a", readItem(t, c, "a"))
+	assert.NotZero(t, c.Info().Memory)
+	require.NoError(t, os.Remove(filepath.Join(c.root, "a")))
+	assert.Equal(t, "a", readItem(t, c, "a"))
+}
+
+func TestMemoryLayerTracksHandles(t *testing.T) {
+	pproffd.Enable()
+	c, err := NewCacheOpts(t.TempDir(), CacheOpts{MemoryCapacity: 1 << 10})
+	require.NoError(t, err)
+	require.NoError(t, c.Put("a", strings.NewReader("a")))
+	handles := len(pproffd.OpenHandles())
+	// Loaded into memory, and then served from there.
+	for range [2]struct{}{} {
+		f, err := c.OpenFile("a", os.O_RDONLY)
+		require.NoError(t, err)
+		assert.Len(t, pproffd.OpenHandles(), handles+1)
+		f.Close()
+		assert.Len(t, pproffd.OpenHandles(), handles)
+	}
+	assert.NotZero(t, c.Info().Memory)
+}
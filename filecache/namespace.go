@@ -0,0 +1,257 @@
+package filecache
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/anacrolix/missinggo/v2/pathsan"
+)
+
+// Namespace items are stored under this directory in the root, in a
+// directory per namespace.
+const namespacesDir = ".namespaces"
+
+// Accounting for a namespace's items, which also count toward the cache's
+// totals. Methods are no-ops on nil, which stands for the default
+// namespace, as it has no accounting of its own.
+type namespace struct {
+	capacity int64
+	filled   int64
+	pinned   int64
+	numItems int
+	policy   *classPolicy
+	stats    Stats
+}
+
+// Returns the state for the namespace holding k, creating it if necessary,
+// or nil for the default namespace.
+func (me *Cache) namespaceOf(k key) *namespace {
+	rest := strings.TrimPrefix(string(k), namespacesDir+"/")
+	if len(rest) == len(k) {
+		return nil
+	}
+	i := strings.IndexByte(rest, '/')
+	if i <= 0 {
+		return nil
+	}
+	return me.namespace(rest[:i])
+}
+
+func (me *Cache) namespace(name string) *namespace {
+	ns := me.namespaces[name]
+	if ns == nil {
+		ns = &namespace{capacity: -1, policy: newClassPolicy(me.clockEviction)}
+		me.namespaces[name] = ns
+	}
+	return ns
+}
+
+func (me *namespace) add(k key, i itemState, pinned bool) {
+	if me == nil {
+		return
+	}
+	me.filled += i.Size
+	me.numItems++
+	if pinned {
+		me.pinned += i.Size
+	} else {
+		me.policy.Used(k, i.Accessed, i.Priority)
+	}
+}
+
+func (me *namespace) forget(k key, i itemState, pinned bool) {
+	if me == nil {
+		return
+	}
+	me.filled -= i.Size
+	me.numItems--
+	if pinned {
+		me.pinned -= i.Size
+	} else {
+		me.policy.Forget(k)
+	}
+}
+
+func (me *Cache) trimNamespace(ns *namespace) {
+	if ns == nil || ns.capacity < 0 || me.readOnly {
+		return
+	}
+	me.trimPolicy(ns.policy, func() bool { return ns.filled > ns.capacity })
+}
+
+// A view of the cache whose items have their own capacity and eviction, and
+// also count toward the cache's. Paths are relative to the namespace.
+type Namespace struct {
+	c      *Cache
+	name   string
+	prefix string
+}
+
+// Returns the named namespace, whose items are kept in the root under
+// .namespaces/name. Panics if the name isn't a valid path component.
+func (me *Cache) Namespace(name string) *Namespace {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		panic("bad namespace name: " + name)
+	}
+	return &Namespace{
+		c:      me,
+		name:   name,
+		prefix: namespacesDir + "/" + name + "/",
+	}
+}
+
+func (me *Namespace) Name() string {
+	return me.name
+}
+
+// Returns the path in the cache for a path in the namespace.
+func (me *Namespace) path(p string) (string, error) {
+	if me.c.rawKeys {
+		return me.prefix + p, nil
+	}
+	p = pathsan.Clean(p)
+	if p == "" {
+		return "", ErrBadPath
+	}
+	return me.prefix + p, nil
+}
+
+func (me *Namespace) OpenFile(path string, flag int) (*File, error) {
+	return me.OpenFileOpts(path, flag, OpenOpts{})
+}
+
+func (me *Namespace) OpenFileOpts(path string, flag int, opts OpenOpts) (*File, error) {
+	return me.OpenFileContext(context.Background(), path, flag, opts)
+}
+
+func (me *Namespace) OpenFileContext(ctx context.Context, path string, flag int, opts OpenOpts) (*File, error) {
+	p, err := me.path(path)
+	if err != nil {
+		return nil, ErrIsDir
+	}
+	return me.c.OpenFileContext(ctx, p, flag, opts)
+}
+
+func (me *Namespace) Put(path string, r io.Reader) error {
+	return me.PutOpts(path, r, OpenOpts{})
+}
+
+func (me *Namespace) PutOpts(path string, r io.Reader, opts OpenOpts) error {
+	p, err := me.path(path)
+	if err != nil {
+		return ErrIsDir
+	}
+	return me.c.PutOpts(p, r, opts)
+}
+
+func (me *Namespace) Verify(path string) error {
+	p, err := me.path(path)
+	if err != nil {
+		return ErrIsDir
+	}
+	return me.c.Verify(p)
+}
+
+func (me *Namespace) GetOrCreate(path string, fill func(io.Writer) error) (*File, error) {
+	p, err := me.path(path)
+	if err != nil {
+		return nil, ErrIsDir
+	}
+	return me.c.GetOrCreate(p, fill)
+}
+
+func (me *Namespace) Stat(path string) (os.FileInfo, error) {
+	p, err := me.path(path)
+	if err != nil {
+		return nil, err
+	}
+	return me.c.Stat(p)
+}
+
+func (me *Namespace) Remove(path string) error {
+	return me.RemoveContext(context.Background(), path)
+}
+
+func (me *Namespace) RemoveContext(ctx context.Context, path string) error {
+	p, err := me.path(path)
+	if err != nil {
+		return err
+	}
+	return me.c.RemoveContext(ctx, p)
+}
+
+func (me *Namespace) Rename(from, to string) error {
+	return me.RenameContext(context.Background(), from, to)
+}
+
+func (me *Namespace) RenameContext(ctx context.Context, from, to string) error {
+	f, err := me.path(from)
+	if err != nil {
+		return err
+	}
+	t, err := me.path(to)
+	if err != nil {
+		return err
+	}
+	return me.c.RenameContext(ctx, f, t)
+}
+
+func (me *Namespace) RenameDir(from, to string) error {
+	f, err := me.path(from)
+	if err != nil {
+		return err
+	}
+	t, err := me.path(to)
+	if err != nil {
+		return err
+	}
+	return me.c.RenameDir(f, t)
+}
+
+func (me *Namespace) Pin(path string) {
+	if p, err := me.path(path); err == nil {
+		me.c.Pin(p)
+	}
+}
+
+func (me *Namespace) Unpin(path string) {
+	if p, err := me.path(path); err == nil {
+		me.c.Unpin(p)
+	}
+}
+
+// Setting a negative capacity means unlimited, subject to the cache's.
+func (me *Namespace) SetCapacity(capacity int64) {
+	me.c.mu.Lock()
+	defer me.c.mu.Unlock()
+	me.c.namespace(me.name).capacity = capacity
+}
+
+func (me *Namespace) Info() CacheInfo {
+	me.c.mu.Lock()
+	defer me.c.mu.Unlock()
+	ns := me.c.namespace(me.name)
+	return CacheInfo{
+		Capacity: ns.capacity,
+		Filled:   ns.filled,
+		NumItems: ns.numItems,
+		Pinned:   ns.pinned,
+		Stats:    ns.stats,
+	}
+}
+
+// Calls cb for each item in the namespace, with paths relative to it.
+func (me *Namespace) WalkItems(cb func(ItemInfo)) {
+	me.WalkItemsOpts(WalkOpts{}, func(i ItemInfo) bool {
+		cb(i)
+		return true
+	})
+}
+
+// Like Cache.WalkItemsOpts, with paths, including the prefix, relative to
+// the namespace.
+func (me *Namespace) WalkItemsOpts(opts WalkOpts, cb func(ItemInfo) bool) {
+	me.c.walkItems(me.prefix, opts, cb)
+}
@@ -0,0 +1,80 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createNamespaceItem(t *testing.T, ns *Namespace, path string, size int) {
+	f, err := ns.OpenFile(path, os.O_CREATE|os.O_WRONLY)
+	require.NoError(t, err)
+	_, err = f.Write(make([]byte, size))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func namespacePaths(ns *Namespace) (ret []string) {
+	ns.WalkItems(func(i ItemInfo) {
+		ret = append(ret, string(i.Path))
+	})
+	return
+}
+
+func TestNamespaces(t *testing.T) {
+	c, fc := newTestCache(t)
+	a := c.Namespace("a")
+	b := c.Namespace("b")
+	a.SetCapacity(10)
+	createNamespaceItem(t, a, "1", 5)
+	fc.Advance(time.Second)
+	createNamespaceItem(t, b, "1", 20)
+	fc.Advance(time.Second)
+	createNamespaceItem(t, a, "2", 5)
+	fc.Advance(time.Second)
+	createItem(t, c, "x", OpenOpts{})
+	assert.Equal(t, CacheInfo{Capacity: 10, Filled: 10, NumItems: 2, Stats: Stats{Misses: 2}}, a.Info())
+	assert.Equal(t, CacheInfo{Capacity: -1, Filled: 20, NumItems: 1, Stats: Stats{Misses: 1}}, b.Info())
+	assert.Equal(t, CacheInfo{Capacity: -1, Filled: 31, NumItems: 4, Stats: Stats{Misses: 4}}, c.Info())
+	_, err := os.Stat(filepath.Join(c.root, namespacesDir, "a", "1"))
+	assert.NoError(t, err)
+
+	// Exceeding a's capacity evicts from a, though b's item is older.
+	fc.Advance(time.Second)
+	createNamespaceItem(t, a, "3", 5)
+	assert.ElementsMatch(t, []string{"2", "3"}, namespacePaths(a))
+	assert.Equal(t, []string{"1"}, namespacePaths(b))
+
+	// The cache's capacity applies across namespaces.
+	c.SetCapacity(15)
+	c.TrimToCapacity()
+	assert.Empty(t, namespacePaths(b))
+	assert.Equal(t, CacheInfo{
+		Capacity: 15, Filled: 11, NumItems: 3,
+		Stats: Stats{Misses: 5, Evictions: 2, EvictedBytes: 25},
+	}, c.Info())
+
+	require.NoError(t, a.Rename("3", "4"))
+	require.NoError(t, a.Remove("2"))
+	assert.Equal(t, []string{"4"}, namespacePaths(a))
+	assert.Equal(t, CacheInfo{
+		Capacity: 10, Filled: 5, NumItems: 1,
+		Stats: Stats{Misses: 3, Evictions: 1, EvictedBytes: 5},
+	}, a.Info())
+	assert.Panics(t, func() { c.Namespace("a/b") })
+}
+
+func TestNamespacesRescan(t *testing.T) {
+	root := t.TempDir()
+	c, err := NewCache(root)
+	require.NoError(t, err)
+	createNamespaceItem(t, c.Namespace("n"), "a", 3)
+	c, err = NewCache(root)
+	require.NoError(t, err)
+	<-c.Ready()
+	assert.Equal(t, CacheInfo{Capacity: -1, Filled: 3, NumItems: 1}, c.Namespace("n").Info())
+}
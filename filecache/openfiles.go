@@ -0,0 +1,42 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+)
+
+// Returned when opening an item would exceed CacheOpts.MaxOpenFiles, and
+// CacheOpts.FailAtMaxOpenFiles is set.
+var ErrTooManyOpenFiles = errors.New("too many open files")
+
+// Takes a slot for a File, waiting for one if the number open is limited.
+func (me *Cache) acquireFile(ctx context.Context) error {
+	if me.fileSlots != nil {
+		if me.failAtMaxOpenFiles {
+			select {
+			case me.fileSlots <- struct{}{}:
+			default:
+				return ErrTooManyOpenFiles
+			}
+		} else {
+			select {
+			case me.fileSlots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	me.mu.Lock()
+	me.openFiles++
+	me.mu.Unlock()
+	return nil
+}
+
+func (me *Cache) releaseFile() {
+	me.mu.Lock()
+	me.openFiles--
+	me.mu.Unlock()
+	if me.fileSlots != nil {
+		<-me.fileSlots
+	}
+}
@@ -0,0 +1,65 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxOpenFiles(t *testing.T) {
+	c, err := NewCacheOpts(t.TempDir(), CacheOpts{MaxOpenFiles: 1})
+	require.NoError(t, err)
+	a, err := c.OpenFile("a", os.O_CREATE|os.O_WRONLY)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Info().OpenFiles)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = c.OpenFileContext(ctx, "b", os.O_CREATE|os.O_WRONLY, OpenOpts{})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.Equal(t, 1, c.Info().OpenFiles)
+
+	opened := make(chan *File)
+	go func() {
+		b, err := c.OpenFile("b", os.O_CREATE|os.O_WRONLY)
+		assert.NoError(t, err)
+		opened <- b
+	}()
+	select {
+	case <-opened:
+		t.Fatal("opened past the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	require.NoError(t, a.Close())
+	// Closing again doesn't free another slot.
+	a.Close()
+	b := <-opened
+	assert.Equal(t, 1, c.Info().OpenFiles)
+	require.NoError(t, b.Close())
+	assert.Equal(t, 0, c.Info().OpenFiles)
+}
+
+func TestFailAtMaxOpenFiles(t *testing.T) {
+	c, err := NewCacheOpts(t.TempDir(), CacheOpts{
+		MaxOpenFiles:       1,
+		FailAtMaxOpenFiles: true,
+	})
+	require.NoError(t, err)
+	a, err := c.OpenFile("a", os.O_CREATE|os.O_WRONLY)
+	require.NoError(t, err)
+	_, err = c.OpenFile("b", os.O_CREATE|os.O_WRONLY)
+	assert.Equal(t, ErrTooManyOpenFiles, err)
+	require.NoError(t, a.Close())
+	// Opens that fail for other reasons don't keep a slot.
+	_, err = c.OpenFile("c", os.O_RDONLY)
+	assert.True(t, os.IsNotExist(err), err)
+	b, err := c.OpenFile("b", os.O_CREATE|os.O_WRONLY)
+	require.NoError(t, err)
+	require.NoError(t, b.Close())
+	assert.Equal(t, 0, c.Info().OpenFiles)
+}
@@ -0,0 +1,47 @@
+package filecache
+
+// Protects the item at path from eviction, and expiry, until a matching
+// Unpin. Pins nest, and can be placed before the item exists. Explicit
+// removal isn't prevented.
+func (me *Cache) Pin(path string) {
+	k := me.keyOf(path)
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.pins[k]++
+	if me.pins[k] != 1 {
+		return
+	}
+	if i, ok := me.items[k]; ok {
+		ns := me.namespaceOf(k)
+		ns.forget(k, i, false)
+		me.policy.Forget(k)
+		me.pinned += i.Size
+		ns.add(k, i, true)
+	}
+}
+
+// Releases a Pin. When no pins remain, the item can be evicted again, which
+// may happen immediately if the cache is over capacity.
+func (me *Cache) Unpin(path string) {
+	k := me.keyOf(path)
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	switch me.pins[k] {
+	case 0:
+		panic("unpinned item that wasn't pinned")
+	case 1:
+		delete(me.pins, k)
+	default:
+		me.pins[k]--
+		return
+	}
+	ns := me.namespaceOf(k)
+	if i, ok := me.items[k]; ok {
+		ns.forget(k, i, true)
+		me.policy.Used(k, i.Accessed, i.Priority)
+		me.pinned -= i.Size
+		ns.add(k, i, false)
+	}
+	me.trimNamespace(ns)
+	me.trimToCapacity()
+}
@@ -0,0 +1,34 @@
+package filecache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPin(t *testing.T) {
+	c, fc := newTestCache(t)
+	c.Pin("a")
+	createItem(t, c, "a", OpenOpts{TTL: time.Second})
+	fc.Advance(time.Second)
+	createItem(t, c, "bb", OpenOpts{})
+	c.Pin("bb")
+	c.Pin("bb")
+	assert.Equal(t, CacheInfo{Capacity: -1, Filled: 3, NumItems: 2, Pinned: 3, Stats: Stats{Misses: 2}}, c.Info())
+
+	// Everything's pinned, so nothing can be evicted, or expired.
+	c.SetCapacity(0)
+	c.TrimToCapacity()
+	assert.Equal(t, 0, c.RemoveExpired())
+	assert.ElementsMatch(t, []string{"a", "bb"}, itemPaths(c))
+
+	c.Unpin("bb")
+	assert.Equal(t, 2, c.Info().NumItems)
+	c.Unpin("bb")
+	assert.Equal(t, []string{"a"}, itemPaths(c))
+	assert.EqualValues(t, 1, c.Info().Pinned)
+	c.Unpin("a")
+	assert.Equal(t, CacheInfo{Stats: Stats{Misses: 2, Evictions: 2, EvictedBytes: 3}}, c.Info())
+	assert.Panics(t, func() { c.Unpin("a") })
+}
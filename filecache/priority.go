@@ -0,0 +1,86 @@
+package filecache
+
+import "time"
+
+// Keeps a policy for each priority class, and chooses from the lowest class
+// that has items, so higher classes are only evicted once lower ones are
+// empty.
+type classPolicy struct {
+	classes   map[int]Policy
+	itemClass map[policyItemKey]int
+	// Makes the policy for a class. Defaults to LRU.
+	newPolicy func() Policy
+}
+
+func newClassPolicy(clock bool) *classPolicy {
+	ret := new(classPolicy)
+	if clock {
+		ret.newPolicy = func() Policy { return new(clockPolicy) }
+	}
+	return ret
+}
+
+func (me *classPolicy) Used(k policyItemKey, at time.Time, class int) {
+	if c, ok := me.itemClass[k]; ok && c != class {
+		me.Forget(k)
+	}
+	if me.classes == nil {
+		me.classes = make(map[int]Policy)
+		me.itemClass = make(map[policyItemKey]int)
+	}
+	p := me.classes[class]
+	if p == nil {
+		if me.newPolicy != nil {
+			p = me.newPolicy()
+		} else {
+			p = new(lru)
+		}
+		me.classes[class] = p
+	}
+	p.Used(k, at)
+	me.itemClass[k] = class
+}
+
+func (me *classPolicy) Forget(k policyItemKey) {
+	c, ok := me.itemClass[k]
+	if !ok {
+		return
+	}
+	delete(me.itemClass, k)
+	p := me.classes[c]
+	p.Forget(k)
+	if p.NumItems() == 0 {
+		delete(me.classes, c)
+	}
+}
+
+func (me *classPolicy) Choose() policyItemKey {
+	var lowest Policy
+	var lowestClass int
+	for c, p := range me.classes {
+		if lowest == nil || c < lowestClass {
+			lowest, lowestClass = p, c
+		}
+	}
+	if lowest == nil {
+		panic("cache empty")
+	}
+	return lowest.Choose()
+}
+
+func (me *classPolicy) NumItems() int {
+	return len(me.itemClass)
+}
+
+// Sets the priority class of the item at path, if it exists. Trimming
+// evicts from lower classes before higher ones, and by the usual policy
+// within a class. The default class is zero.
+func (me *Cache) SetPriority(path string, class int) {
+	k := me.keyOf(path)
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.updateItem(k, func(i *itemState, ok bool) bool {
+		i.Priority = class
+		return ok
+	})
+}
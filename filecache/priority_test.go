@@ -0,0 +1,45 @@
+package filecache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriority(t *testing.T) {
+	c, fc := newTestCache(t)
+	createItem(t, c, "meta", OpenOpts{Priority: 1})
+	fc.Advance(time.Second)
+	for _, p := range []string{"bulk1", "bulk2", "bulk3"} {
+		createItem(t, c, p, OpenOpts{})
+		fc.Advance(time.Second)
+	}
+	c.SetPriority("bulk3", -1)
+	c.SetMaxItems(3)
+	c.TrimToCapacity()
+	assert.ElementsMatch(t, []string{"meta", "bulk1", "bulk2"}, itemPaths(c))
+	c.SetMaxItems(1)
+	c.TrimToCapacity()
+	assert.Equal(t, []string{"meta"}, itemPaths(c))
+	var infos []ItemInfo
+	c.WalkItems(func(i ItemInfo) { infos = append(infos, i) })
+	assert.Equal(t, 1, infos[0].Priority)
+}
+
+func TestPriorityIndex(t *testing.T) {
+	td := t.TempDir()
+	opts := CacheOpts{IndexPath: filepath.Join(td, "index")}
+	c, err := NewCacheOpts(filepath.Join(td, "root"), opts)
+	require.NoError(t, err)
+	createItem(t, c, "a", OpenOpts{Priority: 2})
+	createItem(t, c, "b", OpenOpts{})
+	require.NoError(t, c.Close())
+	c, err = NewCacheOpts(filepath.Join(td, "root"), opts)
+	require.NoError(t, err)
+	c.SetMaxItems(1)
+	c.TrimToCapacity()
+	assert.Equal(t, []string{"a"}, itemPaths(c))
+}
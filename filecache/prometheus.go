@@ -0,0 +1,83 @@
+package filecache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type collector struct {
+	c *Cache
+
+	capacity     *prometheus.Desc
+	filled       *prometheus.Desc
+	pinned       *prometheus.Desc
+	items        *prometheus.Desc
+	openFiles    *prometheus.Desc
+	hits         *prometheus.Desc
+	misses       *prometheus.Desc
+	hitRatio     *prometheus.Desc
+	evictions    *prometheus.Desc
+	evictedBytes *prometheus.Desc
+	expirations  *prometheus.Desc
+	trims        *prometheus.Desc
+}
+
+// Returns a Prometheus collector of the cache's Info, and of how long trims
+// take. The labels distinguish caches registered together. Capacity is -1
+// when unlimited.
+func (me *Cache) PrometheusCollector(constLabels prometheus.Labels) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("filecache_"+name, help, nil, constLabels)
+	}
+	return &collector{
+		c:            me,
+		capacity:     desc("capacity_bytes", "Bytes the cache may fill."),
+		filled:       desc("filled_bytes", "Bytes in the cache's items."),
+		pinned:       desc("pinned_bytes", "Bytes in pinned items."),
+		items:        desc("items", "Items in the cache."),
+		openFiles:    desc("open_files", "Files opened and not yet closed."),
+		hits:         desc("hits_total", "Opens of items that were in the cache."),
+		misses:       desc("misses_total", "Opens of items that weren't in the cache."),
+		hitRatio:     desc("hit_ratio", "Fraction of opens that were hits."),
+		evictions:    desc("evictions_total", "Items removed to make room."),
+		evictedBytes: desc("evicted_bytes_total", "Bytes in items removed to make room."),
+		expirations:  desc("expirations_total", "Items removed because their TTL passed."),
+		trims:        desc("trim_duration_seconds", "Time spent evicting items when over capacity."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (me *collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		me.capacity, me.filled, me.pinned, me.items, me.openFiles,
+		me.hits, me.misses, me.hitRatio,
+		me.evictions, me.evictedBytes, me.expirations, me.trims,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (me *collector) Collect(ch chan<- prometheus.Metric) {
+	info := me.c.Info()
+	me.c.mu.Lock()
+	trims, trimTime := me.c.trims, me.c.trimTime
+	me.c.mu.Unlock()
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v int64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v))
+	}
+	gauge(me.capacity, float64(info.Capacity))
+	gauge(me.filled, float64(info.Filled))
+	gauge(me.pinned, float64(info.Pinned))
+	gauge(me.items, float64(info.NumItems))
+	gauge(me.openFiles, float64(info.OpenFiles))
+	counter(me.hits, info.Stats.Hits)
+	counter(me.misses, info.Stats.Misses)
+	gauge(me.hitRatio, info.Stats.HitRate())
+	counter(me.evictions, info.Stats.Evictions)
+	counter(me.evictedBytes, info.Stats.EvictedBytes)
+	counter(me.expirations, info.Stats.Expirations)
+	ch <- prometheus.MustNewConstSummary(me.trims, uint64(trims), trimTime.Seconds(), nil)
+}
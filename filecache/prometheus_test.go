@@ -0,0 +1,56 @@
+package filecache
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+	c, _ := newTestCache(t)
+	<-c.Ready()
+	c.SetCapacity(3)
+	for _, p := range []string{"a", "b", "c", "d"} {
+		createItem(t, c, p, OpenOpts{})
+	}
+	f, err := c.OpenFile("d", 0)
+	require.NoError(t, err)
+	defer f.Close()
+	col := c.PrometheusCollector(prometheus.Labels{"cache": "test"})
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(col))
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP filecache_capacity_bytes Bytes the cache may fill.
+# TYPE filecache_capacity_bytes gauge
+filecache_capacity_bytes{cache="test"} 3
+# HELP filecache_evictions_total Items removed to make room.
+# TYPE filecache_evictions_total counter
+filecache_evictions_total{cache="test"} 1
+# HELP filecache_filled_bytes Bytes in the cache's items.
+# TYPE filecache_filled_bytes gauge
+filecache_filled_bytes{cache="test"} 3
+# HELP filecache_hit_ratio Fraction of opens that were hits.
+# TYPE filecache_hit_ratio gauge
+filecache_hit_ratio{cache="test"} 0.2
+# HELP filecache_items Items in the cache.
+# TYPE filecache_items gauge
+filecache_items{cache="test"} 3
+# HELP filecache_open_files Files opened and not yet closed.
+# TYPE filecache_open_files gauge
+filecache_open_files{cache="test"} 1
+`), "filecache_capacity_bytes", "filecache_evictions_total", "filecache_filled_bytes",
+		"filecache_hit_ratio", "filecache_items", "filecache_open_files"))
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		if mf.GetName() == "filecache_trim_duration_seconds" {
+			assert.EqualValues(t, 1, mf.Metric[0].Summary.GetSampleCount())
+			return
+		}
+	}
+	t.Fatal("no trim durations")
+}
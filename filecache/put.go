@@ -0,0 +1,133 @@
+package filecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/anacrolix/missinggo/v2/atomicfile"
+)
+
+// Writes the item from r, replacing any existing one. Readers see the old
+// content or the new, never a partial write, and a write that fails or is
+// interrupted by a crash leaves the old content. A checksum of the content
+// is kept for Verify.
+func (me *Cache) Put(path string, r io.Reader) error {
+	return me.PutOpts(path, r, OpenOpts{})
+}
+
+// Like Put, with options as for OpenFileOpts.
+func (me *Cache) PutOpts(path string, r io.Reader, opts OpenOpts) error {
+	key := me.keyOf(path)
+	if key == "" && !me.rawKeys {
+		return ErrIsDir
+	}
+	return me.put(key, opts, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
+// Replaces the item atomically with what fill writes.
+func (me *Cache) put(key key, opts OpenOpts, fill func(io.Writer) error) (err error) {
+	if me.compress {
+		fill = compressFill(fill)
+	}
+	if me.aead != nil {
+		fill = encryptFill(me.aead, fill)
+	}
+	return me.writeItem(key, fill, func(i *itemState, prev itemState) {
+		i.TTL = prev.TTL
+		if opts.TTL != 0 {
+			i.TTL = opts.TTL
+		}
+		i.Priority = prev.Priority
+		if opts.Priority != 0 {
+			i.Priority = opts.Priority
+		}
+		i.Created = me.clock.Now()
+		i.Accessed = i.Created
+	})
+}
+
+// Replaces the item's file atomically with what fill writes, as it's to be
+// stored. The item's state is taken from the new file, with its checksum,
+// then passed to update with the previous state.
+func (me *Cache) writeItem(key key, fill func(io.Writer) error, update func(i *itemState, prev itemState)) (err error) {
+	if me.readOnly {
+		return ErrReadOnly
+	}
+	me.place(key)
+	p := me.realpath(key)
+	defer func() {
+		if err != nil {
+			me.pruneEmptyDirs(key)
+		}
+	}()
+	err = os.MkdirAll(filepath.Dir(p), dirPerm)
+	if err != nil {
+		return
+	}
+	f, err := atomicfile.Create(p, atomicfile.Opts{
+		Perm: filePerm,
+		Sync: atomicfile.SyncNone,
+	})
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	h := crc32.New(castagnoli)
+	w := io.MultiWriter(f, h)
+	var contentHash hash.Hash
+	if me.dedupe {
+		contentHash = sha256.New()
+		w = io.MultiWriter(w, contentHash)
+	}
+	err = fill(w)
+	if err != nil {
+		return
+	}
+	// Sync before taking the key's lock, which is held from the rename until
+	// the item is updated, so a concurrent Put can't leave the wrong
+	// checksum.
+	err = f.Sync()
+	if err != nil {
+		return
+	}
+	defer me.lockKeys(key)()
+	me.journalChange(key)
+	var content string
+	if contentHash != nil {
+		content, err = me.commitDeduped(f, key, hex.EncodeToString(contentHash.Sum(nil)))
+	} else {
+		err = f.Commit()
+	}
+	if err != nil {
+		return
+	}
+	st, ok, err := me.statKey(key)
+	me.mu.Lock()
+	var replaced string
+	me.updateItem(key, func(i *itemState, _ bool) bool {
+		prev := *i
+		replaced = prev.Content
+		*i = st
+		i.Checksum = h.Sum32()
+		i.HasChecksum = true
+		i.Content = content
+		update(i, prev)
+		return ok
+	})
+	me.mu.Unlock()
+	if replaced != content {
+		me.releaseContent(replaced)
+	}
+	if err == nil && me.syncOnClose == SyncAll {
+		err = me.syncDirs(key)
+	}
+	return
+}
@@ -0,0 +1,58 @@
+package filecache
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readItem(t *testing.T, c *Cache, path string) string {
+	f, err := c.OpenFile(path, os.O_RDONLY)
+	require.NoError(t, err)
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestPut(t *testing.T) {
+	c, fc := newTestCache(t)
+	require.NoError(t, c.PutOpts("dir/a", strings.NewReader("hello"), OpenOpts{TTL: time.Minute}))
+	assert.Equal(t, "hello", readItem(t, c, "dir/a"))
+	assert.EqualValues(t, 5, c.Info().Filled)
+
+	// A failed write leaves the existing item, and nothing else.
+	r := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("read failed")))
+	assert.Error(t, c.Put("dir/a", r))
+	assert.Equal(t, "hello", readItem(t, c, "dir/a"))
+	names, err := os.ReadDir(filepath.Join(c.root, "dir"))
+	require.NoError(t, err)
+	assert.Len(t, names, 1)
+
+	// Replacing keeps the TTL, counting from the replacement.
+	fc.Advance(time.Second)
+	require.NoError(t, c.Put("dir/a", strings.NewReader("hi")))
+	c.WalkItems(func(i ItemInfo) {
+		assert.EqualValues(t, 2, i.Size)
+		assert.Equal(t, fc.Now().Add(time.Minute), i.Expires)
+	})
+	assert.Equal(t, CacheInfo{Capacity: -1, Filled: 2, NumItems: 1}, withoutStats(c.Info()))
+
+	// Failing to write a new item doesn't add it.
+	assert.Error(t, c.Put("other/b", iotest.ErrReader(errors.New("read failed"))))
+	assert.Equal(t, []string{"dir/a"}, itemPaths(c))
+	assert.Equal(t, ErrIsDir, c.Put("/", strings.NewReader("")))
+}
+
+func withoutStats(i CacheInfo) CacheInfo {
+	i.Stats = Stats{}
+	return i
+}
@@ -0,0 +1,35 @@
+package filecache
+
+import (
+	"io"
+	"os"
+)
+
+var _ interface {
+	io.ReadWriteSeeker
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+} = (*File)(nil)
+
+// Reads from the item at off, as File.ReadAt. The access time is updated,
+// and it counts as a hit or miss, as opening it would.
+func (me *Cache) ReadAt(path string, b []byte, off int64) (n int, err error) {
+	f, err := me.OpenFile(path, os.O_RDONLY)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return f.ReadAt(b, off)
+}
+
+// Writes to the item at off, creating it if necessary. The cache's filled
+// size grows by however much the item is extended.
+func (me *Cache) WriteAt(path string, b []byte, off int64) (n int, err error) {
+	f, err := me.OpenFile(path, os.O_CREATE|os.O_WRONLY)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return f.WriteAt(b, off)
+}
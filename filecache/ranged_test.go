@@ -0,0 +1,34 @@
+package filecache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceRandomAccess(t *testing.T) {
+	c, fc := newTestCache(t)
+	r, err := c.AsResourceProvider().NewInstance("piece")
+	require.NoError(t, err)
+	n, err := r.WriteAt([]byte("world"), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.EqualValues(t, 15, c.Info().Filled)
+	_, err = r.WriteAt([]byte("hello"), 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 15, c.Info().Filled)
+
+	fc.Advance(time.Minute)
+	b := make([]byte, 5)
+	n, err = r.ReadAt(b, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(b[:n]))
+	c.WalkItems(func(i ItemInfo) {
+		assert.True(t, fc.Now().Equal(i.Accessed))
+	})
+	fi, err := r.Stat()
+	require.NoError(t, err)
+	assert.EqualValues(t, 15, fi.Size())
+}
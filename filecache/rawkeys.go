@@ -0,0 +1,82 @@
+package filecache
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// With CacheOpts.RawKeys, a key's file is at a path made from the key's bytes
+// in hex, so no two keys share a file and any key can be stored. The first
+// directory is a shard from a hash of the key, so items are spread out. The
+// hex is split into components short enough for any filesystem. Components
+// before the last end in rawKeyMore, and the last starts with rawKeyLast,
+// so a key can't collide with the directory of a longer one.
+const (
+	rawKeyChunk = 200
+	rawKeyMore  = "+"
+	rawKeyLast  = "k"
+)
+
+var errBadRawKeyPath = errors.New("not a raw key path")
+
+// Returned by RenameDir with CacheOpts.RawKeys, as items' files aren't in
+// directories by key.
+var ErrRawKeys = errors.New("keys are raw")
+
+// Returns the key for a path in the cache, which is cleaned unless keys are
+// raw.
+func (me *Cache) keyOf(path string) key {
+	if me.rawKeys {
+		return key(path)
+	}
+	return sanitizePath(path)
+}
+
+func rawKeyShard(k key) string {
+	return fmt.Sprintf("%02x", crc32.Checksum([]byte(k), castagnoli)&0xff)
+}
+
+func rawKeyPath(k key) string {
+	h := hex.EncodeToString([]byte(k))
+	var b strings.Builder
+	b.WriteString(rawKeyShard(k))
+	for len(h) > rawKeyChunk {
+		b.WriteString("/" + h[:rawKeyChunk] + rawKeyMore)
+		h = h[rawKeyChunk:]
+	}
+	b.WriteString("/" + rawKeyLast + h)
+	return b.String()
+}
+
+func parseRawKeyPath(p string) (k key, err error) {
+	cs := strings.Split(p, "/")
+	if len(cs) < 2 {
+		return "", errBadRawKeyPath
+	}
+	var h strings.Builder
+	for i, c := range cs[1:] {
+		if i == len(cs)-2 {
+			if !strings.HasPrefix(c, rawKeyLast) || len(c)-len(rawKeyLast) > rawKeyChunk {
+				return "", errBadRawKeyPath
+			}
+			h.WriteString(c[len(rawKeyLast):])
+		} else {
+			if len(c) != rawKeyChunk+len(rawKeyMore) || !strings.HasSuffix(c, rawKeyMore) {
+				return "", errBadRawKeyPath
+			}
+			h.WriteString(c[:rawKeyChunk])
+		}
+	}
+	b, err := hex.DecodeString(h.String())
+	if err != nil || strings.ToLower(h.String()) != h.String() {
+		return "", errBadRawKeyPath
+	}
+	k = key(b)
+	if cs[0] != rawKeyShard(k) {
+		return "", errBadRawKeyPath
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package filecache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawKeys(t *testing.T) {
+	root := t.TempDir()
+	opts := CacheOpts{RawKeys: true}
+	c, err := NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	<-c.Ready()
+	long := strings.Repeat("x", rawKeyChunk/2)
+	keys := []string{
+		"b", "a/../b", "a//b", "/b", "", ".", "..", "\x00\xff", "CON",
+		long, long + "y", long + long + "z",
+	}
+	for _, k := range keys {
+		require.NoError(t, c.Put(k, strings.NewReader(k)))
+	}
+	for _, k := range keys {
+		assert.Equal(t, k, readItem(t, c, k))
+	}
+	assert.Equal(t, len(keys), c.Info().NumItems)
+
+	// The keys are recovered when scanning the root.
+	c, err = NewCacheOpts(root, opts)
+	require.NoError(t, err)
+	<-c.Ready()
+	got := itemPaths(c)
+	sort.Strings(got)
+	sort.Strings(keys)
+	assert.Equal(t, keys, got)
+
+	err = c.RenameDir("a", "c")
+	assert.True(t, errors.Is(err, ErrRawKeys), err)
+	for _, k := range keys {
+		require.NoError(t, c.Remove(k))
+	}
+	des, err := os.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, des)
+}
+
+func TestParseRawKeyPath(t *testing.T) {
+	for _, k := range []key{"", "a", key(strings.Repeat("ab", rawKeyChunk))} {
+		got, err := parseRawKeyPath(rawKeyPath(k))
+		require.NoError(t, err)
+		assert.Equal(t, k, got)
+	}
+	for _, p := range []string{
+		"a", "00/x", rawKeyShard("a") + "/k6A", "ff/k61",
+		filepath.ToSlash(filepath.Join(rawKeyShard(""), "k", "k")),
+	} {
+		_, err := parseRawKeyPath(p)
+		assert.Error(t, err, p)
+	}
+}
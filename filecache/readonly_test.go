@@ -0,0 +1,56 @@
+package filecache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/v2/clock"
+)
+
+func TestReadOnly(t *testing.T) {
+	root := t.TempDir()
+	index := filepath.Join(t.TempDir(), "index")
+	c, err := NewCacheOpts(root, CacheOpts{IndexPath: index})
+	require.NoError(t, err)
+	createItem(t, c, "a", OpenOpts{TTL: time.Minute})
+	createItem(t, c, "dir/b", OpenOpts{})
+	require.NoError(t, c.Close())
+
+	c, err = NewCacheOpts(root, CacheOpts{IndexPath: index, ReadOnly: true})
+	require.NoError(t, err)
+	<-c.Ready()
+	_, err = os.Stat(index)
+	assert.NoError(t, err)
+	fc := clock.NewFake(time.Now().Add(time.Hour))
+	c.SetClock(fc)
+	c.SetCapacity(1)
+	c.TrimToCapacity()
+	assert.Zero(t, c.RemoveExpired())
+	assert.ElementsMatch(t, []string{"a", "dir/b"}, itemPaths(c))
+	assert.Equal(t, "a", readItem(t, c, "a"))
+	assert.Equal(t, "dir/b", readItem(t, c, "dir/b"))
+
+	for _, err := range []error{
+		func() error { _, err := c.OpenFile("a", os.O_RDWR); return err }(),
+		func() error { _, err := c.OpenFile("c", os.O_RDONLY|os.O_CREATE); return err }(),
+		func() error { _, err := c.WriteAt("a", []byte("x"), 0); return err }(),
+		c.Put("a", strings.NewReader("x")),
+		c.Remove("a"),
+		c.Rename("a", "c"),
+		c.RenameDir("dir", "dir2"),
+	} {
+		assert.True(t, errors.Is(err, ErrReadOnly), err)
+	}
+	assert.ElementsMatch(t, []string{"a", "dir/b"}, itemPaths(c))
+	assert.Equal(t, "a", readItem(t, c, "a"))
+	require.NoError(t, c.Close())
+	_, err = os.Stat(filepath.Join(root, "a"))
+	assert.NoError(t, err)
+}
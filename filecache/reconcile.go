@@ -0,0 +1,98 @@
+package filecache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// What Reconcile found and repaired.
+type ReconcileResult struct {
+	// Files in the root that weren't items.
+	Added int
+	// Items whose files were gone.
+	Removed int
+	// Items whose files had a different size.
+	Resized int
+}
+
+// Brings the items in line with the files in the root, such as after they
+// were changed outside the cache. Items whose files are gone are forgotten,
+// sizes are updated, and files that appeared are added, adjusting Filled
+// and evicting as needed. Unlike Verify, contents aren't read. The lock is
+// only held for each item, so the cache can be used meanwhile.
+func (me *Cache) Reconcile() (ret ReconcileResult) {
+	<-me.ready
+	me.mu.Lock()
+	keys := make([]key, 0, len(me.items))
+	for k := range me.items {
+		keys = append(keys, k)
+	}
+	me.mu.Unlock()
+	for _, k := range keys {
+		switch me.reconcileItem(k) {
+		case reconcileRemoved:
+			ret.Removed++
+		case reconcileResized:
+			ret.Resized++
+		}
+	}
+	var added int64
+	me.scanItems(nil, func(k key) {
+		if me.addFound(k) {
+			atomic.AddInt64(&added, 1)
+		}
+	})
+	ret.Added = int(added)
+	return
+}
+
+type reconcileChange int
+
+const (
+	reconcileNone reconcileChange = iota
+	reconcileRemoved
+	reconcileResized
+)
+
+func (me *Cache) reconcileItem(k key) reconcileChange {
+	defer me.lockKeys(k)()
+	st, ok, err := me.statKey(k)
+	if err != nil {
+		// Leave it be, rather than forgetting an item that may be fine.
+		me.handleError(err)
+		return reconcileNone
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	i, known := me.items[k]
+	if !known {
+		return reconcileNone
+	}
+	if !ok {
+		me.updateItem(k, func(*itemState, bool) bool { return false })
+		return reconcileRemoved
+	}
+	if st.Size == i.Size {
+		return reconcileNone
+	}
+	me.updateItem(k, func(i *itemState, known bool) bool {
+		i.Size = st.Size
+		return known
+	})
+	return reconcileResized
+}
+
+// Reconciles a cache periodically. See Cache.StartAutoReconcile.
+type AutoReconcile struct {
+	periodic
+}
+
+// Calls Reconcile about every interval, using the cache's clock, until Stop.
+func (me *Cache) StartAutoReconcile(interval time.Duration) *AutoReconcile {
+	if interval <= 0 {
+		panic("non-positive interval for StartAutoReconcile")
+	}
+	ar := new(AutoReconcile)
+	me.startPeriodic(&ar.periodic, interval, func() { me.Reconcile() })
+	return ar
+}
@@ -0,0 +1,37 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcile(t *testing.T) {
+	c, fc := newTestCache(t)
+	<-c.Ready()
+	for _, p := range []string{"a", "b", "dir/c"} {
+		createItem(t, c, p, OpenOpts{})
+	}
+	require.EqualValues(t, 7, c.Info().Filled)
+	assert.Equal(t, ReconcileResult{}, c.Reconcile())
+
+	require.NoError(t, os.Remove(filepath.Join(c.root, "a")))
+	require.NoError(t, os.WriteFile(filepath.Join(c.root, "b"), []byte("bigger"), 0o640))
+	require.NoError(t, os.WriteFile(filepath.Join(c.root, "dir", "d"), []byte("new"), 0o640))
+	assert.Equal(t, ReconcileResult{Added: 1, Removed: 1, Resized: 1}, c.Reconcile())
+	assert.ElementsMatch(t, []string{"b", "dir/c", "dir/d"}, itemPaths(c))
+	assert.EqualValues(t, 6+5+3, c.Info().Filled)
+
+	// Periodically.
+	ar := c.StartAutoReconcile(time.Minute)
+	require.NoError(t, os.Remove(filepath.Join(c.root, "b")))
+	fc.Advance(time.Minute * 11 / 10)
+	assert.ElementsMatch(t, []string{"dir/c", "dir/d"}, itemPaths(c))
+	assert.EqualValues(t, 8, c.Info().Filled)
+	ar.Stop()
+	assert.Zero(t, fc.Waiters())
+}
@@ -0,0 +1,108 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Moves the directory from, and the items under it, to the directory to.
+// Items keep their state, and pins move with them. Other operations on the
+// cache wait until the move is done.
+func (me *Cache) RenameDir(from, to string) error {
+	if me.readOnly {
+		return ErrReadOnly
+	}
+	if me.rawKeys {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: ErrRawKeys}
+	}
+	_from := me.keyOf(from)
+	_to := me.keyOf(to)
+	if _from == "" || _to == "" || strings.HasPrefix(string(_to)+"/", string(_from)+"/") {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: ErrBadPath}
+	}
+	defer me.lockAllKeys()()
+	// The directory may be on any of the roots.
+	var roots []string
+	for _, root := range me.roots {
+		fi, statErr := os.Stat(me.pathIn(root, _from))
+		if os.IsNotExist(statErr) {
+			continue
+		}
+		if statErr != nil {
+			return statErr
+		}
+		if !fi.IsDir() {
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: ErrNotDir}
+		}
+		roots = append(roots, root)
+	}
+	if len(roots) == 0 {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: os.ErrNotExist}
+	}
+	me.journalDir(_from, _to)
+	for _, root := range roots {
+		if err := me.renameDirIn(root, _from, _to); err != nil {
+			return err
+		}
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	prefix := string(_from) + "/"
+	newKey := func(k key) key {
+		return _to + k[len(_from):]
+	}
+	moved := make(map[key]itemState)
+	for k, i := range me.items {
+		if strings.HasPrefix(string(k), prefix) {
+			moved[k] = i
+		}
+	}
+	placed := make(map[key]int, len(moved))
+	for k := range moved {
+		placed[k], _ = me.rootOf(k)
+		me.updateItem(k, func(*itemState, bool) bool { return false })
+	}
+	for k, n := range me.pins {
+		if strings.HasPrefix(string(k), prefix) {
+			delete(me.pins, k)
+			me.pins[newKey(k)] = n
+		}
+	}
+	for k, i := range moved {
+		me.memory.drop(newKey(k))
+		me.placeOn(newKey(k), placed[k])
+		me.updateItem(newKey(k), func(ii *itemState, _ bool) bool {
+			*ii = i
+			return true
+		})
+	}
+	return nil
+}
+
+func (me *Cache) renameDirIn(root string, from, to key) error {
+	dst := me.pathIn(root, to)
+	if err := os.MkdirAll(filepath.Dir(dst), dirPerm); err != nil {
+		return err
+	}
+	if err := os.Rename(me.pathIn(root, from), dst); err != nil {
+		pruneEmptyDirs(root, dst)
+		return err
+	}
+	pruneEmptyDirs(root, me.pathIn(root, from))
+	return nil
+}
+
+// Journals the items in the directory under both their old and new keys.
+func (me *Cache) journalDir(from, to key) {
+	prefix := string(from) + "/"
+	var ks []key
+	me.mu.Lock()
+	for k := range me.items {
+		if strings.HasPrefix(string(k), prefix) {
+			ks = append(ks, k, to+k[len(from):])
+		}
+	}
+	me.mu.Unlock()
+	me.journalChange(ks...)
+}
@@ -0,0 +1,42 @@
+package filecache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameDir(t *testing.T) {
+	c, _ := newTestCache(t)
+	for _, p := range []string{"t/a", "t/sub/b", "other"} {
+		createItem(t, c, p, OpenOpts{})
+	}
+	c.Pin("t/a")
+	before := c.Info()
+	require.NoError(t, c.RenameDir("t", "moved/t2"))
+	assert.ElementsMatch(t, []string{"moved/t2/a", "moved/t2/sub/b", "other"}, itemPaths(c))
+	after := c.Info()
+	assert.Equal(t, before.Filled, after.Filled)
+	assert.Equal(t, before.Pinned, after.Pinned)
+	assert.Equal(t, "t/sub/b", readItem(t, c, "moved/t2/sub/b"))
+	_, err := os.Stat(filepath.Join(c.root, "t"))
+	assert.True(t, os.IsNotExist(err))
+	c.Unpin("moved/t2/a")
+	assert.Zero(t, c.Info().Pinned)
+
+	err = c.RenameDir("moved", "moved/t2/x")
+	assert.True(t, errors.Is(err, ErrBadPath), err)
+	err = c.RenameDir("other", "x")
+	assert.True(t, errors.Is(err, ErrNotDir), err)
+	assert.True(t, os.IsNotExist(c.RenameDir("nope", "x")))
+	assert.Len(t, itemPaths(c), 3)
+
+	ns := c.Namespace("ns")
+	createNamespaceItem(t, ns, "d/e", 1)
+	require.NoError(t, ns.RenameDir("d", "f"))
+	assert.Equal(t, []string{"f/e"}, namespacePaths(ns))
+}
@@ -0,0 +1,117 @@
+package filecache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// The fill of one of the cache's roots.
+type RootInfo struct {
+	Path     string
+	Filled   int64
+	NumItems int
+}
+
+// Returns the path for the key under the given root.
+func (me *Cache) pathIn(root string, k key) string {
+	return filepath.Join(root, filepath.FromSlash(me.storedPath(k)))
+}
+
+// Returns the index of the root holding the item, and false if it isn't on
+// any. With a single root, it's always there.
+func (me *Cache) rootOf(k key) (int, bool) {
+	if len(me.roots) == 1 {
+		return 0, true
+	}
+	me.placeMu.Lock()
+	defer me.placeMu.Unlock()
+	if i, ok := me.placed[k]; ok {
+		return i, true
+	}
+	for i, root := range me.roots {
+		if fi, err := os.Stat(me.pathIn(root, k)); err == nil && !fi.IsDir() {
+			me.placed[k] = i
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Makes sure the item has a root, for when it's about to be written. New
+// items go on the root with the most space available.
+func (me *Cache) place(k key) {
+	if _, ok := me.rootOf(k); ok {
+		return
+	}
+	best, bestAvail := 0, int64(-1)
+	for i, root := range me.roots {
+		u, err := me.diskUsage(root)
+		if err != nil {
+			me.handleError(fmt.Errorf("checking space on %q: %w", root, err))
+			continue
+		}
+		if u.Available > bestAvail {
+			best, bestAvail = i, u.Available
+		}
+	}
+	me.placeOn(k, best)
+}
+
+func (me *Cache) placeOn(k key, i int) {
+	if len(me.roots) == 1 {
+		return
+	}
+	me.placeMu.Lock()
+	me.placed[k] = i
+	me.placeMu.Unlock()
+}
+
+// Records the root an item was found on when scanning. Returns false if it
+// was already found on another root, in which case the copy is ignored.
+func (me *Cache) placeFound(k key, i int) bool {
+	if len(me.roots) == 1 {
+		return true
+	}
+	me.placeMu.Lock()
+	defer me.placeMu.Unlock()
+	if j, ok := me.placed[k]; ok && j != i {
+		return false
+	}
+	me.placed[k] = i
+	return true
+}
+
+func (me *Cache) unplace(k key) {
+	if len(me.roots) == 1 {
+		return
+	}
+	me.placeMu.Lock()
+	delete(me.placed, k)
+	me.placeMu.Unlock()
+}
+
+// Adjusts the fill of the root holding the item. The cache must be locked.
+func (me *Cache) fillRoot(k key, size int64, items int) {
+	if len(me.roots) == 1 {
+		return
+	}
+	i, _ := me.rootOf(k)
+	me.rootFilled[i] += size
+	me.rootItems[i] += items
+}
+
+func (me *Cache) rootInfos() (ret []RootInfo) {
+	for i, root := range me.roots {
+		ri := RootInfo{Path: root}
+		if len(me.roots) == 1 {
+			ri.Filled = me.filled
+			ri.NumItems = len(me.items)
+		} else {
+			ri.Filled = me.rootFilled[i]
+			ri.NumItems = me.rootItems[i]
+		}
+		ret = append(ret, ri)
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package filecache
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/v2/diskspace"
+)
+
+func TestExtraRoots(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	available := map[string]int64{a: 10, b: 100}
+	open := func() *Cache {
+		c, err := NewCacheOpts(a, CacheOpts{
+			ExtraRoots:   []string{b},
+			ErrorHandler: func(error) {},
+		})
+		require.NoError(t, err)
+		c.diskUsage = func(root string) (diskspace.Usage, error) {
+			return diskspace.Usage{Available: available[root]}, nil
+		}
+		<-c.Ready()
+		return c
+	}
+	exists := func(root, name string) bool {
+		_, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+		return err == nil
+	}
+	c := open()
+	require.NoError(t, c.Put("dir/x", strings.NewReader("xx")))
+	available[a] = 1000
+	createItem(t, c, "dir/y", OpenOpts{})
+	assert.True(t, exists(b, "dir/x"))
+	assert.True(t, exists(a, "dir/y"))
+	assert.Equal(t, []RootInfo{
+		{Path: a, Filled: 5, NumItems: 1},
+		{Path: b, Filled: 2, NumItems: 1},
+	}, c.Info().Roots)
+	// Items stay on their root when rewritten.
+	require.NoError(t, c.Put("dir/x", strings.NewReader("xxx")))
+	assert.False(t, exists(a, "dir/x"))
+	assert.Equal(t, "xxx", readItem(t, c, "dir/x"))
+
+	des, err := fs.ReadDir(c.FS(), "dir")
+	require.NoError(t, err)
+	var names []string
+	for _, de := range des {
+		names = append(names, de.Name())
+	}
+	assert.Equal(t, []string{"x", "y"}, names)
+
+	// The roots are scanned when the cache is opened again.
+	c = open()
+	assert.ElementsMatch(t, []string{"dir/x", "dir/y"}, itemPaths(c))
+	assert.Equal(t, []RootInfo{
+		{Path: a, Filled: 5, NumItems: 1},
+		{Path: b, Filled: 3, NumItems: 1},
+	}, c.Info().Roots)
+
+	require.NoError(t, c.RenameDir("dir", "moved"))
+	assert.True(t, exists(a, "moved/y"))
+	assert.True(t, exists(b, "moved/x"))
+	assert.False(t, exists(a, "dir"))
+	assert.False(t, exists(b, "dir"))
+	// Replacing an item on the other root.
+	require.NoError(t, c.Rename("moved/x", "moved/y"))
+	assert.False(t, exists(a, "moved/y"))
+	assert.True(t, exists(b, "moved/y"))
+	assert.Equal(t, "xxx", readItem(t, c, "moved/y"))
+	assert.Equal(t, []RootInfo{
+		{Path: a, Filled: 0, NumItems: 0},
+		{Path: b, Filled: 3, NumItems: 1},
+	}, c.Info().Roots)
+	require.NoError(t, c.Remove("moved/y"))
+	assert.Equal(t, []RootInfo{{Path: a}, {Path: b}}, c.Info().Roots)
+	assert.Empty(t, c.placed)
+
+	_, err = NewCacheOpts(a, CacheOpts{Dedupe: true, ExtraRoots: []string{b}})
+	assert.Equal(t, ErrDedupeUnsupported, err)
+}
@@ -0,0 +1,159 @@
+package filecache
+
+import (
+	"archive/tar"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// PAX records for item state that tar headers have no place for.
+const (
+	snapshotTTLRecord      = "FILECACHE.ttl"
+	snapshotChecksumRecord = "FILECACHE.checksum"
+	snapshotPriorityRecord = "FILECACHE.priority"
+)
+
+// Writes the items to w as a tar stream, with their state, for RestoreFrom.
+// Items are written as they're stored, so a snapshot of a compressed or
+// encrypted cache must be restored to one with the same options. Each item
+// is locked while it's written, but items being written through an open
+// File may be captured partway.
+func (me *Cache) SnapshotTo(w io.Writer) error {
+	me.mu.Lock()
+	me.flushAccesses()
+	keys := make([]key, 0, len(me.items))
+	for k := range me.items {
+		keys = append(keys, k)
+	}
+	me.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	tw := tar.NewWriter(w)
+	for _, k := range keys {
+		if err := me.snapshotItem(tw, k); err != nil {
+			return fmt.Errorf("snapshotting %q: %w", k, err)
+		}
+	}
+	return tw.Close()
+}
+
+func (me *Cache) snapshotItem(tw *tar.Writer, k key) error {
+	defer me.lockKeys(k)()
+	me.mu.Lock()
+	i, ok := me.items[k]
+	me.mu.Unlock()
+	if !ok {
+		// Removed since the snapshot began.
+		return nil
+	}
+	f, err := os.Open(me.realpath(k))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	h := &tar.Header{
+		Typeflag:   tar.TypeReg,
+		Name:       string(k),
+		Size:       fi.Size(),
+		Mode:       filePerm,
+		ModTime:    i.Created,
+		AccessTime: i.Accessed,
+		Format:     tar.FormatPAX,
+		PAXRecords: map[string]string{
+			snapshotTTLRecord:      strconv.FormatInt(int64(i.TTL), 10),
+			snapshotPriorityRecord: strconv.Itoa(i.Priority),
+		},
+	}
+	if i.HasChecksum {
+		h.PAXRecords[snapshotChecksumRecord] = strconv.FormatUint(uint64(i.Checksum), 10)
+	}
+	if err := tw.WriteHeader(h); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, fi.Size())
+	return err
+}
+
+// Adds the items from a tar stream written by SnapshotTo, replacing any
+// with the same paths. Items keep their state from the snapshot, and are
+// trimmed as they're added if the cache is over capacity. Items with a
+// checksum that doesn't match aren't added, and return ErrCorrupt.
+func (me *Cache) RestoreFrom(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		k := me.keyOf(h.Name)
+		if k == "" && !me.rawKeys {
+			return fmt.Errorf("restoring %q: %w", h.Name, ErrBadPath)
+		}
+		if err := me.restoreItem(k, h, tr); err != nil {
+			return fmt.Errorf("restoring %q: %w", k, err)
+		}
+	}
+}
+
+func (me *Cache) restoreItem(k key, h *tar.Header, r io.Reader) error {
+	var ttl time.Duration
+	if s, ok := h.PAXRecords[snapshotTTLRecord]; ok {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		ttl = time.Duration(n)
+	}
+	var priority int
+	if s, ok := h.PAXRecords[snapshotPriorityRecord]; ok {
+		var err error
+		priority, err = strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+	}
+	var want *uint32
+	if s, ok := h.PAXRecords[snapshotChecksumRecord]; ok {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return err
+		}
+		sum := uint32(n)
+		want = &sum
+	}
+	return me.writeItem(k, func(w io.Writer) error {
+		h := crc32.New(castagnoli)
+		if _, err := io.Copy(io.MultiWriter(w, h), r); err != nil {
+			return err
+		}
+		if want != nil && h.Sum32() != *want {
+			return ErrCorrupt
+		}
+		return nil
+	}, func(i *itemState, _ itemState) {
+		i.Created = h.ModTime
+		i.Accessed = h.AccessTime
+		if i.Accessed.IsZero() {
+			i.Accessed = i.Created
+		}
+		i.TTL = ttl
+		i.Priority = priority
+	})
+}
@@ -0,0 +1,52 @@
+package filecache
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sortedItemInfos(c *Cache) (ret []ItemInfo) {
+	c.WalkItems(func(i ItemInfo) {
+		ret = append(ret, i)
+	})
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Path < ret[j].Path })
+	return
+}
+
+func TestSnapshot(t *testing.T) {
+	c, fc := newTestCache(t)
+	createItem(t, c, "a", OpenOpts{})
+	fc.Advance(time.Second)
+	require.NoError(t, c.PutOpts("dir/b", strings.NewReader("hello"), OpenOpts{TTL: time.Hour}))
+	fc.Advance(time.Second)
+	createNamespaceItem(t, c.Namespace("ns"), "c", 3)
+	var buf bytes.Buffer
+	require.NoError(t, c.SnapshotTo(&buf))
+	snapshot := buf.Bytes()
+
+	d, dfc := newTestCache(t)
+	dfc.Set(fc.Now())
+	createItem(t, d, "a", OpenOpts{TTL: time.Minute})
+	require.NoError(t, d.RestoreFrom(bytes.NewReader(snapshot)))
+	assert.Equal(t, sortedItemInfos(c), sortedItemInfos(d))
+	assert.Equal(t, c.Info().Filled, d.Info().Filled)
+	assert.Equal(t, "a", readItem(t, d, "a"))
+	assert.Equal(t, "hello", readItem(t, d, "dir/b"))
+	assert.NoError(t, d.Verify("dir/b"))
+	assert.ElementsMatch(t, []string{"c"}, namespacePaths(d.Namespace("ns")))
+
+	// Damaged content with a checksum isn't restored. Items before it in the
+	// snapshot are.
+	e, _ := newTestCache(t)
+	damaged := bytes.Replace(snapshot, []byte("hello"), []byte("jello"), 1)
+	err := e.RestoreFrom(bytes.NewReader(damaged))
+	assert.True(t, errors.Is(err, ErrCorrupt), err)
+	assert.ElementsMatch(t, []string{".namespaces/ns/c", "a"}, itemPaths(e))
+}
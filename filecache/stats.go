@@ -0,0 +1,43 @@
+package filecache
+
+import (
+	"expvar"
+)
+
+// Counts of cache activity since the cache was created.
+type Stats struct {
+	// Opens of items that were in the cache.
+	Hits int64
+	// Opens of items that weren't, whether or not they were then created.
+	Misses int64
+	// Items removed to make room, and their total size.
+	Evictions    int64
+	EvictedBytes int64
+	// Items removed because their TTL passed.
+	Expirations int64
+}
+
+// Returns the fraction of opens that were hits, or 0 if there were none.
+func (me Stats) HitRate() float64 {
+	total := me.Hits + me.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(me.Hits) / float64(total)
+}
+
+// Applies f to the cache's stats, and those of the namespace holding k.
+func (me *Cache) count(k key, f func(*Stats)) {
+	f(&me.stats)
+	if ns := me.namespaceOf(k); ns != nil {
+		f(&ns.stats)
+	}
+}
+
+// Publishes the cache's Info as an expvar with the given name. Like
+// expvar.Publish, it panics if the name is already in use.
+func (me *Cache) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return me.Info()
+	}))
+}
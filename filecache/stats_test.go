@@ -0,0 +1,54 @@
+package filecache
+
+import (
+	"encoding/json"
+	"expvar"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	c, fc := newTestCache(t)
+	assert.EqualValues(t, 0, c.Info().Stats.HitRate())
+	createItem(t, c, "a", OpenOpts{TTL: time.Minute})
+	ns := c.Namespace("n")
+	createNamespaceItem(t, ns, "x", 4)
+	fc.Advance(time.Second)
+	createItem(t, c, "bb", OpenOpts{})
+	f, err := c.OpenFile("a", os.O_RDONLY)
+	require.NoError(t, err)
+	f.Close()
+	_, err = c.OpenFile("missing", os.O_RDONLY)
+	assert.True(t, os.IsNotExist(err))
+
+	fc.Advance(time.Minute)
+	assert.Equal(t, 1, c.RemoveExpired())
+	c.SetCapacity(2)
+	c.TrimToCapacity()
+	assert.Equal(t, []string{"bb"}, itemPaths(c))
+	stats := c.Info().Stats
+	assert.Equal(t, Stats{
+		Hits:         1,
+		Misses:       4,
+		Evictions:    1,
+		EvictedBytes: 4,
+		Expirations:  1,
+	}, stats)
+	assert.EqualValues(t, 0.2, stats.HitRate())
+	assert.Equal(t, Stats{Misses: 1, Evictions: 1, EvictedBytes: 4}, ns.Info().Stats)
+}
+
+func TestPublishExpvar(t *testing.T) {
+	c, _ := newTestCache(t)
+	createItem(t, c, "a", OpenOpts{})
+	// Names can't be reused, and the root is unique.
+	name := "filecache " + c.root
+	c.PublishExpvar(name)
+	var info CacheInfo
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &info))
+	assert.Equal(t, c.Info(), info)
+}
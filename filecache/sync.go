@@ -0,0 +1,78 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// What File.Close syncs of what was written to an item.
+type SyncPolicy int
+
+const (
+	// Doesn't sync. A crash can lose recent writes.
+	SyncNone SyncPolicy = iota
+	// Syncs the item's contents.
+	SyncFile
+	// Syncs the item's contents, and the directories leading to it from the
+	// root, so a new item is found after a crash.
+	SyncAll
+)
+
+// Syncs the item's contents, and the directories leading to it, so what's
+// been written survives a crash.
+func (me *File) Sync() error {
+	if err := me.syncContents(); err != nil {
+		return err
+	}
+	return me.syncDirs()
+}
+
+func (me *File) syncContents() error {
+	// Items served from memory have nothing to sync.
+	if s, ok := me.f.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+func (me *File) syncOnClose() error {
+	switch me.syncPolicy {
+	case SyncFile:
+		return me.syncContents()
+	case SyncAll:
+		return me.Sync()
+	}
+	return nil
+}
+
+// Syncs the directories from the item's up to its root.
+func (me *Cache) syncDirs(k key) error {
+	i, _ := me.rootOf(k)
+	root := filepath.Clean(me.roots[i])
+	for dir := filepath.Dir(me.realpath(k)); ; dir = filepath.Dir(dir) {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+		if dir == root || filepath.Dir(dir) == dir {
+			return nil
+		}
+	}
+}
+
+// Windows can't sync directories, but entries there are durable once
+// they're made.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncOnClose(t *testing.T) {
+	root := t.TempDir()
+	c, err := NewCacheOpts(root, CacheOpts{
+		SyncOnClose:    SyncAll,
+		MemoryCapacity: 100,
+	})
+	require.NoError(t, err)
+	createItem(t, c, "dir/a", OpenOpts{})
+	require.NoError(t, c.Put("dir/b", strings.NewReader("b")))
+	for range [2]struct{}{} {
+		// The second open is from memory.
+		f, err := c.OpenFile("dir/a", os.O_RDONLY)
+		require.NoError(t, err)
+		assert.NoError(t, f.Sync())
+		assert.NoError(t, f.Close())
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// Errors syncing are returned by Close, which still releases the File.
+	f, err := c.OpenFile("dir/c", os.O_CREATE|os.O_WRONLY)
+	require.NoError(t, err)
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "dir")))
+	assert.Error(t, f.Close())
+	assert.Equal(t, 0, c.Info().OpenFiles)
+}
+
+func TestSyncOnCloseOpts(t *testing.T) {
+	root := t.TempDir()
+	c, err := NewCache(root)
+	require.NoError(t, err)
+	f, err := c.OpenFile("dir/a", os.O_CREATE|os.O_WRONLY)
+	require.NoError(t, err)
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "dir")))
+	// Nothing is synced by default.
+	assert.NoError(t, f.Close())
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	f, err = c.OpenFileOpts("dir/a", os.O_CREATE|os.O_WRONLY, OpenOpts{SyncOnClose: SyncAll})
+	require.NoError(t, err)
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "dir")))
+	assert.Error(t, f.Close())
+}
@@ -0,0 +1,131 @@
+package filecache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2/orderedset"
+)
+
+type expiry struct {
+	at  time.Time
+	key key
+}
+
+func newExpirySet() *orderedset.Set[expiry] {
+	return orderedset.New(func(l, r expiry) bool {
+		if l.at.Equal(r.at) {
+			return l.key < r.key
+		}
+		return l.at.Before(r.at)
+	})
+}
+
+// Returns when the item expires, or zero if it doesn't.
+func (me *Cache) expires(i itemState) time.Time {
+	ttl := i.TTL
+	if ttl == 0 {
+		ttl = me.defaultTTL
+	}
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return i.Created.Add(ttl)
+}
+
+func (me *Cache) expired(i itemState) bool {
+	at := me.expires(i)
+	return !at.IsZero() && !me.clock.Now().Before(at)
+}
+
+func (me *Cache) scheduleExpiry(k key, i itemState) {
+	if at := me.expires(i); !at.IsZero() {
+		me.expiring.Add(expiry{at, k})
+	}
+}
+
+func (me *Cache) unexpire(k key, i itemState) {
+	if at := me.expires(i); !at.IsZero() {
+		me.expiring.Delete(expiry{at, k})
+	}
+}
+
+// Sets the TTL for items without one of their own, counting from when they
+// were added, or their modification time for items found at startup. Zero
+// means no expiry.
+func (me *Cache) SetDefaultTTL(ttl time.Duration) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.defaultTTL = ttl
+	me.expiring = newExpirySet()
+	for k, i := range me.items {
+		me.scheduleExpiry(k, i)
+	}
+}
+
+// Removes items that have expired, returning how many. Pinned items are left
+// until they're unpinned.
+func (me *Cache) RemoveExpired() (n int) {
+	if me.readOnly {
+		return
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	now := me.clock.Now()
+	// Items that couldn't be removed are retried on the next sweep.
+	var failed []expiry
+	defer func() {
+		for _, e := range failed {
+			me.expiring.Add(e)
+		}
+	}()
+	for {
+		e, ok := me.expiring.Min()
+		if !ok || now.Before(e.at) {
+			return
+		}
+		if me.pins[e.key] == 0 {
+			// Items busy with other operations are left for the next sweep.
+			if unlock, ok := me.tryLockKey(e.key); ok {
+				err := me.expire(e.key)
+				unlock()
+				if err == nil {
+					n++
+					continue
+				}
+				me.handleError(fmt.Errorf("expiring %q: %w", e.key, err))
+			}
+		}
+		me.expiring.Delete(e)
+		failed = append(failed, e)
+	}
+}
+
+// Starts a goroutine that calls RemoveExpired every interval, so expired
+// items are removed even when the cache isn't full. Call stop to end it,
+// which can be done more than once.
+func (me *Cache) StartSweeper(interval time.Duration) (stop func()) {
+	me.mu.Lock()
+	t := me.clock.NewTicker(interval)
+	me.mu.Unlock()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C():
+				me.RemoveExpired()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
+	}
+}
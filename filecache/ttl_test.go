@@ -0,0 +1,82 @@
+package filecache
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/v2/clock"
+)
+
+func newTestCache(t *testing.T) (*Cache, *clock.Fake) {
+	c, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+	// The scan holds key locks, which would keep items from being evicted.
+	<-c.Ready()
+	fc := clock.NewFake(time.Unix(1000, 0))
+	c.SetClock(fc)
+	return c, fc
+}
+
+func createItem(t *testing.T, c *Cache, path string, opts OpenOpts) {
+	f, err := c.OpenFileOpts(path, os.O_CREATE|os.O_WRONLY, opts)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(path))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func itemPaths(c *Cache) (ret []string) {
+	c.WalkItems(func(i ItemInfo) {
+		ret = append(ret, string(i.Path))
+	})
+	return
+}
+
+func TestTTL(t *testing.T) {
+	c, fc := newTestCache(t)
+	createItem(t, c, "default", OpenOpts{})
+	createItem(t, c, "short", OpenOpts{TTL: time.Minute})
+	createItem(t, c, "forever", OpenOpts{TTL: -1})
+	c.SetDefaultTTL(time.Hour)
+	c.WalkItems(func(i ItemInfo) {
+		switch i.Path {
+		case "default":
+			assert.Equal(t, time.Unix(1000, 0).Add(time.Hour), i.Expires)
+		case "forever":
+			assert.True(t, i.Expires.IsZero())
+		}
+	})
+
+	fc.Advance(time.Minute - 1)
+	assert.Equal(t, 0, c.RemoveExpired())
+	fc.Advance(1)
+	// Expired items aren't served, even before they're swept.
+	_, err := c.OpenFile("short", os.O_RDONLY)
+	assert.True(t, os.IsNotExist(err), err)
+	assert.Equal(t, 0, c.RemoveExpired())
+	assert.ElementsMatch(t, []string{"default", "forever"}, itemPaths(c))
+
+	fc.Advance(time.Hour)
+	assert.Equal(t, 1, c.RemoveExpired())
+	assert.Equal(t, []string{"forever"}, itemPaths(c))
+	c.SetDefaultTTL(0)
+}
+
+func TestSweeper(t *testing.T) {
+	c, fc := newTestCache(t)
+	createItem(t, c, "a", OpenOpts{TTL: time.Second})
+	stop := c.StartSweeper(time.Minute)
+	defer stop()
+	fc.BlockUntil(1)
+	fc.Advance(time.Minute)
+	for c.Info().NumItems != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	_, err := os.Stat(c.realpath("a"))
+	assert.True(t, os.IsNotExist(err))
+	stop()
+}
@@ -37,21 +37,11 @@ func (me *uniformResource) Put(r io.Reader) (err error) {
 }
 
 func (me *uniformResource) ReadAt(b []byte, off int64) (n int, err error) {
-	f, err := me.Cache.OpenFile(me.Location, os.O_RDONLY)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-	return f.ReadAt(b, off)
+	return me.Cache.ReadAt(me.Location, b, off)
 }
 
 func (me *uniformResource) WriteAt(b []byte, off int64) (n int, err error) {
-	f, err := me.Cache.OpenFile(me.Location, os.O_CREATE|os.O_WRONLY)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-	return f.WriteAt(b, off)
+	return me.Cache.WriteAt(me.Location, b, off)
 }
 
 func (me *uniformResource) Stat() (fi os.FileInfo, err error) {
@@ -0,0 +1,70 @@
+package filecache
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+var ErrCorrupt = errors.New("item content doesn't match its checksum")
+
+// Checks the item's content against the checksum recorded when it was
+// written with Put. Corrupt items are removed, and ErrCorrupt returned.
+// Items without a checksum, such as those written through OpenFile, pass.
+func (me *Cache) Verify(path string) error {
+	key := me.keyOf(path)
+	if key == "" {
+		return ErrIsDir
+	}
+	me.mu.Lock()
+	_, ok := me.items[key]
+	me.mu.Unlock()
+	if !ok {
+		return &os.PathError{Op: "verify", Path: path, Err: os.ErrNotExist}
+	}
+	return me.verify(key)
+}
+
+func (me *Cache) verify(k key) error {
+	me.mu.Lock()
+	i, ok := me.items[k]
+	me.mu.Unlock()
+	if !ok || !i.HasChecksum {
+		return nil
+	}
+	// The lock isn't held while reading, so the item could change meanwhile.
+	sum, err := fileChecksum(me.realpath(k))
+	if os.IsNotExist(err) {
+		me.mu.Lock()
+		me.updateItem(k, func(*itemState, bool) bool { return false })
+		me.mu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	if sum == i.Checksum {
+		return nil
+	}
+	me.mu.Lock()
+	cur, ok := me.items[k]
+	if ok && cur.HasChecksum && cur.Checksum == i.Checksum && cur.Created.Equal(i.Created) {
+		me.remove(k)
+		me.mu.Unlock()
+		return ErrCorrupt
+	}
+	me.mu.Unlock()
+	// It was rewritten while we read it.
+	return me.verify(k)
+}
+
+func fileChecksum(name string) (uint32, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	h := crc32.New(castagnoli)
+	_, err = io.Copy(h, f)
+	return h.Sum32(), err
+}
@@ -0,0 +1,72 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Flips a bit in the item's file, without the cache knowing.
+func corruptItem(t *testing.T, c *Cache, path string) {
+	name := c.realpath(sanitizePath(path))
+	b, err := os.ReadFile(name)
+	require.NoError(t, err)
+	b[0] ^= 1
+	require.NoError(t, os.WriteFile(name, b, 0o644))
+}
+
+func TestVerify(t *testing.T) {
+	c, _ := newTestCache(t)
+	require.NoError(t, c.Put("a", strings.NewReader("hello")))
+	require.NoError(t, c.Put("b", strings.NewReader("world")))
+	createItem(t, c, "c", OpenOpts{})
+	assert.NoError(t, c.Verify("a"))
+	corruptItem(t, c, "a")
+	assert.Equal(t, ErrCorrupt, c.Verify("a"))
+	assert.ElementsMatch(t, []string{"b", "c"}, itemPaths(c))
+	assert.True(t, os.IsNotExist(c.Verify("a")))
+
+	// Writing through a File drops the checksum, as the content is no
+	// longer known.
+	f, err := c.OpenFile("b", os.O_WRONLY)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("W"), 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.NoError(t, c.Verify("b"))
+	corruptItem(t, c, "c")
+	assert.NoError(t, c.Verify("c"))
+}
+
+func TestVerifyOnOpen(t *testing.T) {
+	td := t.TempDir()
+	opts := CacheOpts{
+		IndexPath:    filepath.Join(td, "index"),
+		VerifyOnOpen: true,
+	}
+	c, err := NewCacheOpts(filepath.Join(td, "root"), opts)
+	require.NoError(t, err)
+	require.NoError(t, c.Put("a", strings.NewReader("hello")))
+	require.NoError(t, c.Put("b", strings.NewReader("world")))
+	require.NoError(t, c.Close())
+
+	// Checksums are kept in the index.
+	c, err = NewCacheOpts(filepath.Join(td, "root"), opts)
+	require.NoError(t, err)
+	corruptItem(t, c, "a")
+	_, err = c.OpenFile("a", os.O_RDONLY)
+	assert.Equal(t, ErrCorrupt, err)
+	assert.Equal(t, []string{"b"}, itemPaths(c))
+	assert.Equal(t, "world", readItem(t, c, "b"))
+
+	// Truncating doesn't need the old content to be intact.
+	corruptItem(t, c, "b")
+	f, err := c.OpenFile("b", os.O_WRONLY|os.O_TRUNC)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "", readItem(t, c, "b"))
+}
@@ -0,0 +1,73 @@
+package filecache
+
+import (
+	"sort"
+	"strings"
+)
+
+// How WalkItemsOpts orders items.
+type WalkOrder int
+
+const (
+	// In no particular order.
+	Unordered WalkOrder = iota
+	// Least recently accessed first.
+	OrderAccessed
+	// Smallest first.
+	OrderSize
+	// By path, bytewise.
+	OrderPath
+)
+
+type WalkOpts struct {
+	// Only items with paths starting with this.
+	Prefix  string
+	Order   WalkOrder
+	Reverse bool
+}
+
+// Calls cb for the items selected by opts, until it returns false. The items
+// are collected first, so the cache isn't locked while cb runs, and may have
+// changed by the time it's called.
+func (me *Cache) WalkItemsOpts(opts WalkOpts, cb func(ItemInfo) bool) {
+	me.walkItems("", opts, cb)
+}
+
+// Walks the items with keys under root, with it trimmed from their paths.
+func (me *Cache) walkItems(root string, opts WalkOpts, cb func(ItemInfo) bool) {
+	prefix := root + opts.Prefix
+	var infos []ItemInfo
+	me.mu.Lock()
+	me.flushAccesses()
+	for k, ii := range me.items {
+		if !strings.HasPrefix(string(k), prefix) {
+			continue
+		}
+		info := me.itemInfo(k, ii)
+		info.Path = k[len(root):]
+		infos = append(infos, info)
+	}
+	me.mu.Unlock()
+	var less func(a, b ItemInfo) bool
+	switch opts.Order {
+	case OrderAccessed:
+		less = func(a, b ItemInfo) bool { return a.Accessed.Before(b.Accessed) }
+	case OrderSize:
+		less = func(a, b ItemInfo) bool { return a.Size < b.Size }
+	case OrderPath:
+		less = func(a, b ItemInfo) bool { return a.Path < b.Path }
+	}
+	if less != nil {
+		sort.SliceStable(infos, func(i, j int) bool {
+			if opts.Reverse {
+				return less(infos[j], infos[i])
+			}
+			return less(infos[i], infos[j])
+		})
+	}
+	for _, info := range infos {
+		if !cb(info) {
+			return
+		}
+	}
+}
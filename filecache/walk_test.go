@@ -0,0 +1,47 @@
+package filecache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func walkPaths(walk func(WalkOpts, func(ItemInfo) bool), opts WalkOpts, limit int) (ret []string) {
+	walk(opts, func(i ItemInfo) bool {
+		ret = append(ret, string(i.Path))
+		return len(ret) < limit
+	})
+	return
+}
+
+func TestWalkItemsOpts(t *testing.T) {
+	c, fc := newTestCache(t)
+	for _, p := range []string{"dir/bb", "a", "dir/c", "dddd"} {
+		createItem(t, c, p, OpenOpts{})
+		fc.Advance(time.Second)
+	}
+	createNamespaceItem(t, c.Namespace("ns"), "dir/e", 1)
+	ns := c.Namespace("ns")
+	for _, _case := range []struct {
+		opts  WalkOpts
+		limit int
+		want  []string
+	}{
+		{WalkOpts{Order: OrderPath}, 10, []string{".namespaces/ns/dir/e", "a", "dddd", "dir/bb", "dir/c"}},
+		{WalkOpts{Order: OrderAccessed}, 3, []string{"dir/bb", "a", "dir/c"}},
+		{WalkOpts{Order: OrderSize, Reverse: true}, 2, []string{"dir/bb", "dir/c"}},
+		{WalkOpts{Prefix: "dir/", Order: OrderPath, Reverse: true}, 10, []string{"dir/c", "dir/bb"}},
+		{WalkOpts{Prefix: "x"}, 10, nil},
+	} {
+		assert.Equal(t, _case.want, walkPaths(c.WalkItemsOpts, _case.opts, _case.limit), _case.opts)
+	}
+	assert.Equal(t, []string{"dir/e"}, walkPaths(ns.WalkItemsOpts, WalkOpts{Prefix: "dir/"}, 10))
+
+	// The cache can be used from the callback.
+	c.WalkItemsOpts(WalkOpts{}, func(i ItemInfo) bool {
+		c.Remove(string(i.Path))
+		return true
+	})
+	assert.Empty(t, itemPaths(c))
+}
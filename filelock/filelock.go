@@ -0,0 +1,105 @@
+// Package filelock provides advisory file locks, shared between processes.
+// Locks are held by the open file, so two Locks on the same path conflict
+// even within a process.
+package filelock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+type Mode int
+
+const (
+	// Any number of shared locks can be held at once, but not alongside an
+	// exclusive lock.
+	Shared Mode = iota
+	Exclusive
+)
+
+var (
+	// Returned by TryAcquire when the lock is held elsewhere.
+	ErrLocked = errors.New("file is locked")
+	// Returned on platforms without file locking.
+	ErrUnsupported = errors.New("file locking not supported")
+)
+
+// A held lock.
+type Lock struct {
+	f *os.File
+}
+
+func open(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o666)
+}
+
+// Locks the file at path, creating it if necessary, without waiting. If the
+// lock is held elsewhere, ErrLocked is returned.
+func TryAcquire(path string, mode Mode) (*Lock, error) {
+	f, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := lock(f, mode, false); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Lock{f}, nil
+}
+
+// Locks the file at path, creating it if necessary, waiting until the lock
+// is available or ctx is done. Use a ctx with a timeout to bound the wait.
+func Acquire(ctx context.Context, path string, mode Mode) (*Lock, error) {
+	f, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	// Without a way to be cancelled, the wait can block in the OS.
+	if ctx.Done() == nil {
+		err = lock(f, mode, true)
+	} else {
+		err = pollLock(ctx, f, mode)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Lock{f}, nil
+}
+
+func pollLock(ctx context.Context, f *os.File, mode Mode) error {
+	wait := time.Millisecond
+	for {
+		err := lock(f, mode, false)
+		if err != ErrLocked {
+			return err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+		if wait < 100*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// The locked file, which can be used for storing such as the holder's PID.
+func (me *Lock) File() *os.File {
+	return me.f
+}
+
+// Releases the lock. The file isn't removed, as another process may already
+// have it open to lock.
+func (me *Lock) Release() error {
+	err := unlock(me.f)
+	if cerr := me.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly,!windows
+
+package filelock
+
+import "os"
+
+func lock(f *os.File, mode Mode, block bool) error {
+	return ErrUnsupported
+}
+
+func unlock(f *os.File) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,57 @@
+package filelock
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	l, err := TryAcquire(path, Exclusive)
+	require.NoError(t, err)
+	_, err = TryAcquire(path, Shared)
+	assert.Equal(t, ErrLocked, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = Acquire(ctx, path, Exclusive)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	acquired := make(chan *Lock)
+	go func() {
+		l, err := Acquire(context.Background(), path, Exclusive)
+		assert.NoError(t, err)
+		acquired <- l
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired held lock")
+	case <-time.After(10 * time.Millisecond):
+	}
+	require.NoError(t, l.Release())
+	require.NoError(t, (<-acquired).Release())
+}
+
+func TestShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	l1, err := TryAcquire(path, Shared)
+	require.NoError(t, err)
+	l2, err := TryAcquire(path, Shared)
+	require.NoError(t, err)
+	_, err = TryAcquire(path, Exclusive)
+	assert.Equal(t, ErrLocked, err)
+	l1.Release()
+	_, err = TryAcquire(path, Exclusive)
+	assert.Equal(t, ErrLocked, err)
+	l2.Release()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	l, err := Acquire(ctx, path, Exclusive)
+	require.NoError(t, err)
+	assert.Equal(t, path, l.File().Name())
+	l.Release()
+}
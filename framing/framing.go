@@ -0,0 +1,146 @@
+// Package framing reads and writes length-prefixed frames on byte streams,
+// and dispatches them to handlers.
+package framing
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/anacrolix/missinggo/v2/bufpool"
+)
+
+type LengthPrefix int
+
+const (
+	// A uvarint, as in encoding/binary.
+	Uvarint LengthPrefix = iota
+	// A big-endian uint32.
+	Fixed32
+)
+
+type Opts struct {
+	Prefix LengthPrefix
+	// The largest frame payload accepted or written. Defaults to 1MiB.
+	MaxSize int
+	// Where frame buffers come from. Defaults to bufpool.Default.
+	Pool *bufpool.Pool
+}
+
+func (me Opts) withDefaults() Opts {
+	if me.MaxSize <= 0 {
+		me.MaxSize = 1 << 20
+	}
+	if me.Pool == nil {
+		me.Pool = bufpool.Default
+	}
+	return me
+}
+
+var ErrTooLarge = errors.New("frame exceeds maximum size")
+
+type Reader struct {
+	r    *bufio.Reader
+	opts Opts
+}
+
+func NewReader(r io.Reader, opts Opts) *Reader {
+	return &Reader{
+		r:    bufio.NewReader(r),
+		opts: opts.withDefaults(),
+	}
+}
+
+// Reads the next frame's payload into a buffer from the pool, which should
+// be passed to Release when done with. io.EOF is returned only at a frame
+// boundary.
+func (me *Reader) ReadFrame() (b []byte, err error) {
+	var n uint64
+	switch me.opts.Prefix {
+	case Uvarint:
+		n, err = binary.ReadUvarint(me.r)
+		if err == io.EOF {
+			return
+		}
+	case Fixed32:
+		var p [4]byte
+		_, err = io.ReadFull(me.r, p[:])
+		if err == io.EOF {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint32(p[:]))
+	default:
+		panic(me.opts.Prefix)
+	}
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if n > uint64(me.opts.MaxSize) {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, n)
+	}
+	b = me.opts.Pool.Get(int(n))
+	_, err = io.ReadFull(me.r, b)
+	if err != nil {
+		me.opts.Pool.Put(b)
+		return nil, unexpectedEOF(err)
+	}
+	return
+}
+
+// A stream ending within a frame is unexpected.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Returns a buffer from ReadFrame to the pool.
+func (me *Reader) Release(b []byte) {
+	me.opts.Pool.Put(b)
+}
+
+// Writes frames. Each frame is written with a single call to the underlying
+// Writer, so a Writer can be shared if the underlying one is safe for
+// concurrent use.
+type Writer struct {
+	w    io.Writer
+	opts Opts
+}
+
+func NewWriter(w io.Writer, opts Opts) *Writer {
+	return &Writer{
+		w:    w,
+		opts: opts.withDefaults(),
+	}
+}
+
+// Writes a frame, with a payload of the concatenated parts.
+func (me *Writer) WriteFrame(parts ...[]byte) error {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	if n > me.opts.MaxSize {
+		return fmt.Errorf("%w: %d bytes", ErrTooLarge, n)
+	}
+	b := me.opts.Pool.Get(binary.MaxVarintLen64 + n)
+	defer me.opts.Pool.Put(b)
+	var off int
+	switch me.opts.Prefix {
+	case Uvarint:
+		off = binary.PutUvarint(b, uint64(n))
+	case Fixed32:
+		binary.BigEndian.PutUint32(b, uint32(n))
+		off = 4
+	default:
+		panic(me.opts.Prefix)
+	}
+	for _, p := range parts {
+		off += copy(b[off:], p)
+	}
+	_, err := me.w.Write(b[:off])
+	return err
+}
@@ -0,0 +1,84 @@
+package framing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, prefix := range []LengthPrefix{Uvarint, Fixed32} {
+		var buf bytes.Buffer
+		opts := Opts{Prefix: prefix, MaxSize: 1000}
+		w := NewWriter(&buf, opts)
+		payloads := [][]byte{{}, []byte("hello"), bytes.Repeat([]byte{1}, 1000)}
+		for _, p := range payloads {
+			require.NoError(t, w.WriteFrame(p))
+		}
+		assert.True(t, errors.Is(w.WriteFrame(make([]byte, 1001)), ErrTooLarge))
+		require.NoError(t, w.WriteFrame([]byte("a"), []byte("b")))
+		r := NewReader(&buf, opts)
+		for _, p := range append(payloads, []byte("ab")) {
+			b, err := r.ReadFrame()
+			require.NoError(t, err)
+			assert.Equal(t, p, b)
+			r.Release(b)
+		}
+		_, err := r.ReadFrame()
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	var buf bytes.Buffer
+	NewWriter(&buf, Opts{}).WriteFrame(make([]byte, 100))
+	_, err := NewReader(bytes.NewReader(buf.Bytes()), Opts{MaxSize: 99}).ReadFrame()
+	assert.True(t, errors.Is(err, ErrTooLarge))
+	_, err = NewReader(bytes.NewReader(buf.Bytes()[:50]), Opts{}).ReadFrame()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = NewReader(bytes.NewReader([]byte{0, 0}), Opts{Prefix: Fixed32}).ReadFrame()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestPump(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, Opts{})
+	w.WriteMessage(1, []byte("one"))
+	w.WriteMessage(2, []byte("two"))
+	w.WriteMessage(1, []byte("uno"))
+	w.WriteFrame()
+	var got []string
+	var unknown [][]byte
+	p := Pump{
+		Reader: NewReader(&buf, Opts{}),
+		Handlers: map[byte]Handler{
+			1: func(b []byte) error {
+				got = append(got, string(b))
+				return nil
+			},
+		},
+	}
+	err := p.Run(context.Background())
+	assert.EqualError(t, err, "no handler for message type 2")
+	assert.Equal(t, []string{"one"}, got)
+
+	p.Default = func(b []byte) error {
+		unknown = append(unknown, append([]byte(nil), b...))
+		return nil
+	}
+	require.NoError(t, p.Run(context.Background()))
+	assert.Equal(t, []string{"one", "uno"}, got)
+	// The empty frame.
+	if assert.Len(t, unknown, 1) {
+		assert.Empty(t, unknown[0])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, p.Run(ctx))
+}
@@ -0,0 +1,65 @@
+package framing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Handles a message's payload, which is only valid until it returns.
+type Handler func(payload []byte) error
+
+// Dispatches frames to handlers by their first byte, the message type. See
+// Writer.WriteMessage.
+type Pump struct {
+	Reader   *Reader
+	Handlers map[byte]Handler
+	// Handles messages without a type in Handlers, and empty frames, which
+	// it gets whole. By default these are an error.
+	Default Handler
+}
+
+// Writes a frame with the message type, then the payload.
+func (me *Writer) WriteMessage(typ byte, payload []byte) error {
+	return me.WriteFrame([]byte{typ}, payload)
+}
+
+// Reads and dispatches frames in order until the stream ends, a handler
+// returns an error, or ctx is done, returning nil in the first case. ctx is
+// only checked between frames, so to interrupt a blocked read, close the
+// underlying stream.
+func (me *Pump) Run(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b, err := me.Reader.ReadFrame()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = me.dispatch(b)
+		me.Reader.Release(b)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (me *Pump) dispatch(b []byte) error {
+	if len(b) != 0 {
+		if h, ok := me.Handlers[b[0]]; ok {
+			return h(b[1:])
+		}
+	}
+	if me.Default != nil {
+		return me.Default(b)
+	}
+	if len(b) == 0 {
+		return errors.New("empty frame")
+	}
+	return fmt.Errorf("no handler for message type %d", b[0])
+}
@@ -0,0 +1,29 @@
+package fsm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Writes the transitions as a Graphviz digraph. Guarded transitions are drawn
+// dashed, and the initial state is marked by an arrow from a point.
+func (me *Machine[S, E]) WriteDOT(w io.Writer, name string) error {
+	bw := bufio.NewWriter(w)
+	quote := func(v any) string {
+		return strconv.Quote(fmt.Sprint(v))
+	}
+	fmt.Fprintf(bw, "digraph %s {\n", quote(name))
+	fmt.Fprintf(bw, "\t%s [shape=point];\n", quote(""))
+	fmt.Fprintf(bw, "\t%s -> %s;\n", quote(""), quote(me.initial))
+	for _, t := range me.ordered {
+		fmt.Fprintf(bw, "\t%s -> %s [label=%s", quote(t.From), quote(t.To), quote(t.Event))
+		if t.Guard != nil {
+			bw.WriteString(", style=dashed")
+		}
+		bw.WriteString("];\n")
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
@@ -0,0 +1,133 @@
+// Package fsm provides finite state machines with typed states and events.
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Returned by Fire when no transition for the event applies in the current
+// state, or all of their guards reject it.
+var ErrNoTransition = errors.New("no transition")
+
+type Transition[S, E comparable] struct {
+	From  S
+	Event E
+	To    S
+	// If set, the transition is only taken when this returns true.
+	Guard func() bool
+}
+
+// Called with the states on either side of a transition, and the event that
+// caused it.
+type Hook[S, E comparable] func(from S, event E, to S)
+
+type key[S, E comparable] struct {
+	state S
+	event E
+}
+
+// A state machine. Transitions and hooks should be added before events are
+// fired.
+type Machine[S, E comparable] struct {
+	// Serializes Fire, so hooks run in the order of transitions.
+	fireMu sync.Mutex
+	mu     sync.Mutex
+	state  S
+
+	initial     S
+	transitions map[key[S, E]][]Transition[S, E]
+	// In the order added, for deterministic output.
+	ordered      []Transition[S, E]
+	onEnter      map[S][]Hook[S, E]
+	onExit       map[S][]Hook[S, E]
+	onTransition []Hook[S, E]
+}
+
+func New[S, E comparable](initial S) *Machine[S, E] {
+	return &Machine[S, E]{
+		state:       initial,
+		initial:     initial,
+		transitions: make(map[key[S, E]][]Transition[S, E]),
+		onEnter:     make(map[S][]Hook[S, E]),
+		onExit:      make(map[S][]Hook[S, E]),
+	}
+}
+
+// Adds transitions. Where several share a From and Event, the first whose
+// guard passes is taken.
+func (me *Machine[S, E]) Add(ts ...Transition[S, E]) *Machine[S, E] {
+	for _, t := range ts {
+		k := key[S, E]{t.From, t.Event}
+		me.transitions[k] = append(me.transitions[k], t)
+		me.ordered = append(me.ordered, t)
+	}
+	return me
+}
+
+// Adds a hook run on entering the state, after the state has changed.
+func (me *Machine[S, E]) OnEnter(s S, h Hook[S, E]) *Machine[S, E] {
+	me.onEnter[s] = append(me.onEnter[s], h)
+	return me
+}
+
+// Adds a hook run on leaving the state, before the state has changed.
+func (me *Machine[S, E]) OnExit(s S, h Hook[S, E]) *Machine[S, E] {
+	me.onExit[s] = append(me.onExit[s], h)
+	return me
+}
+
+// Adds a hook run on every transition, between the exit and enter hooks.
+func (me *Machine[S, E]) OnTransition(h Hook[S, E]) *Machine[S, E] {
+	me.onTransition = append(me.onTransition, h)
+	return me
+}
+
+func (me *Machine[S, E]) State() S {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.state
+}
+
+// Returns the transition the event would take, without taking it.
+func (me *Machine[S, E]) find(event E) (t Transition[S, E], ok bool) {
+	for _, t := range me.transitions[key[S, E]{me.State(), event}] {
+		if t.Guard == nil || t.Guard() {
+			return t, true
+		}
+	}
+	return
+}
+
+// Returns whether firing the event now would cause a transition.
+func (me *Machine[S, E]) Can(event E) bool {
+	me.fireMu.Lock()
+	defer me.fireMu.Unlock()
+	_, ok := me.find(event)
+	return ok
+}
+
+// Takes the transition for the event, running the hooks. Hooks must not
+// fire events on the same Machine.
+func (me *Machine[S, E]) Fire(event E) error {
+	me.fireMu.Lock()
+	defer me.fireMu.Unlock()
+	t, ok := me.find(event)
+	if !ok {
+		return fmt.Errorf("%w for event %v in state %v", ErrNoTransition, event, me.State())
+	}
+	for _, h := range me.onExit[t.From] {
+		h(t.From, event, t.To)
+	}
+	me.mu.Lock()
+	me.state = t.To
+	me.mu.Unlock()
+	for _, h := range me.onTransition {
+		h(t.From, event, t.To)
+	}
+	for _, h := range me.onEnter[t.To] {
+		h(t.From, event, t.To)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package fsm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	state string
+	event string
+)
+
+func newConnMachine(allowRetry *bool) *Machine[state, event] {
+	return New[state, event]("idle").Add(
+		Transition[state, event]{From: "idle", Event: "dial", To: "connecting"},
+		Transition[state, event]{From: "connecting", Event: "ok", To: "open"},
+		Transition[state, event]{From: "connecting", Event: "fail", To: "connecting", Guard: func() bool { return *allowRetry }},
+		Transition[state, event]{From: "connecting", Event: "fail", To: "closed"},
+		Transition[state, event]{From: "open", Event: "close", To: "closed"},
+	)
+}
+
+func TestMachine(t *testing.T) {
+	retry := true
+	m := newConnMachine(&retry)
+	var log []string
+	record := func(prefix string) Hook[state, event] {
+		return func(from state, ev event, to state) {
+			log = append(log, prefix+":"+string(from)+"-"+string(ev)+"->"+string(to))
+		}
+	}
+	m.OnExit("connecting", record("exit")).
+		OnEnter("connecting", record("enter")).
+		OnTransition(record("trans"))
+
+	assert.False(t, m.Can("ok"))
+	err := m.Fire("ok")
+	assert.True(t, errors.Is(err, ErrNoTransition))
+	assert.EqualValues(t, "idle", m.State())
+
+	require.NoError(t, m.Fire("dial"))
+	require.NoError(t, m.Fire("fail"))
+	assert.EqualValues(t, "connecting", m.State())
+	retry = false
+	require.NoError(t, m.Fire("fail"))
+	assert.EqualValues(t, "closed", m.State())
+	assert.Equal(t, []string{
+		"trans:idle-dial->connecting",
+		"enter:idle-dial->connecting",
+		"exit:connecting-fail->connecting",
+		"trans:connecting-fail->connecting",
+		"enter:connecting-fail->connecting",
+		"exit:connecting-fail->closed",
+		"trans:connecting-fail->closed",
+	}, log)
+}
+
+func TestWriteDOT(t *testing.T) {
+	retry := false
+	var sb strings.Builder
+	require.NoError(t, newConnMachine(&retry).WriteDOT(&sb, "conn"))
+	assert.Equal(t, `digraph "conn" {
+	"" [shape=point];
+	"" -> "idle";
+	"idle" -> "connecting" [label="dial"];
+	"connecting" -> "open" [label="ok"];
+	"connecting" -> "connecting" [label="fail", style=dashed];
+	"connecting" -> "closed" [label="fail"];
+	"open" -> "closed" [label="close"];
+}
+`, sb.String())
+}
@@ -9,6 +9,8 @@ func timeoutFuture(timeout time.Duration) *F {
 	})
 }
 
+// A block of futures released by AsCompletedDelayed after Delay. Create them
+// with Lazy so they don't run until then.
 type Delayed struct {
 	Delay time.Duration
 	Fs    []*F
@@ -3,6 +3,7 @@ package futures
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -84,3 +85,46 @@ func TestAsCompletedDelayedContextCanceled(t *testing.T) {
 	assert.False(t, ok)
 	assert.True(t, time.Since(s) < 1*u)
 }
+
+func TestAsCompletedDelayedLazy(t *testing.T) {
+	t.Parallel()
+	var started int32
+	lazy := Lazy(func() (interface{}, error) {
+		atomic.StoreInt32(&started, 1)
+		return nil, nil
+	})
+	initial := timeoutFuture(u)
+	as, drained := Drained(AsCompletedDelayed(
+		context.Background(),
+		[]*F{initial},
+		[]Delayed{{time.Hour, []*F{lazy}}},
+	))
+	time.Sleep(u / 2)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&started))
+	assert.Equal(t, initial, <-as)
+	select {
+	case <-drained:
+		t.Fatal("drained too soon")
+	default:
+	}
+	// The delayed block is released early since everything before it is done.
+	assert.Equal(t, lazy, <-as)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&started))
+	<-drained
+	_, ok := <-as
+	assert.False(t, ok)
+}
+
+func TestAsCompletedTimed(t *testing.T) {
+	t.Parallel()
+	var fs []*F
+	for i := range iter.N(3) {
+		fs = append(fs, timeoutFuture(time.Duration(i)*u))
+	}
+	var prev time.Duration
+	for tf := range AsCompletedTimed(fs...) {
+		assert.True(t, tf.Elapsed >= prev)
+		prev = tf.Elapsed
+	}
+	assert.True(t, prev >= 2*u)
+}
@@ -30,6 +30,40 @@ func AsCompleted(fs ...*F) <-chan *F {
 	return ret
 }
 
+// A completed future, and how long it took.
+type Timed struct {
+	F       *F
+	Elapsed time.Duration
+}
+
+// Like AsCompleted, but annotates each future with how long it took.
+func AsCompletedTimed(fs ...*F) <-chan Timed {
+	ret := make(chan Timed, len(fs))
+	go func() {
+		defer close(ret)
+		for f := range AsCompleted(fs...) {
+			ret <- Timed{f, f.Elapsed()}
+		}
+	}()
+	return ret
+}
+
+// Forwards futures from ch, such as those returned by AsCompleted. The
+// returned struct{} chan is closed once every future has been received from
+// the returned *F chan, which is useful to know when fallbacks are exhausted.
+func Drained(ch <-chan *F) (<-chan *F, <-chan struct{}) {
+	ret := make(chan *F)
+	drained := make(chan struct{})
+	go func() {
+		defer close(ret)
+		for f := range ch {
+			ret <- f
+		}
+		close(drained)
+	}()
+	return ret, drained
+}
+
 // Additional state maintained for each delayed element.
 type delayedState struct {
 	timeout *F
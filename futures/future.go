@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 )
 
 func Start(fn func() (interface{}, error)) *F {
 	f := &F{
-		done: make(chan struct{}),
+		done:    make(chan struct{}),
+		started: time.Now(),
 	}
 	go func() {
 		f.setResult(fn())
@@ -16,6 +18,21 @@ func Start(fn func() (interface{}, error)) *F {
 	return f
 }
 
+// Returns a future that doesn't run fn until something demands the result,
+// such as by waiting on Done. Lazy futures in a Delayed block aren't started
+// until the block is released.
+func Lazy(fn func() (interface{}, error)) *F {
+	f := &F{
+		done: make(chan struct{}),
+	}
+	f.start = func() {
+		go func() {
+			f.setResult(fn())
+		}()
+	}
+	return f
+}
+
 func StartNoError(fn func() interface{}) *F {
 	return Start(func() (interface{}, error) {
 		return fn(), nil
@@ -23,11 +40,14 @@ func StartNoError(fn func() interface{}) *F {
 }
 
 type F struct {
-	name   string
-	mu     sync.Mutex
-	result interface{}
-	err    error
-	done   chan struct{}
+	name      string
+	mu        sync.Mutex
+	result    interface{}
+	err       error
+	done      chan struct{}
+	start     func() // Set until a lazy future is demanded.
+	started   time.Time
+	completed time.Time
 }
 
 func (f *F) String() string {
@@ -42,13 +62,13 @@ func (f *F) SetName(s string) {
 }
 
 func (f *F) Err() error {
-	<-f.done
+	<-f.Done()
 	return f.err
 }
 
 // TODO: Just return value.
 func (f *F) Result() (interface{}, error) {
-	<-f.done
+	<-f.Done()
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	return f.result, f.err
@@ -63,14 +83,39 @@ func (f *F) MustResult() interface{} {
 }
 
 func (f *F) Done() <-chan struct{} {
+	f.demand()
 	return f.done
 }
 
+// Starts a lazy future if it hasn't been already.
+func (f *F) demand() {
+	f.mu.Lock()
+	start := f.start
+	if start != nil {
+		f.start = nil
+		f.started = time.Now()
+	}
+	f.mu.Unlock()
+	if start != nil {
+		start()
+	}
+}
+
+// Waits for the result, and returns how long the future took from when it
+// started running.
+func (f *F) Elapsed() time.Duration {
+	<-f.Done()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.completed.Sub(f.started)
+}
+
 func (f *F) setResult(result interface{}, err error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.result = result
 	f.err = err
+	f.completed = time.Now()
 	close(f.done)
 }
 
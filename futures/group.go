@@ -0,0 +1,98 @@
+package futures
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Deduplicates calls by key: concurrent callers with the same key share one
+// execution, and successful results can be reused for a while after. The
+// zero value is ready for use.
+type Group[K comparable, V any] struct {
+	// How long successful results are reused. Zero means only callers that
+	// overlap the execution share its result.
+	TTL time.Duration
+
+	mu    sync.Mutex
+	calls map[K]*groupCall[V]
+}
+
+type groupCall[V any] struct {
+	f      *Future[V]
+	cancel context.CancelFunc
+	// Callers waiting on f.
+	waiters int
+	// Set when the result is being reused.
+	expiry *time.Timer
+}
+
+// Returns the result of fn for key, running it only if there isn't already a
+// call in progress, or a result that's still fresh. fn is given a Context
+// that's cancelled once every caller waiting on it has given up, so it
+// doesn't carry values from any particular caller's ctx.
+func (me *Group[K, V]) Do(ctx context.Context, key K, fn func(context.Context) (V, error)) (value V, err error) {
+	me.mu.Lock()
+	c, ok := me.calls[key]
+	if !ok {
+		c = me.start(key, fn)
+	}
+	c.waiters++
+	me.mu.Unlock()
+	value, err = c.f.Result(ctx)
+	me.mu.Lock()
+	c.waiters--
+	if c.waiters == 0 && me.calls[key] == c {
+		if _, _, done := c.f.Peek(); !done {
+			c.cancel()
+			delete(me.calls, key)
+		}
+	}
+	me.mu.Unlock()
+	return
+}
+
+func (me *Group[K, V]) start(key K, fn func(context.Context) (V, error)) *groupCall[V] {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &groupCall[V]{cancel: cancel}
+	f, complete := NewPromise[V]()
+	c.f = f
+	if me.calls == nil {
+		me.calls = make(map[K]*groupCall[V])
+	}
+	me.calls[key] = c
+	go func() {
+		v, err := fn(ctx)
+		cancel()
+		me.mu.Lock()
+		complete(v, err)
+		if me.calls[key] == c {
+			if err != nil || me.TTL <= 0 {
+				delete(me.calls, key)
+			} else {
+				c.expiry = time.AfterFunc(me.TTL, func() {
+					me.mu.Lock()
+					if me.calls[key] == c {
+						delete(me.calls, key)
+					}
+					me.mu.Unlock()
+				})
+			}
+		}
+		me.mu.Unlock()
+	}()
+	return c
+}
+
+// Discards any result reused for key, and detaches callers of any call in
+// progress from future callers.
+func (me *Group[K, V]) Forget(key K) {
+	me.mu.Lock()
+	if c, ok := me.calls[key]; ok {
+		if c.expiry != nil {
+			c.expiry.Stop()
+		}
+		delete(me.calls, key)
+	}
+	me.mu.Unlock()
+}
@@ -0,0 +1,102 @@
+package futures
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupShares(t *testing.T) {
+	var g Group[string, int]
+	var calls int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := g.Do(context.Background(), "a", fn)
+			assert.NoError(t, err)
+			assert.Equal(t, 42, v)
+		}()
+	}
+	for {
+		g.mu.Lock()
+		c := g.calls["a"]
+		waiters := 0
+		if c != nil {
+			waiters = c.waiters
+		}
+		g.mu.Unlock()
+		if waiters == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	assert.EqualValues(t, 1, calls)
+	// Without a TTL, nothing is memoized.
+	g.Do(context.Background(), "a", fn)
+	assert.EqualValues(t, 2, calls)
+}
+
+func TestGroupTTL(t *testing.T) {
+	g := Group[int, int]{TTL: time.Hour}
+	calls := 0
+	fn := func(ctx context.Context) (int, error) {
+		calls++
+		return calls, nil
+	}
+	v, _ := g.Do(context.Background(), 1, fn)
+	assert.Equal(t, 1, v)
+	v, _ = g.Do(context.Background(), 1, fn)
+	assert.Equal(t, 1, v)
+	g.Forget(1)
+	v, _ = g.Do(context.Background(), 1, fn)
+	assert.Equal(t, 2, v)
+
+	boom := errors.New("boom")
+	_, err := g.Do(context.Background(), 2, func(context.Context) (int, error) { return 0, boom })
+	assert.Equal(t, boom, err)
+	// Errors aren't memoized.
+	v, err = g.Do(context.Background(), 2, fn)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+}
+
+func TestGroupCancelled(t *testing.T) {
+	var g Group[int, int]
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan struct{})
+	go func() {
+		for {
+			g.mu.Lock()
+			_, ok := g.calls[1]
+			g.mu.Unlock()
+			if ok {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	_, err := g.Do(ctx, 1, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return 0, ctx.Err()
+	})
+	assert.Equal(t, context.Canceled, err)
+	// The last waiter giving up cancels the execution.
+	<-cancelled
+}
@@ -0,0 +1,104 @@
+package futures
+
+import "sync"
+
+// Progress of an operation, in units of its choosing, such as bytes or
+// percent.
+type Progress struct {
+	Done int64
+	// Zero if unknown.
+	Total int64
+}
+
+// Returns the fraction of Total that's Done, if Total is known.
+func (me Progress) Fraction() (f float64, ok bool) {
+	if me.Total == 0 {
+		return
+	}
+	return float64(me.Done) / float64(me.Total), true
+}
+
+type progressState struct {
+	mu       sync.Mutex
+	latest   Progress
+	watchers []chan Progress
+	closed   bool
+}
+
+// Like NewPromise, also returning a func that reports progress toward
+// completion to watchers of the Future.
+func NewProgressPromise[T any]() (f *Future[T], complete func(T, error), report func(Progress)) {
+	f, complete = NewPromise[T]()
+	f.progress = new(progressState)
+	return f, complete, f.progress.report
+}
+
+// Runs fn in a new goroutine, like Go, passing it a func to report its
+// progress.
+func GoProgress[T any](fn func(report func(Progress)) (T, error)) *Future[T] {
+	f, complete, report := NewProgressPromise[T]()
+	go func() {
+		complete(fn(report))
+	}()
+	return f
+}
+
+func (me *progressState) report(p Progress) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.closed {
+		return
+	}
+	me.latest = p
+	for _, w := range me.watchers {
+		// Replace any update the watcher hasn't received yet.
+		select {
+		case <-w:
+		default:
+		}
+		w <- p
+	}
+}
+
+func (me *progressState) close() {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.closed = true
+	for _, w := range me.watchers {
+		close(w)
+	}
+	me.watchers = nil
+}
+
+// Returns the most recently reported progress. It's the zero value if the
+// Future doesn't report progress.
+func (f *Future[T]) Progress() Progress {
+	if f.progress == nil {
+		return Progress{}
+	}
+	f.progress.mu.Lock()
+	defer f.progress.mu.Unlock()
+	return f.progress.latest
+}
+
+// Returns a channel that receives progress reports, starting with the
+// current one. Reports a slow receiver misses are dropped in favour of the
+// latest. The channel is closed when the Future completes, or immediately if
+// it doesn't report progress.
+func (f *Future[T]) ProgressUpdates() <-chan Progress {
+	ch := make(chan Progress, 1)
+	if f.progress == nil {
+		close(ch)
+		return ch
+	}
+	me := f.progress
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.closed {
+		close(ch)
+		return ch
+	}
+	ch <- me.latest
+	me.watchers = append(me.watchers, ch)
+	return ch
+}
@@ -0,0 +1,50 @@
+package futures
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	f, complete, report := NewProgressPromise[string]()
+	updates := f.ProgressUpdates()
+	assert.Equal(t, Progress{}, <-updates)
+	report(Progress{1, 10})
+	report(Progress{5, 10})
+	// Only the latest is kept for a slow receiver.
+	p := <-updates
+	assert.Equal(t, Progress{5, 10}, p)
+	frac, ok := p.Fraction()
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, frac)
+	assert.Equal(t, p, f.Progress())
+	complete("done", nil)
+	_, ok = <-updates
+	assert.False(t, ok)
+	report(Progress{10, 10})
+	assert.Equal(t, p, f.Progress())
+	_, ok = <-f.ProgressUpdates()
+	assert.False(t, ok)
+}
+
+func TestGoProgress(t *testing.T) {
+	f := GoProgress(func(report func(Progress)) (int, error) {
+		for i := int64(1); i <= 3; i++ {
+			report(Progress{Done: i})
+		}
+		return 3, nil
+	})
+	for range f.ProgressUpdates() {
+	}
+	v, err := f.Result(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+	assert.Equal(t, Progress{Done: 3}, f.Progress())
+	_, ok := Progress{Done: 3}.Fraction()
+	assert.False(t, ok)
+
+	_, ok = <-Ready(1, nil).ProgressUpdates()
+	assert.False(t, ok)
+}
@@ -0,0 +1,123 @@
+package futures
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Future is a value of type T, or an error, that becomes available at some
+// point. Unlike F, waiting on it can be abandoned through a Context.
+type Future[T any] struct {
+	once  sync.Once
+	done  chan struct{}
+	value T
+	err   error
+	// Set if the Future reports progress.
+	progress *progressState
+}
+
+// Returns an incomplete Future, and the func that completes it. Only the first
+// completion has any effect.
+func NewPromise[T any]() (f *Future[T], complete func(T, error)) {
+	f = &Future[T]{done: make(chan struct{})}
+	return f, f.complete
+}
+
+// Runs fn in a new goroutine, completing the returned Future with its result.
+func Go[T any](fn func() (T, error)) *Future[T] {
+	f, complete := NewPromise[T]()
+	go func() {
+		complete(fn())
+	}()
+	return f
+}
+
+// Returns an already completed Future.
+func Ready[T any](value T, err error) *Future[T] {
+	f, complete := NewPromise[T]()
+	complete(value, err)
+	return f
+}
+
+func (f *Future[T]) complete(value T, err error) {
+	f.once.Do(func() {
+		f.value = value
+		f.err = err
+		close(f.done)
+		if f.progress != nil {
+			f.progress.close()
+		}
+	})
+}
+
+// Closed when the result is available.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Waits for the result. If ctx completes first, its error is returned
+// instead, and the Future is unaffected.
+func (f *Future[T]) Result(ctx context.Context) (value T, err error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	}
+}
+
+// Returns the result without waiting. ok is false if it's not available yet.
+func (f *Future[T]) Peek() (value T, err error, ok bool) {
+	select {
+	case <-f.done:
+		return f.value, f.err, true
+	default:
+		return
+	}
+}
+
+// Returns a Future for the result of fn applied to f's value. Errors from f
+// pass through without calling fn.
+func Then[T, U any](f *Future[T], fn func(T) (U, error)) *Future[U] {
+	ret, complete := NewPromise[U]()
+	go func() {
+		<-f.done
+		if f.err != nil {
+			var u U
+			complete(u, f.err)
+			return
+		}
+		complete(fn(f.value))
+	}()
+	return ret
+}
+
+// Returns a Future that's the result of fn if f fails, allowing recovery or
+// substitution of the error. Successful results pass through.
+func (f *Future[T]) Catch(fn func(error) (T, error)) *Future[T] {
+	ret, complete := NewPromise[T]()
+	go func() {
+		<-f.done
+		if f.err == nil {
+			complete(f.value, nil)
+			return
+		}
+		complete(fn(f.err))
+	}()
+	return ret
+}
+
+// Returns an untyped F, for use with the older helpers like AsCompleted.
+func (f *Future[T]) Untyped() *F {
+	ret := &F{
+		done:    make(chan struct{}),
+		started: time.Now(),
+	}
+	go func() {
+		<-f.done
+		ret.setResult(f.value, f.err)
+	}()
+	return ret
+}
@@ -0,0 +1,55 @@
+package futures
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFutureResultContext(t *testing.T) {
+	f, complete := NewPromise[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.Result(ctx)
+	assert.Equal(t, context.Canceled, err)
+	_, _, ok := f.Peek()
+	assert.False(t, ok)
+	complete(1, nil)
+	complete(2, nil)
+	v, err := f.Result(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func TestFutureThenCatch(t *testing.T) {
+	ctx := context.Background()
+	s, err := Then(Go(func() (int, error) { return 42, nil }), func(i int) (string, error) {
+		return strconv.Itoa(i), nil
+	}).Result(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "42", s)
+
+	boom := errors.New("boom")
+	called := false
+	f := Then(Ready(0, boom), func(i int) (string, error) {
+		called = true
+		return "", nil
+	})
+	_, err = f.Result(ctx)
+	assert.Equal(t, boom, err)
+	assert.False(t, called)
+
+	s, err = f.Catch(func(err error) (string, error) {
+		return "recovered", nil
+	}).Result(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "recovered", s)
+}
+
+func TestFutureUntyped(t *testing.T) {
+	f := Ready("a", nil).Untyped()
+	assert.Equal(t, "a", f.MustResult())
+}
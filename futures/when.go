@@ -0,0 +1,133 @@
+package futures
+
+import (
+	"context"
+	"strings"
+)
+
+// A unit of work that isn't started until a combinator has capacity for it.
+// It should return promptly when ctx is done.
+type Task[T any] func(ctx context.Context) (T, error)
+
+type WhenOpts struct {
+	// The most tasks running at once. Zero means no limit.
+	Concurrency int
+}
+
+// The errors from every task, when none succeeded.
+type Errors []error
+
+func (me Errors) Error() string {
+	if len(me) == 0 {
+		return "no tasks"
+	}
+	ss := make([]string, 0, len(me))
+	for _, err := range me {
+		ss = append(ss, err.Error())
+	}
+	return strings.Join(ss, "; ")
+}
+
+// Runs tasks with at most opts.Concurrency at once, passing results to each in
+// the order they complete, until it returns true. Tasks still running are
+// then cancelled through their Context. Tasks that were never started report
+// the Context's error.
+func runTasks[T any](ctx context.Context, opts WhenOpts, tasks []Task[T], each func(i int, v T, err error) (stop bool)) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		i   int
+		v   T
+		err error
+	}
+	// Buffered so that tasks never block reporting after we've stopped
+	// listening.
+	results := make(chan result, len(tasks))
+	go func() {
+		var sem chan struct{}
+		if opts.Concurrency > 0 {
+			sem = make(chan struct{}, opts.Concurrency)
+		}
+		for i, t := range tasks {
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+				}
+			}
+			if ctx.Err() != nil {
+				var v T
+				results <- result{i, v, ctx.Err()}
+				continue
+			}
+			go func(i int, t Task[T]) {
+				v, err := t(ctx)
+				if sem != nil {
+					<-sem
+				}
+				results <- result{i, v, err}
+			}(i, t)
+		}
+	}()
+	for range tasks {
+		r := <-results
+		if each(r.i, r.v, r.err) {
+			return
+		}
+	}
+}
+
+// Completes with the values of all the tasks in order, or the first error,
+// in which case the remaining tasks are cancelled.
+func WhenAll[T any](ctx context.Context, opts WhenOpts, tasks ...Task[T]) *Future[[]T] {
+	return Go(func() (values []T, err error) {
+		values = make([]T, len(tasks))
+		runTasks(ctx, opts, tasks, func(i int, v T, _err error) bool {
+			if _err != nil {
+				err = _err
+				return true
+			}
+			values[i] = v
+			return false
+		})
+		if err != nil {
+			values = nil
+		}
+		return
+	})
+}
+
+// Completes with the first successful value, cancelling the other tasks. If
+// every task fails, the error is Errors, ordered as the tasks were given.
+func WhenAny[T any](ctx context.Context, opts WhenOpts, tasks ...Task[T]) *Future[T] {
+	return Go(func() (value T, err error) {
+		errs := make(Errors, len(tasks))
+		ok := false
+		runTasks(ctx, opts, tasks, func(i int, v T, err error) bool {
+			if err == nil {
+				value = v
+				ok = true
+				return true
+			}
+			errs[i] = err
+			return false
+		})
+		if !ok {
+			err = errs
+		}
+		return
+	})
+}
+
+// Completes with the result of the first task to finish, successful or not,
+// cancelling the others.
+func Race[T any](ctx context.Context, opts WhenOpts, tasks ...Task[T]) *Future[T] {
+	return Go(func() (value T, err error) {
+		err = Errors(nil)
+		runTasks(ctx, opts, tasks, func(i int, v T, _err error) bool {
+			value, err = v, _err
+			return true
+		})
+		return
+	})
+}
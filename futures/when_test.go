@@ -0,0 +1,98 @@
+package futures
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhenAllConcurrency(t *testing.T) {
+	var (
+		mu              sync.Mutex
+		running, maxRun int
+	)
+	var tasks []Task[int]
+	for i := 0; i < 10; i++ {
+		i := i
+		tasks = append(tasks, func(ctx context.Context) (int, error) {
+			mu.Lock()
+			running++
+			if running > maxRun {
+				maxRun = running
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return i, nil
+		})
+	}
+	values, err := WhenAll(context.Background(), WhenOpts{Concurrency: 3}, tasks...).Result(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, values)
+	assert.True(t, maxRun <= 3, maxRun)
+}
+
+func TestWhenAllError(t *testing.T) {
+	boom := errors.New("boom")
+	cancelled := make(chan struct{})
+	values, err := WhenAll(context.Background(), WhenOpts{},
+		func(ctx context.Context) (int, error) { return 0, boom },
+		func(ctx context.Context) (int, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return 0, ctx.Err()
+		},
+	).Result(context.Background())
+	assert.Equal(t, boom, err)
+	assert.Nil(t, values)
+	<-cancelled
+}
+
+func TestWhenAnyCancelsLosers(t *testing.T) {
+	cancelled := make(chan struct{})
+	v, err := WhenAny(context.Background(), WhenOpts{},
+		func(ctx context.Context) (string, error) { return "", errors.New("fail") },
+		func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return "slow", nil
+		},
+		func(ctx context.Context) (string, error) {
+			time.Sleep(time.Millisecond)
+			return "fast", nil
+		},
+	).Result(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "fast", v)
+	<-cancelled
+}
+
+func TestWhenAnyAllFail(t *testing.T) {
+	_, err := WhenAny(context.Background(), WhenOpts{Concurrency: 1},
+		func(ctx context.Context) (int, error) { return 0, errors.New("a") },
+		func(ctx context.Context) (int, error) { return 0, errors.New("b") },
+	).Result(context.Background())
+	require.IsType(t, Errors{}, err)
+	assert.EqualError(t, err, "a; b")
+	_, err = WhenAny[int](context.Background(), WhenOpts{}).Result(context.Background())
+	assert.EqualError(t, err, "no tasks")
+}
+
+func TestRace(t *testing.T) {
+	boom := errors.New("boom")
+	_, err := Race(context.Background(), WhenOpts{},
+		func(ctx context.Context) (int, error) { return 0, boom },
+		func(ctx context.Context) (int, error) {
+			<-ctx.Done()
+			return 1, nil
+		},
+	).Result(context.Background())
+	assert.Equal(t, boom, err)
+}
@@ -0,0 +1,140 @@
+// Package hashring spreads keys over members with consistent hashing, so
+// that membership changes move as few keys as possible.
+package hashring
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// A consistent hash ring. Each member has points on the ring in proportion to
+// its weight, and keys belong to the member with the next point. It's safe
+// for concurrent use.
+type Ring struct {
+	pointsPerWeight int
+
+	mu      sync.RWMutex
+	weights map[string]int
+	// Sorted by hash.
+	points []point
+}
+
+type point struct {
+	hash   uint64
+	member string
+}
+
+// Returns an empty Ring, where members get pointsPerWeight points for each
+// unit of weight. More points spread keys more evenly, at the cost of memory.
+// Defaults to 100.
+func New(pointsPerWeight int) *Ring {
+	if pointsPerWeight <= 0 {
+		pointsPerWeight = 100
+	}
+	return &Ring{
+		pointsPerWeight: pointsPerWeight,
+		weights:         make(map[string]int),
+	}
+}
+
+// FNV alone distributes similar inputs poorly, so its output is mixed with
+// the splitmix64 finalizer.
+func hash(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (me *Ring) memberPoints(member string, weight int) (ret []point) {
+	for i := 0; i < weight*me.pointsPerWeight; i++ {
+		ret = append(ret, point{hash([]byte(member + "#" + strconv.Itoa(i))), member})
+	}
+	return
+}
+
+// Adds member, or changes its weight. Members with zero weight are removed.
+func (me *Ring) Set(member string, weight int) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if weight <= 0 {
+		delete(me.weights, member)
+	} else {
+		me.weights[member] = weight
+	}
+	me.rebuild()
+}
+
+func (me *Ring) Remove(member string) {
+	me.Set(member, 0)
+}
+
+// Points are recomputed rather than inserted, so the ring is the same for the
+// same members, whatever order they were added in.
+func (me *Ring) rebuild() {
+	me.points = me.points[:0]
+	for m, w := range me.weights {
+		me.points = append(me.points, me.memberPoints(m, w)...)
+	}
+	sort.Slice(me.points, func(i, j int) bool {
+		l, r := me.points[i], me.points[j]
+		if l.hash != r.hash {
+			return l.hash < r.hash
+		}
+		return l.member < r.member
+	})
+}
+
+// Returns the members and their weights.
+func (me *Ring) Members() map[string]int {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	ret := make(map[string]int, len(me.weights))
+	for m, w := range me.weights {
+		ret[m] = w
+	}
+	return ret
+}
+
+// Returns the member key belongs to. ok is false if there are no members.
+func (me *Ring) Get(key []byte) (member string, ok bool) {
+	ms := me.GetN(key, 1)
+	if len(ms) == 0 {
+		return
+	}
+	return ms[0], true
+}
+
+// Returns up to n distinct members for key, in order of preference, such as
+// for placing replicas. The first is the one returned by Get.
+func (me *Ring) GetN(key []byte, n int) (ret []string) {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	if n > len(me.weights) {
+		n = len(me.weights)
+	}
+	if n <= 0 {
+		return
+	}
+	h := hash(key)
+	i := sort.Search(len(me.points), func(i int) bool {
+		return me.points[i].hash >= h
+	})
+	seen := make(map[string]struct{}, n)
+	for j := 0; len(ret) < n; j++ {
+		m := me.points[(i+j)%len(me.points)].member
+		if _, ok := seen[m]; ok {
+			continue
+		}
+		seen[m] = struct{}{}
+		ret = append(ret, m)
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package hashring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const numKeys = 10000
+
+func keyOwners(r *Ring) (ret []string) {
+	for i := 0; i < numKeys; i++ {
+		m, _ := r.Get([]byte(fmt.Sprintf("key%d", i)))
+		ret = append(ret, m)
+	}
+	return
+}
+
+func TestWeights(t *testing.T) {
+	r := New(0)
+	_, ok := r.Get([]byte("a"))
+	assert.False(t, ok)
+	r.Set("a", 1)
+	r.Set("b", 1)
+	r.Set("c", 2)
+	counts := make(map[string]int)
+	for _, m := range keyOwners(r) {
+		counts[m]++
+	}
+	assert.InDelta(t, numKeys/4, counts["a"], numKeys/20)
+	assert.InDelta(t, numKeys/4, counts["b"], numKeys/20)
+	assert.InDelta(t, numKeys/2, counts["c"], numKeys/20)
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 2}, r.Members())
+}
+
+// Only keys of members added or removed move.
+func TestStableRemapping(t *testing.T) {
+	r := New(0)
+	for _, m := range []string{"a", "b", "c"} {
+		r.Set(m, 1)
+	}
+	before := keyOwners(r)
+	r.Set("d", 1)
+	after := keyOwners(r)
+	moved := 0
+	for i := range before {
+		if before[i] != after[i] {
+			assert.Equal(t, "d", after[i])
+			moved++
+		}
+	}
+	assert.InDelta(t, numKeys/4, moved, numKeys/20)
+	r.Remove("b")
+	for i, m := range keyOwners(r) {
+		if after[i] != "b" {
+			assert.Equal(t, after[i], m)
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	r1, r2 := New(10), New(10)
+	for _, m := range []string{"a", "b", "c"} {
+		r1.Set(m, 1)
+	}
+	for _, m := range []string{"c", "a", "b"} {
+		r2.Set(m, 1)
+	}
+	assert.Equal(t, keyOwners(r1), keyOwners(r2))
+}
+
+func TestGetN(t *testing.T) {
+	r := New(0)
+	r.Set("a", 1)
+	r.Set("b", 1)
+	ms := r.GetN([]byte("x"), 3)
+	assert.ElementsMatch(t, []string{"a", "b"}, ms)
+	m, _ := r.Get([]byte("x"))
+	assert.Equal(t, m, ms[0])
+}
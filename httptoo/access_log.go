@@ -0,0 +1,105 @@
+package httptoo
+
+import (
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/anacrolix/missinggo"
+)
+
+// A record of a single request and its response.
+type AccessLogRecord struct {
+	Time      time.Time
+	Method    string
+	Path      string
+	Status    int
+	Bytes     int64
+	Duration  time.Duration
+	ClientIP  net.IP
+	UserAgent string
+	// The probability this request was logged with, for scaling counts.
+	SampleRate float64
+}
+
+type AccessLogger interface {
+	LogAccess(AccessLogRecord)
+}
+
+type AccessLoggerFunc func(AccessLogRecord)
+
+func (f AccessLoggerFunc) LogAccess(r AccessLogRecord) {
+	f(r)
+}
+
+// Writes records as lines of key=value pairs to a log.Logger, or the standard
+// logger if nil.
+type LogAccessLogger struct {
+	Logger *log.Logger
+}
+
+func (me LogAccessLogger) LogAccess(r AccessLogRecord) {
+	printf := log.Printf
+	if me.Logger != nil {
+		printf = me.Logger.Printf
+	}
+	printf("method=%s path=%q status=%d bytes=%d duration=%s client=%s ua=%q sample=%g",
+		r.Method, r.Path, r.Status, r.Bytes, r.Duration, r.ClientIP, r.UserAgent, r.SampleRate)
+}
+
+type AccessLogOpts struct {
+	// Defaults to LogAccessLogger{}, which writes to the standard logger.
+	Logger AccessLogger
+	// Returns the probability that a request is logged. nil logs every
+	// request.
+	SampleRate func(*http.Request) float64
+	// Log server errors regardless of sampling.
+	AlwaysLogErrors bool
+}
+
+// Logs a record for each request handled by h. Client IPs are as determined
+// by the request context middleware, if it's in use.
+func AccessLogHandler(h http.Handler, opts AccessLogOpts) http.Handler {
+	if opts.Logger == nil {
+		opts.Logger = LogAccessLogger{}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rate := 1.0
+		if opts.SampleRate != nil {
+			rate = opts.SampleRate(r)
+		}
+		sampled := rate >= 1 || rand.Float64() < rate
+		if !sampled && !opts.AlwaysLogErrors {
+			h.ServeHTTP(w, r)
+			return
+		}
+		sw := &missinggo.StatusResponseWriter{
+			ResponseWriter: w,
+			Started:        time.Now(),
+		}
+		defer func() {
+			status := sw.Code
+			if status == 0 {
+				// Nothing was written, the server will send a 200.
+				status = http.StatusOK
+			}
+			if !sampled && status < 500 {
+				return
+			}
+			opts.Logger.LogAccess(AccessLogRecord{
+				Time:       sw.Started,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Status:     status,
+				Bytes:      sw.BytesWritten,
+				Duration:   time.Since(sw.Started),
+				ClientIP:   RequestClientIP(r),
+				UserAgent:  r.UserAgent(),
+				SampleRate: rate,
+			})
+		}()
+		h.ServeHTTP(sw, r)
+	})
+}
@@ -0,0 +1,64 @@
+package httptoo
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogHandler(t *testing.T) {
+	var records []AccessLogRecord
+	h := AccessLogHandler(http.HandlerFunc(helloWorldHandler), AccessLogOpts{
+		Logger: AccessLoggerFunc(func(r AccessLogRecord) {
+			records = append(records, r)
+		}),
+	})
+	r := httptest.NewRequest("GET", "/hello", nil)
+	r.RemoteAddr = "1.2.3.4:5"
+	r.Header.Set("User-Agent", "test")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	require.Len(t, records, 1)
+	rec := records[0]
+	assert.Equal(t, "GET", rec.Method)
+	assert.Equal(t, "/hello", rec.Path)
+	assert.Equal(t, 200, rec.Status)
+	assert.EqualValues(t, len(helloWorld), rec.Bytes)
+	assert.Equal(t, "1.2.3.4", rec.ClientIP.String())
+	assert.Equal(t, "test", rec.UserAgent)
+	assert.EqualValues(t, 1, rec.SampleRate)
+}
+
+func TestAccessLogHandlerSampling(t *testing.T) {
+	var records []AccessLogRecord
+	status := http.StatusOK
+	h := AccessLogHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}), AccessLogOpts{
+		Logger: AccessLoggerFunc(func(r AccessLogRecord) {
+			records = append(records, r)
+		}),
+		SampleRate:      func(*http.Request) float64 { return 0 },
+		AlwaysLogErrors: true,
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Len(t, records, 0)
+	status = http.StatusBadGateway
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	require.Len(t, records, 1)
+	assert.Equal(t, http.StatusBadGateway, records[0].Status)
+}
+
+func TestAccessLogHandlerDefaultLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	h := AccessLogHandler(http.HandlerFunc(helloWorldHandler), AccessLogOpts{})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello", nil))
+	assert.Contains(t, buf.String(), `path="/hello" status=200`)
+}
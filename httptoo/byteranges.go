@@ -0,0 +1,156 @@
+package httptoo
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/anacrolix/missinggo"
+)
+
+func (me BytesContentRange) String() string {
+	return fmt.Sprintf("bytes %d-%d/%d", me.First, me.Last, me.Length)
+}
+
+// Parses a Range header with any number of byte ranges, resolving suffix and
+// open ranges against the content size. Ranges that start beyond the content
+// are dropped. ok is false if the header is malformed, or nothing remains.
+func ParseBytesRanges(s string, size int64) (ret []BytesRange, ok bool) {
+	unit, ranges := parseUnitRanges(s)
+	if unit != "bytes" {
+		return
+	}
+	for _, r := range strings.Split(ranges, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		ss := strings.SplitN(r, "-", 2)
+		if len(ss) != 2 {
+			return nil, false
+		}
+		var br BytesRange
+		if ss[0] == "" {
+			// A suffix range.
+			n, err := strconv.ParseInt(ss[1], 10, 64)
+			if err != nil || n < 0 {
+				return nil, false
+			}
+			if n > size {
+				n = size
+			}
+			br = BytesRange{size - n, size - 1}
+		} else {
+			var err error
+			br.First, err = strconv.ParseInt(ss[0], 10, 64)
+			if err != nil || br.First < 0 {
+				return nil, false
+			}
+			if ss[1] == "" {
+				br.Last = size - 1
+			} else {
+				br.Last, err = strconv.ParseInt(ss[1], 10, 64)
+				if err != nil || br.Last < br.First {
+					return nil, false
+				}
+			}
+			if br.Last >= size {
+				br.Last = size - 1
+			}
+		}
+		if br.First >= size || br.Last < br.First {
+			continue
+		}
+		ret = append(ret, br)
+	}
+	ok = len(ret) != 0
+	return
+}
+
+func (me BytesRange) length() int64 {
+	return me.Last - me.First + 1
+}
+
+// Serves the ranges of content in a 206 response. A single range is sent
+// directly with a Content-Range, several are sent as multipart/byteranges.
+func ServeBytesRanges(w http.ResponseWriter, content io.ReaderAt, size int64, contentType string, ranges []BytesRange) (err error) {
+	if len(ranges) == 1 {
+		r := ranges[0]
+		w.Header().Set("Content-Range", BytesContentRange{r.First, r.Last, size}.String())
+		w.Header().Set("Content-Length", strconv.FormatInt(r.length(), 10))
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.WriteHeader(http.StatusPartialContent)
+		_, err = io.Copy(w, io.NewSectionReader(content, r.First, r.length()))
+		return
+	}
+	// Do a dry run of the part headers and boundaries to determine the
+	// Content-Length, adding the lengths of the bodies. The boundary is fixed
+	// to match the real run.
+	cw := missinggo.NewStatWriter(ioutil.Discard)
+	mw := multipart.NewWriter(cw)
+	boundary := mw.Boundary()
+	err = writeByteRangeParts(mw, nil, size, contentType, ranges)
+	if err != nil {
+		return
+	}
+	length := cw.Written
+	for _, r := range ranges {
+		length += r.length()
+	}
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.WriteHeader(http.StatusPartialContent)
+	mw = multipart.NewWriter(w)
+	mw.SetBoundary(boundary)
+	return writeByteRangeParts(mw, content, size, contentType, ranges)
+}
+
+// Writes the parts, without bodies if content is nil.
+func writeByteRangeParts(mw *multipart.Writer, content io.ReaderAt, size int64, contentType string, ranges []BytesRange) error {
+	for _, r := range ranges {
+		h := make(textproto.MIMEHeader)
+		if contentType != "" {
+			h.Set("Content-Type", contentType)
+		}
+		h.Set("Content-Range", BytesContentRange{r.First, r.Last, size}.String())
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if content == nil {
+			continue
+		}
+		_, err = io.Copy(pw, io.NewSectionReader(content, r.First, r.length()))
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// Serves content, honouring any Range header in the request.
+func ServeReaderAt(w http.ResponseWriter, r *http.Request, content io.ReaderAt, size int64, contentType string) error {
+	w.Header().Set("Accept-Ranges", "bytes")
+	if h := r.Header.Get("Range"); h != "" {
+		ranges, ok := ParseBytesRanges(h, size)
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			http.Error(w, "requested range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+			return nil
+		}
+		return ServeBytesRanges(w, content, size, contentType, ranges)
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	_, err := io.Copy(w, io.NewSectionReader(content, 0, size))
+	return err
+}
@@ -0,0 +1,84 @@
+package httptoo
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBytesRanges(t *testing.T) {
+	for _, _case := range []struct {
+		h      string
+		ranges []BytesRange
+	}{
+		{"", nil},
+		{"bytes=", nil},
+		{"items=0-1", nil},
+		{"bytes=0-0", []BytesRange{{0, 0}}},
+		{"bytes=0-99", []BytesRange{{0, 9}}},
+		{"bytes=2-,-3", []BytesRange{{2, 9}, {7, 9}}},
+		{"bytes=-20", []BytesRange{{0, 9}}},
+		{"bytes=10-20", nil},
+		{"bytes=10-20, 1-2", []BytesRange{{1, 2}}},
+		{"bytes=3-2", nil},
+		{"bytes=a-2", nil},
+	} {
+		ranges, ok := ParseBytesRanges(_case.h, 10)
+		assert.Equal(t, _case.ranges != nil, ok, _case.h)
+		assert.Equal(t, _case.ranges, ranges, _case.h)
+	}
+}
+
+func TestServeReaderAtMultipleRanges(t *testing.T) {
+	const content = "hello, world"
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Range", "bytes=0-4,7-")
+	rr := httptest.NewRecorder()
+	require.NoError(t, ServeReaderAt(rr, r, strings.NewReader(content), int64(len(content)), "text/plain"))
+	assert.Equal(t, http.StatusPartialContent, rr.Code)
+	assert.EqualValues(t, rr.Body.Len(), rr.Result().ContentLength)
+	mt, params, err := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/byteranges", mt)
+	mr := multipart.NewReader(rr.Body, params["boundary"])
+	for _, expected := range []struct {
+		contentRange, body string
+	}{
+		{"bytes 0-4/12", "hello"},
+		{"bytes 7-11/12", "world"},
+	} {
+		p, err := mr.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, "text/plain", p.Header.Get("Content-Type"))
+		assert.Equal(t, expected.contentRange, p.Header.Get("Content-Range"))
+		b, err := ioutil.ReadAll(p)
+		require.NoError(t, err)
+		assert.EqualValues(t, expected.body, b)
+	}
+	_, err = mr.NextPart()
+	assert.Error(t, err)
+}
+
+func TestServeReaderAtSingleRange(t *testing.T) {
+	const content = "hello, world"
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Range", "bytes=-5")
+	rr := httptest.NewRecorder()
+	require.NoError(t, ServeReaderAt(rr, r, strings.NewReader(content), int64(len(content)), ""))
+	assert.Equal(t, http.StatusPartialContent, rr.Code)
+	assert.Equal(t, "bytes 7-11/12", rr.Header().Get("Content-Range"))
+	assert.Equal(t, "world", rr.Body.String())
+
+	r.Header.Set("Range", "bytes=20-")
+	rr = httptest.NewRecorder()
+	require.NoError(t, ServeReaderAt(rr, r, strings.NewReader(content), int64(len(content)), ""))
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rr.Code)
+	assert.Equal(t, "bytes */12", rr.Header().Get("Content-Range"))
+}
@@ -0,0 +1,133 @@
+package httptoo
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// An entity tag, per RFC 7232.
+type ETag struct {
+	Weak   bool
+	Opaque string
+}
+
+func (me ETag) String() string {
+	if me.Weak {
+		return `W/"` + me.Opaque + `"`
+	}
+	return `"` + me.Opaque + `"`
+}
+
+// Derives an ETag from resource metadata. With a checksum of the content the
+// tag is strong, otherwise it's weak, as size and modification time don't
+// guarantee byte-for-byte equality.
+func NewETag(size int64, modTime time.Time, checksum []byte) ETag {
+	if len(checksum) != 0 {
+		return ETag{Opaque: hex.EncodeToString(checksum)}
+	}
+	return ETag{
+		Weak:   true,
+		Opaque: fmt.Sprintf("%x-%x", size, modTime.UnixNano()),
+	}
+}
+
+// Derives an ETag from a resource Stat, or a filecache Cache.Stat.
+func FileInfoETag(fi os.FileInfo, checksum []byte) ETag {
+	return NewETag(fi.Size(), fi.ModTime(), checksum)
+}
+
+// Strong comparison requires both tags be strong.
+func (me ETag) strongMatch(other ETag) bool {
+	return !me.Weak && !other.Weak && me.Opaque == other.Opaque
+}
+
+func (me ETag) weakMatch(other ETag) bool {
+	return me.Opaque == other.Opaque
+}
+
+// Parses a list of entity tags, as in If-Match and If-None-Match. any is true
+// for "*".
+func ParseETags(s string) (ret []ETag, any bool) {
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" {
+			any = true
+			continue
+		}
+		var e ETag
+		if strings.HasPrefix(t, "W/") {
+			e.Weak = true
+			t = t[2:]
+		}
+		if len(t) < 2 || t[0] != '"' || t[len(t)-1] != '"' {
+			continue
+		}
+		e.Opaque = t[1 : len(t)-1]
+		ret = append(ret, e)
+	}
+	return
+}
+
+func etagsMatch(header string, etag ETag, match func(l, r ETag) bool) bool {
+	tags, any := ParseETags(header)
+	if any {
+		return true
+	}
+	for _, t := range tags {
+		if match(t, etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sets the ETag and Last-Modified headers, and evaluates the conditional
+// request headers against them. If the conditions mean no content should be
+// sent, the 304 or 412 response is written and true is returned. A zero
+// modTime is ignored.
+func CheckConditionalRequest(w http.ResponseWriter, r *http.Request, etag ETag, modTime time.Time) (done bool) {
+	w.Header().Set("ETag", etag.String())
+	modTime = modTime.Truncate(time.Second)
+	if !modTime.IsZero() {
+		w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	get := r.Method == "GET" || r.Method == "HEAD"
+	if h := r.Header.Get("If-Match"); h != "" {
+		if !etagsMatch(h, etag, ETag.strongMatch) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return true
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !modTime.IsZero() {
+		if modTime.After(t) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return true
+		}
+	}
+	if h := r.Header.Get("If-None-Match"); h != "" {
+		if etagsMatch(h, etag, ETag.weakMatch) {
+			if get {
+				writeNotModified(w)
+			} else {
+				w.WriteHeader(http.StatusPreconditionFailed)
+			}
+			return true
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && get && !modTime.IsZero() {
+		if !modTime.After(t) {
+			writeNotModified(w)
+			return true
+		}
+	}
+	return false
+}
+
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
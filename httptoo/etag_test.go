@@ -0,0 +1,63 @@
+package httptoo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseETags(t *testing.T) {
+	tags, any := ParseETags(`"a", W/"b", bad, *`)
+	assert.True(t, any)
+	assert.Equal(t, []ETag{{false, "a"}, {true, "b"}}, tags)
+}
+
+func TestNewETag(t *testing.T) {
+	mt := time.Unix(1, 0)
+	assert.True(t, NewETag(1, mt, nil).Weak)
+	assert.Equal(t, NewETag(1, mt, nil), NewETag(1, mt, nil))
+	assert.NotEqual(t, NewETag(1, mt, nil), NewETag(2, mt, nil))
+	assert.Equal(t, `"abcd"`, NewETag(1, mt, []byte{0xab, 0xcd}).String())
+}
+
+func TestCheckConditionalRequest(t *testing.T) {
+	modTime := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	strong := ETag{Opaque: "x"}
+	weak := ETag{Weak: true, Opaque: "x"}
+	for _, _case := range []struct {
+		method  string
+		headers map[string]string
+		etag    ETag
+		status  int
+	}{
+		{"GET", nil, strong, 0},
+		{"GET", map[string]string{"If-None-Match": `"x"`}, strong, http.StatusNotModified},
+		{"GET", map[string]string{"If-None-Match": `W/"x"`}, weak, http.StatusNotModified},
+		{"GET", map[string]string{"If-None-Match": `"y"`}, strong, 0},
+		{"PUT", map[string]string{"If-None-Match": `*`}, strong, http.StatusPreconditionFailed},
+		{"PUT", map[string]string{"If-Match": `"x"`}, strong, 0},
+		{"PUT", map[string]string{"If-Match": `W/"x"`}, weak, http.StatusPreconditionFailed},
+		{"GET", map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, strong, http.StatusNotModified},
+		{"GET", map[string]string{"If-Modified-Since": modTime.Add(-time.Second).Format(http.TimeFormat)}, strong, 0},
+		{"GET", map[string]string{
+			"If-Modified-Since": modTime.Format(http.TimeFormat),
+			"If-None-Match":     `"y"`,
+		}, strong, 0},
+		{"PUT", map[string]string{"If-Unmodified-Since": modTime.Add(-time.Second).Format(http.TimeFormat)}, strong, http.StatusPreconditionFailed},
+	} {
+		r := httptest.NewRequest(_case.method, "/", nil)
+		for k, v := range _case.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		done := CheckConditionalRequest(w, r, _case.etag, modTime)
+		assert.Equal(t, _case.status != 0, done, "%v", _case)
+		if done {
+			assert.Equal(t, _case.status, w.Code, "%v", _case)
+		}
+		assert.Equal(t, _case.etag.String(), w.Header().Get("ETag"))
+	}
+}
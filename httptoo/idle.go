@@ -0,0 +1,141 @@
+package httptoo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrResponseIdle = errors.New("response idle timeout")
+
+// Returns a chan that's closed when the client goes away or the handler
+// should otherwise stop responding. The Request Context covers this on modern
+// servers, the ResponseWriter's CloseNotify is used too where it's
+// available.
+func ClientGone(w http.ResponseWriter, r *http.Request) <-chan struct{} {
+	cn, ok := w.(http.CloseNotifier)
+	if !ok {
+		return r.Context().Done()
+	}
+	if hr, ok := w.(interface{ hijacked() bool }); ok && hr.hijacked() {
+		// CloseNotify is not permitted after Hijack.
+		return r.Context().Done()
+	}
+	ret := make(chan struct{})
+	go func() {
+		defer close(ret)
+		select {
+		case <-cn.CloseNotify():
+		case <-r.Context().Done():
+		}
+	}()
+	return ret
+}
+
+// Aborts responses that don't write anything for the idle duration. The
+// Request Context is cancelled, and further writes return ErrResponseIdle.
+// Hijacked connections are left alone once they're taken over.
+func IdleTimeoutHandler(h http.Handler, idle time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		itw := &idleTimeoutWriter{
+			ResponseWriter: w,
+			idle:           idle,
+		}
+		itw.mu.Lock()
+		itw.timer = time.AfterFunc(idle, func() {
+			itw.mu.Lock()
+			defer itw.mu.Unlock()
+			if itw._hijacked {
+				return
+			}
+			itw.timedOut = true
+			cancel()
+		})
+		itw.mu.Unlock()
+		defer itw.timer.Stop()
+		h.ServeHTTP(itw, r.WithContext(ctx))
+	})
+}
+
+type idleTimeoutWriter struct {
+	http.ResponseWriter
+	idle      time.Duration
+	mu        sync.Mutex
+	timer     *time.Timer
+	timedOut  bool
+	_hijacked bool
+}
+
+var _ interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.CloseNotifier
+} = (*idleTimeoutWriter)(nil)
+
+func (me *idleTimeoutWriter) active() {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if !me.timedOut && !me._hijacked {
+		me.timer.Reset(me.idle)
+	}
+}
+
+func (me *idleTimeoutWriter) Write(b []byte) (n int, err error) {
+	me.mu.Lock()
+	timedOut := me.timedOut
+	me.mu.Unlock()
+	if timedOut {
+		err = ErrResponseIdle
+		return
+	}
+	n, err = me.ResponseWriter.Write(b)
+	if n > 0 {
+		me.active()
+	}
+	return
+}
+
+func (me *idleTimeoutWriter) Flush() {
+	if f, ok := me.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (me *idleTimeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := me.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.timedOut {
+		return nil, nil, ErrResponseIdle
+	}
+	c, rw, err := h.Hijack()
+	if err == nil {
+		me._hijacked = true
+		me.timer.Stop()
+	}
+	return c, rw, err
+}
+
+func (me *idleTimeoutWriter) hijacked() bool {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me._hijacked
+}
+
+// Use ClientGone instead.
+func (me *idleTimeoutWriter) CloseNotify() <-chan bool {
+	if cn, ok := me.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return make(chan bool)
+}
@@ -0,0 +1,43 @@
+package httptoo
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdleTimeoutHandler(t *testing.T) {
+	const idle = 20 * time.Millisecond
+	handlerErr := make(chan error, 1)
+	s := httptest.NewServer(IdleTimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Keep the response alive for a while.
+		for range make([]struct{}, 3) {
+			time.Sleep(idle / 2)
+			w.Write([]byte("a"))
+		}
+		select {
+		case <-ClientGone(w, r):
+		case <-time.After(time.Second):
+			t.Error("response didn't time out")
+		}
+		_, err := w.Write([]byte("b"))
+		handlerErr <- err
+	}), idle))
+	defer s.Close()
+	resp, err := http.Get(s.URL)
+	require.NoError(t, err)
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.EqualValues(t, "aaa", b)
+	assert.Equal(t, ErrResponseIdle, <-handlerErr)
+}
+
+func TestClientGoneRequestContext(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	assert.Equal(t, r.Context().Done(), ClientGone(httptest.NewRecorder(), r))
+}
@@ -0,0 +1,192 @@
+package httptoo
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The first file descriptor passed by systemd socket activation.
+const systemdListenFdsStart = 3
+
+type ListenOpts struct {
+	// Used for specs with the "tls://" prefix. Required if there are any.
+	TLSConfig *tls.Config
+}
+
+// Listens on each of the address specs, returning the listeners in the same
+// order, and a func that closes them all. If any spec fails, listeners
+// already opened are closed. Supported specs are:
+//
+//	"host:port", ":8080"            TCP
+//	"tcp://", "tcp4://", "tcp6://"  TCP with an explicit network
+//	"unix:/path"                    Unix domain socket
+//	"fd:3"                          An inherited listening file descriptor
+//	"systemd:", "systemd:name"      Sockets passed by systemd activation
+//	"tls://spec"                    Any of the above, wrapped in TLS
+//
+// A "systemd:" spec can produce any number of listeners.
+func Listen(specs []string, opts ListenOpts) (ls []net.Listener, close func() error, err error) {
+	close = func() (err error) {
+		for _, l := range ls {
+			if closeErr := l.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return
+	}
+	for _, s := range specs {
+		var sls []net.Listener
+		sls, err = listenSpec(s, opts)
+		if err != nil {
+			close()
+			ls = nil
+			err = fmt.Errorf("listening on %q: %s", s, err)
+			return
+		}
+		ls = append(ls, sls...)
+	}
+	return
+}
+
+func listenSpec(spec string, opts ListenOpts) ([]net.Listener, error) {
+	if inner := strings.TrimPrefix(spec, "tls://"); inner != spec {
+		if opts.TLSConfig == nil {
+			return nil, errors.New("no TLS config")
+		}
+		ls, err := listenSpec(inner, opts)
+		for i := range ls {
+			ls[i] = tls.NewListener(ls[i], opts.TLSConfig)
+		}
+		return ls, err
+	}
+	scheme, rest := splitListenSpec(spec)
+	switch scheme {
+	case "":
+		return listenOne("tcp", spec)
+	case "tcp", "tcp4", "tcp6":
+		return listenOne(scheme, strings.TrimPrefix(rest, "//"))
+	case "unix":
+		return listenOne("unix", strings.TrimPrefix(rest, "//"))
+	case "fd":
+		fd, err := strconv.ParseUint(rest, 10, 0)
+		if err != nil {
+			return nil, err
+		}
+		l, err := FileDescriptorListener(uintptr(fd), spec)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	case "systemd":
+		return SystemdListeners(rest)
+	default:
+		return nil, fmt.Errorf("unknown scheme %q", scheme)
+	}
+}
+
+// Splits a spec into its scheme and the remainder. Host and port specs,
+// including bracketed IPv6 addresses, have no scheme.
+func splitListenSpec(spec string) (scheme, rest string) {
+	i := strings.Index(spec, ":")
+	if i <= 0 || strings.ContainsAny(spec[:i], "[]") {
+		return
+	}
+	switch spec[:i] {
+	case "tcp", "tcp4", "tcp6", "unix", "fd", "systemd":
+		return spec[:i], spec[i+1:]
+	}
+	if strings.HasPrefix(spec[i+1:], "//") {
+		return spec[:i], spec[i+1:]
+	}
+	return
+}
+
+func listenOne(network, addr string) ([]net.Listener, error) {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return []net.Listener{l}, nil
+}
+
+// Creates a listener from an inherited file descriptor. The descriptor is
+// duplicated, so the original is closed here.
+func FileDescriptorListener(fd uintptr, name string) (net.Listener, error) {
+	f := os.NewFile(fd, name)
+	if f == nil {
+		return nil, fmt.Errorf("bad file descriptor %d", fd)
+	}
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// Returns the listeners passed by systemd socket activation. If name is not
+// empty, only sockets with that name in LISTEN_FDNAMES are returned.
+func SystemdListeners(name string) (ls []net.Listener, err error) {
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		err = errors.New("LISTEN_PID is for another process")
+		return
+	}
+	n, err := strconv.ParseUint(os.Getenv("LISTEN_FDS"), 10, 0)
+	if err != nil {
+		err = fmt.Errorf("parsing LISTEN_FDS: %s", err)
+		return
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < int(n); i++ {
+		var fdName string
+		if i < len(names) {
+			fdName = names[i]
+		}
+		if name != "" && fdName != name {
+			continue
+		}
+		var l net.Listener
+		l, err = FileDescriptorListener(uintptr(systemdListenFdsStart+i), "systemd:"+fdName)
+		if err != nil {
+			for _, l := range ls {
+				l.Close()
+			}
+			ls = nil
+			return
+		}
+		ls = append(ls, l)
+	}
+	if len(ls) == 0 {
+		err = errors.New("no matching sockets passed")
+	}
+	return
+}
+
+// Returns duplicated files for the listeners, for passing to a child process
+// through exec.Cmd.ExtraFiles, such as when restarting without downtime. The
+// child finds them at "fd:3" onwards in the same order.
+func ListenerFiles(ls []net.Listener) (fs []*os.File, err error) {
+	for _, l := range ls {
+		fl, ok := l.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			err = fmt.Errorf("can't get file for %T", l)
+			break
+		}
+		var f *os.File
+		f, err = fl.File()
+		if err != nil {
+			break
+		}
+		fs = append(fs, f)
+	}
+	if err != nil {
+		for _, f := range fs {
+			f.Close()
+		}
+		fs = nil
+	}
+	return
+}
@@ -0,0 +1,57 @@
+package httptoo
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo"
+)
+
+func TestSplitListenSpec(t *testing.T) {
+	for _, _case := range []struct {
+		spec, scheme, rest string
+	}{
+		{":8080", "", ""},
+		{"localhost:8080", "", ""},
+		{"[::1]:8080", "", ""},
+		{"unix:/tmp/sock", "unix", "/tmp/sock"},
+		{"tcp6://[::1]:0", "tcp6", "//[::1]:0"},
+		{"fd:3", "fd", "3"},
+		{"systemd:", "systemd", ""},
+		{"http://localhost", "http", "//localhost"},
+	} {
+		scheme, rest := splitListenSpec(_case.spec)
+		assert.Equal(t, _case.scheme, scheme, _case.spec)
+		assert.Equal(t, _case.rest, rest, _case.spec)
+	}
+}
+
+func TestListen(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+	cert, err := missinggo.NewSelfSignedCertificate()
+	require.NoError(t, err)
+	ls, close, err := Listen([]string{
+		"localhost:0",
+		"unix:" + filepath.Join(td, "sock"),
+		"tls://tcp://localhost:0",
+	}, ListenOpts{
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	})
+	require.NoError(t, err)
+	require.Len(t, ls, 3)
+	assert.Equal(t, "unix", ls[1].Addr().Network())
+	assert.NoError(t, close())
+
+	_, _, err = Listen([]string{"localhost:0", "tls://localhost:0"}, ListenOpts{})
+	assert.Error(t, err)
+	_, _, err = Listen([]string{"wat://localhost:0"}, ListenOpts{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,31 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package httptoo
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenFileDescriptor(t *testing.T) {
+	inherited, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer inherited.Close()
+	fs, err := ListenerFiles([]net.Listener{inherited})
+	require.NoError(t, err)
+	// Listen takes ownership of the descriptor, so give it its own.
+	fd, err := syscall.Dup(int(fs[0].Fd()))
+	require.NoError(t, err)
+	fs[0].Close()
+	ls, close, err := Listen([]string{fmt.Sprintf("fd:%d", fd)}, ListenOpts{})
+	require.NoError(t, err)
+	defer close()
+	require.Len(t, ls, 1)
+	assert.Equal(t, inherited.Addr().String(), ls[0].Addr().String())
+}
@@ -0,0 +1,137 @@
+package httptoo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/missinggo"
+)
+
+type requestContextKey int
+
+const (
+	clientIPContextKey requestContextKey = iota
+)
+
+// Configures the request context middleware. The zero value applies no
+// deadline, and takes the client IP from the connection's remote address.
+type RequestContextOpts struct {
+	// Timeout applied to requests that don't ask for one. Zero means no
+	// deadline.
+	DefaultTimeout time.Duration
+	// Upper bound on any timeout, including those requested through
+	// TimeoutHeader. Zero means no bound.
+	MaxTimeout time.Duration
+	// A header through which clients may request a timeout, such as
+	// "Request-Timeout". Values are Go durations, or integer seconds.
+	TimeoutHeader string
+	// Headers that carry the originating client IP when set by a trusted
+	// proxy, in order of preference. "X-Forwarded-For" is treated as a
+	// comma-separated chain of hops.
+	ClientIPHeaders []string
+	// Peers whose ClientIPHeaders are believed. Headers from other peers
+	// are ignored.
+	TrustedProxies []*net.IPNet
+}
+
+// Returns a middleware that derives a deadline for each request's Context,
+// and stores the client IP in it for retrieval with RequestClientIP.
+func (me RequestContextOpts) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPContextKey, me.clientIP(r))
+		if timeout := me.timeout(r); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (me RequestContextOpts) timeout(r *http.Request) (ret time.Duration) {
+	ret = me.DefaultTimeout
+	if me.TimeoutHeader != "" {
+		if d, ok := parseTimeoutHeader(r.Header.Get(me.TimeoutHeader)); ok {
+			ret = d
+		}
+	}
+	if me.MaxTimeout > 0 && (ret <= 0 || ret > me.MaxTimeout) {
+		ret = me.MaxTimeout
+	}
+	return
+}
+
+func parseTimeoutHeader(s string) (d time.Duration, ok bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(secs) * time.Second, secs > 0
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil && d > 0
+}
+
+func (me RequestContextOpts) trusted(ip net.IP) bool {
+	for _, n := range me.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (me RequestContextOpts) clientIP(r *http.Request) net.IP {
+	ip := net.ParseIP(missinggo.SplitHostMaybePort(r.RemoteAddr).Host)
+	if ip == nil || !me.trusted(ip) {
+		return ip
+	}
+	for _, h := range me.ClientIPHeaders {
+		if fromHeader := me.headerClientIP(r.Header[http.CanonicalHeaderKey(h)]); fromHeader != nil {
+			return fromHeader
+		}
+	}
+	return ip
+}
+
+// Walks the hops in the header values from the nearest, returning the first
+// that isn't a trusted proxy. Returns nil if a hop can't be parsed before
+// then, as nothing beyond it can be believed.
+func (me RequestContextOpts) headerClientIP(values []string) (ret net.IP) {
+	var hops []string
+	for _, v := range values {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(missinggo.SplitHostMaybePort(strings.TrimSpace(hops[i])).Host)
+		if ip == nil {
+			return nil
+		}
+		ret = ip
+		if !me.trusted(ip) {
+			return
+		}
+	}
+	return
+}
+
+// Returns the client IP stored in the Context by the request context
+// middleware.
+func ContextClientIP(ctx context.Context) (ip net.IP, ok bool) {
+	ip, ok = ctx.Value(clientIPContextKey).(net.IP)
+	return
+}
+
+// Returns the client IP determined by the request context middleware,
+// falling back on the request's remote address.
+func RequestClientIP(r *http.Request) net.IP {
+	if ip, ok := ContextClientIP(r.Context()); ok {
+		return ip
+	}
+	return net.ParseIP(missinggo.SplitHostMaybePort(r.RemoteAddr).Host)
+}
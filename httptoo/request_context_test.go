@@ -0,0 +1,82 @@
+package httptoo
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func TestRequestContextClientIP(t *testing.T) {
+	opts := RequestContextOpts{
+		ClientIPHeaders: []string{"X-Real-IP", "X-Forwarded-For"},
+		TrustedProxies:  []*net.IPNet{mustParseCIDR("10.0.0.0/8")},
+	}
+	for _, _case := range []struct {
+		remote  string
+		headers map[string]string
+		client  string
+	}{
+		{"1.2.3.4:80", nil, "1.2.3.4"},
+		{"1.2.3.4:80", map[string]string{"X-Real-IP": "5.6.7.8"}, "1.2.3.4"},
+		{"10.0.0.1:80", map[string]string{"X-Real-IP": "5.6.7.8"}, "5.6.7.8"},
+		{"10.0.0.1:80", map[string]string{"X-Forwarded-For": "6.6.6.6, 5.6.7.8, 10.1.1.1"}, "5.6.7.8"},
+		{"10.0.0.1:80", map[string]string{"X-Forwarded-For": "10.2.2.2, 10.1.1.1"}, "10.2.2.2"},
+		{"10.0.0.1:80", map[string]string{"X-Forwarded-For": "garbage"}, "10.0.0.1"},
+		// A hop that can't be parsed ends what's believed of the chain.
+		{"10.0.0.1:80", map[string]string{"X-Forwarded-For": "5.6.7.8, garbage, 10.1.1.1"}, "10.0.0.1"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = _case.remote
+		for k, v := range _case.headers {
+			r.Header.Set(k, v)
+		}
+		var got net.IP
+		opts.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = RequestClientIP(r)
+		})).ServeHTTP(httptest.NewRecorder(), r)
+		assert.EqualValues(t, _case.client, got.String(), "%v", _case)
+	}
+}
+
+func TestRequestContextTimeout(t *testing.T) {
+	opts := RequestContextOpts{
+		DefaultTimeout: time.Minute,
+		MaxTimeout:     time.Hour,
+		TimeoutHeader:  "Request-Timeout",
+	}
+	for _, _case := range []struct {
+		header  string
+		timeout time.Duration
+	}{
+		{"", time.Minute},
+		{"30", 30 * time.Second},
+		{"2s", 2 * time.Second},
+		{"-1", time.Minute},
+		{"3h", time.Hour},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Request-Timeout", _case.header)
+		assert.Equal(t, _case.timeout, opts.timeout(r), _case.header)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	RequestContextOpts{}.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		assert.False(t, ok)
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	opts.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		assert.True(t, ok)
+	})).ServeHTTP(httptest.NewRecorder(), r)
+}
@@ -0,0 +1,359 @@
+package inproc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Applied to each direction of a conn independently.
+type Shaping struct {
+	// Delay before written data can be read.
+	Latency time.Duration
+	// Bytes per second. Zero is unlimited.
+	Bandwidth int64
+}
+
+const (
+	// Writes block once this much is unread, like a socket buffer.
+	pipeBufferSize = 256 << 10
+	// Bandwidth is metered in chunks no larger than this.
+	maxChunkSize = 16 << 10
+	// Conns that can be dialled before they're accepted.
+	acceptBacklog = 128
+)
+
+var (
+	listeners = map[int]*listener{}
+
+	errConnRefused = errors.New("connection refused")
+)
+
+type chunk struct {
+	b       []byte
+	readyAt time.Time
+}
+
+// One direction of a conn.
+type halfPipe struct {
+	mu sync.Mutex
+	// Closed and replaced when anything changes, to wake waiters.
+	changed  chan struct{}
+	chunks   []chunk
+	buffered int
+	shaping  Shaping
+	// When the data written so far has been transmitted.
+	linkFree time.Time
+	// Set by the writer's Close or CloseWrite, after which the reader gets
+	// EOF once the buffer is drained.
+	writeClosed bool
+	// Set by the reader's Close, after which writes fail.
+	readClosed    bool
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+func newHalfPipe(s Shaping) *halfPipe {
+	return &halfPipe{
+		changed: make(chan struct{}),
+		shaping: s,
+	}
+}
+
+// Must be called with the lock held.
+func (me *halfPipe) broadcast() {
+	close(me.changed)
+	me.changed = make(chan struct{})
+}
+
+// Waits for a change, or until wake if it's not zero. Must be called with the
+// lock held, which is released while waiting.
+func (me *halfPipe) wait(wake time.Time) {
+	changed := me.changed
+	me.mu.Unlock()
+	defer me.mu.Lock()
+	if wake.IsZero() {
+		<-changed
+		return
+	}
+	t := time.NewTimer(time.Until(wake))
+	defer t.Stop()
+	select {
+	case <-changed:
+	case <-t.C:
+	}
+}
+
+// Returns the earlier non-zero time.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+func deadlineExceeded(deadline, now time.Time) bool {
+	return !deadline.IsZero() && !now.Before(deadline)
+}
+
+func (me *halfPipe) read(b []byte) (n int, err error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	for {
+		if me.readClosed {
+			return 0, net.ErrClosed
+		}
+		now := time.Now()
+		var readyAt time.Time
+		if len(me.chunks) != 0 {
+			c := &me.chunks[0]
+			if !now.Before(c.readyAt) {
+				n = copy(b, c.b)
+				c.b = c.b[n:]
+				if len(c.b) == 0 {
+					me.chunks = me.chunks[1:]
+				}
+				me.buffered -= n
+				me.broadcast()
+				return
+			}
+			readyAt = c.readyAt
+		} else if me.writeClosed {
+			return 0, io.EOF
+		}
+		if deadlineExceeded(me.readDeadline, now) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		me.wait(earliest(readyAt, me.readDeadline))
+	}
+}
+
+func (me *halfPipe) write(b []byte) (n int, err error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	for {
+		if me.writeClosed {
+			return n, net.ErrClosed
+		}
+		if me.readClosed {
+			return n, io.ErrClosedPipe
+		}
+		if len(b) == 0 {
+			return
+		}
+		now := time.Now()
+		if deadlineExceeded(me.writeDeadline, now) {
+			return n, os.ErrDeadlineExceeded
+		}
+		space := pipeBufferSize - me.buffered
+		if space <= 0 {
+			me.wait(me.writeDeadline)
+			continue
+		}
+		m := len(b)
+		if m > space {
+			m = space
+		}
+		if m > maxChunkSize {
+			m = maxChunkSize
+		}
+		me.push(append([]byte(nil), b[:m]...), now)
+		b = b[m:]
+		n += m
+	}
+}
+
+// Queues data, delayed by the shaping. Must be called with the lock held.
+func (me *halfPipe) push(b []byte, now time.Time) {
+	start := me.linkFree
+	if start.Before(now) {
+		start = now
+	}
+	me.linkFree = start
+	if bw := me.shaping.Bandwidth; bw > 0 {
+		me.linkFree = start.Add(time.Duration(int64(len(b)) * int64(time.Second) / bw))
+	}
+	me.chunks = append(me.chunks, chunk{b, me.linkFree.Add(me.shaping.Latency)})
+	me.buffered += len(b)
+	me.broadcast()
+}
+
+func (me *halfPipe) update(f func()) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	f()
+	me.broadcast()
+}
+
+// A full-duplex net.Conn. Closing one end makes pending and future reads
+// on it return net.ErrClosed, and those on the other end return io.EOF once
+// buffered data is drained. Writes to the other end then fail with
+// io.ErrClosedPipe.
+type Conn struct {
+	local, remote Addr
+	// Read from, and written to.
+	r, w *halfPipe
+}
+
+var _ net.Conn = (*Conn)(nil)
+
+// Returns connected Conns, each with a new address, and data in both
+// directions subject to s.
+func Pipe(s Shaping) (*Conn, *Conn) {
+	return pipe(Addr{getPort()}, Addr{getPort()}, s)
+}
+
+func pipe(a, b Addr, s Shaping) (*Conn, *Conn) {
+	ab := newHalfPipe(s)
+	ba := newHalfPipe(s)
+	return &Conn{a, b, ba, ab}, &Conn{b, a, ab, ba}
+}
+
+func (me *Conn) Read(b []byte) (int, error) {
+	return me.r.read(b)
+}
+
+func (me *Conn) Write(b []byte) (int, error) {
+	return me.w.write(b)
+}
+
+// Signals EOF to the other end, while still allowing reads.
+func (me *Conn) CloseWrite() error {
+	me.w.update(func() { me.w.writeClosed = true })
+	return nil
+}
+
+func (me *Conn) Close() error {
+	me.CloseWrite()
+	me.r.update(func() { me.r.readClosed = true })
+	return nil
+}
+
+func (me *Conn) LocalAddr() net.Addr {
+	return me.local
+}
+
+func (me *Conn) RemoteAddr() net.Addr {
+	return me.remote
+}
+
+func (me *Conn) SetDeadline(t time.Time) error {
+	me.SetReadDeadline(t)
+	return me.SetWriteDeadline(t)
+}
+
+func (me *Conn) SetReadDeadline(t time.Time) error {
+	me.r.update(func() { me.r.readDeadline = t })
+	return nil
+}
+
+func (me *Conn) SetWriteDeadline(t time.Time) error {
+	me.w.update(func() { me.w.writeDeadline = t })
+	return nil
+}
+
+type listener struct {
+	addr      Addr
+	shaping   Shaping
+	conns     chan *Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func Listen(network, addr string) (net.Listener, error) {
+	return ListenShaped(network, addr, Shaping{})
+}
+
+// Listens for Dials. Accepted conns are subject to s.
+func ListenShaped(network, addrStr string, s Shaping) (_ net.Listener, err error) {
+	addr, err := ResolveInprocAddr(network, addrStr)
+	if err != nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := listeners[addr.Port]; ok {
+		err = errors.New("address in use")
+		return
+	}
+	l := &listener{
+		addr:    addr,
+		shaping: s,
+		conns:   make(chan *Conn, acceptBacklog),
+		closed:  make(chan struct{}),
+	}
+	listeners[addr.Port] = l
+	return l, nil
+}
+
+func (me *listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-me.conns:
+		return c, nil
+	case <-me.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Conns dialled but not yet accepted are closed.
+func (me *listener) Close() error {
+	me.closeOnce.Do(func() {
+		mu.Lock()
+		delete(listeners, me.addr.Port)
+		close(me.closed)
+		mu.Unlock()
+		for {
+			select {
+			case c := <-me.conns:
+				c.Close()
+			default:
+				return
+			}
+		}
+	})
+	return nil
+}
+
+func (me *listener) Addr() net.Addr {
+	return me.addr
+}
+
+func Dial(network, addr string) (net.Conn, error) {
+	return DialContext(context.Background(), network, addr)
+}
+
+// Connects to a Listener. It waits if the listener's backlog is full.
+func DialContext(ctx context.Context, network, addrStr string) (_ net.Conn, err error) {
+	addr, err := ResolveInprocAddr(network, addrStr)
+	if err != nil {
+		return
+	}
+	mu.Lock()
+	l, ok := listeners[addr.Port]
+	mu.Unlock()
+	if !ok {
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: addr, Err: errConnRefused}
+	}
+	client, server := pipe(Addr{getPort()}, addr, l.shaping)
+	select {
+	case l.conns <- server:
+		// The listener may have closed after draining its backlog.
+		select {
+		case <-l.closed:
+			client.Close()
+		default:
+			return client, nil
+		}
+	case <-l.closed:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = &net.OpError{Op: "dial", Net: network, Addr: addr, Err: errConnRefused}
+	}
+	return
+}
@@ -0,0 +1,111 @@
+package inproc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeClose(t *testing.T) {
+	a, b := Pipe(Shaping{})
+	assert.Equal(t, a.LocalAddr(), b.RemoteAddr())
+	_, err := a.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, a.Close())
+	_, err = a.Read(make([]byte, 1))
+	assert.Equal(t, net.ErrClosed, err)
+	_, err = a.Write([]byte("x"))
+	assert.Equal(t, net.ErrClosed, err)
+	// Buffered data is still delivered.
+	all, err := io.ReadAll(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(all))
+	_, err = b.Write([]byte("x"))
+	assert.Equal(t, io.ErrClosedPipe, err)
+}
+
+func TestPipeCloseWrite(t *testing.T) {
+	a, b := Pipe(Shaping{})
+	defer a.Close()
+	defer b.Close()
+	require.NoError(t, a.CloseWrite())
+	_, err := b.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	_, err = b.Write([]byte("ok"))
+	require.NoError(t, err)
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(a, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(buf))
+}
+
+func TestPipeDeadline(t *testing.T) {
+	a, b := Pipe(Shaping{})
+	defer a.Close()
+	defer b.Close()
+	require.NoError(t, a.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err := a.Read(make([]byte, 1))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+	var ne net.Error
+	require.True(t, errors.As(err, &ne))
+	assert.True(t, ne.Timeout())
+
+	// Fill the buffer, so a write blocks until its deadline.
+	b.SetWriteDeadline(time.Now().Add(10 * time.Millisecond))
+	n, err := b.Write(make([]byte, pipeBufferSize+1))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+	assert.Equal(t, pipeBufferSize, n)
+}
+
+func TestPipeShaping(t *testing.T) {
+	a, b := Pipe(Shaping{Latency: 20 * time.Millisecond, Bandwidth: 1 << 20})
+	defer a.Close()
+	defer b.Close()
+	data := bytes.Repeat([]byte("x"), 50<<10)
+	started := time.Now()
+	go a.Write(data)
+	buf := make([]byte, len(data))
+	_, err := io.ReadFull(b, buf)
+	require.NoError(t, err)
+	// 50KiB at 1MiB/s is about 49ms, plus the latency.
+	assert.True(t, time.Since(started) >= 65*time.Millisecond, time.Since(started))
+	assert.Equal(t, data, buf)
+}
+
+func TestListenDial(t *testing.T) {
+	l, err := Listen("inproc", "")
+	require.NoError(t, err)
+	_, err = Listen("inproc", l.Addr().String())
+	assert.Error(t, err)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		io.Copy(c, c)
+		c.Close()
+	}()
+	c, err := Dial("inproc", l.Addr().String())
+	require.NoError(t, err)
+	assert.Equal(t, l.Addr(), c.RemoteAddr())
+	_, err = c.Write([]byte("echo"))
+	require.NoError(t, err)
+	c.(*Conn).CloseWrite()
+	all, err := io.ReadAll(c)
+	require.NoError(t, err)
+	assert.Equal(t, "echo", string(all))
+	c.Close()
+
+	require.NoError(t, l.Close())
+	_, err = l.Accept()
+	assert.Equal(t, net.ErrClosed, err)
+	_, err = Dial("inproc", l.Addr().String())
+	assert.Error(t, err)
+}
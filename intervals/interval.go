@@ -0,0 +1,41 @@
+// Package intervals provides containers of half-open intervals: Tree, which
+// holds overlapping intervals with values, and Set, which holds the union of
+// the intervals added to it.
+package intervals
+
+import "fmt"
+
+type Ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 | ~string
+}
+
+// The half-open interval [Start, End).
+type Interval[K Ordered] struct {
+	Start, End K
+}
+
+func (me Interval[K]) String() string {
+	return fmt.Sprintf("[%v, %v)", me.Start, me.End)
+}
+
+func (me Interval[K]) Empty() bool {
+	return me.End <= me.Start
+}
+
+func (me Interval[K]) Contains(k K) bool {
+	return me.Start <= k && k < me.End
+}
+
+// Empty intervals overlap nothing.
+func (me Interval[K]) Overlaps(other Interval[K]) bool {
+	return me.Start < other.End && other.Start < me.End && !me.Empty() && !other.Empty()
+}
+
+func (me Interval[K]) less(other Interval[K]) bool {
+	if me.Start != other.Start {
+		return me.Start < other.Start
+	}
+	return me.End < other.End
+}
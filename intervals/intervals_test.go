@@ -0,0 +1,105 @@
+package intervals
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type iv = Interval[int]
+
+// Checks the Tree's queries against a brute force search.
+func TestTree(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var tree Tree[int, int]
+	var all []Entry[int, int]
+	for i := 0; i < 1000; i++ {
+		s := r.Intn(1000)
+		e := Entry[int, int]{iv{s, s + 1 + r.Intn(50)}, i}
+		tree.Insert(e.Interval, e.Value)
+		all = append(all, e)
+	}
+	// Remove some, including one of several with the same interval.
+	tree.Insert(all[0].Interval, -1)
+	assert.True(t, tree.Delete(all[0].Interval, func(v int) bool { return v == -1 }))
+	for i := 0; i < 100; i++ {
+		assert.True(t, tree.Delete(all[i].Interval, func(v int) bool { return v == i }))
+	}
+	all = all[100:]
+	assert.False(t, tree.Delete(iv{-2, -1}, nil))
+	assert.Equal(t, len(all), tree.Len())
+	values := func(es []Entry[int, int]) (ret []int) {
+		for _, e := range es {
+			ret = append(ret, e.Value)
+		}
+		sort.Ints(ret)
+		return
+	}
+	for i := 0; i < 200; i++ {
+		q := iv{r.Intn(1100), 0}
+		q.End = q.Start + r.Intn(20)
+		var want, got []Entry[int, int]
+		for _, e := range all {
+			if e.Overlaps(q) {
+				want = append(want, e)
+			}
+		}
+		last := -1
+		tree.Overlapping(q, func(e Entry[int, int]) bool {
+			assert.True(t, e.Start >= last)
+			last = e.Start
+			got = append(got, e)
+			return true
+		})
+		assert.Equal(t, values(want), values(got), q)
+
+		want = want[:0]
+		for _, e := range all {
+			if e.Contains(q.Start) {
+				want = append(want, e)
+			}
+		}
+		assert.Equal(t, values(want), values(tree.Stab(q.Start)), q.Start)
+	}
+	n := 0
+	tree.Iter(func(Entry[int, int]) bool {
+		n++
+		return n < 10
+	})
+	assert.Equal(t, 10, n)
+}
+
+func TestSet(t *testing.T) {
+	var s Set[int64]
+	type iv = Interval[int64]
+	s.Add(iv{10, 20})
+	s.Add(iv{30, 40})
+	s.Add(iv{5, 5})
+	assert.Equal(t, []iv{{10, 20}, {30, 40}}, s.Intervals())
+	// Adjacent intervals merge.
+	s.Add(iv{20, 25})
+	assert.Equal(t, []iv{{10, 25}, {30, 40}}, s.Intervals())
+	s.Add(iv{0, 100})
+	assert.Equal(t, []iv{{0, 100}}, s.Intervals())
+	s.Remove(iv{10, 20})
+	s.Remove(iv{50, 60})
+	s.Remove(iv{90, 200})
+	assert.Equal(t, []iv{{0, 10}, {20, 50}, {60, 90}}, s.Intervals())
+	for _, _case := range []struct {
+		k        int64
+		contains bool
+	}{
+		{-1, false}, {0, true}, {9, true}, {10, false}, {20, true}, {89, true}, {90, false},
+	} {
+		assert.Equal(t, _case.contains, s.Contains(_case.k), _case.k)
+	}
+	assert.True(t, s.Covers(iv{20, 50}))
+	assert.False(t, s.Covers(iv{5, 25}))
+	assert.True(t, s.Covers(iv{7, 7}))
+	assert.Equal(t, []iv{{5, 10}, {20, 50}, {60, 70}}, s.Intersection(iv{5, 70}))
+	assert.Equal(t, []iv{{10, 20}, {50, 60}, {90, 95}}, s.Gaps(iv{5, 95}))
+	assert.Equal(t, []iv{{100, 110}}, s.Gaps(iv{100, 110}))
+	assert.Equal(t, 3, s.Len())
+}
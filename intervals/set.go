@@ -0,0 +1,130 @@
+package intervals
+
+import "sort"
+
+// The union of the intervals added, less those removed, kept as disjoint,
+// non-adjacent intervals. For example, the extents of a sparse file that
+// have been written. The zero value is empty and ready to use.
+type Set[K Ordered] struct {
+	// Sorted, disjoint and non-adjacent.
+	ivs []Interval[K]
+}
+
+// Returns the index of the first interval that ends at or after k.
+func (me *Set[K]) search(k K) int {
+	return sort.Search(len(me.ivs), func(i int) bool {
+		return me.ivs[i].End >= k
+	})
+}
+
+// Adds iv, merging it with intervals it overlaps or adjoins.
+func (me *Set[K]) Add(iv Interval[K]) {
+	if iv.Empty() {
+		return
+	}
+	i := me.search(iv.Start)
+	j := i
+	for j < len(me.ivs) && me.ivs[j].Start <= iv.End {
+		if me.ivs[j].Start < iv.Start {
+			iv.Start = me.ivs[j].Start
+		}
+		if me.ivs[j].End > iv.End {
+			iv.End = me.ivs[j].End
+		}
+		j++
+	}
+	me.replace(i, j, iv)
+}
+
+// Replaces the intervals [i, j) with ivs.
+func (me *Set[K]) replace(i, j int, ivs ...Interval[K]) {
+	tail := append([]Interval[K](nil), me.ivs[j:]...)
+	me.ivs = append(append(me.ivs[:i], ivs...), tail...)
+}
+
+// Removes iv, splitting intervals that contain it.
+func (me *Set[K]) Remove(iv Interval[K]) {
+	if iv.Empty() {
+		return
+	}
+	// Intervals ending exactly at iv.Start are unaffected.
+	i := sort.Search(len(me.ivs), func(i int) bool {
+		return me.ivs[i].End > iv.Start
+	})
+	j := i
+	var keep []Interval[K]
+	for j < len(me.ivs) && me.ivs[j].Start < iv.End {
+		cur := me.ivs[j]
+		if cur.Start < iv.Start {
+			keep = append(keep, Interval[K]{cur.Start, iv.Start})
+		}
+		if cur.End > iv.End {
+			keep = append(keep, Interval[K]{iv.End, cur.End})
+		}
+		j++
+	}
+	me.replace(i, j, keep...)
+}
+
+func (me *Set[K]) Contains(k K) bool {
+	i := sort.Search(len(me.ivs), func(i int) bool {
+		return me.ivs[i].End > k
+	})
+	return i < len(me.ivs) && me.ivs[i].Start <= k
+}
+
+// Returns whether all of iv is in the Set. Empty intervals are always
+// covered.
+func (me *Set[K]) Covers(iv Interval[K]) bool {
+	if iv.Empty() {
+		return true
+	}
+	i := sort.Search(len(me.ivs), func(i int) bool {
+		return me.ivs[i].End > iv.Start
+	})
+	return i < len(me.ivs) && me.ivs[i].Start <= iv.Start && me.ivs[i].End >= iv.End
+}
+
+// Returns the parts of the Set within iv.
+func (me *Set[K]) Intersection(iv Interval[K]) (ret []Interval[K]) {
+	for _, cur := range me.ivs[me.search(iv.Start):] {
+		if cur.Start >= iv.End {
+			break
+		}
+		if cur.Start < iv.Start {
+			cur.Start = iv.Start
+		}
+		if cur.End > iv.End {
+			cur.End = iv.End
+		}
+		if !cur.Empty() {
+			ret = append(ret, cur)
+		}
+	}
+	return
+}
+
+// Returns the parts of iv not in the Set, such as the holes in a partially
+// written range.
+func (me *Set[K]) Gaps(iv Interval[K]) (ret []Interval[K]) {
+	start := iv.Start
+	for _, cur := range me.Intersection(iv) {
+		if cur.Start > start {
+			ret = append(ret, Interval[K]{start, cur.Start})
+		}
+		start = cur.End
+	}
+	if start < iv.End {
+		ret = append(ret, Interval[K]{start, iv.End})
+	}
+	return
+}
+
+// Returns the disjoint intervals in the Set, in order.
+func (me *Set[K]) Intervals() []Interval[K] {
+	return append([]Interval[K](nil), me.ivs...)
+}
+
+func (me *Set[K]) Len() int {
+	return len(me.ivs)
+}
@@ -0,0 +1,196 @@
+package intervals
+
+import "math/rand"
+
+// Holds intervals, which may overlap, each with a value. It's a treap, with
+// each node tracking the greatest End beneath it, so queries visit only
+// subtrees that can overlap. The zero value is empty and ready to use.
+type Tree[K Ordered, V any] struct {
+	root *node[K, V]
+	len  int
+}
+
+type Entry[K Ordered, V any] struct {
+	Interval[K]
+	Value V
+}
+
+type node[K Ordered, V any] struct {
+	Entry[K, V]
+	prio        uint32
+	maxEnd      K
+	left, right *node[K, V]
+}
+
+func (me *node[K, V]) update() {
+	me.maxEnd = me.End
+	if me.left != nil && me.left.maxEnd > me.maxEnd {
+		me.maxEnd = me.left.maxEnd
+	}
+	if me.right != nil && me.right.maxEnd > me.maxEnd {
+		me.maxEnd = me.right.maxEnd
+	}
+}
+
+func rotateRight[K Ordered, V any](n *node[K, V]) *node[K, V] {
+	l := n.left
+	n.left = l.right
+	l.right = n
+	n.update()
+	l.update()
+	return l
+}
+
+func rotateLeft[K Ordered, V any](n *node[K, V]) *node[K, V] {
+	r := n.right
+	n.right = r.left
+	r.left = n
+	n.update()
+	r.update()
+	return r
+}
+
+func (me *Tree[K, V]) Len() int {
+	return me.len
+}
+
+// Adds an interval with a value. Empty intervals are ignored, as nothing can
+// overlap them.
+func (me *Tree[K, V]) Insert(iv Interval[K], value V) {
+	if iv.Empty() {
+		return
+	}
+	me.root = insert(me.root, &node[K, V]{
+		Entry: Entry[K, V]{iv, value},
+		prio:  rand.Uint32(),
+	})
+	me.len++
+}
+
+func insert[K Ordered, V any](n, new *node[K, V]) *node[K, V] {
+	if n == nil {
+		new.update()
+		return new
+	}
+	if new.Interval.less(n.Interval) {
+		n.left = insert(n.left, new)
+		if n.left.prio > n.prio {
+			return rotateRight(n)
+		}
+	} else {
+		n.right = insert(n.right, new)
+		if n.right.prio > n.prio {
+			return rotateLeft(n)
+		}
+	}
+	n.update()
+	return n
+}
+
+// Removes an entry with the interval, for which match returns true. A nil
+// match accepts any entry. Returns whether an entry was removed.
+func (me *Tree[K, V]) Delete(iv Interval[K], match func(V) bool) bool {
+	var deleted bool
+	me.root = deleteNode(me.root, iv, match, &deleted)
+	if deleted {
+		me.len--
+	}
+	return deleted
+}
+
+func deleteNode[K Ordered, V any](n *node[K, V], iv Interval[K], match func(V) bool, deleted *bool) *node[K, V] {
+	if n == nil {
+		return nil
+	}
+	if n.Interval == iv && (match == nil || match(n.Value)) {
+		*deleted = true
+		return merge(n.left, n.right)
+	}
+	// Equal intervals can be on either side after rotations.
+	if !n.Interval.less(iv) {
+		n.left = deleteNode(n.left, iv, match, deleted)
+	}
+	if !*deleted && !iv.less(n.Interval) {
+		n.right = deleteNode(n.right, iv, match, deleted)
+	}
+	n.update()
+	return n
+}
+
+// Joins two treaps, where everything in l orders before r.
+func merge[K Ordered, V any](l, r *node[K, V]) *node[K, V] {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+	if l.prio > r.prio {
+		l.right = merge(l.right, r)
+		l.update()
+		return l
+	}
+	r.left = merge(l, r.left)
+	r.update()
+	return r
+}
+
+// Calls f with entries overlapping iv, in order of Start, until it returns
+// false.
+func (me *Tree[K, V]) Overlapping(iv Interval[K], f func(Entry[K, V]) bool) {
+	if iv.Empty() {
+		return
+	}
+	overlapping(me.root, iv, f)
+}
+
+func overlapping[K Ordered, V any](n *node[K, V], iv Interval[K], f func(Entry[K, V]) bool) bool {
+	if n == nil || n.maxEnd <= iv.Start {
+		return true
+	}
+	if !overlapping(n.left, iv, f) {
+		return false
+	}
+	if n.Start >= iv.End {
+		// Nothing further right can start before iv ends.
+		return true
+	}
+	if n.Overlaps(iv) && !f(n.Entry) {
+		return false
+	}
+	return overlapping(n.right, iv, f)
+}
+
+// Returns the entries containing k.
+func (me *Tree[K, V]) Stab(k K) (ret []Entry[K, V]) {
+	stab(me.root, k, func(e Entry[K, V]) {
+		ret = append(ret, e)
+	})
+	return
+}
+
+func stab[K Ordered, V any](n *node[K, V], k K, f func(Entry[K, V])) {
+	if n == nil || n.maxEnd <= k {
+		return
+	}
+	stab(n.left, k, f)
+	if n.Start > k {
+		return
+	}
+	if n.Contains(k) {
+		f(n.Entry)
+	}
+	stab(n.right, k, f)
+}
+
+// Calls f with every entry, in order of Start, until it returns false.
+func (me *Tree[K, V]) Iter(f func(Entry[K, V]) bool) {
+	iter(me.root, f)
+}
+
+func iter[K Ordered, V any](n *node[K, V], f func(Entry[K, V]) bool) bool {
+	if n == nil {
+		return true
+	}
+	return iter(n.left, f) && f(n.Entry) && iter(n.right, f)
+}
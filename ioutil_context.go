@@ -0,0 +1,187 @@
+package missinggo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2/bufpool"
+)
+
+// Like io.CopyN, but it's an error for src to end before n bytes, reported
+// as io.ErrUnexpectedEOF.
+func CopyNExact(dst io.Writer, src io.Reader, n int64) (written int64, err error) {
+	written, err = io.CopyN(dst, src, n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// Implemented by net.Conn and *os.File.
+type readDeadliner interface {
+	SetReadDeadline(time.Time) error
+}
+
+type writeDeadliner interface {
+	SetWriteDeadline(time.Time) error
+}
+
+// Applies ctx's deadline through setDeadline, and sets a deadline in the past
+// if ctx is done before stop is called, so that blocked calls return. stop
+// clears the deadline again.
+func interruptOnDone(ctx context.Context, setDeadline func(time.Time) error) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	if d, ok := ctx.Deadline(); ok {
+		setDeadline(d)
+	}
+	stopped := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+			setDeadline(time.Unix(1, 0))
+		case <-stopped:
+		}
+	}()
+	return func() {
+		close(stopped)
+		wg.Wait()
+		setDeadline(time.Time{})
+	}
+}
+
+// Returns ctx's error in place of err if ctx is the reason for failure.
+func ctxErr(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// The deadline we applied can expire just before ctx notices.
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) && errors.Is(err, os.ErrDeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// Like io.ReadFull, but returns early when ctx is done. If r supports read
+// deadlines, a read in progress is interrupted, otherwise ctx is checked
+// between reads.
+func ReadFullCtx(ctx context.Context, r io.Reader, buf []byte) (n int, err error) {
+	if rd, ok := r.(readDeadliner); ok {
+		defer interruptOnDone(ctx, rd.SetReadDeadline)()
+	}
+	for n < len(buf) && err == nil {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var nn int
+		nn, err = r.Read(buf[n:])
+		n += nn
+	}
+	if n == len(buf) {
+		err = nil
+	} else if err == io.EOF && n != 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, ctxErr(ctx, err)
+}
+
+// Writes all of b to w, returning early when ctx is done. If w supports
+// write deadlines, a write in progress is interrupted, otherwise ctx is
+// checked between writes.
+func WriteAllCtx(ctx context.Context, w io.Writer, b []byte) (n int, err error) {
+	if wd, ok := w.(writeDeadliner); ok {
+		defer interruptOnDone(ctx, wd.SetWriteDeadline)()
+	}
+	for n < len(b) {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var nn int
+		nn, err = w.Write(b[n:])
+		n += nn
+		if err != nil {
+			break
+		}
+		if nn == 0 {
+			err = io.ErrShortWrite
+			break
+		}
+	}
+	return n, ctxErr(ctx, err)
+}
+
+// Writes bufs to w, using vectored IO if w supports it, as with net.Buffers.
+// ctx is handled as with WriteAllCtx.
+func WriteBuffersCtx(ctx context.Context, w io.Writer, bufs net.Buffers) (n int64, err error) {
+	if wd, ok := w.(writeDeadliner); ok {
+		defer interruptOnDone(ctx, wd.SetWriteDeadline)()
+		n, err = bufs.WriteTo(w)
+		return n, ctxErr(ctx, err)
+	}
+	for _, b := range bufs {
+		var nn int
+		nn, err = WriteAllCtx(ctx, w, b)
+		n += int64(nn)
+		if err != nil {
+			break
+		}
+	}
+	return
+}
+
+// Like io.Copy, using pooled buffers, and returning early when ctx is done.
+// Reads and writes in progress are interrupted if src and dst support
+// deadlines.
+func CopyCtx(ctx context.Context, dst io.Writer, src io.Reader) (written int64, err error) {
+	if rd, ok := src.(readDeadliner); ok {
+		defer interruptOnDone(ctx, rd.SetReadDeadline)()
+	}
+	if wd, ok := dst.(writeDeadliner); ok {
+		defer interruptOnDone(ctx, wd.SetWriteDeadline)()
+	}
+	buf := bufpool.Get(32 << 10)
+	defer bufpool.Put(buf)
+	for {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		nr, rerr := src.Read(buf)
+		if nr > 0 {
+			nw, werr := dst.Write(buf[:nr])
+			written += int64(nw)
+			if werr == nil && nw != nr {
+				werr = io.ErrShortWrite
+			}
+			if werr != nil {
+				return written, ctxErr(ctx, werr)
+			}
+		}
+		if rerr == io.EOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, ctxErr(ctx, rerr)
+		}
+	}
+}
+
+// Like CopyCtx, but copies exactly n bytes, as with CopyNExact.
+func CopyNCtx(ctx context.Context, dst io.Writer, src io.Reader, n int64) (written int64, err error) {
+	written, err = CopyCtx(ctx, dst, io.LimitReader(src, n))
+	if err == nil && written < n {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
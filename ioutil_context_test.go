@@ -0,0 +1,99 @@
+package missinggo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyNExact(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := CopyNExact(&buf, strings.NewReader("hello"), 5)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, n)
+	n, err = CopyNExact(&buf, strings.NewReader("hi"), 5)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.EqualValues(t, 2, n)
+}
+
+func TestReadFullCtxInterrupted(t *testing.T) {
+	c, s := net.Pipe()
+	defer c.Close()
+	defer s.Close()
+	go s.Write([]byte("ab"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	buf := make([]byte, 4)
+	n, err := ReadFullCtx(ctx, c, buf)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 2, n)
+	// The deadline is cleared afterwards.
+	go s.Write([]byte("cd"))
+	n, err = ReadFullCtx(context.Background(), c, buf[2:])
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", string(buf))
+}
+
+func TestReadFullCtx(t *testing.T) {
+	buf := make([]byte, 3)
+	n, err := ReadFullCtx(context.Background(), strings.NewReader("abcd"), buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	_, err = ReadFullCtx(context.Background(), strings.NewReader("ab"), buf)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = ReadFullCtx(context.Background(), strings.NewReader(""), buf)
+	assert.Equal(t, io.EOF, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ReadFullCtx(ctx, strings.NewReader("abc"), buf)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestWriteCtx(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := WriteAllCtx(context.Background(), &buf, []byte("ab"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n64, err := WriteBuffersCtx(context.Background(), &buf, net.Buffers{[]byte("c"), []byte("de")})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n64)
+	assert.Equal(t, "abcde", buf.String())
+
+	c, s := net.Pipe()
+	defer c.Close()
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = WriteAllCtx(ctx, c, []byte("blocked"))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestCopyCtx(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := CopyCtx(context.Background(), &buf, strings.NewReader("hello"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, n)
+	n, err = CopyNCtx(context.Background(), &buf, strings.NewReader("hello"), 6)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.EqualValues(t, 5, n)
+
+	c, s := net.Pipe()
+	defer c.Close()
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		s.Write([]byte("partial"))
+		cancel()
+	}()
+	buf.Reset()
+	n, err = CopyCtx(ctx, &buf, c)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, "partial", buf.String())
+}
@@ -0,0 +1,343 @@
+// Package kvfile is a key-value store kept in a single append-only file,
+// for many small values that would be wasteful as files of their own. The
+// index is held in memory, and space from overwritten and deleted values is
+// reclaimed by Compact.
+package kvfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/anacrolix/missinggo/v2/atomicfile"
+	"github.com/anacrolix/missinggo/v2/filelock"
+)
+
+const (
+	opPut    = 1
+	opDelete = 2
+	// CRC of the rest of the record, op, key length and value length.
+	recordHeaderLen = 13
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var ErrClosed = errors.New("kvfile closed")
+
+type Opts struct {
+	// Sync the file after every write. Otherwise a crash can lose recent
+	// writes, but not corrupt earlier ones.
+	SyncWrites bool
+}
+
+type Stats struct {
+	Keys int
+	// The file size, and how much of it is overwritten or deleted values.
+	Size    int64
+	Garbage int64
+}
+
+// Where a value is in the file.
+type location struct {
+	off int64
+	len int64
+}
+
+// Safe for concurrent use. Only one DB can have a file open at a time,
+// across processes, where file locks are supported.
+type DB struct {
+	path string
+	opts Opts
+	lock *filelock.Lock
+
+	mu      sync.RWMutex
+	f       *os.File
+	size    int64
+	garbage int64
+	index   map[string]location
+}
+
+// Opens the store at path, creating it if necessary. A record left
+// incomplete by a crash is discarded.
+func Open(path string, opts Opts) (_ *DB, err error) {
+	lock, err := filelock.TryAcquire(path+".lock", filelock.Exclusive)
+	if err == filelock.ErrUnsupported {
+		// Go without, and hope for the best.
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil && lock != nil {
+			lock.Release()
+		}
+	}()
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return
+	}
+	me := &DB{
+		path:  path,
+		opts:  opts,
+		lock:  lock,
+		f:     f,
+		index: make(map[string]location),
+	}
+	if err = me.load(); err != nil {
+		f.Close()
+		return
+	}
+	return me, nil
+}
+
+// Builds the index from the file, truncating any torn tail.
+func (me *DB) load() error {
+	fi, err := me.f.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := fi.Size()
+	for me.size < fileSize {
+		next, ok, err := me.loadRecord(fileSize)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return me.f.Truncate(me.size)
+		}
+		me.size = next
+	}
+	return nil
+}
+
+// Applies the record at the end of the loaded part of the file to the index.
+// ok is false if the record is incomplete or corrupt.
+func (me *DB) loadRecord(fileSize int64) (next int64, ok bool, err error) {
+	off := me.size
+	if off+recordHeaderLen > fileSize {
+		return
+	}
+	var h [recordHeaderLen]byte
+	if _, err = me.f.ReadAt(h[:], off); err != nil {
+		return
+	}
+	keyLen := int64(binary.BigEndian.Uint32(h[5:]))
+	valLen := int64(binary.BigEndian.Uint32(h[9:]))
+	next = off + recordHeaderLen + keyLen + valLen
+	if next > fileSize {
+		return
+	}
+	body := make([]byte, recordHeaderLen-4+keyLen+valLen)
+	if _, err = me.f.ReadAt(body, off+4); err != nil {
+		return
+	}
+	if crc32.Checksum(body, castagnoli) != binary.BigEndian.Uint32(h[:]) {
+		return
+	}
+	key := string(body[recordHeaderLen-4 : recordHeaderLen-4+keyLen])
+	switch h[4] {
+	case opPut:
+		me.dropOld(key)
+		me.index[key] = location{off + recordHeaderLen + keyLen, valLen}
+	case opDelete:
+		me.dropOld(key)
+		me.garbage += next - off
+	default:
+		return
+	}
+	ok = true
+	return
+}
+
+// Removes key from the index, counting the record it was set by as garbage.
+func (me *DB) dropOld(key string) {
+	if old, ok := me.index[key]; ok {
+		me.garbage += recordHeaderLen + int64(len(key)) + old.len
+		delete(me.index, key)
+	}
+}
+
+func appendRecord(b []byte, op byte, key string, value []byte) []byte {
+	start := len(b)
+	var h [recordHeaderLen]byte
+	h[4] = op
+	binary.BigEndian.PutUint32(h[5:], uint32(len(key)))
+	binary.BigEndian.PutUint32(h[9:], uint32(len(value)))
+	b = append(b, h[:]...)
+	b = append(b, key...)
+	b = append(b, value...)
+	binary.BigEndian.PutUint32(b[start:], crc32.Checksum(b[start+4:], castagnoli))
+	return b
+}
+
+// Appends a record, returning the offset it was written at. Must be called
+// with the write lock held.
+func (me *DB) write(op byte, key string, value []byte) (off int64, err error) {
+	if me.f == nil {
+		err = ErrClosed
+		return
+	}
+	off = me.size
+	rec := appendRecord(nil, op, key, value)
+	if _, err = me.f.WriteAt(rec, off); err != nil {
+		// Don't leave a partial record where the next one will go.
+		me.f.Truncate(off)
+		return
+	}
+	if me.opts.SyncWrites {
+		if err = me.f.Sync(); err != nil {
+			return
+		}
+	}
+	me.size += int64(len(rec))
+	return
+}
+
+// Returns a copy of the value for key.
+func (me *DB) Get(key string) (value []byte, ok bool, err error) {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	if me.f == nil {
+		err = ErrClosed
+		return
+	}
+	loc, ok := me.index[key]
+	if !ok {
+		return
+	}
+	value = make([]byte, loc.len)
+	_, err = me.f.ReadAt(value, loc.off)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+func (me *DB) Put(key string, value []byte) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	off, err := me.write(opPut, key, value)
+	if err != nil {
+		return err
+	}
+	me.dropOld(key)
+	me.index[key] = location{off + recordHeaderLen + int64(len(key)), int64(len(value))}
+	return nil
+}
+
+// Deleting a missing key isn't an error.
+func (me *DB) Delete(key string) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if _, ok := me.index[key]; !ok {
+		return nil
+	}
+	off, err := me.write(opDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	me.dropOld(key)
+	me.garbage += me.size - off
+	return nil
+}
+
+// Returns the keys in order.
+func (me *DB) Keys() (ret []string) {
+	me.mu.RLock()
+	ret = make([]string, 0, len(me.index))
+	for k := range me.index {
+		ret = append(ret, k)
+	}
+	me.mu.RUnlock()
+	sort.Strings(ret)
+	return
+}
+
+func (me *DB) Stats() Stats {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+	return Stats{
+		Keys:    len(me.index),
+		Size:    me.size,
+		Garbage: me.garbage,
+	}
+}
+
+// Flushes writes to stable storage.
+func (me *DB) Sync() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.f == nil {
+		return ErrClosed
+	}
+	return me.f.Sync()
+}
+
+// Rewrites the file with only the live values. Reads and writes wait for it.
+func (me *DB) Compact() (err error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.f == nil {
+		return ErrClosed
+	}
+	af, err := atomicfile.Create(me.path, atomicfile.Opts{PreserveMode: true})
+	if err != nil {
+		return
+	}
+	defer af.Close()
+	index := make(map[string]location, len(me.index))
+	var size int64
+	var rec, value []byte
+	for key, loc := range me.index {
+		if int64(cap(value)) < loc.len {
+			value = make([]byte, loc.len)
+		}
+		value = value[:loc.len]
+		if _, err = me.f.ReadAt(value, loc.off); err != nil {
+			return
+		}
+		rec = appendRecord(rec[:0], opPut, key, value)
+		if _, err = af.Write(rec); err != nil {
+			return
+		}
+		index[key] = location{size + recordHeaderLen + int64(len(key)), loc.len}
+		size += int64(len(rec))
+	}
+	// Windows can't replace a file that's open.
+	me.f.Close()
+	me.f = nil
+	err = af.Commit()
+	f, openErr := os.OpenFile(me.path, os.O_RDWR, 0)
+	if openErr != nil {
+		return openErr
+	}
+	me.f = f
+	if err != nil {
+		return
+	}
+	me.index = index
+	me.size = size
+	me.garbage = 0
+	return
+}
+
+func (me *DB) Close() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.f == nil {
+		return ErrClosed
+	}
+	err := me.f.Close()
+	me.f = nil
+	if me.lock != nil {
+		if lerr := me.lock.Release(); err == nil {
+			err = lerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package kvfile
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/v2/filelock"
+	"github.com/anacrolix/missinggo/v2/resource"
+)
+
+var _ resource.KV = (*DB)(nil)
+
+func get(t *testing.T, db *DB, key string) string {
+	v, ok, err := db.Get(key)
+	require.NoError(t, err)
+	if !ok {
+		return "<missing>"
+	}
+	return string(v)
+}
+
+func TestDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db, err := Open(path, Opts{})
+	require.NoError(t, err)
+	_, err = Open(path, Opts{})
+	assert.Equal(t, filelock.ErrLocked, err)
+
+	require.NoError(t, db.Put("a", []byte("1")))
+	require.NoError(t, db.Put("b", []byte("2")))
+	require.NoError(t, db.Put("a", []byte("3")))
+	require.NoError(t, db.Put("empty", nil))
+	require.NoError(t, db.Delete("b"))
+	require.NoError(t, db.Delete("missing"))
+	assert.Equal(t, "3", get(t, db, "a"))
+	assert.Equal(t, "<missing>", get(t, db, "b"))
+	assert.Equal(t, "", get(t, db, "empty"))
+	assert.Equal(t, []string{"a", "empty"}, db.Keys())
+	stats := db.Stats()
+	require.NoError(t, db.Close())
+	assert.Equal(t, ErrClosed, db.Put("a", nil))
+
+	// Reopening, after a torn write, restores the same state.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write(appendRecord(nil, opPut, "torn", []byte("x"))[:15])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	db, err = Open(path, Opts{})
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, stats, db.Stats())
+	assert.Equal(t, []string{"a", "empty"}, db.Keys())
+	require.NoError(t, db.Put("c", []byte("4")))
+	assert.Equal(t, "4", get(t, db, "c"))
+}
+
+func TestCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db, err := Open(path, Opts{})
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, db.Put(strconv.Itoa(i%10), []byte(strconv.Itoa(i))))
+	}
+	require.NoError(t, db.Delete("0"))
+	before := db.Stats()
+	assert.Equal(t, 9, before.Keys)
+	require.NoError(t, db.Compact())
+	after := db.Stats()
+	assert.EqualValues(t, 0, after.Garbage)
+	assert.Equal(t, before.Size-before.Garbage, after.Size)
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, after.Size, fi.Size())
+	assert.Equal(t, "99", get(t, db, "9"))
+	require.NoError(t, db.Close())
+
+	db, err = Open(path, Opts{})
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, after, db.Stats())
+	assert.Equal(t, "91", get(t, db, "1"))
+}
@@ -0,0 +1,109 @@
+package leaktest
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2"
+	"github.com/anacrolix/missinggo/v2/pproffd"
+)
+
+// How long goroutines and handles are given to go away before they're
+// considered leaked.
+var settleTimeout = 2 * time.Second
+
+// Put defer Check(t)() at the top of your test. Goroutines started, and
+// pproffd tracked handles wrapped, during the test that are still around when
+// the returned func is called fail the test, with their stacks. Handles are
+// only tracked if pproffd is enabled. Unlike GoroutineLeakCheck, this doesn't
+// suit parallel tests, as their goroutines can't be told apart.
+func Check(t testing.TB) func() {
+	startGoroutines := goroutines()
+	var lastHandle int64
+	if hs := pproffd.OpenHandles(); len(hs) != 0 {
+		lastHandle = hs[len(hs)-1].ID
+	}
+	return func() {
+		var leakedGoroutines []string
+		var leakedHandles []pproffd.OpenHandle
+		deadline := time.Now().Add(settleTimeout)
+		for wait := time.Millisecond; ; wait *= 2 {
+			leakedGoroutines = leakedGoroutines[:0]
+			for id, stack := range goroutines() {
+				if _, ok := startGoroutines[id]; !ok {
+					leakedGoroutines = append(leakedGoroutines, stack)
+				}
+			}
+			leakedHandles = leakedHandles[:0]
+			for _, h := range pproffd.OpenHandles() {
+				if h.ID > lastHandle {
+					leakedHandles = append(leakedHandles, h)
+				}
+			}
+			if len(leakedGoroutines) == 0 && len(leakedHandles) == 0 {
+				return
+			}
+			if time.Now().Add(wait).After(deadline) {
+				break
+			}
+			time.Sleep(wait)
+		}
+		for _, stack := range leakedGoroutines {
+			t.Errorf("leaked goroutine:\n%s", stack)
+		}
+		for _, h := range leakedHandles {
+			t.Errorf("leaked handle, wrapped at:\n%s", missinggo.FormatStack("", h.Stack))
+		}
+	}
+}
+
+// Returns the stacks of all goroutines, keyed by ID, except the caller's, and
+// those belonging to the testing package and runtime.
+func goroutines() map[int64]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	ret := make(map[int64]string)
+	// The first is the caller's.
+	for _, g := range bytes.Split(buf, []byte("\n\n"))[1:] {
+		stack := string(g)
+		if ignoreGoroutine(stack) {
+			continue
+		}
+		header := strings.Fields(stack)
+		if len(header) < 2 {
+			continue
+		}
+		id, err := strconv.ParseInt(header[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		ret[id] = stack
+	}
+	return ret
+}
+
+// Goroutines without a creator are main, or belong to the runtime.
+func ignoreGoroutine(stack string) bool {
+	i := strings.LastIndex(stack, "\ncreated by ")
+	if i == -1 {
+		return true
+	}
+	creator := stack[i+len("\ncreated by "):]
+	for _, pkg := range []string{"testing.", "runtime.", "os/signal."} {
+		if strings.HasPrefix(creator, pkg) {
+			return true
+		}
+	}
+	return false
+}
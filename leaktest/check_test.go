@@ -0,0 +1,75 @@
+package leaktest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anacrolix/missinggo/v2/pproffd"
+)
+
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (me *recordingTB) Errorf(format string, args ...interface{}) {
+	me.errors = append(me.errors, fmt.Sprintf(format, args...))
+}
+
+func withSettleTimeout(d time.Duration) func() {
+	old := settleTimeout
+	settleTimeout = d
+	return func() { settleTimeout = old }
+}
+
+func leakyGoroutine(stop chan struct{}) {
+	<-stop
+}
+
+func TestCheckGoroutines(t *testing.T) {
+	defer withSettleTimeout(50 * time.Millisecond)()
+	var tb recordingTB
+	stop := make(chan struct{})
+	check := Check(&tb)
+	go leakyGoroutine(stop)
+	check()
+	close(stop)
+	if assert.Len(t, tb.errors, 1) {
+		assert.Contains(t, tb.errors[0], "leakyGoroutine")
+	}
+
+	// Goroutines that finish shortly after aren't reported.
+	tb.errors = nil
+	check = Check(&tb)
+	stop = make(chan struct{})
+	go leakyGoroutine(stop)
+	time.AfterFunc(time.Millisecond, func() { close(stop) })
+	check()
+	assert.Empty(t, tb.errors)
+}
+
+func TestCheckHandles(t *testing.T) {
+	defer withSettleTimeout(10 * time.Millisecond)()
+	pproffd.Enable()
+	var tb recordingTB
+	check := Check(&tb)
+	f, err := os.Create(filepath.Join(t.TempDir(), "f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wf := pproffd.WrapOSFile(f)
+	check()
+	wf.Close()
+	if assert.Len(t, tb.errors, 1) {
+		assert.True(t, strings.Contains(tb.errors[0], "TestCheckHandles"), tb.errors[0])
+	}
+	tb.errors = nil
+	Check(&tb)()
+	assert.Empty(t, tb.errors)
+}
@@ -0,0 +1,325 @@
+// Package memcache provides a size bounded in-memory cache, complementing
+// filecache for data that should stay in RAM.
+package memcache
+
+import (
+	"sync"
+	"time"
+)
+
+type Policy int
+
+const (
+	// Evicts the least recently used entry.
+	LRU Policy = iota
+	// Adaptive Replacement Cache, which balances recency and frequency, and
+	// resists scans flushing frequently used entries.
+	ARC
+)
+
+type Opts[K comparable, V any] struct {
+	// The most entries held. Zero means no limit.
+	MaxEntries int
+	// The most bytes held, as determined by Size. Zero means no limit.
+	MaxBytes int64
+	// Returns the size of an entry in bytes. Required if MaxBytes is set.
+	Size   func(K, V) int64
+	Policy Policy
+	// Called with entries removed to make room, or found to have expired,
+	// with the Cache locked.
+	OnEvict func(K, V)
+}
+
+type Stats struct {
+	Hits   int64
+	Misses int64
+	// Entries removed to make room.
+	Evictions int64
+	// Entries removed because their TTL passed.
+	Expirations int64
+}
+
+func (me Stats) HitRate() float64 {
+	if me.Hits+me.Misses == 0 {
+		return 0
+	}
+	return float64(me.Hits) / float64(me.Hits+me.Misses)
+}
+
+// An in-memory cache, bounded by entries or bytes. It's safe for concurrent
+// use.
+type Cache[K comparable, V any] struct {
+	opts Opts[K, V]
+
+	mu      sync.Mutex
+	entries map[K]*entry[K, V]
+	// Resident entries. LRU uses only t1. For ARC, t1 holds entries seen once
+	// recently, and t2 those seen at least twice.
+	t1, t2 list[K, V]
+	// ARC's ghosts: the keys of entries recently evicted from t1 and t2.
+	b1, b2 list[K, V]
+	// ARC's target cost for t1.
+	p     int64
+	bytes int64
+	stats Stats
+}
+
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+	size  int64
+	// The entry's share of the ARC capacity: its size if the Cache is
+	// bounded by bytes, otherwise 1.
+	cost    int64
+	expires time.Time
+
+	list       *list[K, V]
+	prev, next *entry[K, V]
+}
+
+func New[K comparable, V any](opts Opts[K, V]) *Cache[K, V] {
+	if opts.MaxBytes > 0 && opts.Size == nil {
+		panic("memcache: MaxBytes requires Size")
+	}
+	me := &Cache[K, V]{
+		opts:    opts,
+		entries: make(map[K]*entry[K, V]),
+	}
+	me.t1.init()
+	me.t2.init()
+	me.b1.init()
+	me.b2.init()
+	return me
+}
+
+func (me *Cache[K, V]) resident(e *entry[K, V]) bool {
+	return e.list == &me.t1 || e.list == &me.t2
+}
+
+// Returns the value for key, if it's present and not expired.
+func (me *Cache[K, V]) Get(key K) (value V, ok bool) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	e, ok := me.entries[key]
+	if ok && !me.resident(e) {
+		ok = false
+	}
+	if ok && !e.expires.IsZero() && !time.Now().Before(e.expires) {
+		me.expire(e)
+		ok = false
+	}
+	if !ok {
+		me.stats.Misses++
+		return
+	}
+	me.stats.Hits++
+	e.list.remove(e)
+	if me.opts.Policy == ARC {
+		me.t2.pushFront(e)
+	} else {
+		me.t1.pushFront(e)
+	}
+	return e.value, true
+}
+
+// Sets the value for key, with no expiry.
+func (me *Cache[K, V]) Set(key K, value V) {
+	me.SetTTL(key, value, 0)
+}
+
+// Sets the value for key. If ttl is positive, the entry expires after that
+// long.
+func (me *Cache[K, V]) SetTTL(key K, value V, ttl time.Duration) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	size := int64(0)
+	if me.opts.Size != nil {
+		size = me.opts.Size(key, value)
+	}
+	cost := int64(1)
+	if me.opts.MaxBytes > 0 {
+		cost = size
+	}
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	e, ok := me.entries[key]
+	inB2 := false
+	if ok {
+		switch e.list {
+		case &me.t1, &me.t2:
+			me.bytes -= e.size
+		case &me.b1:
+			me.p = min64(me.capacity(), me.p+max64(1, ratio(me.b2.cost, me.b1.cost))*cost)
+		case &me.b2:
+			me.p = max64(0, me.p-max64(1, ratio(me.b1.cost, me.b2.cost))*cost)
+			inB2 = true
+		}
+		e.list.remove(e)
+	} else {
+		e = &entry[K, V]{key: key}
+		me.entries[key] = e
+	}
+	e.value = value
+	e.size = size
+	e.cost = cost
+	e.expires = expires
+	me.bytes += size
+	// For ARC, entries seen before, even as ghosts, are frequently used.
+	if me.opts.Policy == ARC && ok {
+		me.t2.pushFront(e)
+	} else {
+		me.t1.pushFront(e)
+	}
+	me.makeRoom(inB2)
+}
+
+func ratio(a, b int64) int64 {
+	if b == 0 {
+		return a
+	}
+	return a / b
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ARC's capacity, in entry cost.
+func (me *Cache[K, V]) capacity() int64 {
+	if me.opts.MaxBytes > 0 {
+		return me.opts.MaxBytes
+	}
+	return int64(me.opts.MaxEntries)
+}
+
+func (me *Cache[K, V]) overLimit() bool {
+	if me.opts.MaxEntries > 0 && me.t1.len+me.t2.len > me.opts.MaxEntries {
+		return true
+	}
+	return me.opts.MaxBytes > 0 && me.bytes > me.opts.MaxBytes
+}
+
+// Evicts entries until within limits. inB2 is whether the entry just set was
+// an ARC ghost from b2.
+func (me *Cache[K, V]) makeRoom(inB2 bool) {
+	for me.overLimit() {
+		if me.opts.Policy != ARC {
+			me.evict(me.t1.back(), nil)
+			continue
+		}
+		if me.t1.len != 0 && (me.t1.cost > me.p || (inB2 && me.t1.cost == me.p) || me.t2.len == 0) {
+			me.evict(me.t1.back(), &me.b1)
+		} else {
+			me.evict(me.t2.back(), &me.b2)
+		}
+	}
+	if me.opts.Policy != ARC {
+		return
+	}
+	// Bound the ghosts, so that they remember about as much again as is
+	// resident.
+	c := me.capacity()
+	for me.b1.len != 0 && me.t1.cost+me.b1.cost > c {
+		me.forget(me.b1.back())
+	}
+	for me.b2.len != 0 && me.t1.cost+me.t2.cost+me.b1.cost+me.b2.cost > 2*c {
+		me.forget(me.b2.back())
+	}
+}
+
+// Removes a resident entry, keeping its key in ghost if that's not nil.
+func (me *Cache[K, V]) evict(e *entry[K, V], ghost *list[K, V]) {
+	me.stats.Evictions++
+	e.list.remove(e)
+	me.bytes -= e.size
+	k, v := e.key, e.value
+	if ghost != nil {
+		var zero V
+		e.value = zero
+		ghost.pushFront(e)
+	} else {
+		delete(me.entries, e.key)
+	}
+	if me.opts.OnEvict != nil {
+		me.opts.OnEvict(k, v)
+	}
+}
+
+func (me *Cache[K, V]) forget(e *entry[K, V]) {
+	e.list.remove(e)
+	delete(me.entries, e.key)
+}
+
+func (me *Cache[K, V]) expire(e *entry[K, V]) {
+	me.stats.Expirations++
+	e.list.remove(e)
+	me.bytes -= e.size
+	delete(me.entries, e.key)
+	if me.opts.OnEvict != nil {
+		me.opts.OnEvict(e.key, e.value)
+	}
+}
+
+// Removes key, without calling OnEvict. Returns whether it was present.
+func (me *Cache[K, V]) Delete(key K) bool {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	e, ok := me.entries[key]
+	if !ok {
+		return false
+	}
+	ok = me.resident(e)
+	if ok {
+		me.bytes -= e.size
+	}
+	me.forget(e)
+	return ok
+}
+
+// Removes expired entries, returning how many there were. Expired entries are
+// otherwise only removed when they're looked up, or evicted.
+func (me *Cache[K, V]) RemoveExpired() (n int) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	now := time.Now()
+	for _, e := range me.entries {
+		if me.resident(e) && !e.expires.IsZero() && !now.Before(e.expires) {
+			me.expire(e)
+			n++
+		}
+	}
+	return
+}
+
+// Returns the number of entries held, including any that have expired but not
+// been removed yet.
+func (me *Cache[K, V]) Len() int {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.t1.len + me.t2.len
+}
+
+// Returns the total size of entries held.
+func (me *Cache[K, V]) Bytes() int64 {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.bytes
+}
+
+func (me *Cache[K, V]) Stats() Stats {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.stats
+}
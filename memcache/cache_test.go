@@ -0,0 +1,121 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUMaxEntries(t *testing.T) {
+	var evicted []int
+	c := New(Opts[int, string]{
+		MaxEntries: 2,
+		OnEvict:    func(k int, v string) { evicted = append(evicted, k) },
+	})
+	c.Set(1, "a")
+	c.Set(2, "b")
+	_, ok := c.Get(1)
+	assert.True(t, ok)
+	c.Set(3, "c")
+	assert.Equal(t, []int{2}, evicted)
+	_, ok = c.Get(2)
+	assert.False(t, ok)
+	v, ok := c.Get(3)
+	assert.True(t, ok)
+	assert.Equal(t, "c", v)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, Stats{Hits: 2, Misses: 1, Evictions: 1}, c.Stats())
+	assert.InDelta(t, 2.0/3, c.Stats().HitRate(), 1e-9)
+}
+
+func TestMaxBytes(t *testing.T) {
+	c := New(Opts[string, []byte]{
+		MaxBytes: 10,
+		Size:     func(k string, v []byte) int64 { return int64(len(v)) },
+	})
+	c.Set("a", make([]byte, 4))
+	c.Set("b", make([]byte, 4))
+	assert.EqualValues(t, 8, c.Bytes())
+	c.Set("a", make([]byte, 6))
+	assert.EqualValues(t, 10, c.Bytes())
+	c.Set("c", make([]byte, 3))
+	// b was least recently used.
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	assert.EqualValues(t, 9, c.Bytes())
+	assert.True(t, c.Delete("c"))
+	assert.False(t, c.Delete("c"))
+	assert.EqualValues(t, 6, c.Bytes())
+	// Too large to hold at all.
+	c.Set("d", make([]byte, 11))
+	_, ok = c.Get("d")
+	assert.False(t, ok)
+	assert.EqualValues(t, 0, c.Bytes())
+}
+
+func TestTTL(t *testing.T) {
+	var evicted []string
+	c := New(Opts[string, int]{OnEvict: func(k string, v int) { evicted = append(evicted, k) }})
+	c.SetTTL("a", 1, time.Millisecond)
+	c.SetTTL("b", 2, time.Millisecond)
+	c.Set("c", 3)
+	time.Sleep(2 * time.Millisecond)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.RemoveExpired())
+	assert.ElementsMatch(t, []string{"a", "b"}, evicted)
+	assert.Equal(t, 1, c.Len())
+	assert.EqualValues(t, 2, c.Stats().Expirations)
+}
+
+// A scan of entries used once doesn't flush frequently used entries from an
+// ARC cache, as it would an LRU one.
+func TestARCScanResistance(t *testing.T) {
+	for _, _case := range []struct {
+		policy   Policy
+		retained bool
+	}{
+		{LRU, false},
+		{ARC, true},
+	} {
+		c := New(Opts[int, int]{MaxEntries: 10, Policy: _case.policy})
+		for i := 0; i < 5; i++ {
+			c.Set(i, i)
+			c.Get(i)
+		}
+		for i := 100; i < 200; i++ {
+			c.Set(i, i)
+		}
+		hot := 0
+		for i := 0; i < 5; i++ {
+			if _, ok := c.Get(i); ok {
+				hot++
+			}
+		}
+		assert.Equal(t, _case.retained, hot == 5, "%v: %v", _case, hot)
+		assert.Equal(t, 10, c.Len())
+	}
+}
+
+// ARC adapts towards recency when ghosts of once-used entries are hit.
+func TestARCGhostHit(t *testing.T) {
+	c := New(Opts[int, int]{MaxEntries: 4, Policy: ARC})
+	for i := 0; i < 2; i++ {
+		c.Set(i, i)
+		c.Get(i)
+	}
+	for i := 2; i < 8; i++ {
+		c.Set(i, i)
+	}
+	assert.Equal(t, 4, c.Len())
+	assert.EqualValues(t, 0, c.p)
+	c.Set(5, 5)
+	assert.EqualValues(t, 1, c.p)
+	v, ok := c.Get(5)
+	assert.True(t, ok)
+	assert.Equal(t, 5, v)
+	assert.Equal(t, 4, c.Len())
+}
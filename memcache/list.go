@@ -0,0 +1,42 @@
+package memcache
+
+// An intrusive doubly linked list of entries, most recently used first, with
+// their total cost.
+type list[K comparable, V any] struct {
+	root entry[K, V]
+	len  int
+	cost int64
+}
+
+func (me *list[K, V]) init() {
+	me.root.next = &me.root
+	me.root.prev = &me.root
+}
+
+func (me *list[K, V]) pushFront(e *entry[K, V]) {
+	e.list = me
+	e.prev = &me.root
+	e.next = me.root.next
+	e.prev.next = e
+	e.next.prev = e
+	me.len++
+	me.cost += e.cost
+}
+
+func (me *list[K, V]) remove(e *entry[K, V]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.next = nil
+	e.prev = nil
+	e.list = nil
+	me.len--
+	me.cost -= e.cost
+}
+
+// Returns the least recently used entry, or nil.
+func (me *list[K, V]) back() *entry[K, V] {
+	if me.len == 0 {
+		return nil
+	}
+	return me.root.prev
+}
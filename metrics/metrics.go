@@ -0,0 +1,184 @@
+// Package metrics provides counters, gauges and rates that publish
+// themselves through expvar.
+package metrics
+
+import (
+	"expvar"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A named collection of metrics. It's an expvar.Var, rendering as a map.
+type Set struct {
+	m    expvar.Map
+	mu   sync.Mutex
+	vars map[string]snapshotter
+}
+
+type snapshotter interface {
+	expvar.Var
+	snapshot() float64
+}
+
+var _ expvar.Var = (*Set)(nil)
+
+// Returns a new Set, published to expvar under name unless it's empty.
+// Publishing a name twice panics, as with expvar.Publish.
+func NewSet(name string) *Set {
+	me := &Set{vars: make(map[string]snapshotter)}
+	me.m.Init()
+	if name != "" {
+		expvar.Publish(name, me)
+	}
+	return me
+}
+
+func (me *Set) String() string {
+	return me.m.String()
+}
+
+// Returns the existing metric with name, or adds the one from new. Panics if
+// the existing metric is of a different kind.
+func (me *Set) getOrAdd(name string, new func() snapshotter) snapshotter {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if v, ok := me.vars[name]; ok {
+		return v
+	}
+	v := new()
+	me.vars[name] = v
+	me.m.Set(name, v)
+	return v
+}
+
+func (me *Set) Counter(name string) *Counter {
+	return me.getOrAdd(name, func() snapshotter { return new(Counter) }).(*Counter)
+}
+
+func (me *Set) Gauge(name string) *Gauge {
+	return me.getOrAdd(name, func() snapshotter { return new(Gauge) }).(*Gauge)
+}
+
+// Returns the EWMA with name, creating it with the given window if it
+// doesn't exist.
+func (me *Set) EWMA(name string, window time.Duration) *EWMA {
+	return me.getOrAdd(name, func() snapshotter { return NewEWMA(window) }).(*EWMA)
+}
+
+// Returns the current value of every metric in the Set.
+func (me *Set) Snapshot() map[string]float64 {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	ret := make(map[string]float64, len(me.vars))
+	for name, v := range me.vars {
+		ret[name] = v.snapshot()
+	}
+	return ret
+}
+
+// A monotonically increasing count.
+type Counter struct {
+	v int64
+}
+
+func (me *Counter) Add(n int64) {
+	if n < 0 {
+		panic(n)
+	}
+	atomic.AddInt64(&me.v, n)
+}
+
+func (me *Counter) Inc() {
+	me.Add(1)
+}
+
+func (me *Counter) Value() int64 {
+	return atomic.LoadInt64(&me.v)
+}
+
+func (me *Counter) String() string {
+	return strconv.FormatInt(me.Value(), 10)
+}
+
+func (me *Counter) snapshot() float64 {
+	return float64(me.Value())
+}
+
+// A value that can go up and down.
+type Gauge struct {
+	v int64
+}
+
+func (me *Gauge) Set(v int64) {
+	atomic.StoreInt64(&me.v, v)
+}
+
+func (me *Gauge) Add(delta int64) {
+	atomic.AddInt64(&me.v, delta)
+}
+
+func (me *Gauge) Value() int64 {
+	return atomic.LoadInt64(&me.v)
+}
+
+func (me *Gauge) String() string {
+	return strconv.FormatInt(me.Value(), 10)
+}
+
+func (me *Gauge) snapshot() float64 {
+	return float64(me.Value())
+}
+
+// An exponentially weighted moving average of the rate of events per
+// second. Events older than the window have a weight of 1/e.
+type EWMA struct {
+	window float64 // Seconds
+	mu     sync.Mutex
+	// Decayed sum of events as of last.
+	sum  float64
+	last time.Time
+	now  func() time.Time
+}
+
+func NewEWMA(window time.Duration) *EWMA {
+	return &EWMA{
+		window: window.Seconds(),
+		now:    time.Now,
+	}
+}
+
+// Decays sum to now. Must be called with mu held.
+func (me *EWMA) decay() {
+	now := me.now()
+	if !me.last.IsZero() {
+		me.sum *= math.Exp(-now.Sub(me.last).Seconds() / me.window)
+	}
+	me.last = now
+}
+
+// Records n events.
+func (me *EWMA) Mark(n float64) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.decay()
+	me.sum += n
+}
+
+// Returns events per second.
+func (me *EWMA) Rate() float64 {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.decay()
+	return me.sum / me.window
+}
+
+func (me *EWMA) String() string {
+	return strconv.FormatFloat(me.Rate(), 'g', -1, 64)
+}
+
+func (me *EWMA) snapshot() float64 {
+	return me.Rate()
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet(t *testing.T) {
+	s := NewSet("")
+	s.Counter("requests").Inc()
+	s.Counter("requests").Add(2)
+	s.Gauge("open").Set(5)
+	s.Gauge("open").Add(-1)
+	assert.Panics(t, func() { s.Counter("requests").Add(-1) })
+	assert.Panics(t, func() { s.Gauge("requests") })
+	var m map[string]float64
+	require.NoError(t, json.Unmarshal([]byte(s.String()), &m))
+	assert.Equal(t, map[string]float64{"requests": 3, "open": 4}, m)
+	assert.Equal(t, m, s.Snapshot())
+}
+
+func TestEWMA(t *testing.T) {
+	e := NewEWMA(time.Second)
+	now := time.Unix(0, 0)
+	e.now = func() time.Time { return now }
+	// A steady 10 events per second converges on a rate of 10.
+	for i := 0; i < 100; i++ {
+		now = now.Add(100 * time.Millisecond)
+		e.Mark(1)
+	}
+	assert.InDelta(t, 10, e.Rate(), 0.6)
+	// After a window of nothing, it's decayed by 1/e.
+	r := e.Rate()
+	now = now.Add(time.Second)
+	assert.InDelta(t, r/math.E, e.Rate(), 1e-9)
+}
@@ -12,7 +12,11 @@ func (t Type) String() string {
 }
 
 func (t *Type) FromString(s string) {
-	ss := strings.SplitN(s, "/", 1)
+	ss := strings.SplitN(s, "/", 2)
 	t.Class = ss[0]
-	t.Specific = ss[1]
+	if len(ss) == 2 {
+		t.Specific = ss[1]
+	} else {
+		t.Specific = ""
+	}
 }
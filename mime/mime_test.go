@@ -0,0 +1,41 @@
+package mime
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeFromString(t *testing.T) {
+	var typ Type
+	typ.FromString("text/html")
+	assert.Equal(t, Type{"text", "html"}, typ)
+	typ.FromString("*")
+	assert.Equal(t, Type{"*", ""}, typ)
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, "text/html; charset=utf-8", Detect("index.HTML", nil))
+	assert.Equal(t, "image/png", Detect("noext", []byte("\x89PNG\x0d\x0a\x1a\x0a")))
+	assert.Equal(t, "text/plain; charset=utf-8", Detect("noext", []byte("hello")))
+	typ, err := DetectReaderAt("noext", strings.NewReader("%PDF-1.4"))
+	assert.NoError(t, err)
+	assert.Equal(t, "application/pdf", typ)
+	RegisterExtension(".Custom", "application/x-custom")
+	assert.Equal(t, "application/x-custom", TypeByExtension(".custom"))
+}
+
+func TestWithCharset(t *testing.T) {
+	for _, _case := range []struct {
+		in, out string
+	}{
+		{"text/plain", "text/plain; charset=utf-8"},
+		{"text/plain; charset=latin1", "text/plain; charset=latin1"},
+		{"image/svg+xml", "image/svg+xml; charset=utf-8"},
+		{"image/png", "image/png"},
+		{"not a type/", "not a type/"},
+	} {
+		assert.Equal(t, _case.out, WithCharset(_case.in, "utf-8"), _case.in)
+	}
+}
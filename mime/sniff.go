@@ -0,0 +1,107 @@
+package mime
+
+import (
+	"io"
+	stdmime "mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+var (
+	extensionsMu sync.RWMutex
+	// Types that take precedence over the standard library's, which depend on
+	// the system.
+	extensions = map[string]string{
+		".css":     "text/css; charset=utf-8",
+		".gif":     "image/gif",
+		".htm":     "text/html; charset=utf-8",
+		".html":    "text/html; charset=utf-8",
+		".jpeg":    "image/jpeg",
+		".jpg":     "image/jpeg",
+		".js":      "text/javascript; charset=utf-8",
+		".json":    "application/json",
+		".m3u8":    "application/vnd.apple.mpegurl",
+		".md":      "text/markdown; charset=utf-8",
+		".mkv":     "video/x-matroska",
+		".mp3":     "audio/mpeg",
+		".mp4":     "video/mp4",
+		".pdf":     "application/pdf",
+		".png":     "image/png",
+		".svg":     "image/svg+xml",
+		".torrent": "application/x-bittorrent",
+		".txt":     "text/plain; charset=utf-8",
+		".wasm":    "application/wasm",
+		".webm":    "video/webm",
+		".webp":    "image/webp",
+		".xml":     "text/xml; charset=utf-8",
+	}
+)
+
+// Sets the type for files with the extension ext, which includes the leading
+// dot, overriding any existing type.
+func RegisterExtension(ext, typ string) {
+	extensionsMu.Lock()
+	defer extensionsMu.Unlock()
+	extensions[strings.ToLower(ext)] = typ
+}
+
+// Returns the type for the extension ext, which includes the leading dot, or
+// "" if it's unknown.
+func TypeByExtension(ext string) string {
+	extensionsMu.RLock()
+	typ, ok := extensions[strings.ToLower(ext)]
+	extensionsMu.RUnlock()
+	if ok {
+		return typ
+	}
+	return stdmime.TypeByExtension(ext)
+}
+
+// The most content http.DetectContentType considers.
+const sniffLen = 512
+
+// Returns the type of the content, from the extension of name if it's known,
+// otherwise by sniffing the start of content.
+func Detect(name string, content []byte) string {
+	if typ := TypeByExtension(path.Ext(name)); typ != "" {
+		return typ
+	}
+	return http.DetectContentType(content)
+}
+
+// Like Detect, reading what content is needed from r.
+func DetectReaderAt(name string, r io.ReaderAt) (string, error) {
+	if typ := TypeByExtension(path.Ext(name)); typ != "" {
+		return typ, nil
+	}
+	var buf [sniffLen]byte
+	n, err := r.ReadAt(buf[:], 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// Returns typ with a charset parameter, if it's textual and doesn't already
+// specify one.
+func WithCharset(typ, charset string) string {
+	mediaType, params, err := stdmime.ParseMediaType(typ)
+	if err != nil || params["charset"] != "" || !isText(mediaType) {
+		return typ
+	}
+	params["charset"] = charset
+	return stdmime.FormatMediaType(mediaType, params)
+}
+
+func isText(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+xml")
+}
@@ -15,6 +15,82 @@ func (me MonotonicTime) Sub(other MonotonicTime) time.Duration {
 	return me.skewedStdTime.Sub(other.skewedStdTime)
 }
 
+func (me MonotonicTime) Add(d time.Duration) MonotonicTime {
+	return MonotonicTime{me.skewedStdTime.Add(d)}
+}
+
+func (me MonotonicTime) Before(other MonotonicTime) bool {
+	return me.skewedStdTime.Before(other.skewedStdTime)
+}
+
+func (me MonotonicTime) After(other MonotonicTime) bool {
+	return me.skewedStdTime.After(other.skewedStdTime)
+}
+
+func (me MonotonicTime) Equal(other MonotonicTime) bool {
+	return me.skewedStdTime.Equal(other.skewedStdTime)
+}
+
+func (me MonotonicTime) IsZero() bool {
+	return me.skewedStdTime.IsZero()
+}
+
+// Returns the wall time corresponding to me, given the skew accumulated from
+// the wall clock going backwards so far. If the wall clock goes backwards
+// again, the same MonotonicTime will convert to an earlier wall time.
+func (me MonotonicTime) Wall() time.Time {
+	if me.IsZero() {
+		return time.Time{}
+	}
+	return me.skewedStdTime.Add(-MonotonicSkew()).Round(0)
+}
+
+// The inverse of MonotonicTime.Wall, using the current skew.
+func MonotonicFromWall(t time.Time) MonotonicTime {
+	if t.IsZero() {
+		return MonotonicTime{}
+	}
+	return MonotonicTime{t.Add(MonotonicSkew())}
+}
+
+// Returns how far monotonic time is ahead of the wall clock, due to the wall
+// clock going backwards.
+func MonotonicSkew() time.Duration {
+	monotonicMu.Lock()
+	defer monotonicMu.Unlock()
+	return monotonicSkew
+}
+
+// Encodes the wall time, see MonotonicTime.Wall. Decoding converts it back
+// with the skew at that time, so the encoding can be persisted or sent to
+// other processes.
+func (me MonotonicTime) MarshalBinary() ([]byte, error) {
+	return me.Wall().MarshalBinary()
+}
+
+func (me *MonotonicTime) UnmarshalBinary(b []byte) error {
+	var t time.Time
+	if err := t.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	*me = MonotonicFromWall(t)
+	return nil
+}
+
+// Encodes the wall time, like MarshalBinary.
+func (me MonotonicTime) MarshalJSON() ([]byte, error) {
+	return me.Wall().MarshalJSON()
+}
+
+func (me *MonotonicTime) UnmarshalJSON(b []byte) error {
+	var t time.Time
+	if err := t.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	*me = MonotonicFromWall(t)
+	return nil
+}
+
 var (
 	stdNowFunc    = time.Now
 	monotonicMu   sync.Mutex
@@ -1,10 +1,12 @@
 package missinggo
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Calls suite with the used time.Now function used by MonotonicNow replaced
@@ -12,11 +14,15 @@ import (
 func withCustomStdNow(stdNow func() time.Time, suite func()) {
 	oldStdNow := stdNowFunc
 	oldSkew := monotonicSkew
+	oldLastStdNow := lastStdNow
 	defer func() {
 		stdNowFunc = oldStdNow
 		monotonicSkew = oldSkew
+		lastStdNow = oldLastStdNow
 	}()
 	stdNowFunc = stdNow
+	monotonicSkew = 0
+	lastStdNow = time.Time{}
 	suite()
 }
 
@@ -50,3 +56,39 @@ func TestMonotonicTime(t *testing.T) {
 	// reasonable bounds.
 	assert.True(t, MonotonicSince(started) >= 0 && MonotonicSince(started) < time.Second)
 }
+
+func TestMonotonicTimeWall(t *testing.T) {
+	withCustomStdNow(stdNowSeqFunc([]int64{10, 5}), func() {
+		i0 := MonotonicNow()
+		i1 := MonotonicNow()
+		// The clock went back 5, so that's the skew now.
+		assert.EqualValues(t, 5, MonotonicSkew())
+		assert.True(t, i1.Equal(i0))
+		assert.Equal(t, time.Unix(0, 5), i0.Wall())
+		assert.True(t, MonotonicFromWall(time.Unix(0, 5)).Equal(i0))
+		i2 := i1.Add(3)
+		assert.True(t, i2.After(i1))
+		assert.True(t, i1.Before(i2))
+		assert.EqualValues(t, 3, i2.Sub(i1))
+	})
+	assert.True(t, MonotonicTime{}.Wall().IsZero())
+	assert.True(t, MonotonicFromWall(time.Time{}).IsZero())
+}
+
+func TestMonotonicTimeMarshal(t *testing.T) {
+	now := MonotonicNow()
+	b, err := now.MarshalBinary()
+	require.NoError(t, err)
+	var fromBinary MonotonicTime
+	require.NoError(t, fromBinary.UnmarshalBinary(b))
+	assert.True(t, now.Equal(fromBinary))
+
+	type s struct {
+		T MonotonicTime
+	}
+	b, err = json.Marshal(s{now})
+	require.NoError(t, err)
+	var fromJSON s
+	require.NoError(t, json.Unmarshal(b, &fromJSON))
+	assert.True(t, now.Equal(fromJSON.T))
+}
@@ -0,0 +1,131 @@
+// Package orderedset provides a sorted set, for sparse keys such as
+// timestamps or offsets, that supports finding the neighbours of a value.
+package orderedset
+
+import (
+	"github.com/google/btree"
+)
+
+// A set ordered by a less func. The zero value isn't usable.
+type Set[T any] struct {
+	bt   *btree.BTree
+	less func(l, r T) bool
+}
+
+type item[T any] struct {
+	value T
+	less  func(l, r T) bool
+}
+
+func (me item[T]) Less(right btree.Item) bool {
+	return me.less(me.value, right.(item[T]).value)
+}
+
+func New[T any](less func(l, r T) bool) *Set[T] {
+	return &Set[T]{
+		bt:   btree.New(32),
+		less: less,
+	}
+}
+
+func (me *Set[T]) item(v T) item[T] {
+	return item[T]{v, me.less}
+}
+
+func (me *Set[T]) equal(l, r T) bool {
+	return !me.less(l, r) && !me.less(r, l)
+}
+
+// Returns true if v wasn't already present. An existing equal value is
+// replaced.
+func (me *Set[T]) Add(v T) bool {
+	return me.bt.ReplaceOrInsert(me.item(v)) == nil
+}
+
+// Returns true if v was present.
+func (me *Set[T]) Delete(v T) bool {
+	return me.bt.Delete(me.item(v)) != nil
+}
+
+func (me *Set[T]) Contains(v T) bool {
+	return me.bt.Has(me.item(v))
+}
+
+func (me *Set[T]) Len() int {
+	return me.bt.Len()
+}
+
+func unwrap[T any](i btree.Item) (v T, ok bool) {
+	if i == nil {
+		return
+	}
+	return i.(item[T]).value, true
+}
+
+func (me *Set[T]) Min() (T, bool) {
+	return unwrap[T](me.bt.Min())
+}
+
+func (me *Set[T]) Max() (T, bool) {
+	return unwrap[T](me.bt.Max())
+}
+
+// Returns the least value greater than v.
+func (me *Set[T]) Next(v T) (ret T, ok bool) {
+	me.bt.AscendGreaterOrEqual(me.item(v), func(i btree.Item) bool {
+		ret = i.(item[T]).value
+		ok = !me.equal(ret, v)
+		return !ok
+	})
+	if !ok {
+		var zero T
+		ret = zero
+	}
+	return
+}
+
+// Returns the greatest value less than v.
+func (me *Set[T]) Prev(v T) (ret T, ok bool) {
+	me.bt.DescendLessOrEqual(me.item(v), func(i btree.Item) bool {
+		ret = i.(item[T]).value
+		ok = !me.equal(ret, v)
+		return !ok
+	})
+	if !ok {
+		var zero T
+		ret = zero
+	}
+	return
+}
+
+// Calls f with the values in ascending order until it returns false.
+func (me *Set[T]) Iter(f func(T) bool) {
+	me.bt.Ascend(func(i btree.Item) bool {
+		return f(i.(item[T]).value)
+	})
+}
+
+// Calls f with the values in descending order until it returns false.
+func (me *Set[T]) Reverse(f func(T) bool) {
+	me.bt.Descend(func(i btree.Item) bool {
+		return f(i.(item[T]).value)
+	})
+}
+
+// Calls f in ascending order with the values from lo up to, but excluding,
+// hi, until it returns false.
+func (me *Set[T]) Range(lo, hi T, f func(T) bool) {
+	me.bt.AscendRange(me.item(lo), me.item(hi), func(i btree.Item) bool {
+		return f(i.(item[T]).value)
+	})
+}
+
+// Returns the values in ascending order.
+func (me *Set[T]) Slice() (ret []T) {
+	ret = make([]T, 0, me.Len())
+	me.Iter(func(v T) bool {
+		ret = append(ret, v)
+		return true
+	})
+	return
+}
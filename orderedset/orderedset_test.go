@@ -0,0 +1,89 @@
+package orderedset
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intLess(l, r int) bool { return l < r }
+
+func TestSet(t *testing.T) {
+	s := New(intLess)
+	_, ok := s.Min()
+	assert.False(t, ok)
+	for _, v := range []int{5, 1, 9, 3} {
+		assert.True(t, s.Add(v))
+	}
+	assert.False(t, s.Add(3))
+	assert.Equal(t, 4, s.Len())
+	assert.True(t, s.Contains(9))
+	assert.False(t, s.Contains(4))
+	min, _ := s.Min()
+	max, _ := s.Max()
+	assert.Equal(t, 1, min)
+	assert.Equal(t, 9, max)
+	assert.Equal(t, []int{1, 3, 5, 9}, s.Slice())
+
+	for _, _case := range []struct {
+		v          int
+		next, prev int
+		nok, pok   bool
+	}{
+		{0, 1, 0, true, false},
+		{1, 3, 0, true, false},
+		{4, 5, 3, true, true},
+		{5, 9, 3, true, true},
+		{9, 0, 5, false, true},
+		{10, 0, 9, false, true},
+	} {
+		next, ok := s.Next(_case.v)
+		assert.Equal(t, _case.nok, ok, "%v", _case)
+		assert.Equal(t, _case.next, next, "%v", _case)
+		prev, ok := s.Prev(_case.v)
+		assert.Equal(t, _case.pok, ok, "%v", _case)
+		assert.Equal(t, _case.prev, prev, "%v", _case)
+	}
+
+	var got []int
+	s.Range(3, 9, func(v int) bool {
+		got = append(got, v)
+		return true
+	})
+	assert.Equal(t, []int{3, 5}, got)
+	got = nil
+	s.Reverse(func(v int) bool {
+		got = append(got, v)
+		return len(got) < 2
+	})
+	assert.Equal(t, []int{9, 5}, got)
+
+	assert.True(t, s.Delete(5))
+	assert.False(t, s.Delete(5))
+	next, _ := s.Next(3)
+	assert.Equal(t, 9, next)
+}
+
+func TestSetRandom(t *testing.T) {
+	s := New(intLess)
+	m := make(map[int]bool)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		v := r.Intn(1000)
+		if r.Intn(3) == 0 {
+			assert.Equal(t, m[v], s.Delete(v))
+			delete(m, v)
+		} else {
+			assert.Equal(t, !m[v], s.Add(v))
+			m[v] = true
+		}
+	}
+	var want []int
+	for v := range m {
+		want = append(want, v)
+	}
+	sort.Ints(want)
+	assert.Equal(t, want, s.Slice())
+}
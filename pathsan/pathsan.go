@@ -0,0 +1,166 @@
+// Package pathsan maps arbitrary strings to safe relative slash-separated
+// paths and filenames.
+package pathsan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+// Returns p as a relative slash-separated path, with no "." or ".."
+// components, so it can't refer outside the directory it's joined to. The
+// empty path stays empty, and refers to that directory.
+func Clean(p string) string {
+	if p == "" {
+		return ""
+	}
+	p = path.Clean("/" + p)
+	return p[1:]
+}
+
+type Options struct {
+	// Also escape what Windows reserves, regardless of the current OS.
+	Windows bool
+	// Components longer than this many bytes after escaping are shortened,
+	// which can't be reversed. Zero means no limit.
+	MaxComponentLen int
+}
+
+// Suits the current OS, and common filesystem limits.
+var Default = Options{
+	Windows:         runtime.GOOS == "windows",
+	MaxComponentLen: 255,
+}
+
+var windowsDeviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+func (me Options) reserved(c byte) bool {
+	switch {
+	case c < 0x20, c == 0x7f, c == '%', c == '/':
+		return true
+	case me.Windows:
+		return strings.IndexByte(`<>:"\|?*`, c) >= 0
+	default:
+		return false
+	}
+}
+
+// Escapes s so that it's usable as a single path component, using
+// percent-encoding that UnescapeComponent reverses, unless it had to be
+// shortened.
+func (me Options) EscapeComponent(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		// Windows ignores trailing dots and spaces.
+		trailing := me.Windows && (c == '.' || c == ' ') && strings.Trim(s[i:], ". ") == ""
+		if me.reserved(c) || trailing {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	ret := b.String()
+	switch {
+	case ret == "":
+	case ret == "." || ret == "..":
+		ret = strings.Repeat("%2E", len(ret))
+	case me.Windows && isWindowsDeviceName(ret):
+		ret = fmt.Sprintf("%%%02X", ret[0]) + ret[1:]
+	}
+	return me.shorten(ret)
+}
+
+// Device names are reserved with any extension.
+func isWindowsDeviceName(s string) bool {
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = s[:i]
+	}
+	return windowsDeviceNames[strings.ToUpper(s)]
+}
+
+// Truncates s to MaxComponentLen, replacing the end with a hash of the whole,
+// so distinct long components stay distinct.
+func (me Options) shorten(s string) string {
+	if me.MaxComponentLen <= 0 || len(s) <= me.MaxComponentLen {
+		return s
+	}
+	sum := sha256.Sum256([]byte(s))
+	suffix := "~" + hex.EncodeToString(sum[:8])
+	n := me.MaxComponentLen - len(suffix)
+	if n < 0 {
+		n = 0
+	}
+	// Don't split an escape or a rune.
+	if i := strings.LastIndexByte(s[:n], '%'); i >= 0 && i+3 > n {
+		n = i
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + suffix
+}
+
+// Cleans p, and escapes each component.
+func (me Options) EscapePath(p string) string {
+	p = Clean(p)
+	if p == "" {
+		return ""
+	}
+	cs := strings.Split(p, "/")
+	for i, c := range cs {
+		cs[i] = me.EscapeComponent(c)
+	}
+	return strings.Join(cs, "/")
+}
+
+// Reverses EscapeComponent.
+func UnescapeComponent(s string) (string, error) {
+	if strings.IndexByte(s, '%') < 0 {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("truncated escape in %q", s)
+		}
+		v, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("bad escape in %q: %w", s, err)
+		}
+		b.WriteByte(v[0])
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// Reverses EscapePath.
+func UnescapePath(p string) (string, error) {
+	if p == "" {
+		return "", nil
+	}
+	cs := strings.Split(p, "/")
+	for i, c := range cs {
+		var err error
+		cs[i], err = UnescapeComponent(c)
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(cs, "/"), nil
+}
@@ -0,0 +1,75 @@
+package pathsan
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClean(t *testing.T) {
+	for in, out := range map[string]string{
+		"":          "",
+		"/":         "",
+		"a/../../b": "b",
+		"/a//b/":    "a/b",
+		"./a":       "a",
+	} {
+		assert.Equal(t, out, Clean(in), in)
+	}
+}
+
+func TestEscapeComponent(t *testing.T) {
+	unix := Options{}
+	windows := Options{Windows: true}
+	for _, _case := range []struct {
+		opts    Options
+		in, out string
+	}{
+		{unix, "a:b", "a:b"},
+		{windows, "a:b", "a%3Ab"},
+		{unix, "100%", "100%25"},
+		{unix, "a/b", "a%2Fb"},
+		{unix, "..", "%2E%2E"},
+		{unix, "tab\t", "tab%09"},
+		{windows, "con.txt", "%63on.txt"},
+		{windows, "console", "console"},
+		{windows, "end. .", "end%2E%20%2E"},
+		{unix, "end.", "end."},
+	} {
+		out := _case.opts.EscapeComponent(_case.in)
+		assert.Equal(t, _case.out, out, _case.in)
+		un, err := UnescapeComponent(out)
+		require.NoError(t, err)
+		assert.Equal(t, _case.in, un)
+	}
+	_, err := UnescapeComponent("a%2")
+	assert.Error(t, err)
+	_, err = UnescapeComponent("a%zz")
+	assert.Error(t, err)
+}
+
+func TestEscapePathRoundTrip(t *testing.T) {
+	p := `dir/../x:y/100%/"q"`
+	e := Options{Windows: true}.EscapePath(p)
+	assert.Equal(t, "x%3Ay/100%25/%22q%22", e)
+	un, err := UnescapePath(e)
+	require.NoError(t, err)
+	assert.Equal(t, Clean(p), un)
+}
+
+func TestShorten(t *testing.T) {
+	opts := Options{MaxComponentLen: 32}
+	long := strings.Repeat("é", 20)
+	a := opts.EscapeComponent(long)
+	b := opts.EscapeComponent(long + "x")
+	assert.True(t, len(a) <= 32)
+	assert.NotEqual(t, a, b)
+	// Runes aren't split.
+	assert.True(t, utf8.ValidString(a))
+	// Escapes aren't split.
+	a = opts.EscapeComponent(strings.Repeat("%", 20))
+	assert.Equal(t, strings.Repeat("%25", 5)+"~", a[:16])
+}
@@ -1,35 +1,93 @@
 // Package pproffd is for detecting resource leaks due to unclosed handles.
+// Tracking is off by default, and is enabled by setting PPROFFD in the
+// environment, or calling Enable. Only handles wrapped while enabled are
+// tracked.
 package pproffd
 
 import (
 	"io"
 	"net"
 	"os"
+	"runtime"
 	"runtime/pprof"
+	"sort"
+	"sync"
+	"sync/atomic"
 )
 
-const enabled = false
+var (
+	enabled int32
+	p       *pprof.Profile
+	newP    sync.Once
 
-var p *pprof.Profile
+	mu     sync.Mutex
+	nextID int64
+	open   = make(map[*fd][]uintptr)
+)
 
 func init() {
-	if enabled {
-		p = pprof.NewProfile("fds")
+	if os.Getenv("PPROFFD") != "" {
+		Enable()
 	}
 }
 
-type fd int
+// Starts tracking handles as they're wrapped, in the "fds" pprof profile and
+// for OpenHandles.
+func Enable() {
+	newP.Do(func() {
+		p = pprof.NewProfile("fds")
+	})
+	atomic.StoreInt32(&enabled, 1)
+}
+
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) != 0
+}
+
+type fd int64
 
 func (me *fd) Closed() {
 	p.Remove(me)
+	mu.Lock()
+	delete(open, me)
+	mu.Unlock()
 }
 
 func add(skip int) (ret *fd) {
+	var pcs [32]uintptr
+	stack := append([]uintptr(nil), pcs[:runtime.Callers(skip+2, pcs[:])]...)
+	mu.Lock()
+	nextID++
 	ret = new(fd)
+	*ret = fd(nextID)
+	open[ret] = stack
+	mu.Unlock()
 	p.Add(ret, skip+2)
 	return
 }
 
+// A tracked handle that hasn't been closed.
+type OpenHandle struct {
+	// Unique to the handle, and increasing in the order they're wrapped.
+	ID int64
+	// Program counters of where the handle was wrapped, as from
+	// runtime.Callers.
+	Stack []uintptr
+}
+
+// Returns the tracked handles that haven't been closed, oldest first.
+func OpenHandles() (ret []OpenHandle) {
+	mu.Lock()
+	for fd, stack := range open {
+		ret = append(ret, OpenHandle{int64(*fd), stack})
+	}
+	mu.Unlock()
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ID < ret[j].ID
+	})
+	return
+}
+
 type closeWrapper struct {
 	fd *fd
 	c  io.Closer
@@ -58,7 +116,7 @@ func (me wrappedNetConn) Close() error {
 
 // Tracks a net.Conn until Close() is explicitly called.
 func WrapNetConn(nc net.Conn) net.Conn {
-	if !enabled {
+	if !Enabled() {
 		return nc
 	}
 	if nc == nil {
@@ -90,8 +148,25 @@ func (me wrappedOSFile) Close() error {
 }
 
 func WrapOSFile(f *os.File) OSFile {
-	if !enabled {
+	if !Enabled() {
 		return f
 	}
 	return &wrappedOSFile{f, newCloseWrapper(f)}
 }
+
+type wrappedFile struct {
+	OSFile
+	closeWrapper
+}
+
+func (me wrappedFile) Close() error {
+	return me.closeWrapper.Close()
+}
+
+// Like WrapOSFile, for files that aren't backed by an *os.File.
+func WrapFile(f OSFile) OSFile {
+	if !Enabled() {
+		return f
+	}
+	return &wrappedFile{f, newCloseWrapper(f)}
+}
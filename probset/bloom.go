@@ -0,0 +1,128 @@
+// Package probset provides space efficient probabilistic sets, which can
+// report false positives, but never false negatives.
+package probset
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+var errIncompatible = errors.New("filters have different parameters")
+
+// A Bloom filter. Items can't be removed.
+type Bloom struct {
+	bits []uint64
+	// The number of bits, and hash functions.
+	m uint64
+	k uint32
+}
+
+// Returns a Bloom filter sized to hold n items with the given false positive
+// rate.
+func NewBloom(n int, fpRate float64) *Bloom {
+	if n < 1 {
+		n = 1
+	}
+	m := math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+	return newBloom(uint64(m), uint32(k))
+}
+
+func newBloom(m uint64, k uint32) *Bloom {
+	if m == 0 {
+		m = 1
+	}
+	return &Bloom{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// Two independent hashes, from which the k are derived, per Kirsch and
+// Mitzenmacher.
+func bloomHashes(b []byte) (h1, h2 uint64) {
+	h := fnv.New128a()
+	h.Write(b)
+	var sum [16]byte
+	h.Sum(sum[:0])
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+func (me *Bloom) Add(item []byte) {
+	h1, h2 := bloomHashes(item)
+	for i := uint64(0); i < uint64(me.k); i++ {
+		bit := (h1 + i*h2) % me.m
+		me.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Returns false if item was definitely not added.
+func (me *Bloom) Test(item []byte) bool {
+	h1, h2 := bloomHashes(item)
+	for i := uint64(0); i < uint64(me.k); i++ {
+		bit := (h1 + i*h2) % me.m
+		if me.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Adds the items of other, which must have been created with the same
+// parameters.
+func (me *Bloom) Union(other *Bloom) error {
+	if me.m != other.m || me.k != other.k {
+		return errIncompatible
+	}
+	for i, w := range other.bits {
+		me.bits[i] |= w
+	}
+	return nil
+}
+
+// Estimates the number of items added, from the proportion of bits set.
+func (me *Bloom) ApproximateCount() int {
+	set := 0
+	for _, w := range me.bits {
+		set += bits.OnesCount64(w)
+	}
+	if uint64(set) >= me.m {
+		return math.MaxInt
+	}
+	m, k := float64(me.m), float64(me.k)
+	return int(math.Round(-m / k * math.Log(1-float64(set)/m)))
+}
+
+func (me *Bloom) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 12+8*len(me.bits))
+	binary.BigEndian.PutUint64(b, me.m)
+	binary.BigEndian.PutUint32(b[8:], me.k)
+	for i, w := range me.bits {
+		binary.BigEndian.PutUint64(b[12+8*i:], w)
+	}
+	return b, nil
+}
+
+func (me *Bloom) UnmarshalBinary(b []byte) error {
+	if len(b) < 12 {
+		return errors.New("bloom filter too short")
+	}
+	m := binary.BigEndian.Uint64(b)
+	k := binary.BigEndian.Uint32(b[8:])
+	b = b[12:]
+	if m == 0 || uint64(len(b)) != (m+63)/64*8 {
+		return errors.New("bloom filter length mismatch")
+	}
+	*me = *newBloom(m, k)
+	for i := range me.bits {
+		me.bits[i] = binary.BigEndian.Uint64(b[8*i:])
+	}
+	return nil
+}
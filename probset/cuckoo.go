@@ -0,0 +1,233 @@
+package probset
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"math/rand"
+)
+
+var ErrFull = errors.New("filter is full")
+
+const (
+	bucketSize = 4
+	maxKicks   = 500
+)
+
+// A cuckoo filter. Unlike Bloom, items can be deleted, but only those that
+// were added, and insertion fails when the filter is full.
+type Cuckoo struct {
+	// Each bucket is bucketSize consecutive fingerprints. Zero is empty.
+	fps     []uint32
+	mask    uint64
+	fpBits  uint
+	count   int
+	victim  uint32
+	victimI uint64
+}
+
+// Returns a cuckoo filter sized to hold n items with about the given false
+// positive rate.
+func NewCuckoo(n int, fpRate float64) *Cuckoo {
+	fpBits := uint(math.Ceil(math.Log2(2 * bucketSize / fpRate)))
+	if fpBits < 4 {
+		fpBits = 4
+	}
+	if fpBits > 32 {
+		fpBits = 32
+	}
+	// Cuckoo filters fill to about 95% with 4-way buckets.
+	buckets := uint64(math.Ceil(float64(n) / bucketSize / 0.95))
+	if buckets < 1 {
+		buckets = 1
+	}
+	return newCuckoo(uint64(1)<<bits.Len64(buckets-1), fpBits)
+}
+
+func newCuckoo(buckets uint64, fpBits uint) *Cuckoo {
+	return &Cuckoo{
+		fps:    make([]uint32, buckets*bucketSize),
+		mask:   buckets - 1,
+		fpBits: fpBits,
+	}
+}
+
+func (me *Cuckoo) indexes(item []byte) (fp uint32, i1, i2 uint64) {
+	h := fnv.New64a()
+	h.Write(item)
+	sum := h.Sum64()
+	fp = uint32(sum>>32) & (1<<me.fpBits - 1)
+	if fp == 0 {
+		fp = 1
+	}
+	i1 = sum & me.mask
+	return fp, i1, me.altIndex(i1, fp)
+}
+
+// The other bucket for fp, from either of its buckets.
+func (me *Cuckoo) altIndex(i uint64, fp uint32) uint64 {
+	return (i ^ uint64(fp)*0x5bd1e995) & me.mask
+}
+
+func (me *Cuckoo) bucket(i uint64) []uint32 {
+	return me.fps[i*bucketSize : (i+1)*bucketSize]
+}
+
+func (me *Cuckoo) insertInto(i uint64, fp uint32) bool {
+	b := me.bucket(i)
+	for j := range b {
+		if b[j] == 0 {
+			b[j] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// Adds item. If the filter is full, ErrFull is returned and the filter is
+// unchanged.
+func (me *Cuckoo) Add(item []byte) error {
+	fp, i1, i2 := me.indexes(item)
+	return me.add(fp, i1, i2)
+}
+
+func (me *Cuckoo) add(fp uint32, i1, i2 uint64) error {
+	if me.victim != 0 {
+		return ErrFull
+	}
+	me.count++
+	if me.insertInto(i1, fp) || me.insertInto(i2, fp) {
+		return nil
+	}
+	i := i1
+	if rand.Intn(2) == 0 {
+		i = i2
+	}
+	for n := 0; n < maxKicks; n++ {
+		b := me.bucket(i)
+		j := rand.Intn(bucketSize)
+		fp, b[j] = b[j], fp
+		i = me.altIndex(i, fp)
+		if me.insertInto(i, fp) {
+			return nil
+		}
+	}
+	// The last fingerprint evicted has nowhere to go. It's held aside so
+	// that it can still be found, and nothing more is accepted.
+	me.victim = fp
+	me.victimI = i
+	return nil
+}
+
+// Returns false if item is definitely not in the filter.
+func (me *Cuckoo) Test(item []byte) bool {
+	fp, i1, i2 := me.indexes(item)
+	if me.victim == fp && (me.victimI == i1 || me.victimI == i2) {
+		return true
+	}
+	for _, i := range [2]uint64{i1, i2} {
+		for _, f := range me.bucket(i) {
+			if f == fp {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Removes an item previously added. Deleting items that weren't added can
+// remove others that share their fingerprint.
+func (me *Cuckoo) Delete(item []byte) bool {
+	fp, i1, i2 := me.indexes(item)
+	if me.victim == fp && (me.victimI == i1 || me.victimI == i2) {
+		me.victim = 0
+		me.count--
+		return true
+	}
+	for _, i := range [2]uint64{i1, i2} {
+		b := me.bucket(i)
+		for j := range b {
+			if b[j] == fp {
+				b[j] = 0
+				me.count--
+				me.reinsertVictim()
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Space may have been freed for the victim.
+func (me *Cuckoo) reinsertVictim() {
+	if me.victim == 0 {
+		return
+	}
+	fp, i := me.victim, me.victimI
+	me.victim = 0
+	me.count--
+	me.add(fp, i, me.altIndex(i, fp))
+}
+
+// Returns the number of items in the filter.
+func (me *Cuckoo) Count() int {
+	return me.count
+}
+
+// Adds the items of other, which must have been created with the same
+// parameters. If the filter becomes full, ErrFull is returned and only some
+// of the items were added.
+func (me *Cuckoo) Union(other *Cuckoo) error {
+	if me.mask != other.mask || me.fpBits != other.fpBits {
+		return errIncompatible
+	}
+	for i := uint64(0); i <= other.mask; i++ {
+		for _, fp := range other.bucket(i) {
+			if fp == 0 {
+				continue
+			}
+			if err := me.add(fp, i, me.altIndex(i, fp)); err != nil {
+				return err
+			}
+		}
+	}
+	if other.victim != 0 {
+		return me.add(other.victim, other.victimI, me.altIndex(other.victimI, other.victim))
+	}
+	return nil
+}
+
+func (me *Cuckoo) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 25+4*len(me.fps))
+	binary.BigEndian.PutUint64(b, me.mask+1)
+	b[8] = byte(me.fpBits)
+	binary.BigEndian.PutUint32(b[9:], me.victim)
+	binary.BigEndian.PutUint64(b[13:], me.victimI)
+	binary.BigEndian.PutUint32(b[21:], uint32(me.count))
+	for i, fp := range me.fps {
+		binary.BigEndian.PutUint32(b[25+4*i:], fp)
+	}
+	return b, nil
+}
+
+func (me *Cuckoo) UnmarshalBinary(b []byte) error {
+	if len(b) < 25 {
+		return errors.New("cuckoo filter too short")
+	}
+	buckets := binary.BigEndian.Uint64(b)
+	fpBits := uint(b[8])
+	if buckets == 0 || buckets&(buckets-1) != 0 || fpBits == 0 || fpBits > 32 ||
+		uint64(len(b)-25) != buckets*bucketSize*4 {
+		return errors.New("cuckoo filter parameters invalid")
+	}
+	*me = *newCuckoo(buckets, fpBits)
+	me.victim = binary.BigEndian.Uint32(b[9:])
+	me.victimI = binary.BigEndian.Uint64(b[13:]) & me.mask
+	me.count = int(binary.BigEndian.Uint32(b[21:]))
+	for i := range me.fps {
+		me.fps[i] = binary.BigEndian.Uint32(b[25+4*i:])
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package probset
+
+import (
+	"encoding"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func key(prefix string, i int) []byte {
+	return []byte(fmt.Sprintf("%s%d", prefix, i))
+}
+
+// The proportion of items never added that test positive.
+func falsePositiveRate(test func([]byte) bool) float64 {
+	const n = 100000
+	fps := 0
+	for i := 0; i < n; i++ {
+		if test(key("absent", i)) {
+			fps++
+		}
+	}
+	return float64(fps) / n
+}
+
+func TestBloom(t *testing.T) {
+	const n = 10000
+	b := NewBloom(n, 0.01)
+	for i := 0; i < n; i++ {
+		b.Add(key("", i))
+	}
+	for i := 0; i < n; i++ {
+		require.True(t, b.Test(key("", i)))
+	}
+	rate := falsePositiveRate(b.Test)
+	assert.True(t, rate < 0.015, rate)
+	assert.InDelta(t, n, b.ApproximateCount(), n/50)
+}
+
+func TestBloomUnionMarshal(t *testing.T) {
+	a := NewBloom(100, 0.01)
+	b := NewBloom(100, 0.01)
+	a.Add([]byte("a"))
+	b.Add([]byte("b"))
+	require.NoError(t, a.Union(b))
+	assert.True(t, a.Test([]byte("b")))
+	assert.Error(t, a.Union(NewBloom(1000, 0.01)))
+
+	data, err := a.MarshalBinary()
+	require.NoError(t, err)
+	var c Bloom
+	require.NoError(t, c.UnmarshalBinary(data))
+	assert.Equal(t, a, &c)
+	assert.Error(t, c.UnmarshalBinary(data[:len(data)-1]))
+}
+
+func TestCuckoo(t *testing.T) {
+	const n = 10000
+	c := NewCuckoo(n, 0.001)
+	for i := 0; i < n; i++ {
+		require.NoError(t, c.Add(key("", i)))
+	}
+	assert.Equal(t, n, c.Count())
+	for i := 0; i < n; i++ {
+		require.True(t, c.Test(key("", i)))
+	}
+	rate := falsePositiveRate(c.Test)
+	assert.True(t, rate < 0.002, rate)
+	for i := 0; i < n; i += 2 {
+		require.True(t, c.Delete(key("", i)))
+	}
+	assert.Equal(t, n/2, c.Count())
+	for i := 1; i < n; i += 2 {
+		require.True(t, c.Test(key("", i)))
+	}
+}
+
+func TestCuckooFull(t *testing.T) {
+	c := NewCuckoo(8, 0.01)
+	var err error
+	added := 0
+	for ; err == nil; added++ {
+		err = c.Add(key("", added))
+	}
+	assert.Equal(t, ErrFull, err)
+	// The filter holds everything that was accepted, including the last,
+	// which was stashed.
+	added--
+	assert.Equal(t, added, c.Count())
+	for i := 0; i < added; i++ {
+		assert.True(t, c.Test(key("", i)), i)
+	}
+	require.True(t, c.Delete(key("", 0)))
+	assert.NoError(t, c.Add(key("", 0)))
+}
+
+func TestCuckooUnionMarshal(t *testing.T) {
+	a := NewCuckoo(100, 0.01)
+	b := NewCuckoo(100, 0.01)
+	require.NoError(t, a.Add([]byte("a")))
+	require.NoError(t, b.Add([]byte("b")))
+	require.NoError(t, a.Union(b))
+	assert.True(t, a.Test([]byte("b")))
+	assert.Equal(t, 2, a.Count())
+	assert.Error(t, a.Union(NewCuckoo(1000, 0.01)))
+
+	var m encoding.BinaryMarshaler = a
+	data, err := m.MarshalBinary()
+	require.NoError(t, err)
+	var c Cuckoo
+	require.NoError(t, c.UnmarshalBinary(data))
+	assert.Equal(t, a, &c)
+}
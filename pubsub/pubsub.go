@@ -2,6 +2,8 @@ package pubsub
 
 import (
 	"sync"
+
+	"github.com/anacrolix/missinggo/v2"
 )
 
 type PubSub struct {
@@ -18,8 +20,7 @@ type item struct {
 type Subscription struct {
 	next   chan item
 	Values chan interface{}
-	mu     sync.Mutex
-	closed chan struct{}
+	closed missinggo.SynchronizedEvent
 }
 
 func NewPubSub() (ret *PubSub) {
@@ -54,17 +55,12 @@ func (me *PubSub) Publish(v interface{}) {
 }
 
 func (me *Subscription) Close() {
-	me.mu.Lock()
-	defer me.mu.Unlock()
-	select {
-	case <-me.closed:
-	default:
-		close(me.closed)
-	}
+	me.closed.Set()
 }
 
 func (me *Subscription) runner() {
 	defer close(me.Values)
+	closed := me.closed.Done()
 	for {
 		select {
 		case i, ok := <-me.next:
@@ -82,10 +78,10 @@ func (me *Subscription) runner() {
 			me.next = i.next
 			select {
 			case me.Values <- i.value:
-			case <-me.closed:
+			case <-closed:
 				return
 			}
-		case <-me.closed:
+		case <-closed:
 			return
 		}
 	}
@@ -94,7 +90,6 @@ func (me *Subscription) runner() {
 func (me *PubSub) Subscribe() (ret *Subscription) {
 	me.lazyInit()
 	ret = &Subscription{
-		closed: make(chan struct{}),
 		Values: make(chan interface{}),
 	}
 	me.mu.Lock()
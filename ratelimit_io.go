@@ -0,0 +1,121 @@
+package missinggo
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Counts bytes transferred, and when the last transfer happened. It's safe
+// for concurrent use.
+type IOStats struct {
+	bytes        int64
+	lastActivity int64 // UnixNano
+}
+
+func (me *IOStats) add(n int) {
+	if n <= 0 {
+		return
+	}
+	atomic.AddInt64(&me.bytes, int64(n))
+	atomic.StoreInt64(&me.lastActivity, time.Now().UnixNano())
+}
+
+func (me *IOStats) Bytes() int64 {
+	return atomic.LoadInt64(&me.bytes)
+}
+
+// Returns the zero Time if nothing has been transferred.
+func (me *IOStats) LastActivity() time.Time {
+	ns := atomic.LoadInt64(&me.lastActivity)
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// Returns a limiter for the given bytes per second. Burst is the most bytes
+// transferred at once, and so the largest read or write passed through.
+func NewByteRateLimiter(bytesPerSecond float64, burst int) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), burst)
+}
+
+// The most of n to transfer before waiting on l. A zero burst is left for
+// the limiter to reject.
+func rateChunk(l *rate.Limiter, n int) int {
+	if l.Limit() == rate.Inf {
+		return n
+	}
+	if b := l.Burst(); b > 0 && b < n {
+		return b
+	}
+	return n
+}
+
+// Reads from R no faster than Limiter allows, counting what's read. Limiters
+// can be shared to limit several readers and writers together.
+type LimitedRateReader struct {
+	R       io.Reader
+	Limiter *rate.Limiter
+	IOStats
+}
+
+func NewLimitedRateReader(r io.Reader, l *rate.Limiter) *LimitedRateReader {
+	return &LimitedRateReader{R: r, Limiter: l}
+}
+
+func (me *LimitedRateReader) Read(b []byte) (int, error) {
+	return me.ReadContext(context.Background(), b)
+}
+
+// Like Read, but the wait for the limiter is abandoned if ctx is done. What
+// was read is still returned.
+func (me *LimitedRateReader) ReadContext(ctx context.Context, b []byte) (n int, err error) {
+	b = b[:rateChunk(me.Limiter, len(b))]
+	if len(b) == 0 {
+		return
+	}
+	if err = me.Limiter.WaitN(ctx, len(b)); err != nil {
+		return
+	}
+	n, err = me.R.Read(b)
+	me.add(n)
+	return
+}
+
+// Writes to W no faster than Limiter allows, counting what's written.
+type LimitedRateWriter struct {
+	W       io.Writer
+	Limiter *rate.Limiter
+	IOStats
+}
+
+func NewLimitedRateWriter(w io.Writer, l *rate.Limiter) *LimitedRateWriter {
+	return &LimitedRateWriter{W: w, Limiter: l}
+}
+
+func (me *LimitedRateWriter) Write(b []byte) (int, error) {
+	return me.WriteContext(context.Background(), b)
+}
+
+// Like Write, but returns early if ctx is done while waiting for the limiter.
+func (me *LimitedRateWriter) WriteContext(ctx context.Context, b []byte) (n int, err error) {
+	for n < len(b) {
+		chunk := b[n:]
+		chunk = chunk[:rateChunk(me.Limiter, len(chunk))]
+		if err = me.Limiter.WaitN(ctx, len(chunk)); err != nil {
+			return
+		}
+		var nn int
+		nn, err = me.W.Write(chunk)
+		n += nn
+		me.add(nn)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package missinggo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestLimitedRateReader(t *testing.T) {
+	r := NewLimitedRateReader(strings.NewReader("hello world"), NewByteRateLimiter(1000, 4))
+	assert.True(t, r.LastActivity().IsZero())
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(b))
+	assert.EqualValues(t, 11, r.Bytes())
+	assert.False(t, r.LastActivity().IsZero())
+}
+
+func TestLimitedRateWriterContext(t *testing.T) {
+	var buf bytes.Buffer
+	// The initial burst is available immediately, then one byte per hour.
+	w := NewLimitedRateWriter(&buf, NewByteRateLimiter(1.0/3600, 2))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	n, err := w.WriteContext(ctx, []byte("abcd"))
+	assert.Error(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "ab", buf.String())
+	assert.EqualValues(t, 2, w.Bytes())
+}
+
+func TestLimitedRateUnlimited(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewLimitedRateWriter(&buf, rate.NewLimiter(rate.Inf, 0))
+	n, err := w.Write(make([]byte, 1<<20))
+	assert.NoError(t, err)
+	assert.Equal(t, 1<<20, n)
+	_, err = NewLimitedRateReader(strings.NewReader("a"), rate.NewLimiter(1, 0)).Read(make([]byte, 1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,84 @@
+// Package recovery turns panics into errors, so that a crash in one worker
+// can be contained and reported rather than taking down the process.
+package recovery
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// A recovered panic.
+type PanicError struct {
+	Value interface{}
+	// Where the panic occurred, as from debug.Stack.
+	Stack []byte
+}
+
+func (me PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", me.Value, me.Stack)
+}
+
+// Returns the panic value if it's an error, such as from a nil dereference.
+func (me PanicError) Unwrap() error {
+	err, _ := me.Value.(error)
+	return err
+}
+
+// Converts a panic into a PanicError, assigned to *errp. It must be deferred
+// directly:
+//
+//	defer recovery.Recover(&err)
+func Recover(errp *error) {
+	if r := recover(); r != nil {
+		*errp = PanicError{r, debug.Stack()}
+	}
+}
+
+// Calls fn, returning its error, or a PanicError if it panics.
+func Call(fn func() error) (err error) {
+	defer Recover(&err)
+	return fn()
+}
+
+var (
+	mu      sync.Mutex
+	handler = logHandler
+)
+
+func logHandler(pe PanicError) {
+	log.Printf("recovered %v", pe)
+}
+
+// Sets the handler for panics recovered by Go, returning the previous one.
+// The default logs them.
+func SetHandler(h func(PanicError)) (old func(PanicError)) {
+	mu.Lock()
+	defer mu.Unlock()
+	old = handler
+	handler = h
+	return
+}
+
+// Passes pe to the handler. For recovering code that doesn't use Go.
+func Handle(pe PanicError) {
+	mu.Lock()
+	h := handler
+	mu.Unlock()
+	h(pe)
+}
+
+// Runs fn in a new goroutine, passing any panic to the handler instead of
+// crashing.
+func Go(fn func()) {
+	go func() {
+		err := Call(func() error {
+			fn()
+			return nil
+		})
+		if err != nil {
+			Handle(err.(PanicError))
+		}
+	}()
+}
@@ -0,0 +1,31 @@
+package recovery
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCall(t *testing.T) {
+	assert.Equal(t, io.EOF, Call(func() error { return io.EOF }))
+	err := Call(func() error { panic(io.ErrUnexpectedEOF) })
+	var pe PanicError
+	require.True(t, errors.As(err, &pe))
+	assert.Equal(t, io.ErrUnexpectedEOF, pe.Value)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.Contains(t, string(pe.Stack), "TestCall")
+	assert.Contains(t, err.Error(), "panic: unexpected EOF")
+}
+
+func TestGo(t *testing.T) {
+	got := make(chan PanicError, 1)
+	old := SetHandler(func(pe PanicError) { got <- pe })
+	defer SetHandler(old)
+	Go(func() { panic("oh no") })
+	pe := <-got
+	assert.Equal(t, "oh no", pe.Value)
+	assert.Nil(t, pe.Unwrap())
+}
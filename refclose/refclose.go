@@ -1,60 +1,94 @@
 package refclose
 
 import (
+	"fmt"
+	"io"
+	"log"
+	"runtime"
 	"runtime/pprof"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2"
 )
 
 var profile = pprof.NewProfile("refs")
 
 type RefPool struct {
+	// Log Refs that are garbage collected without being released, with where
+	// they were created. This is for debugging, as it sets a finalizer on
+	// every Ref.
+	WarnUnreleased bool
+
 	mu sync.Mutex
 	rs map[interface{}]*resource
 }
 
 type Closer func()
 
-func (me *RefPool) inc(key interface{}) {
+func (me *RefPool) inc(key interface{}, rec *refRecord) {
 	me.mu.Lock()
 	defer me.mu.Unlock()
 	r := me.rs[key]
 	if r == nil {
-		r = new(resource)
+		r = &resource{
+			refs: make(map[*refRecord]struct{}),
+		}
 		if me.rs == nil {
 			me.rs = make(map[interface{}]*resource)
 		}
 		me.rs[key] = r
 	}
-	r.numRefs++
+	r.refs[rec] = struct{}{}
 }
 
-func (me *RefPool) dec(key interface{}) {
+func (me *RefPool) dec(key interface{}, rec *refRecord) {
 	me.mu.Lock()
 	defer me.mu.Unlock()
 	r := me.rs[key]
-	r.numRefs--
-	if r.numRefs > 0 {
+	delete(r.refs, rec)
+	if len(r.refs) > 0 {
 		return
 	}
-	if r.numRefs < 0 {
-		panic(r.numRefs)
-	}
 	r.closer()
 	delete(me.rs, key)
 }
 
 type resource struct {
-	closer  Closer
-	numRefs int
+	closer Closer
+	refs   map[*refRecord]struct{}
+}
+
+// What's known about a Ref, kept separately so that tracking it doesn't keep
+// the Ref alive.
+type refRecord struct {
+	key     interface{}
+	created time.Time
+	stack   []uintptr
 }
 
 func (me *RefPool) NewRef(key interface{}) (ret *Ref) {
-	me.inc(key)
+	var pcs [32]uintptr
+	rec := &refRecord{
+		key:     key,
+		created: time.Now(),
+		// Skip runtime.Callers and NewRef.
+		stack: pcs[:runtime.Callers(2, pcs[:])],
+	}
+	me.inc(key, rec)
 	ret = &Ref{
 		pool: me,
 		key:  key,
+		rec:  rec,
+	}
+	profile.Add(rec, 1)
+	if me.WarnUnreleased {
+		runtime.SetFinalizer(ret, func(ref *Ref) {
+			log.Printf("refclose: ref to %v created %s ago was never released:\n%s",
+				rec.key, time.Since(rec.created), missinggo.FormatStack("\t", rec.stack))
+		})
 	}
-	profile.Add(ret, 0)
 	return
 }
 
@@ -62,6 +96,7 @@ type Ref struct {
 	mu     sync.Mutex
 	pool   *RefPool
 	key    interface{}
+	rec    *refRecord
 	closed bool
 }
 
@@ -81,8 +116,10 @@ func (me *Ref) Release() {
 	me.mu.Lock()
 	defer me.mu.Unlock()
 	me.panicIfClosed()
-	profile.Remove(me)
-	me.pool.dec(me.key)
+	me.closed = true
+	runtime.SetFinalizer(me, nil)
+	profile.Remove(me.rec)
+	me.pool.dec(me.key, me.rec)
 }
 
 func (me *Ref) Key() interface{} {
@@ -91,3 +128,23 @@ func (me *Ref) Key() interface{} {
 	me.panicIfClosed()
 	return me.key
 }
+
+// Writes the unreleased refs, oldest first, with the stacks that created them.
+func (me *RefPool) Dump(w io.Writer) {
+	var recs []*refRecord
+	me.mu.Lock()
+	for _, r := range me.rs {
+		for rec := range r.refs {
+			recs = append(recs, rec)
+		}
+	}
+	me.mu.Unlock()
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].created.Before(recs[j].created)
+	})
+	now := time.Now()
+	fmt.Fprintf(w, "%d unreleased refs\n", len(recs))
+	for _, rec := range recs {
+		fmt.Fprintf(w, "\nref to %v, age %s:\n%s", rec.key, now.Sub(rec.created), missinggo.FormatStack("\t", rec.stack))
+	}
+}
@@ -1,6 +1,8 @@
 package refclose
 
 import (
+	"bytes"
+	"strings"
 	"sync"
 	"testing"
 
@@ -64,3 +66,23 @@ func Test(t *testing.T) {
 		t:   t,
 	}).run()
 }
+
+func TestDump(t *testing.T) {
+	var pool RefPool
+	a := pool.NewRef("a")
+	a.SetCloser(func() {})
+	b := pool.NewRef("b")
+	b.SetCloser(func() {})
+	var buf bytes.Buffer
+	pool.Dump(&buf)
+	s := buf.String()
+	assert.Contains(t, s, "2 unreleased refs")
+	assert.True(t, strings.Index(s, "ref to a") < strings.Index(s, "ref to b"))
+	assert.Contains(t, s, "refclose.TestDump")
+	a.Release()
+	assert.Panics(t, a.Release)
+	b.Release()
+	buf.Reset()
+	pool.Dump(&buf)
+	assert.Equal(t, "0 unreleased refs\n", buf.String())
+}
@@ -0,0 +1,160 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// A minimal key-value store, such as a bolt bucket, a sqlite table, or a
+// kvfile.DB. It must be safe for concurrent use.
+type KV interface {
+	// ok is false if the key isn't present.
+	Get(key string) (value []byte, ok bool, err error)
+	Put(key string, value []byte) error
+	Delete(key string) error
+}
+
+// Stores each instance as a single value in a KV, keyed by its location. It
+// suits many small instances, which would waste inodes and metadata writes
+// as individual files. Values carry a modification time header, so the KV
+// should be dedicated to the provider.
+type KVProvider struct {
+	kv KV
+	// Serializes read-modify-writes.
+	mu sync.Mutex
+}
+
+var _ Provider = &KVProvider{}
+
+func NewKVProvider(kv KV) *KVProvider {
+	return &KVProvider{kv: kv}
+}
+
+func (me *KVProvider) NewInstance(key string) (Instance, error) {
+	return &kvInstance{me, key}, nil
+}
+
+// Unix nanoseconds of the last modification.
+const kvHeaderLen = 8
+
+type kvInstance struct {
+	p   *KVProvider
+	key string
+}
+
+var _ Instance = &kvInstance{}
+
+func (me *kvInstance) notExist(op string) error {
+	return &os.PathError{Op: op, Path: me.key, Err: os.ErrNotExist}
+}
+
+// Returns the content, and its modification time.
+func (me *kvInstance) get(op string) (b []byte, mtime time.Time, err error) {
+	v, ok, err := me.p.kv.Get(me.key)
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = me.notExist(op)
+		return
+	}
+	if len(v) < kvHeaderLen {
+		err = errors.New("kv resource value missing header")
+		return
+	}
+	mtime = time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+	b = v[kvHeaderLen:]
+	return
+}
+
+func (me *kvInstance) put(b []byte) error {
+	v := make([]byte, kvHeaderLen+len(b))
+	binary.BigEndian.PutUint64(v, uint64(time.Now().UnixNano()))
+	copy(v[kvHeaderLen:], b)
+	return me.p.kv.Put(me.key, v)
+}
+
+func (me *kvInstance) Get() (io.ReadCloser, error) {
+	b, _, err := me.get("get")
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (me *kvInstance) Put(r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	me.p.mu.Lock()
+	defer me.p.mu.Unlock()
+	return me.put(b)
+}
+
+func (me *kvInstance) ReadAt(b []byte, off int64) (n int, err error) {
+	v, _, err := me.get("read")
+	if err != nil {
+		return
+	}
+	if off >= int64(len(v)) {
+		return 0, io.EOF
+	}
+	n = copy(b, v[off:])
+	if n < len(b) {
+		err = io.EOF
+	}
+	return
+}
+
+// Creates the instance if it doesn't exist, and zero fills any gap.
+func (me *kvInstance) WriteAt(b []byte, off int64) (n int, err error) {
+	me.p.mu.Lock()
+	defer me.p.mu.Unlock()
+	v, _, err := me.get("write")
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	if end := off + int64(len(b)); end > int64(len(v)) {
+		v = append(v, make([]byte, end-int64(len(v)))...)
+	}
+	copy(v[off:], b)
+	err = me.put(v)
+	if err == nil {
+		n = len(b)
+	}
+	return
+}
+
+func (me *kvInstance) Stat() (os.FileInfo, error) {
+	v, mtime, err := me.get("stat")
+	if err != nil {
+		return nil, err
+	}
+	return kvFileInfo{me.key, int64(len(v)), mtime}, nil
+}
+
+func (me *kvInstance) Delete() error {
+	return me.p.kv.Delete(me.key)
+}
+
+type kvFileInfo struct {
+	name  string
+	size  int64
+	mtime time.Time
+}
+
+func (me kvFileInfo) Name() string       { return me.name }
+func (me kvFileInfo) Size() int64        { return me.size }
+func (me kvFileInfo) Mode() os.FileMode  { return 0o644 }
+func (me kvFileInfo) ModTime() time.Time { return me.mtime }
+func (me kvFileInfo) IsDir() bool        { return false }
+func (me kvFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,68 @@
+package resource
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mapKV struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+func (me *mapKV) Get(key string) ([]byte, bool, error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	v, ok := me.m[key]
+	return v, ok, nil
+}
+
+func (me *mapKV) Put(key string, value []byte) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.m[key] = value
+	return nil
+}
+
+func (me *mapKV) Delete(key string) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	delete(me.m, key)
+	return nil
+}
+
+func TestKVProvider(t *testing.T) {
+	p := NewKVProvider(&mapKV{m: make(map[string][]byte)})
+	i, err := p.NewInstance("a/b")
+	require.NoError(t, err)
+	assert.False(t, Exists(i))
+	_, err = i.Get()
+	assert.True(t, os.IsNotExist(err))
+
+	n, err := i.WriteAt([]byte("lo"), 3)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	fi, err := i.Stat()
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, fi.Size())
+	assert.False(t, fi.ModTime().IsZero())
+
+	require.NoError(t, i.Put(strings.NewReader("hello")))
+	b := make([]byte, 4)
+	n, err = i.ReadAt(b, 2)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "llo", string(b[:n]))
+	rc, err := i.Get()
+	require.NoError(t, err)
+	all, _ := io.ReadAll(rc)
+	assert.Equal(t, "hello", string(all))
+
+	require.NoError(t, i.Delete())
+	assert.False(t, Exists(i))
+}
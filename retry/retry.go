@@ -0,0 +1,153 @@
+// Package retry repeats failing operations with exponential backoff and
+// jitter.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Exponential backoff with full jitter: each delay is uniformly random up to
+// the exponentially growing ceiling.
+type Backoff struct {
+	// The ceiling for the first delay. Defaults to 100ms.
+	Initial time.Duration
+	// The most the ceiling can grow to. Defaults to 30s.
+	Max time.Duration
+	// How much the ceiling grows each attempt. Defaults to 2.
+	Multiplier float64
+}
+
+func (me Backoff) withDefaults() Backoff {
+	if me.Initial <= 0 {
+		me.Initial = 100 * time.Millisecond
+	}
+	if me.Max <= 0 {
+		me.Max = 30 * time.Second
+	}
+	if me.Multiplier < 1 {
+		me.Multiplier = 2
+	}
+	return me
+}
+
+// Returns the ceiling for the delay after the given attempt, counting from 0.
+func (me Backoff) Ceiling(attempt int) time.Duration {
+	me = me.withDefaults()
+	c := float64(me.Initial) * math.Pow(me.Multiplier, float64(attempt))
+	if c >= float64(me.Max) || math.IsInf(c, 0) || math.IsNaN(c) {
+		return me.Max
+	}
+	return time.Duration(c)
+}
+
+// Returns a random delay for after the given attempt, in [0, Ceiling(attempt)].
+func (me Backoff) Delay(attempt int) time.Duration {
+	return time.Duration(rand.Int63n(int64(me.Ceiling(attempt)) + 1))
+}
+
+type Policy struct {
+	Backoff
+	// The most times to call the func. Zero means no limit.
+	MaxAttempts int
+	// No retry is started that would begin after this long since the first
+	// attempt. Zero means no limit.
+	MaxElapsed time.Duration
+	// Reports whether an error is worth retrying. By default, all errors are,
+	// except those wrapped by Permanent.
+	Retryable func(error) bool
+	// Called before waiting to retry.
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (me permanentError) Error() string {
+	return me.err.Error()
+}
+
+func (me permanentError) Unwrap() error {
+	return me.err
+}
+
+// Wraps err so that it's not retried, regardless of Policy.Retryable.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// Reports whether err was wrapped by Permanent.
+func IsPermanent(err error) bool {
+	var pe permanentError
+	return errors.As(err, &pe)
+}
+
+func (me Policy) retryable(err error) bool {
+	if IsPermanent(err) {
+		return false
+	}
+	if me.Retryable == nil {
+		return true
+	}
+	return me.Retryable(err)
+}
+
+// Calls fn until it succeeds, returns an error that isn't retryable, or the
+// Policy limits are reached, returning fn's last error. If ctx is done while
+// waiting to retry, its error is returned. Permanent errors are returned
+// unwrapped.
+func (me Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, err := Do(ctx, me, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// Like Policy.Do, for funcs that return a value.
+func Do[T any](ctx context.Context, p Policy, fn func(ctx context.Context) (T, error)) (value T, err error) {
+	started := time.Now()
+	for attempt := 0; ; attempt++ {
+		value, err = fn(ctx)
+		if err == nil {
+			return
+		}
+		if !p.retryable(err) {
+			if pe, ok := err.(permanentError); ok {
+				err = pe.err
+			}
+			return
+		}
+		if p.MaxAttempts > 0 && attempt+1 >= p.MaxAttempts {
+			return
+		}
+		delay := p.Delay(attempt)
+		if p.MaxElapsed > 0 && time.Since(started)+delay > p.MaxElapsed {
+			return
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, delay)
+		}
+		if ctxErr := sleep(ctx, delay); ctxErr != nil {
+			err = ctxErr
+			return
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
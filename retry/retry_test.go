@@ -0,0 +1,110 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffCeiling(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 10 * time.Second}
+	for _, _case := range []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{3, 8 * time.Second},
+		{4, 10 * time.Second},
+		{1000, 10 * time.Second},
+	} {
+		assert.Equal(t, _case.ceiling, b.Ceiling(_case.attempt), "%v", _case)
+	}
+	for i := 0; i < 100; i++ {
+		d := b.Delay(2)
+		assert.True(t, d >= 0 && d <= 4*time.Second, d)
+	}
+}
+
+var fast = Backoff{Initial: time.Microsecond, Max: time.Millisecond}
+
+func TestDoSucceeds(t *testing.T) {
+	var retries []int
+	p := Policy{
+		Backoff: fast,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			retries = append(retries, attempt)
+		},
+	}
+	v, err := Do(context.Background(), p, func(ctx context.Context) (int, error) {
+		if len(retries) < 2 {
+			return 0, errors.New("not yet")
+		}
+		return 42, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 42, v)
+	assert.Equal(t, []int{0, 1}, retries)
+}
+
+func TestDoLimits(t *testing.T) {
+	boom := errors.New("boom")
+	calls := 0
+	err := Policy{Backoff: fast, MaxAttempts: 3}.Do(context.Background(), func(context.Context) error {
+		calls++
+		return boom
+	})
+	assert.Equal(t, boom, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = Policy{
+		Backoff:    Backoff{Initial: time.Hour, Max: time.Hour},
+		MaxElapsed: time.Millisecond,
+	}.Do(context.Background(), func(context.Context) error {
+		calls++
+		return boom
+	})
+	assert.Equal(t, boom, err)
+	// A zero delay could be drawn, allowing a second attempt within the limit.
+	assert.True(t, calls >= 1 && calls <= 2, calls)
+}
+
+func TestDoNotRetryable(t *testing.T) {
+	boom := errors.New("boom")
+	calls := 0
+	err := Policy{Backoff: fast}.Do(context.Background(), func(context.Context) error {
+		calls++
+		return Permanent(boom)
+	})
+	assert.Equal(t, boom, err)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	err = Policy{
+		Backoff:   fast,
+		Retryable: func(err error) bool { return err != boom },
+	}.Do(context.Background(), func(context.Context) error {
+		calls++
+		return boom
+	})
+	assert.Equal(t, boom, err)
+	assert.Equal(t, 1, calls)
+	assert.True(t, IsPermanent(Permanent(boom)))
+	assert.False(t, IsPermanent(boom))
+	assert.NoError(t, Permanent(nil))
+}
+
+func TestDoContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := Policy{
+		Backoff: Backoff{Initial: time.Hour, Max: time.Hour},
+	}.Do(ctx, func(context.Context) error {
+		return errors.New("boom")
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
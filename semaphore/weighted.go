@@ -0,0 +1,121 @@
+// Package semaphore provides a weighted semaphore with prioritized admission.
+package semaphore
+
+import (
+	"context"
+
+	"github.com/anacrolix/stm"
+	"github.com/anacrolix/stm/stmutil"
+
+	"github.com/anacrolix/missinggo/v2/iter"
+)
+
+// A weighted semaphore. While waiters of some priority are blocked, waiters
+// of lower priority aren't admitted, even if there's room for them. This is
+// the admission logic of conntrack.Instance, for general use.
+type Weighted struct {
+	capacity *stm.Var // int64
+	used     *stm.Var // int64
+	// priority to count of blocked waiters, ordered by priority descending.
+	waitersByPriority *stm.Var // Mappish
+}
+
+func NewWeighted(capacity int64) *Weighted {
+	return &Weighted{
+		capacity: stm.NewVar(capacity),
+		used:     stm.NewVar(int64(0)),
+		waitersByPriority: stm.NewVar(stmutil.NewSortedMap(func(l, r interface{}) bool {
+			return l.(int) > r.(int)
+		})),
+	}
+}
+
+// Changes the total weight that can be held. Lowering it below what's
+// currently held only affects future acquisitions.
+func (me *Weighted) SetCapacity(n int64) {
+	stm.AtomicSet(me.capacity, n)
+}
+
+// Returns the total weight currently held.
+func (me *Weighted) Used() int64 {
+	return stm.AtomicGet(me.used).(int64)
+}
+
+func (me *Weighted) addWaiter(tx *stm.Tx, p int, delta int) {
+	m := tx.Get(me.waitersByPriority).(stmutil.Mappish)
+	n := delta
+	if v, ok := m.Get(p); ok {
+		n += v.(int)
+	}
+	if n == 0 {
+		m = m.Delete(p)
+	} else {
+		m = m.Set(p, n)
+	}
+	tx.Set(me.waitersByPriority, m)
+}
+
+// Acquires n if there's room and no higher priority is waiting. The caller
+// must be counted among the waiters at p if waiting is true.
+func (me *Weighted) allow(tx *stm.Tx, n int64, p int, waiting bool) bool {
+	used := tx.Get(me.used).(int64)
+	if used+n > tx.Get(me.capacity).(int64) {
+		return false
+	}
+	topPrio, ok := iter.First(tx.Get(me.waitersByPriority).(iter.Iterable).Iter)
+	if ok && (topPrio.(int) > p || !waiting && topPrio.(int) == p) {
+		return false
+	}
+	tx.Set(me.used, used+n)
+	return true
+}
+
+// Acquires n at priority p, blocking until it's admitted or ctx is done, in
+// which case ctx's error is returned and nothing is acquired.
+func (me *Weighted) Acquire(ctx context.Context, n int64, p int) error {
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		me.addWaiter(tx, p, 1)
+	}))
+	ctxDone, cancel := stmutil.ContextDoneVar(ctx)
+	defer cancel()
+	success := stm.Atomically(func(tx *stm.Tx) interface{} {
+		if me.allow(tx, n, p, true) {
+			me.addWaiter(tx, p, -1)
+			return true
+		}
+		if tx.Get(ctxDone).(bool) {
+			me.addWaiter(tx, p, -1)
+			return false
+		}
+		tx.Retry()
+		panic("unreachable")
+	}).(bool)
+	if !success {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Acquires n at priority p without blocking. Waiters of the same or higher
+// priority take precedence.
+func (me *Weighted) TryAcquire(n int64, p int) bool {
+	return stm.Atomically(func(tx *stm.Tx) interface{} {
+		return me.allow(tx, n, p, false)
+	}).(bool)
+}
+
+// Like TryAcquire, for composing with other operations in tx.
+func (me *Weighted) AcquireTx(tx *stm.Tx, n int64, p int) bool {
+	return me.allow(tx, n, p, false)
+}
+
+// Returns n to the semaphore.
+func (me *Weighted) Release(n int64) {
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		used := tx.Get(me.used).(int64) - n
+		if used < 0 {
+			panic("released more than acquired")
+		}
+		tx.Set(me.used, used)
+	}))
+}
@@ -0,0 +1,70 @@
+package semaphore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/stm"
+	"github.com/anacrolix/stm/stmutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForWaiters(s *Weighted, n int) {
+	stm.Atomically(stm.VoidOperation(func(tx *stm.Tx) {
+		count := 0
+		tx.Get(s.waitersByPriority).(stmutil.Mappish).Range(func(_, v interface{}) bool {
+			count += v.(int)
+			return true
+		})
+		tx.Assert(count == n)
+	}))
+}
+
+func TestWeightedTryAcquire(t *testing.T) {
+	s := NewWeighted(3)
+	assert.True(t, s.TryAcquire(2, 0))
+	assert.False(t, s.TryAcquire(2, 0))
+	assert.True(t, s.TryAcquire(1, 0))
+	assert.EqualValues(t, 3, s.Used())
+	s.Release(3)
+	assert.EqualValues(t, 0, s.Used())
+	assert.Panics(t, func() { s.Release(1) })
+}
+
+func TestWeightedAcquireContext(t *testing.T) {
+	s := NewWeighted(1)
+	require.True(t, s.TryAcquire(1, 0))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, s.Acquire(ctx, 1, 0))
+	// The abandoned waiter doesn't hold up others.
+	s.Release(1)
+	assert.True(t, s.TryAcquire(1, -1))
+}
+
+func TestWeightedPriority(t *testing.T) {
+	s := NewWeighted(2)
+	require.True(t, s.TryAcquire(2, 0))
+	admitted := make(chan int, 2)
+	acquire := func(n int64, p int) {
+		require.NoError(t, s.Acquire(context.Background(), n, p))
+		admitted <- p
+	}
+	go acquire(2, 1)
+	go acquire(1, 2)
+	waitForWaiters(s, 2)
+	// The high priority waiter blocks lower ones.
+	assert.False(t, s.TryAcquire(1, 1))
+	s.Release(2)
+	assert.Equal(t, 2, <-admitted)
+	// There's room for 1 more, but the next waiter needs 2.
+	select {
+	case p := <-admitted:
+		t.Fatalf("admitted %v", p)
+	case <-time.After(10 * time.Millisecond):
+	}
+	s.Release(1)
+	assert.Equal(t, 1, <-admitted)
+}
@@ -0,0 +1,255 @@
+// Package shutdown coordinates graceful shutdown, running the hooks
+// subsystems register in order, each with a timeout, and reporting those that
+// are slow.
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stops a subsystem. It should return promptly once ctx is done.
+type Hook func(ctx context.Context) error
+
+type Opts struct {
+	// The timeout for hooks registered without one. Defaults to 10s.
+	DefaultTimeout time.Duration
+	// Hooks still running after this are reported to OnSlow. Defaults to
+	// half their timeout.
+	SlowAfter time.Duration
+	// By default, slow hooks are logged. It's called from its own goroutine,
+	// possibly concurrently for hooks in the same stage.
+	OnSlow func(name string, running time.Duration)
+}
+
+type hook struct {
+	name    string
+	stage   int
+	timeout time.Duration
+	fn      Hook
+}
+
+// A hook's failure, including timing out.
+type HookError struct {
+	Name string
+	Err  error
+}
+
+func (me HookError) Error() string {
+	return me.Name + ": " + me.Err.Error()
+}
+
+func (me HookError) Unwrap() error {
+	return me.Err
+}
+
+// The failures from a shutdown, in the order the hooks were run.
+type Errors []HookError
+
+func (me Errors) Error() string {
+	ss := make([]string, 0, len(me))
+	for _, err := range me {
+		ss = append(ss, err.Error())
+	}
+	return strings.Join(ss, "; ")
+}
+
+type Manager struct {
+	opts Opts
+
+	mu      sync.Mutex
+	hooks   []hook
+	started bool
+	done    chan struct{}
+	err     error
+}
+
+func New(opts Opts) *Manager {
+	if opts.DefaultTimeout <= 0 {
+		opts.DefaultTimeout = 10 * time.Second
+	}
+	if opts.OnSlow == nil {
+		opts.OnSlow = func(name string, running time.Duration) {
+			log.Printf("shutdown: %q still running after %v", name, running)
+		}
+	}
+	return &Manager{
+		opts: opts,
+		done: make(chan struct{}),
+	}
+}
+
+// Adds a hook to run on shutdown. Stages run in ascending order, and the
+// hooks within a stage run concurrently. For example, stop accepting work in
+// stage 0, drain in stage 1, and close storage in stage 2. A timeout of zero
+// uses Opts.DefaultTimeout. Hooks registered after shutdown starts are run
+// immediately, in the caller's goroutine.
+func (me *Manager) Register(name string, stage int, timeout time.Duration, fn Hook) {
+	if timeout <= 0 {
+		timeout = me.opts.DefaultTimeout
+	}
+	h := hook{name, stage, timeout, fn}
+	me.mu.Lock()
+	if me.started {
+		me.mu.Unlock()
+		if err := me.runHook(context.Background(), h); err != nil {
+			log.Printf("shutdown: hook registered late: %v", err)
+		}
+		return
+	}
+	me.hooks = append(me.hooks, h)
+	me.mu.Unlock()
+}
+
+// Runs the hooks, and returns Errors for any that failed. Only the first call
+// runs them; later calls wait for the result. If ctx is done, hooks still
+// running are abandoned, and the hooks of later stages are run with a done
+// Context.
+func (me *Manager) Shutdown(ctx context.Context) error {
+	me.mu.Lock()
+	if me.started {
+		me.mu.Unlock()
+		select {
+		case <-me.done:
+			return me.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	me.started = true
+	hooks := me.hooks
+	me.hooks = nil
+	me.mu.Unlock()
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].stage < hooks[j].stage
+	})
+	var errs Errors
+	for i := 0; i < len(hooks); {
+		j := i
+		for j < len(hooks) && hooks[j].stage == hooks[i].stage {
+			j++
+		}
+		errs = append(errs, me.runStage(ctx, hooks[i:j])...)
+		i = j
+	}
+	if len(errs) != 0 {
+		me.err = errs
+	}
+	close(me.done)
+	return me.err
+}
+
+func (me *Manager) runStage(ctx context.Context, hooks []hook) (errs Errors) {
+	stageErrs := make([]error, len(hooks))
+	var wg sync.WaitGroup
+	for i, h := range hooks {
+		wg.Add(1)
+		go func(i int, h hook) {
+			defer wg.Done()
+			stageErrs[i] = me.runHook(ctx, h)
+		}(i, h)
+	}
+	wg.Wait()
+	for _, err := range stageErrs {
+		if err != nil {
+			errs = append(errs, err.(HookError))
+		}
+	}
+	return
+}
+
+// Runs the hook, waiting no longer than its timeout.
+func (me *Manager) runHook(ctx context.Context, h hook) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	slowAfter := me.opts.SlowAfter
+	if slowAfter <= 0 {
+		slowAfter = h.timeout / 2
+	}
+	started := time.Now()
+	slow := time.AfterFunc(slowAfter, func() {
+		me.opts.OnSlow(h.name, time.Since(started))
+	})
+	defer slow.Stop()
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		result <- h.fn(ctx)
+	}()
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		return HookError{h.name, err}
+	}
+	return nil
+}
+
+// Closed when Shutdown has finished.
+func (me *Manager) Done() <-chan struct{} {
+	return me.done
+}
+
+// Starts shutdown on the first of the signals, or os.Interrupt if none are
+// given. A second signal abandons hooks still running. Returns a func that
+// stops listening.
+func (me *Manager) OnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt}
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-c:
+		case <-stopped:
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-c:
+				cancel()
+			case <-stopped:
+			case <-me.done:
+			}
+		}()
+		me.Shutdown(ctx)
+		cancel()
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(stopped)
+		})
+	}
+}
+
+// The process-wide Manager.
+var Default = New(Opts{})
+
+// Registers with Default.
+func Register(name string, stage int, timeout time.Duration, fn Hook) {
+	Default.Register(name, stage, timeout, fn)
+}
+
+// Shuts down Default.
+func Shutdown(ctx context.Context) error {
+	return Default.Shutdown(ctx)
+}
@@ -0,0 +1,100 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStages(t *testing.T) {
+	m := New(Opts{})
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) Hook {
+		return func(context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+	// Hooks in a stage run together, so this only returns if b runs.
+	bRan := make(chan struct{})
+	m.Register("c", 2, 0, record("c"))
+	m.Register("a", 1, 0, func(ctx context.Context) error {
+		<-bRan
+		return record("a")(ctx)
+	})
+	m.Register("b", 1, 0, func(ctx context.Context) error {
+		close(bRan)
+		return record("b")(ctx)
+	})
+	m.Register("z", 0, 0, record("z"))
+	require.NoError(t, m.Shutdown(context.Background()))
+	assert.Equal(t, []string{"z", "b", "a", "c"}, order)
+	<-m.Done()
+	// Later calls get the same result.
+	require.NoError(t, m.Shutdown(context.Background()))
+	// Hooks registered late run immediately.
+	m.Register("late", 0, 0, record("late"))
+	assert.Equal(t, "late", order[len(order)-1])
+}
+
+func TestErrorsAndTimeouts(t *testing.T) {
+	var mu sync.Mutex
+	var slow []string
+	m := New(Opts{
+		SlowAfter: time.Millisecond,
+		OnSlow: func(name string, running time.Duration) {
+			mu.Lock()
+			slow = append(slow, name)
+			mu.Unlock()
+		},
+	})
+	boom := errors.New("boom")
+	m.Register("fails", 0, 0, func(context.Context) error { return boom })
+	m.Register("panics", 1, 0, func(context.Context) error { panic("oh no") })
+	m.Register("stuck", 2, 10*time.Millisecond, func(context.Context) error {
+		select {}
+	})
+	ran := false
+	m.Register("after", 3, 0, func(context.Context) error {
+		ran = true
+		return nil
+	})
+	err := m.Shutdown(context.Background())
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 3)
+	assert.Equal(t, "fails", errs[0].Name)
+	assert.True(t, errors.Is(errs[0], boom))
+	assert.EqualError(t, errs[1], "panics: panic: oh no")
+	assert.Equal(t, "stuck", errs[2].Name)
+	assert.True(t, errors.Is(errs[2], context.DeadlineExceeded))
+	assert.True(t, ran)
+	mu.Lock()
+	assert.Contains(t, slow, "stuck")
+	mu.Unlock()
+}
+
+func TestOnSignal(t *testing.T) {
+	m := New(Opts{})
+	m.Register("hook", 0, 0, func(context.Context) error { return nil })
+	defer m.OnSignal()()
+	p, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skip(err)
+	}
+	select {
+	case <-m.Done():
+	case <-time.After(time.Second):
+		t.Fatal("signal didn't shut down")
+	}
+}
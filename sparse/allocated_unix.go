@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package sparse
+
+import (
+	"os"
+	"syscall"
+)
+
+func allocatedSize(path string) (int64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	// Blocks are always 512 bytes here, whatever the filesystem's block
+	// size.
+	return int64(fi.Sys().(*syscall.Stat_t).Blocks) * 512, nil
+}
@@ -0,0 +1,53 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package sparse
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/anacrolix/missinggo/v2/intervals"
+)
+
+// Finds data extents with SEEK_DATA and SEEK_HOLE. ok is false if the
+// filesystem doesn't support them.
+func seekDataExtents(f *os.File, size int64) (ret []intervals.Interval[int64], ok bool, err error) {
+	cur, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if _, serr := f.Seek(cur, io.SeekStart); err == nil {
+			err = serr
+		}
+	}()
+	ok = true
+	var off int64
+	for off < size {
+		var start, end int64
+		start, err = f.Seek(off, seekData)
+		if errors.Is(err, syscall.ENXIO) {
+			// No data after off.
+			err = nil
+			return
+		}
+		if errors.Is(err, syscall.EINVAL) && off == 0 {
+			ok = false
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		end, err = f.Seek(start, seekHole)
+		if err != nil {
+			return
+		}
+		ret = append(ret, intervals.Interval[int64]{Start: start, End: end})
+		off = end
+	}
+	return
+}
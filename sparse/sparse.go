@@ -0,0 +1,65 @@
+// Package sparse inspects and creates holes in sparse files, and reports the
+// space files actually occupy.
+package sparse
+
+import (
+	"errors"
+	"os"
+
+	"github.com/anacrolix/missinggo/v2/intervals"
+)
+
+// Returned where the platform or filesystem can't punch holes.
+var ErrUnsupported = errors.New("not supported on this platform")
+
+// Returns the ranges of f that hold data, in order. Where holes can't be
+// detected, the whole file is reported as data. The file offset is
+// preserved.
+func DataExtents(f *os.File) (ret []intervals.Interval[int64], err error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return
+	}
+	size := fi.Size()
+	if size == 0 {
+		return
+	}
+	ret, ok, err := seekDataExtents(f, size)
+	if !ok {
+		return []intervals.Interval[int64]{{Start: 0, End: size}}, nil
+	}
+	return
+}
+
+// Returns the ranges of f that are holes, in order.
+func Holes(f *os.File) ([]intervals.Interval[int64], error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, err := DataExtents(f)
+	if err != nil {
+		return nil, err
+	}
+	var s intervals.Set[int64]
+	for _, iv := range data {
+		s.Add(iv)
+	}
+	return s.Gaps(intervals.Interval[int64]{Start: 0, End: fi.Size()}), nil
+}
+
+// Deallocates the range of f, which then reads as zeroes, without changing
+// its size. Filesystems may only free whole blocks, zeroing the remainder.
+func PunchHole(f *os.File, off, length int64) error {
+	if length <= 0 {
+		return nil
+	}
+	return punchHole(f, off, length)
+}
+
+// Returns the disk space allocated to the file at path, which is less than
+// its size if it's sparse, or compressed. Where that's unknown, its size is
+// returned.
+func AllocatedSize(path string) (int64, error) {
+	return allocatedSize(path)
+}
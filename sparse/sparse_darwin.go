@@ -0,0 +1,12 @@
+package sparse
+
+import "os"
+
+const (
+	seekHole = 3
+	seekData = 4
+)
+
+func punchHole(f *os.File, off, length int64) error {
+	return ErrUnsupported
+}
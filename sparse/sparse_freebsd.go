@@ -0,0 +1,12 @@
+package sparse
+
+import "os"
+
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+func punchHole(f *os.File, off, length int64) error {
+	return ErrUnsupported
+}
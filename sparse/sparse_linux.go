@@ -0,0 +1,24 @@
+package sparse
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+const (
+	fallocKeepSize  = 0x1
+	fallocPunchHole = 0x2
+)
+
+func punchHole(f *os.File, off, length int64) error {
+	err := syscall.Fallocate(int(f.Fd()), fallocPunchHole|fallocKeepSize, off, length)
+	if err == syscall.EOPNOTSUPP {
+		return ErrUnsupported
+	}
+	return err
+}
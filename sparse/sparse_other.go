@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package sparse
+
+import (
+	"os"
+
+	"github.com/anacrolix/missinggo/v2/intervals"
+)
+
+// Holes can't be found by seeking.
+func seekDataExtents(f *os.File, size int64) (ret []intervals.Interval[int64], ok bool, err error) {
+	return
+}
+
+func punchHole(f *os.File, off, length int64) error {
+	return ErrUnsupported
+}
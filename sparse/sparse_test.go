@@ -0,0 +1,75 @@
+package sparse
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/v2/intervals"
+)
+
+const (
+	blockSize = 1 << 16
+	fileSize  = 16 * blockSize
+)
+
+// Returns a file with a block of data at each end, and a hole between.
+func sparseFile(t *testing.T) *os.File {
+	f, err := os.Create(filepath.Join(t.TempDir(), "sparse"))
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	data := bytes.Repeat([]byte{1}, blockSize)
+	_, err = f.WriteAt(data, 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt(data, fileSize-blockSize)
+	require.NoError(t, err)
+	return f
+}
+
+type iv = intervals.Interval[int64]
+
+func TestDataExtents(t *testing.T) {
+	f := sparseFile(t)
+	f.Seek(123, 0)
+	data, err := DataExtents(f)
+	require.NoError(t, err)
+	off, _ := f.Seek(0, 1)
+	assert.EqualValues(t, 123, off)
+	if len(data) == 1 {
+		// Holes aren't supported here.
+		assert.Equal(t, []iv{{Start: 0, End: fileSize}}, data)
+		return
+	}
+	assert.Equal(t, []iv{{Start: 0, End: blockSize}, {Start: fileSize - blockSize, End: fileSize}}, data)
+	holes, err := Holes(f)
+	require.NoError(t, err)
+	assert.Equal(t, []iv{{Start: blockSize, End: fileSize - blockSize}}, holes)
+	size, err := AllocatedSize(f.Name())
+	require.NoError(t, err)
+	assert.True(t, size < fileSize, size)
+}
+
+func TestPunchHole(t *testing.T) {
+	f := sparseFile(t)
+	before, err := AllocatedSize(f.Name())
+	require.NoError(t, err)
+	err = PunchHole(f, 0, blockSize)
+	if err == ErrUnsupported {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	b := make([]byte, blockSize)
+	_, err = f.ReadAt(b, 0)
+	require.NoError(t, err)
+	assert.Equal(t, make([]byte, blockSize), b)
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	assert.EqualValues(t, fileSize, fi.Size())
+	after, err := AllocatedSize(f.Name())
+	require.NoError(t, err)
+	assert.True(t, after <= before, "%v > %v", after, before)
+}
@@ -0,0 +1,55 @@
+package sparse
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/anacrolix/missinggo/v2/intervals"
+)
+
+// Holes can't be found by seeking.
+func seekDataExtents(f *os.File, size int64) (ret []intervals.Interval[int64], ok bool, err error) {
+	return
+}
+
+const (
+	fsctlSetSparse   = 0x900c4
+	fsctlSetZeroData = 0x980c8
+)
+
+type fileZeroDataInformation struct {
+	FileOffset      int64
+	BeyondFinalZero int64
+}
+
+func punchHole(f *os.File, off, length int64) error {
+	h := syscall.Handle(f.Fd())
+	var n uint32
+	// Zeroing only deallocates in files marked sparse.
+	err := syscall.DeviceIoControl(h, fsctlSetSparse, nil, 0, nil, 0, &n, nil)
+	if err != nil {
+		return err
+	}
+	info := fileZeroDataInformation{off, off + length}
+	return syscall.DeviceIoControl(
+		h, fsctlSetZeroData,
+		(*byte)(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)),
+		nil, 0, &n, nil)
+}
+
+var procGetCompressedFileSizeW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetCompressedFileSizeW")
+
+func allocatedSize(path string) (int64, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var high uint32
+	low, _, err := procGetCompressedFileSizeW.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&high)))
+	// The low word is all ones on failure, or when that's the actual size.
+	if uint32(low) == ^uint32(0) && err != syscall.Errno(0) {
+		return 0, err
+	}
+	return int64(high)<<32 | int64(uint32(low)), nil
+}
@@ -4,20 +4,48 @@ import (
 	"fmt"
 	"io"
 	"runtime"
+	"strings"
 )
 
+// Writes the stack from runtime.Callers in the style of pprof's debug
+// output, with a line for each function and its position.
 func WriteStack(w io.Writer, stack []uintptr) {
-	for _, pc := range stack {
+	walkStack(stack, func(f runtime.Frame) {
+		fmt.Fprintf(w, "# %s:\t%s:%d\n", f.Function, f.File, f.Line)
+	})
+	fmt.Fprintf(w, "\n")
+}
+
+// Returns the stack from runtime.Callers with a line for each function, and
+// an indented line for its position, each line starting with prefix.
+func FormatStack(prefix string, pcs []uintptr) string {
+	var sb strings.Builder
+	walkStack(pcs, func(f runtime.Frame) {
+		fmt.Fprintf(&sb, "%s%s\n%s\t%s:%d\n", prefix, f.Function, prefix, f.File, f.Line)
+	})
+	return sb.String()
+}
+
+// Calls f for each frame of the stack, which ends at the first zero, as in
+// an array filled by runtime.Callers.
+func walkStack(pcs []uintptr, f func(runtime.Frame)) {
+	for i, pc := range pcs {
 		if pc == 0 {
+			pcs = pcs[:i]
 			break
 		}
-		pc--
-		f := runtime.FuncForPC(pc)
-		if f.Name() == "runtime.goexit" {
-			continue
+	}
+	if len(pcs) == 0 {
+		return
+	}
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "runtime.goexit" {
+			f(frame)
+		}
+		if !more {
+			return
 		}
-		file, line := f.FileLine(pc)
-		fmt.Fprintf(w, "# %s:\t%s:%d\n", f.Name(), file, line)
 	}
-	fmt.Fprintf(w, "\n")
 }
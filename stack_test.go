@@ -0,0 +1,24 @@
+package missinggo
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStackFormats(t *testing.T) {
+	var pcs [32]uintptr
+	stack := pcs[:runtime.Callers(1, pcs[:])]
+	const fn = "github.com/anacrolix/missinggo/v2.TestStackFormats"
+	s := FormatStack("\t", stack)
+	assert.True(t, strings.HasPrefix(s, "\t"+fn+"\n\t\t"), s)
+	assert.NotContains(t, s, "runtime.goexit")
+	var buf bytes.Buffer
+	// The array's unused zeroes end the stack.
+	WriteStack(&buf, pcs[:])
+	assert.True(t, strings.HasPrefix(buf.String(), "# "+fn+":\t"), buf.String())
+	assert.Equal(t, strings.Count(s, "\n")/2+1, strings.Count(buf.String(), "\n"))
+}
@@ -0,0 +1,67 @@
+package streammux
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+type frameType uint8
+
+const (
+	// Opens a stream with a new ID.
+	frameOpen frameType = iota
+	// Carries stream data in the payload.
+	frameData
+	// Grants the sender more window, in the length field.
+	frameWindow
+	// The sender won't send more data on the stream.
+	frameClose
+	// Aborts the stream in both directions.
+	frameReset
+)
+
+func (me frameType) String() string {
+	switch me {
+	case frameOpen:
+		return "open"
+	case frameData:
+		return "data"
+	case frameWindow:
+		return "window"
+	case frameClose:
+		return "close"
+	case frameReset:
+		return "reset"
+	default:
+		return fmt.Sprintf("frameType(%d)", uint8(me))
+	}
+}
+
+const headerLen = 9
+
+// Type, stream ID, and a length, which is the payload length for data
+// frames, and the window increment for window frames.
+type header struct {
+	typ    frameType
+	stream uint32
+	length uint32
+}
+
+func (me header) marshal(b *[headerLen]byte) {
+	b[0] = byte(me.typ)
+	binary.BigEndian.PutUint32(b[1:5], me.stream)
+	binary.BigEndian.PutUint32(b[5:9], me.length)
+}
+
+func readHeader(r io.Reader) (h header, err error) {
+	var b [headerLen]byte
+	_, err = io.ReadFull(r, b[:])
+	if err != nil {
+		return
+	}
+	h.typ = frameType(b[0])
+	h.stream = binary.BigEndian.Uint32(b[1:5])
+	h.length = binary.BigEndian.Uint32(b[5:9])
+	return
+}
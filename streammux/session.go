@@ -0,0 +1,224 @@
+// Package streammux multiplexes bidirectional streams, with flow control,
+// over a single reliable connection.
+package streammux
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	ErrSessionClosed = errors.New("session closed")
+	ErrStreamReset   = errors.New("stream reset")
+)
+
+const (
+	// The initial, and maximum, data a stream buffers before the reader
+	// consumes it.
+	streamWindow = 256 << 10
+	// The largest data frame sent.
+	maxDataFrame = 16 << 10
+	// Streams opened by the peer awaiting Accept. Further opens are reset.
+	acceptBacklog = 64
+)
+
+// One end of a multiplexed connection. Both ends can open streams.
+type Session struct {
+	conn io.ReadWriteCloser
+
+	writeMu sync.Mutex
+
+	mu       sync.Mutex
+	nextID   uint32
+	streams  map[uint32]*Stream
+	accepted chan *Stream
+	closed   chan struct{}
+	err      error
+}
+
+// Returns the Session for the end of conn that dialled.
+func Client(conn io.ReadWriteCloser) *Session {
+	return newSession(conn, 1)
+}
+
+// Returns the Session for the end of conn that accepted.
+func Server(conn io.ReadWriteCloser) *Session {
+	return newSession(conn, 2)
+}
+
+// Each end uses IDs of different parity, so they never collide.
+func newSession(conn io.ReadWriteCloser, firstID uint32) *Session {
+	me := &Session{
+		conn:     conn,
+		nextID:   firstID,
+		streams:  make(map[uint32]*Stream),
+		accepted: make(chan *Stream, acceptBacklog),
+		closed:   make(chan struct{}),
+	}
+	go me.readLoop()
+	return me
+}
+
+// Opens a new stream to the peer.
+func (me *Session) Open() (*Stream, error) {
+	me.mu.Lock()
+	if me.err != nil {
+		me.mu.Unlock()
+		return nil, me.err
+	}
+	s := newStream(me, me.nextID)
+	me.nextID += 2
+	me.streams[s.id] = s
+	me.mu.Unlock()
+	if err := me.writeFrame(header{typ: frameOpen, stream: s.id}, nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Waits for the peer to open a stream.
+func (me *Session) Accept() (*Stream, error) {
+	select {
+	case s := <-me.accepted:
+		return s, nil
+	case <-me.closed:
+		return nil, me.Err()
+	}
+}
+
+// Returns why the Session closed, or nil if it hasn't.
+func (me *Session) Err() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.err
+}
+
+// Closed when the Session is.
+func (me *Session) Done() <-chan struct{} {
+	return me.closed
+}
+
+// Closes the connection, and with it every stream.
+func (me *Session) Close() error {
+	return me.closeWithError(ErrSessionClosed)
+}
+
+func (me *Session) closeWithError(err error) error {
+	me.mu.Lock()
+	if me.err != nil {
+		me.mu.Unlock()
+		return nil
+	}
+	me.err = err
+	streams := me.streams
+	me.streams = nil
+	close(me.closed)
+	me.mu.Unlock()
+	for _, s := range streams {
+		s.sessionClosed(err)
+	}
+	return me.conn.Close()
+}
+
+func (me *Session) writeFrame(h header, payload []byte) error {
+	var b [headerLen]byte
+	h.marshal(&b)
+	me.writeMu.Lock()
+	defer me.writeMu.Unlock()
+	select {
+	case <-me.closed:
+		return me.Err()
+	default:
+	}
+	_, err := me.conn.Write(b[:])
+	if err == nil && len(payload) != 0 {
+		_, err = me.conn.Write(payload)
+	}
+	if err != nil {
+		me.closeWithError(err)
+	}
+	return err
+}
+
+func (me *Session) stream(id uint32) *Stream {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.streams[id]
+}
+
+func (me *Session) forget(id uint32) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	delete(me.streams, id)
+}
+
+func (me *Session) readLoop() {
+	for {
+		err := me.readFrame()
+		if err != nil {
+			if err == io.EOF {
+				err = ErrSessionClosed
+			}
+			me.closeWithError(err)
+			return
+		}
+	}
+}
+
+func (me *Session) readFrame() error {
+	h, err := readHeader(me.conn)
+	if err != nil {
+		return err
+	}
+	switch h.typ {
+	case frameOpen:
+		return me.opened(h.stream)
+	case frameData:
+		if h.length > maxDataFrame {
+			return fmt.Errorf("data frame of %d bytes exceeds maximum", h.length)
+		}
+		payload := make([]byte, h.length)
+		if _, err := io.ReadFull(me.conn, payload); err != nil {
+			return err
+		}
+		// Data for streams we've forgotten is dropped.
+		if s := me.stream(h.stream); s != nil {
+			return s.received(payload)
+		}
+	case frameWindow:
+		if s := me.stream(h.stream); s != nil {
+			s.windowGranted(h.length)
+		}
+	case frameClose:
+		if s := me.stream(h.stream); s != nil {
+			s.remoteClosed()
+		}
+	case frameReset:
+		if s := me.stream(h.stream); s != nil {
+			s.reset(ErrStreamReset)
+		}
+	default:
+		return fmt.Errorf("unknown frame type %v", h.typ)
+	}
+	return nil
+}
+
+func (me *Session) opened(id uint32) error {
+	me.mu.Lock()
+	if _, ok := me.streams[id]; ok || id%2 == me.nextID%2 {
+		me.mu.Unlock()
+		return fmt.Errorf("peer opened bad stream ID %d", id)
+	}
+	s := newStream(me, id)
+	me.streams[id] = s
+	me.mu.Unlock()
+	select {
+	case me.accepted <- s:
+	default:
+		me.forget(id)
+		go me.writeFrame(header{typ: frameReset, stream: id}, nil)
+	}
+	return nil
+}
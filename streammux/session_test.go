@@ -0,0 +1,140 @@
+package streammux
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/v2/inproc"
+	"github.com/anacrolix/missinggo/v2/leaktest"
+)
+
+func pair() (client, server *Session) {
+	a, b := net.Pipe()
+	return Client(a), Server(b)
+}
+
+func TestEcho(t *testing.T) {
+	defer leaktest.Check(t)()
+	c, s := pair()
+	defer c.Close()
+	defer s.Close()
+	go func() {
+		for {
+			st, err := s.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(st, st)
+				st.Close()
+			}()
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		st, err := c.Open()
+		require.NoError(t, err)
+		assert.EqualValues(t, 2*i+1, st.ID())
+		_, err = st.Write([]byte("hello"))
+		require.NoError(t, err)
+		require.NoError(t, st.CloseWrite())
+		b, err := io.ReadAll(st)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(b))
+	}
+}
+
+// Writing much more than a window's worth requires the reader to grant more.
+func TestFlowControl(t *testing.T) {
+	testFlowControl(t, pair)
+}
+
+// Window updates still flow when the link has latency, and buffers writes.
+func TestFlowControlLatency(t *testing.T) {
+	testFlowControl(t, func() (*Session, *Session) {
+		a, b := inproc.Pipe(inproc.Shaping{Latency: 5 * time.Millisecond})
+		return Client(a), Server(b)
+	})
+}
+
+func testFlowControl(t *testing.T, pair func() (client, server *Session)) {
+	defer leaktest.Check(t)()
+	c, s := pair()
+	defer c.Close()
+	defer s.Close()
+	data := make([]byte, 4*streamWindow+123)
+	rand.Read(data)
+	go func() {
+		st, err := c.Open()
+		if err != nil {
+			return
+		}
+		st.Write(data)
+		st.Close()
+	}()
+	st, err := s.Accept()
+	require.NoError(t, err)
+	b, err := io.ReadAll(st)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, b))
+}
+
+// A stream that isn't read from doesn't hold up others.
+func TestStreamsIndependent(t *testing.T) {
+	defer leaktest.Check(t)()
+	c, s := pair()
+	defer c.Close()
+	defer s.Close()
+	blocked, err := c.Open()
+	require.NoError(t, err)
+	writeDone := make(chan error, 1)
+	go func() {
+		_, err := blocked.Write(make([]byte, 2*streamWindow))
+		writeDone <- err
+	}()
+	other, err := c.Open()
+	require.NoError(t, err)
+	other.Write([]byte("x"))
+	other.Close()
+	s1, err := s.Accept()
+	require.NoError(t, err)
+	s2, err := s.Accept()
+	require.NoError(t, err)
+	b, err := io.ReadAll(s2)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(b))
+	select {
+	case <-writeDone:
+		t.Fatal("write completed beyond window")
+	default:
+	}
+	s1.Reset()
+	assert.Equal(t, ErrStreamReset, <-writeDone)
+}
+
+func TestSessionClose(t *testing.T) {
+	defer leaktest.Check(t)()
+	c, s := pair()
+	st, err := c.Open()
+	require.NoError(t, err)
+	_, err = s.Accept()
+	require.NoError(t, err)
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := st.Read(make([]byte, 1))
+		readErr <- err
+	}()
+	s.Close()
+	assert.Equal(t, ErrSessionClosed, <-readErr)
+	<-c.Done()
+	_, err = c.Open()
+	assert.Equal(t, ErrSessionClosed, err)
+	_, err = s.Accept()
+	assert.Equal(t, ErrSessionClosed, err)
+}
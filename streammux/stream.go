@@ -0,0 +1,186 @@
+package streammux
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A bidirectional stream within a Session.
+type Stream struct {
+	id uint32
+	s  *Session
+
+	mu   sync.Mutex
+	cond sync.Cond
+	// Received data not yet read.
+	buf bytes.Buffer
+	// Data read since the peer was last granted more window.
+	unacked uint32
+	// What we're allowed to send before the peer grants more.
+	sendWindow uint32
+	// Set when the peer won't send more.
+	remoteDone bool
+	// Set when we won't send more.
+	localDone bool
+	// Set when the stream was reset or the Session closed.
+	err error
+}
+
+func newStream(s *Session, id uint32) *Stream {
+	me := &Stream{
+		id:         id,
+		s:          s,
+		sendWindow: streamWindow,
+	}
+	me.cond.L = &me.mu
+	return me
+}
+
+func (me *Stream) ID() uint32 {
+	return me.id
+}
+
+func (me *Stream) Read(b []byte) (n int, err error) {
+	me.mu.Lock()
+	for me.buf.Len() == 0 && !me.remoteDone && me.err == nil {
+		me.cond.Wait()
+	}
+	if me.buf.Len() == 0 {
+		err = me.err
+		if err == nil {
+			err = io.EOF
+		}
+		me.mu.Unlock()
+		return
+	}
+	n, _ = me.buf.Read(b)
+	me.unacked += uint32(n)
+	var grant uint32
+	if me.unacked >= streamWindow/2 && !me.remoteDone {
+		grant = me.unacked
+		me.unacked = 0
+	}
+	me.mu.Unlock()
+	if grant != 0 {
+		me.s.writeFrame(header{typ: frameWindow, stream: me.id, length: grant}, nil)
+	}
+	return
+}
+
+func (me *Stream) Write(b []byte) (n int, err error) {
+	for n < len(b) {
+		me.mu.Lock()
+		for me.sendWindow == 0 && me.err == nil && !me.localDone {
+			me.cond.Wait()
+		}
+		if me.err != nil {
+			err = me.err
+		} else if me.localDone {
+			err = io.ErrClosedPipe
+		}
+		if err != nil {
+			me.mu.Unlock()
+			return
+		}
+		chunk := b[n:]
+		if len(chunk) > maxDataFrame {
+			chunk = chunk[:maxDataFrame]
+		}
+		if uint32(len(chunk)) > me.sendWindow {
+			chunk = chunk[:me.sendWindow]
+		}
+		me.sendWindow -= uint32(len(chunk))
+		me.mu.Unlock()
+		err = me.s.writeFrame(header{typ: frameData, stream: me.id, length: uint32(len(chunk))}, chunk)
+		if err != nil {
+			return
+		}
+		n += len(chunk)
+	}
+	return
+}
+
+// Tells the peer no more data will be written. Reading can continue until
+// the peer closes its side too.
+func (me *Stream) CloseWrite() error {
+	me.mu.Lock()
+	if me.localDone || me.err != nil {
+		me.mu.Unlock()
+		return nil
+	}
+	me.localDone = true
+	both := me.remoteDone
+	me.cond.Broadcast()
+	me.mu.Unlock()
+	if both {
+		me.s.forget(me.id)
+	}
+	return me.s.writeFrame(header{typ: frameClose, stream: me.id}, nil)
+}
+
+// Closes the writing side, like CloseWrite.
+func (me *Stream) Close() error {
+	return me.CloseWrite()
+}
+
+// Aborts the stream in both directions, discarding unread data.
+func (me *Stream) Reset() error {
+	me.reset(ErrStreamReset)
+	return me.s.writeFrame(header{typ: frameReset, stream: me.id}, nil)
+}
+
+func (me *Stream) reset(err error) {
+	me.mu.Lock()
+	if me.err == nil {
+		me.err = err
+		me.buf.Reset()
+	}
+	me.cond.Broadcast()
+	me.mu.Unlock()
+	me.s.forget(me.id)
+}
+
+func (me *Stream) sessionClosed(err error) {
+	me.mu.Lock()
+	if me.err == nil {
+		me.err = err
+	}
+	me.cond.Broadcast()
+	me.mu.Unlock()
+}
+
+func (me *Stream) received(b []byte) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.remoteDone {
+		return fmt.Errorf("data on stream %d after close", me.id)
+	}
+	if me.buf.Len()+len(b) > streamWindow {
+		return fmt.Errorf("stream %d window exceeded", me.id)
+	}
+	if me.err == nil {
+		me.buf.Write(b)
+		me.cond.Broadcast()
+	}
+	return nil
+}
+
+func (me *Stream) windowGranted(n uint32) {
+	me.mu.Lock()
+	me.sendWindow += n
+	me.cond.Broadcast()
+	me.mu.Unlock()
+}
+
+func (me *Stream) remoteClosed() {
+	me.mu.Lock()
+	me.remoteDone = true
+	both := me.localDone
+	me.cond.Broadcast()
+	me.mu.Unlock()
+	if both {
+		me.s.forget(me.id)
+	}
+}
@@ -0,0 +1,167 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type DeadlockOpts struct {
+	// Locks held longer than this are reported. Zero disables the check.
+	HoldThreshold time.Duration
+	// Called with a description of each problem, for example to log it
+	// instead. By default it panics.
+	Report func(msg string)
+}
+
+var deadlockDetectorValue atomic.Value
+
+// Starts recording the order each goroutine acquires locks in, reporting
+// acquisitions that invert a previously seen order, or that would deadlock
+// on a lock the goroutine already holds. Only locks acquired after this are
+// considered. This is intended for tests, and is expensive.
+func EnableDeadlockDetection(opts DeadlockOpts) {
+	deadlockDetectorValue.Store(&deadlockDetector{
+		opts:  opts,
+		held:  make(map[int64][]heldLock),
+		order: make(map[interface{}]map[interface{}]string),
+	})
+}
+
+func DisableDeadlockDetection() {
+	deadlockDetectorValue.Store((*deadlockDetector)(nil))
+}
+
+func currentDeadlockDetector() *deadlockDetector {
+	d, _ := deadlockDetectorValue.Load().(*deadlockDetector)
+	return d
+}
+
+type heldLock struct {
+	lock interface{}
+	kind string
+	site string
+}
+
+type deadlockDetector struct {
+	opts DeadlockOpts
+	mu   sync.Mutex
+	// Locks held by each goroutine, in the order acquired.
+	held map[int64][]heldLock
+	// order[a][b] is the site where b was first acquired while a was held.
+	order map[interface{}]map[interface{}]string
+}
+
+func (d *deadlockDetector) report(msg string) {
+	msg = "sync: " + msg
+	if d.opts.Report != nil {
+		d.opts.Report(msg)
+	} else {
+		panic(msg)
+	}
+}
+
+// Returns the site of an acquisition on a path from a to b in the observed
+// lock order.
+func (d *deadlockDetector) reaches(a, b interface{}) (site string, ok bool) {
+	seen := map[interface{}]bool{a: true}
+	next := []interface{}{a}
+	for len(next) != 0 {
+		cur := next[len(next)-1]
+		next = next[:len(next)-1]
+		for l, s := range d.order[cur] {
+			if l == b {
+				return s, true
+			}
+			if !seen[l] {
+				seen[l] = true
+				next = append(next, l)
+			}
+		}
+	}
+	return
+}
+
+// Called before goroutine g blocks on l.
+func (d *deadlockDetector) checkOrder(g int64, l heldLock) {
+	var problems []string
+	d.mu.Lock()
+	for _, h := range d.held[g] {
+		if h.lock == l.lock {
+			problems = append(problems, fmt.Sprintf(
+				"%s of %p at %s while already held since %s",
+				l.kind, l.lock, l.site, h.site))
+			continue
+		}
+		if site, ok := d.reaches(l.lock, h.lock); ok {
+			problems = append(problems, fmt.Sprintf(
+				"lock order inversion: %s of %p at %s while holding %p from %s, having seen the opposite order at %s",
+				l.kind, l.lock, l.site, h.lock, h.site, site))
+			continue
+		}
+		after := d.order[h.lock]
+		if after == nil {
+			after = make(map[interface{}]string)
+			d.order[h.lock] = after
+		}
+		if _, ok := after[l.lock]; !ok {
+			after[l.lock] = l.site
+		}
+	}
+	d.mu.Unlock()
+	for _, p := range problems {
+		d.report(p)
+	}
+}
+
+// Records that g now holds l. The returned timer, if any, reports l being held
+// for too long.
+func (d *deadlockDetector) acquired(g int64, l heldLock) (alarm *time.Timer) {
+	d.mu.Lock()
+	d.held[g] = append(d.held[g], l)
+	d.mu.Unlock()
+	if d.opts.HoldThreshold > 0 {
+		alarm = time.AfterFunc(d.opts.HoldThreshold, func() {
+			d.report(fmt.Sprintf("%s of %p acquired at %s held longer than %s",
+				l.kind, l.lock, l.site, d.opts.HoldThreshold))
+		})
+	}
+	return
+}
+
+func (d *deadlockDetector) released(g int64, lock interface{}, alarm *time.Timer) {
+	if alarm != nil {
+		alarm.Stop()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	held := d.held[g]
+	for i := len(held) - 1; i >= 0; i-- {
+		if held[i].lock == lock {
+			held = append(held[:i], held[i+1:]...)
+			break
+		}
+	}
+	if len(held) == 0 {
+		delete(d.held, g)
+	} else {
+		d.held[g] = held
+	}
+}
+
+// Parses the goroutine ID from the header of its stack trace.
+func goroutineId() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	b = b[:bytes.IndexByte(b, ' ')]
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadlockLockOrderInversion(t *testing.T) {
+	EnableDeadlockDetection(DeadlockOpts{})
+	defer DisableDeadlockDetection()
+	var a Mutex
+	var b RWMutex
+	a.Lock()
+	b.RLock()
+	b.RUnlock()
+	a.Unlock()
+	// Releasing in a different order from acquisition doesn't matter.
+	a.Lock()
+	b.Lock()
+	a.Unlock()
+	b.Unlock()
+	b.Lock()
+	assert.Panics(t, a.Lock)
+	b.Unlock()
+	// Without b held, there's no problem.
+	a.Lock()
+	a.Unlock()
+}
+
+func TestDeadlockRecursiveLock(t *testing.T) {
+	EnableDeadlockDetection(DeadlockOpts{})
+	defer DisableDeadlockDetection()
+	var mu RWMutex
+	mu.RLock()
+	assert.Panics(t, mu.Lock)
+	assert.Panics(t, mu.RLock)
+	mu.RUnlock()
+}
+
+func TestDeadlockHoldThreshold(t *testing.T) {
+	reports := make(chan string, 1)
+	EnableDeadlockDetection(DeadlockOpts{
+		HoldThreshold: time.Millisecond,
+		Report: func(msg string) {
+			reports <- msg
+		},
+	})
+	defer DisableDeadlockDetection()
+	var mu Mutex
+	mu.Lock()
+	msg := <-reports
+	mu.Unlock()
+	assert.Contains(t, msg, "held longer than 1ms")
+	assert.Contains(t, msg, "TestDeadlockHoldThreshold")
+}
@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2/perf"
+)
+
+// Frames from a profile Add in this file to the caller of the lock method.
+const profileSkip = 2
+
+// Returns the name of the function that called the lock method.
+func lockSite() string {
+	var pc [1]uintptr
+	// Skip runtime.Callers, lockSite, startWait and the lock method.
+	runtime.Callers(4, pc[:])
+	f, _ := runtime.CallersFrames(pc[:]).Next()
+	return f.Function
+}
+
+// Whether lock methods need to do more than lock.
+func instrumenting() bool {
+	return Enabled() || currentDeadlockDetector() != nil
+}
+
+// Tracks a goroutine waiting on a lock.
+type waiter struct {
+	lock interface{}
+	// "lock" or "rlock", distinguishing writers from readers.
+	kind string
+	site string
+	// Set if profiling.
+	timer *perf.Timer
+	// Set if detecting deadlocks.
+	deadlock *deadlockDetector
+	g        int64
+}
+
+func startWait(lock interface{}, kind string) (w waiter) {
+	w.lock = lock
+	w.kind = kind
+	w.site = lockSite()
+	if Enabled() {
+		w.timer = perf.NewTimer(perf.Name(w.site))
+		lockBlockers.Add(w.timer, profileSkip)
+	}
+	if w.deadlock = currentDeadlockDetector(); w.deadlock != nil {
+		w.g = goroutineId()
+		w.deadlock.checkOrder(w.g, heldLock{w.lock, w.kind, w.site})
+	}
+	return
+}
+
+func (w waiter) holders() *pprof.Profile {
+	if w.kind == "rlock" {
+		return rlockHolders
+	}
+	return lockHolders
+}
+
+// Tracks a goroutine holding a lock, from the moment it was acquired.
+type holder struct {
+	waiter
+	held  *perf.Timer
+	alarm *time.Timer
+}
+
+func (w waiter) acquired() (h *holder) {
+	h = &holder{waiter: w}
+	if w.timer != nil {
+		lockBlockers.Remove(w.timer)
+		w.timer.Mark(w.kind + " wait")
+		h.held = perf.NewTimer(perf.Name(w.site))
+		w.holders().Add(h, profileSkip)
+	}
+	if w.deadlock != nil {
+		h.alarm = w.deadlock.acquired(w.g, heldLock{w.lock, w.kind, w.site})
+	}
+	return
+}
+
+func (h *holder) release() {
+	if h.held != nil {
+		h.holders().Remove(h)
+		h.held.Mark(h.kind + " hold")
+	}
+	if h.deadlock != nil {
+		h.deadlock.released(h.g, h.lock, h.alarm)
+	}
+}
@@ -0,0 +1,27 @@
+package sync
+
+import "sync"
+
+type Mutex struct {
+	mu sync.Mutex
+	// Set while locked, if instrumentation was enabled at the time.
+	hold *holder
+}
+
+func (me *Mutex) Lock() {
+	if !instrumenting() {
+		me.mu.Lock()
+		return
+	}
+	w := startWait(me, "lock")
+	me.mu.Lock()
+	me.hold = w.acquired()
+}
+
+func (me *Mutex) Unlock() {
+	if h := me.hold; h != nil {
+		me.hold = nil
+		h.release()
+	}
+	me.mu.Unlock()
+}
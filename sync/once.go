@@ -0,0 +1,55 @@
+package sync
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Like Once, for funcs that can fail. Every call to Do returns the error from
+// the one that ran.
+type OnceErr struct {
+	done uint32
+	mu   sync.Mutex
+	err  error
+}
+
+func (me *OnceErr) Do(fn func() error) error {
+	if atomic.LoadUint32(&me.done) != 0 {
+		return me.err
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.done == 0 {
+		defer atomic.StoreUint32(&me.done, 1)
+		me.err = fn()
+	}
+	return me.err
+}
+
+// Like OnceErr, but Reset allows the func to run again. For example, to retry
+// failed initialization, Reset after Do returns an error.
+type ResettableOnce struct {
+	mu   sync.Mutex
+	done bool
+	err  error
+}
+
+// Runs fn if it hasn't run since creation or the last Reset. Concurrent
+// callers wait for it, and get its error.
+func (me *ResettableOnce) Do(fn func() error) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if !me.done {
+		me.done = true
+		me.err = fn()
+	}
+	return me.err
+}
+
+// Allows the next Do to run its func. It waits for any Do in progress.
+func (me *ResettableOnce) Reset() {
+	me.mu.Lock()
+	me.done = false
+	me.err = nil
+	me.mu.Unlock()
+}
@@ -0,0 +1,46 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnceErr(t *testing.T) {
+	var once OnceErr
+	boom := errors.New("boom")
+	calls := 0
+	var wg WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, boom, once.Do(func() error {
+				calls++
+				return boom
+			}))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, calls)
+}
+
+func TestOnceErrPanic(t *testing.T) {
+	var once OnceErr
+	assert.Panics(t, func() {
+		once.Do(func() error { panic("boom") })
+	})
+	// Like Once, a panicking func counts as having run.
+	assert.NoError(t, once.Do(func() error { return errors.New("not run") }))
+}
+
+func TestResettableOnce(t *testing.T) {
+	var once ResettableOnce
+	boom := errors.New("boom")
+	assert.Equal(t, boom, once.Do(func() error { return boom }))
+	assert.Equal(t, boom, once.Do(func() error { return nil }))
+	once.Reset()
+	assert.NoError(t, once.Do(func() error { return nil }))
+	assert.NoError(t, once.Do(func() error { return boom }))
+}
@@ -0,0 +1,85 @@
+package sync
+
+import "sync"
+
+type RWMutex struct {
+	mu   sync.RWMutex
+	hold *holder
+	// Guards readers.
+	readersMu sync.Mutex
+	// Instrumented readers. RUnlock releases one acquired by the calling
+	// goroutine if there is one, otherwise an arbitrary one, so the holder
+	// stacks are approximate, though the counts are exact.
+	readers []*holder
+}
+
+func (me *RWMutex) Lock() {
+	if !instrumenting() {
+		me.mu.Lock()
+		return
+	}
+	w := startWait(me, "lock")
+	me.mu.Lock()
+	me.hold = w.acquired()
+}
+
+func (me *RWMutex) Unlock() {
+	if h := me.hold; h != nil {
+		me.hold = nil
+		h.release()
+	}
+	me.mu.Unlock()
+}
+
+func (me *RWMutex) RLock() {
+	if !instrumenting() {
+		me.mu.RLock()
+		return
+	}
+	w := startWait(me, "rlock")
+	me.mu.RLock()
+	h := w.acquired()
+	me.readersMu.Lock()
+	me.readers = append(me.readers, h)
+	me.readersMu.Unlock()
+}
+
+func (me *RWMutex) RUnlock() {
+	if h := me.popReader(); h != nil {
+		h.release()
+	}
+	me.mu.RUnlock()
+}
+
+func (me *RWMutex) popReader() (h *holder) {
+	me.readersMu.Lock()
+	defer me.readersMu.Unlock()
+	n := len(me.readers)
+	if n == 0 {
+		return nil
+	}
+	i := n - 1
+	if me.readers[i].deadlock != nil {
+		g := goroutineId()
+		for j := range me.readers {
+			if me.readers[j].g == g {
+				i = j
+				break
+			}
+		}
+	}
+	h = me.readers[i]
+	me.readers[i] = me.readers[n-1]
+	me.readers[n-1] = nil
+	me.readers = me.readers[:n-1]
+	return
+}
+
+func (me *RWMutex) RLocker() Locker {
+	return (*rlocker)(me)
+}
+
+type rlocker RWMutex
+
+func (me *rlocker) Lock()   { (*RWMutex)(me).RLock() }
+func (me *rlocker) Unlock() { (*RWMutex)(me).RUnlock() }
@@ -0,0 +1,57 @@
+// Package sync is a drop-in replacement for the standard library sync, with
+// locks that can record contention, for debugging. Instrumentation is off by
+// default, and is enabled by setting PPROF_SYNC in the environment, or
+// calling Enable. Locks can also check for potential deadlocks, see
+// EnableDeadlockDetection.
+package sync
+
+import (
+	"os"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	Cond      = sync.Cond
+	Locker    = sync.Locker
+	Map       = sync.Map
+	Once      = sync.Once
+	Pool      = sync.Pool
+	WaitGroup = sync.WaitGroup
+)
+
+func NewCond(l Locker) *Cond {
+	return sync.NewCond(l)
+}
+
+var (
+	enabled int32
+	// Stacks of goroutines holding locks for writing.
+	lockHolders = pprof.NewProfile("lockHolders")
+	// Stacks of goroutines holding locks for reading.
+	rlockHolders = pprof.NewProfile("rlockHolders")
+	// Stacks of goroutines waiting to acquire a lock.
+	lockBlockers = pprof.NewProfile("lockBlockers")
+)
+
+func init() {
+	if os.Getenv("PPROF_SYNC") != "" {
+		Enable()
+	}
+}
+
+// Starts recording lock wait and hold times into perf events, and the stacks
+// of holders and blocked goroutines into pprof profiles. Only locks acquired
+// after this are recorded.
+func Enable() {
+	atomic.StoreInt32(&enabled, 1)
+}
+
+func Disable() {
+	atomic.StoreInt32(&enabled, 0)
+}
+
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) != 0
+}
@@ -0,0 +1,54 @@
+package sync
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anacrolix/missinggo/v2/perf"
+)
+
+func TestRWMutexContention(t *testing.T) {
+	Enable()
+	defer Disable()
+	var mu RWMutex
+	mu.RLock()
+	mu.RLock()
+	assert.EqualValues(t, 2, rlockHolders.Count())
+	locked := make(chan struct{})
+	go func() {
+		mu.Lock()
+		close(locked)
+	}()
+	for lockBlockers.Count() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	mu.RUnlock()
+	mu.RUnlock()
+	<-locked
+	assert.EqualValues(t, 0, rlockHolders.Count())
+	assert.EqualValues(t, 0, lockBlockers.Count())
+	assert.EqualValues(t, 1, lockHolders.Count())
+	mu.Unlock()
+	assert.EqualValues(t, 0, lockHolders.Count())
+	var buf bytes.Buffer
+	perf.WriteEventsTable(&buf)
+	for _, e := range []string{"rlock wait", "rlock hold", "lock wait", "lock hold"} {
+		assert.Contains(t, buf.String(), e)
+	}
+}
+
+func TestMutexDisabled(t *testing.T) {
+	var mu Mutex
+	mu.Lock()
+	assert.EqualValues(t, 0, lockHolders.Count())
+	Enable()
+	defer Disable()
+	mu.Unlock()
+	mu.Lock()
+	assert.EqualValues(t, 1, lockHolders.Count())
+	mu.Unlock()
+	assert.EqualValues(t, 0, lockHolders.Count())
+}
@@ -0,0 +1,108 @@
+// Package taskgroup runs labelled tasks together, like
+// golang.org/x/sync/errgroup, but with panics converted to errors, and the
+// running tasks observable.
+package taskgroup
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2/recovery"
+)
+
+// A collection of tasks. The first to fail cancels the Context from
+// WithContext. The zero value is usable, without the cancellation.
+type Group struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	err     error
+	running map[*Task]struct{}
+}
+
+// A task that's running.
+type Task struct {
+	Label   string
+	Started time.Time
+}
+
+// The error of a failed task.
+type TaskError struct {
+	Label string
+	Err   error
+}
+
+func (me TaskError) Error() string {
+	return me.Label + ": " + me.Err.Error()
+}
+
+func (me TaskError) Unwrap() error {
+	return me.Err
+}
+
+// A task's panic, recovered.
+type PanicError = recovery.PanicError
+
+// Returns a Group, and a Context derived from ctx that's cancelled when a
+// task fails, or Wait returns.
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// Runs fn in a new goroutine. If it returns an error or panics, and it's the
+// first task to do so, the error is returned by Wait.
+func (me *Group) Go(label string, fn func() error) {
+	t := &Task{
+		Label:   label,
+		Started: time.Now(),
+	}
+	me.mu.Lock()
+	if me.running == nil {
+		me.running = make(map[*Task]struct{})
+	}
+	me.running[t] = struct{}{}
+	me.mu.Unlock()
+	me.wg.Add(1)
+	go func() {
+		defer me.wg.Done()
+		err := recovery.Call(fn)
+		me.mu.Lock()
+		delete(me.running, t)
+		first := err != nil && me.err == nil
+		if first {
+			me.err = TaskError{label, err}
+		}
+		me.mu.Unlock()
+		if first && me.cancel != nil {
+			me.cancel()
+		}
+	}()
+}
+
+// Waits for all the tasks, returning the first error, as a TaskError.
+func (me *Group) Wait() error {
+	me.wg.Wait()
+	if me.cancel != nil {
+		me.cancel()
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.err
+}
+
+// Returns the tasks still running, in the order they were started.
+func (me *Group) Running() (ret []Task) {
+	me.mu.Lock()
+	for t := range me.running {
+		ret = append(ret, *t)
+	}
+	me.mu.Unlock()
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Started.Before(ret[j].Started)
+	})
+	return
+}
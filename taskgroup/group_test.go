@@ -0,0 +1,49 @@
+package taskgroup
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupFirstErrorCancels(t *testing.T) {
+	g, ctx := WithContext(context.Background())
+	started := make(chan struct{})
+	g.Go("waiter", func() error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-started
+	assert.Equal(t, []string{"waiter"}, labels(g.Running()))
+	boom := errors.New("boom")
+	g.Go("failer", func() error { return boom })
+	err := g.Wait()
+	assert.EqualError(t, err, "failer: boom")
+	assert.True(t, errors.Is(err, boom))
+	assert.Empty(t, g.Running())
+}
+
+func TestGroupPanic(t *testing.T) {
+	var g Group
+	g.Go("ok", func() error { return nil })
+	g.Go("panicker", func() error { panic("oh no") })
+	err := g.Wait()
+	var te TaskError
+	require.True(t, errors.As(err, &te))
+	assert.Equal(t, "panicker", te.Label)
+	var pe PanicError
+	require.True(t, errors.As(err, &pe))
+	assert.Equal(t, "oh no", pe.Value)
+	assert.Contains(t, string(pe.Stack), "TestGroupPanic")
+}
+
+func labels(ts []Task) (ret []string) {
+	for _, t := range ts {
+		ret = append(ret, t.Label)
+	}
+	return
+}
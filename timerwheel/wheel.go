@@ -0,0 +1,245 @@
+// Package timerwheel schedules large numbers of coarse timers, without a
+// runtime timer for each.
+package timerwheel
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2/clock"
+)
+
+const (
+	levelBits = 6
+	levelSize = 1 << levelBits
+	levelMask = levelSize - 1
+	numLevels = 4
+	// Timers further out than this are placed in the last level, and
+	// rescheduled when they cascade out of it.
+	maxTicks = 1 << (levelBits * numLevels)
+)
+
+// A hierarchical timer wheel. Scheduling and stopping timers are O(1).
+// Expiry has the resolution of the wheel's tick, and timers never fire early.
+// Timers that expire on the same tick have their funcs called in a batch, in
+// the wheel's goroutine, so they should be quick, or hand off the work.
+type Wheel struct {
+	tick  time.Duration
+	clock clock.Clock
+	// Returns the time since the wheel started.
+	elapsed func() time.Duration
+	stop    chan struct{}
+	done    chan struct{}
+
+	mu sync.Mutex
+	// Ticks processed.
+	now     uint64
+	levels  [numLevels][levelSize]slot
+	pending int
+}
+
+type slot struct {
+	root Timer
+}
+
+func (me *slot) init() {
+	me.root.next = &me.root
+	me.root.prev = &me.root
+}
+
+func (me *slot) push(t *Timer) {
+	t.slot = me
+	t.prev = me.root.prev
+	t.next = &me.root
+	t.prev.next = t
+	t.next.prev = t
+}
+
+// Empties the slot, returning the first of its timers, which remain linked
+// to each other through next, ending in nil.
+func (me *slot) take() *Timer {
+	if me.root.next == &me.root {
+		return nil
+	}
+	first := me.root.next
+	me.root.prev.next = nil
+	me.init()
+	return first
+}
+
+type Timer struct {
+	w  *Wheel
+	f  func()
+	at uint64
+	// Set while the timer is pending.
+	slot       *slot
+	prev, next *Timer
+}
+
+// Returns a running Wheel with the given tick. Close it to stop its
+// goroutine.
+func New(tick time.Duration) *Wheel {
+	return NewWithClock(tick, clock.Real)
+}
+
+// Like New, but ticks are driven by c.
+func NewWithClock(tick time.Duration, c clock.Clock) *Wheel {
+	me := newWheel(tick, c)
+	go me.run()
+	return me
+}
+
+func newWheel(tick time.Duration, c clock.Clock) *Wheel {
+	started := c.Now()
+	me := &Wheel{
+		tick:  tick,
+		clock: c,
+		elapsed: func() time.Duration {
+			return c.Since(started)
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	for l := range me.levels {
+		for s := range me.levels[l] {
+			me.levels[l][s].init()
+		}
+	}
+	return me
+}
+
+func (me *Wheel) run() {
+	defer close(me.done)
+	t := me.clock.NewTicker(me.tick)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+			me.advance(uint64(me.elapsed() / me.tick))
+		case <-me.stop:
+			return
+		}
+	}
+}
+
+// Stops the wheel. Pending timers never fire.
+func (me *Wheel) Close() {
+	select {
+	case <-me.stop:
+	default:
+		close(me.stop)
+	}
+	<-me.done
+}
+
+// Returns the number of pending timers.
+func (me *Wheel) Len() int {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.pending
+}
+
+// Calls f in the wheel's goroutine after at least d.
+func (me *Wheel) AfterFunc(d time.Duration, f func()) *Timer {
+	t := &Timer{w: me, f: f}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.schedule(t, d)
+	return t
+}
+
+func (me *Wheel) schedule(t *Timer, d time.Duration) {
+	// Rounded up, and offset by the time into the current tick, so the
+	// timer can't fire early.
+	intoTick := me.elapsed() - time.Duration(me.now)*me.tick
+	ticks := (d + intoTick + me.tick - 1) / me.tick
+	if ticks < 1 {
+		ticks = 1
+	}
+	t.at = me.now + uint64(ticks)
+	me.pending++
+	me.place(t)
+}
+
+// Puts the timer in the slot for when it's due, relative to now.
+func (me *Wheel) place(t *Timer) {
+	delta := t.at - me.now
+	at := t.at
+	if delta >= maxTicks {
+		at = me.now + maxTicks - 1
+		delta = maxTicks - 1
+	}
+	level := 0
+	for delta >= levelSize {
+		delta >>= levelBits
+		level++
+	}
+	me.levels[level][(at>>(levelBits*level))&levelMask].push(t)
+}
+
+func (me *Wheel) unlink(t *Timer) {
+	t.prev.next = t.next
+	t.next.prev = t.prev
+	t.prev = nil
+	t.next = nil
+	t.slot = nil
+	me.pending--
+}
+
+// Processes ticks up to target, calling the funcs of expired timers.
+func (me *Wheel) advance(target uint64) {
+	var expired []func()
+	me.mu.Lock()
+	for me.now < target {
+		me.now++
+		me.cascade(1)
+		for t := me.levels[0][me.now&levelMask].take(); t != nil; {
+			next := t.next
+			t.prev, t.next, t.slot = nil, nil, nil
+			me.pending--
+			expired = append(expired, t.f)
+			t = next
+		}
+	}
+	me.mu.Unlock()
+	for _, f := range expired {
+		f()
+	}
+}
+
+// When a level wraps around, the timers in the next level's current slot are
+// redistributed into lower levels.
+func (me *Wheel) cascade(level int) {
+	if level == numLevels || (me.now>>(levelBits*(level-1)))&levelMask != 0 {
+		return
+	}
+	me.cascade(level + 1)
+	for t := me.levels[level][(me.now>>(levelBits*level))&levelMask].take(); t != nil; {
+		next := t.next
+		me.place(t)
+		t = next
+	}
+}
+
+// Prevents the timer firing, and returns whether it was pending.
+func (me *Timer) Stop() bool {
+	me.w.mu.Lock()
+	defer me.w.mu.Unlock()
+	if me.slot == nil {
+		return false
+	}
+	me.w.unlink(me)
+	return true
+}
+
+// Reschedules the timer to fire after d, and returns whether it was pending.
+func (me *Timer) Reset(d time.Duration) (wasPending bool) {
+	me.w.mu.Lock()
+	defer me.w.mu.Unlock()
+	if me.slot != nil {
+		me.w.unlink(me)
+		wasPending = true
+	}
+	me.w.schedule(me, d)
+	return
+}
@@ -0,0 +1,104 @@
+package timerwheel
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anacrolix/missinggo/v2/clock"
+)
+
+const testTick = time.Second
+
+// Returns a Wheel where time only passes by calling advance.
+func newTestWheel() *Wheel {
+	w := newWheel(testTick, clock.Real)
+	w.elapsed = func() time.Duration {
+		return time.Duration(w.now) * testTick
+	}
+	return w
+}
+
+func TestExpiry(t *testing.T) {
+	w := newTestWheel()
+	r := rand.New(rand.NewSource(1))
+	var dues []uint64
+	fired := 0
+	for i := 0; i < 10000; i++ {
+		// Spread over all the levels, including beyond the last.
+		due := 1 + uint64(r.Int63n(1<<uint(r.Intn(26))))
+		dues = append(dues, due)
+		w.AfterFunc(time.Duration(due)*testTick, func() {
+			assert.True(t, due <= w.now)
+			fired++
+		})
+	}
+	assert.Equal(t, len(dues), w.Len())
+	sort.Slice(dues, func(i, j int) bool { return dues[i] < dues[j] })
+	for fired < len(dues) {
+		w.advance(w.now + 1 + uint64(r.Intn(1<<16)))
+		// Everything due has fired, and nothing else.
+		due := sort.Search(len(dues), func(i int) bool { return dues[i] > w.now })
+		if !assert.Equal(t, due, fired, w.now) {
+			break
+		}
+	}
+	assert.Equal(t, 0, w.Len())
+}
+
+func TestStopReset(t *testing.T) {
+	w := newTestWheel()
+	fired := 0
+	t1 := w.AfterFunc(5*testTick, func() { fired++ })
+	t2 := w.AfterFunc(100*testTick, func() { fired++ })
+	assert.True(t, t1.Stop())
+	assert.False(t, t1.Stop())
+	assert.True(t, t2.Reset(2*testTick))
+	w.advance(2)
+	assert.Equal(t, 1, fired)
+	assert.False(t, t2.Stop())
+	assert.False(t, t1.Reset(testTick))
+	w.advance(3)
+	assert.Equal(t, 2, fired)
+}
+
+func TestRunning(t *testing.T) {
+	w := New(time.Millisecond)
+	defer w.Close()
+	var wg sync.WaitGroup
+	started := time.Now()
+	for i := 0; i < 1000; i++ {
+		wg.Add(1)
+		d := time.Duration(i%20) * time.Millisecond
+		w.AfterFunc(d, func() {
+			assert.True(t, time.Since(started) >= d)
+			wg.Done()
+		})
+	}
+	wg.Wait()
+	assert.Equal(t, 0, w.Len())
+}
+
+func TestFakeClock(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	w := NewWithClock(testTick, c)
+	defer w.Close()
+	fired := make(chan time.Time, 1)
+	w.AfterFunc(90*time.Second, func() { fired <- c.Now() })
+	// Wait for the wheel's ticker.
+	c.BlockUntil(1)
+	for i := 0; i < 89; i++ {
+		c.Advance(testTick)
+	}
+	select {
+	case <-fired:
+		t.Fatal("fired early")
+	case <-time.After(10 * time.Millisecond):
+	}
+	c.Advance(testTick)
+	assert.Equal(t, time.Unix(90, 0), <-fired)
+}
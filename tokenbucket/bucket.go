@@ -0,0 +1,207 @@
+// Package tokenbucket provides hierarchical rate limiting, where the tokens
+// taken from a bucket are also taken from its ancestors, such as to split a
+// global bandwidth budget across categories of traffic.
+package tokenbucket
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// A rate with no limit.
+var Inf = math.Inf(1)
+
+// Buckets in a hierarchy share a lock, so tokens are taken from every level
+// together.
+type tree struct {
+	mu sync.Mutex
+}
+
+// A token bucket, refilled at a rate up to its burst size. Taking from a
+// child bucket requires tokens be available in it and all its ancestors. It's
+// safe for concurrent use.
+type Bucket struct {
+	tree   *tree
+	parent *Bucket
+
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	stats  Stats
+}
+
+type Stats struct {
+	// Tokens taken from this bucket or its descendants.
+	Taken int64
+	// Takes that had to wait, and how long they waited in total.
+	Waits    int64
+	WaitTime time.Duration
+}
+
+func newBucket(t *tree, parent *Bucket, rate float64, burst int) *Bucket {
+	return &Bucket{
+		tree:   t,
+		parent: parent,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Returns a root bucket refilled at rate tokens per second, holding at most
+// burst.
+func New(rate float64, burst int) *Bucket {
+	return newBucket(new(tree), nil, rate, burst)
+}
+
+// Returns a bucket that borrows from me. Its own rate and burst further limit
+// what's taken from it. Use Inf for a child limited only by its ancestors.
+func (me *Bucket) NewChild(rate float64, burst int) *Bucket {
+	return newBucket(me.tree, me, rate, burst)
+}
+
+func (me *Bucket) refill(now time.Time) {
+	if now.After(me.last) {
+		me.tokens = math.Min(me.burst, me.tokens+me.rate*now.Sub(me.last).Seconds())
+		me.last = now
+	}
+}
+
+func (me *Bucket) limited() bool {
+	return !math.IsInf(me.rate, 1)
+}
+
+// Changes the refill rate, from now.
+func (me *Bucket) SetRate(rate float64) {
+	me.tree.mu.Lock()
+	defer me.tree.mu.Unlock()
+	me.refill(time.Now())
+	me.rate = rate
+}
+
+func (me *Bucket) SetBurst(burst int) {
+	me.tree.mu.Lock()
+	defer me.tree.mu.Unlock()
+	me.refill(time.Now())
+	me.burst = float64(burst)
+	me.tokens = math.Min(me.tokens, me.burst)
+}
+
+func (me *Bucket) Rate() float64 {
+	me.tree.mu.Lock()
+	defer me.tree.mu.Unlock()
+	return me.rate
+}
+
+// Returns the tokens available now, which can be negative when takes are
+// waiting on them.
+func (me *Bucket) Tokens() float64 {
+	me.tree.mu.Lock()
+	defer me.tree.mu.Unlock()
+	me.refill(time.Now())
+	return me.tokens
+}
+
+func (me *Bucket) Stats() Stats {
+	me.tree.mu.Lock()
+	defer me.tree.mu.Unlock()
+	return me.stats
+}
+
+// Returns how long until n tokens are available at every level, or an error
+// if they never will be.
+func (me *Bucket) delay(n float64, now time.Time) (d time.Duration, err error) {
+	for b := me; b != nil; b = b.parent {
+		if !b.limited() {
+			continue
+		}
+		if n > b.burst {
+			return 0, fmt.Errorf("%v tokens exceeds burst %v", n, b.burst)
+		}
+		b.refill(now)
+		if b.tokens >= n {
+			continue
+		}
+		if b.rate <= 0 {
+			return 0, fmt.Errorf("no tokens, and rate is %v", b.rate)
+		}
+		if bd := time.Duration((n - b.tokens) / b.rate * float64(time.Second)); bd > d {
+			d = bd
+		}
+	}
+	return
+}
+
+func (me *Bucket) take(n float64) {
+	for b := me; b != nil; b = b.parent {
+		if b.limited() {
+			b.tokens -= n
+		}
+		b.stats.Taken += int64(n)
+	}
+}
+
+func (me *Bucket) giveBack(n float64) {
+	for b := me; b != nil; b = b.parent {
+		if b.limited() {
+			b.tokens = math.Min(b.burst, b.tokens+n)
+		}
+		b.stats.Taken -= int64(n)
+	}
+}
+
+// Takes n tokens if they're available now at every level, and reports
+// whether it did.
+func (me *Bucket) AllowN(n int) bool {
+	me.tree.mu.Lock()
+	defer me.tree.mu.Unlock()
+	d, err := me.delay(float64(n), time.Now())
+	if err != nil || d > 0 {
+		return false
+	}
+	me.take(float64(n))
+	return true
+}
+
+// Waits until n tokens can be taken, and takes them. Tokens are reserved
+// while waiting, so waiters are served in order. If ctx is done first, the
+// reservation is returned, and so is ctx's error.
+func (me *Bucket) WaitN(ctx context.Context, n int) error {
+	me.tree.mu.Lock()
+	d, err := me.delay(float64(n), time.Now())
+	if err != nil {
+		me.tree.mu.Unlock()
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		me.tree.mu.Unlock()
+		return fmt.Errorf("would wait %v beyond context deadline", d)
+	}
+	me.take(float64(n))
+	if d > 0 {
+		for b := me; b != nil; b = b.parent {
+			b.stats.Waits++
+			b.stats.WaitTime += d
+		}
+	}
+	me.tree.mu.Unlock()
+	if d == 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		me.tree.mu.Lock()
+		me.giveBack(float64(n))
+		me.tree.mu.Unlock()
+		return ctx.Err()
+	}
+}
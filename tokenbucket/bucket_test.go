@@ -0,0 +1,68 @@
+package tokenbucket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChildrenShareParent(t *testing.T) {
+	root := New(0.001, 10)
+	peers := root.NewChild(Inf, 0)
+	webseeds := root.NewChild(0.001, 8)
+	assert.True(t, webseeds.AllowN(8))
+	assert.False(t, webseeds.AllowN(1))
+	assert.False(t, peers.AllowN(3))
+	assert.True(t, peers.AllowN(2))
+	assert.False(t, root.AllowN(1))
+	assert.EqualValues(t, 10, root.Stats().Taken)
+	assert.EqualValues(t, 2, peers.Stats().Taken)
+	// More than the burst can never be satisfied.
+	assert.Error(t, webseeds.WaitN(context.Background(), 9))
+}
+
+func TestWaitN(t *testing.T) {
+	root := New(1000, 50)
+	child := root.NewChild(Inf, 0)
+	require.True(t, child.AllowN(50))
+	started := time.Now()
+	require.NoError(t, child.WaitN(context.Background(), 50))
+	waited := time.Since(started)
+	assert.True(t, waited >= 40*time.Millisecond, waited)
+	s := root.Stats()
+	assert.EqualValues(t, 1, s.Waits)
+	assert.EqualValues(t, 100, s.Taken)
+}
+
+func TestWaitNCancelled(t *testing.T) {
+	b := New(10, 10)
+	require.True(t, b.AllowN(10))
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	assert.Equal(t, context.Canceled, b.WaitN(ctx, 10))
+	// The reservation was returned.
+	assert.True(t, b.Tokens() >= 0)
+	assert.EqualValues(t, 10, b.Stats().Taken)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.Error(t, b.WaitN(ctx, 10))
+}
+
+func TestSetRate(t *testing.T) {
+	b := New(0, 5)
+	require.True(t, b.AllowN(5))
+	assert.Error(t, b.WaitN(context.Background(), 1))
+	b.SetRate(Inf)
+	assert.True(t, b.AllowN(100))
+	b.SetRate(1e6)
+	b.SetBurst(1)
+	assert.NoError(t, b.WaitN(context.Background(), 1))
+	assert.EqualValues(t, 1e6, b.Rate())
+}
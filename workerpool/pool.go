@@ -0,0 +1,192 @@
+// Package workerpool runs funcs on a pool of goroutines that grows and
+// shrinks with demand.
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/missinggo/v2/recovery"
+)
+
+var ErrClosed = errors.New("pool is shut down")
+
+type Opts struct {
+	// Workers kept running even when idle.
+	MinWorkers int
+	// The most workers at once. Defaults to GOMAXPROCS.
+	MaxWorkers int
+	// Funcs that can be waiting for a worker before Submit blocks.
+	QueueSize int
+	// How long workers above MinWorkers wait for work before exiting.
+	// Defaults to 10s.
+	IdleTimeout time.Duration
+	// Called with the value recovered from a panicking func, and its stack.
+	// By default, panics go to recovery.Handle.
+	PanicHandler func(r interface{}, stack []byte)
+}
+
+type Pool struct {
+	opts  Opts
+	queue chan func()
+	// Held for reading while submitting, so that the queue can be closed
+	// safely.
+	closeMu sync.RWMutex
+	closed  bool
+
+	mu      sync.Mutex
+	workers int
+	idle    int
+	exited  sync.WaitGroup
+}
+
+func New(opts Opts) *Pool {
+	if opts.MaxWorkers <= 0 {
+		opts.MaxWorkers = runtime.GOMAXPROCS(0)
+	}
+	if opts.MinWorkers > opts.MaxWorkers {
+		opts.MinWorkers = opts.MaxWorkers
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = 10 * time.Second
+	}
+	if opts.PanicHandler == nil {
+		opts.PanicHandler = func(r interface{}, stack []byte) {
+			recovery.Handle(recovery.PanicError{Value: r, Stack: stack})
+		}
+	}
+	me := &Pool{
+		opts:  opts,
+		queue: make(chan func(), opts.QueueSize),
+	}
+	me.mu.Lock()
+	for me.workers < opts.MinWorkers {
+		me.startWorker()
+	}
+	me.mu.Unlock()
+	return me
+}
+
+func (me *Pool) startWorker() {
+	me.workers++
+	me.idle++
+	me.exited.Add(1)
+	go me.worker()
+}
+
+// Queues fn to be run by a worker, blocking while the queue is full. If ctx
+// is done first, its error is returned and fn won't be run.
+func (me *Pool) Submit(ctx context.Context, fn func()) error {
+	me.closeMu.RLock()
+	defer me.closeMu.RUnlock()
+	if me.closed {
+		return ErrClosed
+	}
+	select {
+	case me.queue <- fn:
+		// Add a worker if the queue is getting ahead of the idle ones.
+		me.mu.Lock()
+		if me.workers < me.opts.MaxWorkers && len(me.queue) > me.idle {
+			me.startWorker()
+		}
+		me.mu.Unlock()
+		return nil
+	default:
+	}
+	// No worker was ready and the queue is full.
+	me.mu.Lock()
+	if me.workers < me.opts.MaxWorkers {
+		me.startWorker()
+	}
+	me.mu.Unlock()
+	select {
+	case me.queue <- fn:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (me *Pool) worker() {
+	defer me.exited.Done()
+	for {
+		timer := time.NewTimer(me.opts.IdleTimeout)
+		select {
+		case fn, ok := <-me.queue:
+			timer.Stop()
+			if !ok {
+				me.mu.Lock()
+				me.workers--
+				me.idle--
+				me.mu.Unlock()
+				return
+			}
+			me.mu.Lock()
+			me.idle--
+			me.mu.Unlock()
+			me.run(fn)
+			me.mu.Lock()
+			me.idle++
+			me.mu.Unlock()
+		case <-timer.C:
+			me.mu.Lock()
+			// Submit doesn't add a worker for a func queued while this one
+			// still counts as idle, so it mustn't be left behind.
+			if me.workers > me.opts.MinWorkers && len(me.queue) == 0 {
+				me.workers--
+				me.idle--
+				me.mu.Unlock()
+				return
+			}
+			me.mu.Unlock()
+		}
+	}
+}
+
+func (me *Pool) run(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			me.opts.PanicHandler(r, debug.Stack())
+		}
+	}()
+	fn()
+}
+
+// Returns the number of running workers, and how many of those are idle.
+func (me *Pool) Workers() (workers, idle int) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.workers, me.idle
+}
+
+// Returns the number of funcs waiting for a worker.
+func (me *Pool) Queued() int {
+	return len(me.queue)
+}
+
+// Stops accepting funcs, and waits for those already queued to be run and
+// the workers to exit. If ctx is done first its error is returned, and the
+// remaining work continues in the background.
+func (me *Pool) Shutdown(ctx context.Context) error {
+	me.closeMu.Lock()
+	if !me.closed {
+		me.closed = true
+		close(me.queue)
+	}
+	me.closeMu.Unlock()
+	exited := make(chan struct{})
+	go func() {
+		me.exited.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,83 @@
+package workerpool
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolShutdownDrains(t *testing.T) {
+	p := New(Opts{MaxWorkers: 2, QueueSize: 10})
+	var ran int32
+	for i := 0; i < 10; i++ {
+		require.NoError(t, p.Submit(context.Background(), func() {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&ran, 1)
+		}))
+	}
+	require.NoError(t, p.Shutdown(context.Background()))
+	assert.EqualValues(t, 10, ran)
+	assert.Equal(t, ErrClosed, p.Submit(context.Background(), func() {}))
+	workers, _ := p.Workers()
+	assert.Equal(t, 0, workers)
+}
+
+func TestPoolGrowsAndShrinks(t *testing.T) {
+	p := New(Opts{
+		MinWorkers:  1,
+		MaxWorkers:  3,
+		IdleTimeout: 10 * time.Millisecond,
+	})
+	defer p.Shutdown(context.Background())
+	release := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.Submit(context.Background(), func() { <-release }))
+	}
+	workers, idle := p.Workers()
+	assert.Equal(t, 3, workers)
+	// All the workers are busy, and there's no queue.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, p.Submit(ctx, func() {}))
+	close(release)
+	for workers != 1 || idle != 1 {
+		time.Sleep(time.Millisecond)
+		workers, idle = p.Workers()
+	}
+}
+
+func TestPoolPanicHandler(t *testing.T) {
+	recovered := make(chan interface{}, 1)
+	p := New(Opts{
+		MaxWorkers: 1,
+		PanicHandler: func(r interface{}, stack []byte) {
+			assert.Contains(t, string(stack), "TestPoolPanicHandler")
+			recovered <- r
+		},
+	})
+	require.NoError(t, p.Submit(context.Background(), func() { panic("boom") }))
+	assert.Equal(t, "boom", <-recovered)
+	// The worker survives.
+	done := make(chan struct{})
+	require.NoError(t, p.Submit(context.Background(), func() { close(done) }))
+	<-done
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestPoolIdleWorkerExitRace(t *testing.T) {
+	p := New(Opts{MaxWorkers: 1, QueueSize: 1, IdleTimeout: time.Microsecond})
+	defer p.Shutdown(context.Background())
+	for i := 0; i < 1000; i++ {
+		ran := make(chan struct{})
+		require.NoError(t, p.Submit(context.Background(), func() { close(ran) }))
+		select {
+		case <-ran:
+		case <-time.After(time.Second):
+			t.Fatalf("func %d was left in the queue", i)
+		}
+	}
+}